@@ -0,0 +1,288 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// directory lists the endpoints of an ACME CA
+type directory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+type identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type order struct {
+	Status         string   `json:"status"`
+	Authorizations []string `json:"authorizations"`
+	Finalize       string   `json:"finalize"`
+	Certificate    string   `json:"certificate"`
+	Error          *problem `json:"error"`
+}
+
+type authorization struct {
+	Status     string      `json:"status"`
+	Identifier identifier  `json:"identifier"`
+	Challenges []challenge `json:"challenges"`
+}
+
+type challenge struct {
+	Type   string   `json:"type"`
+	URL    string   `json:"url"`
+	Token  string   `json:"token"`
+	Status string   `json:"status"`
+	Error  *problem `json:"error"`
+}
+
+// challengeError returns why the CA rejected the authorization, if it said
+func (a *authorization) challengeError() error {
+	for _, c := range a.Challenges {
+		if c.Error != nil {
+			return c.Error
+		}
+	}
+	return errors.New("no reason given")
+}
+
+// problem is an RFC 7807 error document returned by the CA
+type problem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status"`
+}
+
+func (p *problem) Error() string {
+	return fmt.Sprintf("acme: %s: %s", p.Type, p.Detail)
+}
+
+// session talks to the CA on behalf of one account, signing every request with its key (JWS, ES256)
+type session struct {
+	client     *http.Client
+	dir        directory
+	key        *ecdsa.PrivateKey
+	jwk        json.RawMessage
+	thumbprint string // RFC 7638 thumbprint of jwk, part of every key authorization
+	kid        string // account URL, used instead of jwk once registered
+	nonce      string
+}
+
+func newSession(ctx context.Context, client *http.Client, directoryURL string, key *ecdsa.PrivateKey) (*session, error) {
+	pub, err := key.PublicKey.ECDH()
+	if err != nil {
+		return nil, err
+	}
+	point := pub.Bytes() // 0x04 || x || y
+	// Members in lexical order without whitespace, as the thumbprint requires
+	jwk := fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`, b64(point[1:33]), b64(point[33:]))
+	sum := sha256.Sum256([]byte(jwk))
+	s := &session{client: client, key: key, jwk: json.RawMessage(jwk), thumbprint: b64(sum[:])}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, directoryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch ACME directory: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch ACME directory: status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&s.dir); err != nil {
+		return nil, fmt.Errorf("failed to decode ACME directory: %w", err)
+	}
+	return s, nil
+}
+
+// register creates the account, or looks up the existing one for the key, and remembers its URL
+func (s *session) register(ctx context.Context, email string) error {
+	account := map[string]any{"termsOfServiceAgreed": true}
+	if email != "" {
+		account["contact"] = []string{"mailto:" + email}
+	}
+	_, header, err := s.post(ctx, s.dir.NewAccount, account)
+	if err != nil {
+		return fmt.Errorf("failed to register ACME account: %w", err)
+	}
+	s.kid = header.Get("Location")
+	if s.kid == "" {
+		return errors.New("acme: account URL missing from the registration response")
+	}
+	return nil
+}
+
+func (s *session) newOrder(ctx context.Context, domains []string) (*order, string, error) {
+	identifiers := make([]identifier, len(domains))
+	for i, domain := range domains {
+		identifiers[i] = identifier{Type: "dns", Value: domain}
+	}
+	body, header, err := s.post(ctx, s.dir.NewOrder, map[string]any{"identifiers": identifiers})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create ACME order: %w", err)
+	}
+	var o order
+	if err := json.Unmarshal(body, &o); err != nil {
+		return nil, "", fmt.Errorf("failed to decode ACME order: %w", err)
+	}
+	return &o, header.Get("Location"), nil
+}
+
+// finalize submits the CSR, waits for the order to be issued and downloads the PEM certificate chain
+func (s *session) finalize(ctx context.Context, o *order, orderURL string, csr []byte, pollInterval time.Duration) ([]byte, error) {
+	body, _, err := s.post(ctx, o.Finalize, map[string]string{"csr": b64(csr)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to finalize ACME order: %w", err)
+	}
+	if err := json.Unmarshal(body, o); err != nil {
+		return nil, fmt.Errorf("failed to decode ACME order: %w", err)
+	}
+	for o.Status != "valid" {
+		switch o.Status {
+		case "pending", "ready", "processing":
+		default:
+			return nil, fmt.Errorf("acme: order is %s: %v", o.Status, o.Error)
+		}
+		if err := sleep(ctx, pollInterval); err != nil {
+			return nil, err
+		}
+		if err := s.fetch(ctx, orderURL, o); err != nil {
+			return nil, err
+		}
+	}
+	chain, _, err := s.post(ctx, o.Certificate, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download certificate: %w", err)
+	}
+	return chain, nil
+}
+
+// fetch reads a resource with a POST-as-GET request and decodes it into v
+func (s *session) fetch(ctx context.Context, url string, v any) error {
+	body, _, err := s.post(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// post sends payload (nil for POST-as-GET) to url, retrying the nonces the CA rejects as stale
+func (s *session) post(ctx context.Context, url string, payload any) ([]byte, http.Header, error) {
+	for attempt := 0; ; attempt++ {
+		body, header, err := s.postOnce(ctx, url, payload)
+		var p *problem
+		if errors.As(err, &p) && p.Type == "urn:ietf:params:acme:error:badNonce" && attempt < 2 {
+			continue
+		}
+		return body, header, err
+	}
+}
+
+func (s *session) postOnce(ctx context.Context, url string, payload any) ([]byte, http.Header, error) {
+	if s.nonce == "" {
+		if err := s.fetchNonce(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+	protected := map[string]any{"alg": "ES256", "nonce": s.nonce, "url": url}
+	if s.kid != "" {
+		protected["kid"] = s.kid
+	} else {
+		protected["jwk"] = s.jwk
+	}
+	s.nonce = ""
+	jws, err := s.sign(protected, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jws))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/jose+json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	s.nonce = resp.Header.Get("Replay-Nonce")
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode >= 400 {
+		p := &problem{Status: resp.StatusCode}
+		if json.Unmarshal(body, p) != nil || p.Type == "" {
+			p.Type, p.Detail = "status", fmt.Sprintf("%d %s", resp.StatusCode, bytes.TrimSpace(body))
+		}
+		return nil, nil, p
+	}
+	return body, resp.Header, nil
+}
+
+func (s *session) fetchNonce(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.dir.NewNonce, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to get ACME nonce: %w", err)
+	}
+	resp.Body.Close()
+	s.nonce = resp.Header.Get("Replay-Nonce")
+	if s.nonce == "" {
+		return errors.New("acme: CA returned no nonce")
+	}
+	return nil
+}
+
+// sign encodes a flattened JWS of payload (empty for nil) with an ES256 signature
+func (s *session) sign(protected map[string]any, payload any) ([]byte, error) {
+	header, err := json.Marshal(protected)
+	if err != nil {
+		return nil, err
+	}
+	var encodedPayload string
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		encodedPayload = b64(data)
+	}
+	signingInput := b64(header) + "." + encodedPayload
+	digest := sha256.Sum256([]byte(signingInput))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	signature := make([]byte, 64) // r || s, each left-padded to the curve size
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+	return json.Marshal(map[string]string{
+		"protected": b64(header),
+		"payload":   encodedPayload,
+		"signature": b64(signature),
+	})
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,344 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LetsEncryptURL is the directory of the Let's Encrypt production CA
+const LetsEncryptURL = "https://acme-v02.api.letsencrypt.org/directory"
+
+const (
+	challengePath  = "/.well-known/acme-challenge/"
+	renewBefore    = 30 * 24 * time.Hour // Let's Encrypt certificates last 90 days and suggest renewing at 60
+	checkInterval  = 12 * time.Hour
+	retryInterval  = 10 * time.Minute
+	obtainTimeout  = 5 * time.Minute
+	accountKeyFile = "account.key"
+	certFile       = "certificate.pem"
+	keyFile        = "certificate.key"
+)
+
+// Manager obtains and renews one certificate for a fixed list of domains from an ACME CA (RFC 8555), answering
+// the HTTP-01 challenges itself, and serves it to TLS handshakes for those domains
+// The account key and the certificate are kept in a cache directory so restarts don't ask the CA again
+type Manager struct {
+	directoryURL string
+	email        string
+	domains      []string
+	cacheDir     string
+	client       *http.Client
+	pollInterval time.Duration
+	logger       *slog.Logger
+
+	mu     sync.RWMutex
+	cert   *tls.Certificate
+	tokens map[string]string // HTTP-01 token -> key authorization
+}
+
+// New creates a Manager for domains using the CA at directoryURL (e.g. LetsEncryptURL), registering the
+// account with the contact email if given, and loads the cached certificate, if any, from cacheDir
+func New(directoryURL, email string, domains []string, cacheDir string, logger *slog.Logger) (*Manager, error) {
+	if len(domains) == 0 {
+		return nil, errors.New("acme: no domains configured")
+	}
+	if err := os.MkdirAll(cacheDir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create ACME cache directory: %w", err)
+	}
+	m := &Manager{
+		directoryURL: directoryURL,
+		email:        email,
+		domains:      make([]string, len(domains)),
+		cacheDir:     cacheDir,
+		client:       &http.Client{Timeout: 30 * time.Second},
+		pollInterval: 2 * time.Second,
+		logger:       logger,
+		tokens:       make(map[string]string),
+	}
+	for i, domain := range domains {
+		m.domains[i] = strings.ToLower(domain)
+	}
+
+	cert, err := tls.LoadX509KeyPair(filepath.Join(cacheDir, certFile), filepath.Join(cacheDir, keyFile))
+	switch {
+	case err == nil:
+		m.cert = &cert
+	case errors.Is(err, os.ErrNotExist):
+	default:
+		// A broken cache only costs a new certificate
+		m.logger.Warn("ignoring cached ACME certificate", slog.String("error", err.Error()))
+	}
+	return m, nil
+}
+
+// GetCertificate returns the certificate for the configured domains, for tls.Config.GetCertificate
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if hello.ServerName != "" && !m.allowed(hello.ServerName) {
+		return nil, fmt.Errorf("acme: host %q is not configured", hello.ServerName)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.cert == nil {
+		return nil, errors.New("acme: no certificate obtained yet")
+	}
+	return m.cert, nil
+}
+
+// HTTPHandler answers the HTTP-01 challenges of the CA and passes every other request to fallback
+// A nil fallback redirects to the same URL over HTTPS
+func (m *Manager) HTTPHandler(fallback http.Handler) http.Handler {
+	if fallback == nil {
+		fallback = http.HandlerFunc(redirectToHTTPS)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.URL.Path, challengePath)
+		if !ok {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+		m.mu.RLock()
+		keyAuth, ok := m.tokens[token]
+		m.mu.RUnlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(keyAuth))
+	})
+}
+
+// Run obtains the certificate if there is none and renews it before it expires, until ctx is canceled
+func (m *Manager) Run(ctx context.Context) {
+	for {
+		wait := checkInterval
+		if err := m.renewIfDue(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			m.logger.Warn("failed to obtain ACME certificate", slog.Any("domains", m.domains), slog.String("error", err.Error()))
+			wait = retryInterval
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// renewIfDue obtains a new certificate if the current one is missing, expires soon or lacks a domain
+func (m *Manager) renewIfDue(ctx context.Context) error {
+	m.mu.RLock()
+	cert := m.cert
+	m.mu.RUnlock()
+	if cert != nil && !m.due(cert.Leaf) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, obtainTimeout)
+	defer cancel()
+	cert, err := m.obtain(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.cert = cert
+	m.mu.Unlock()
+	m.logger.Info("obtained ACME certificate", slog.Any("domains", m.domains), slog.Time("not_after", cert.Leaf.NotAfter))
+	return nil
+}
+
+func (m *Manager) due(leaf *x509.Certificate) bool {
+	if leaf == nil || time.Until(leaf.NotAfter) < renewBefore {
+		return true
+	}
+	for _, domain := range m.domains {
+		if leaf.VerifyHostname(domain) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// obtain runs an order for the domains through to the issued certificate and caches it
+func (m *Manager) obtain(ctx context.Context) (*tls.Certificate, error) {
+	accountKey, err := m.accountKey()
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSession(ctx, m.client, m.directoryURL, accountKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.register(ctx, m.email); err != nil {
+		return nil, err
+	}
+	order, orderURL, err := s.newOrder(ctx, m.domains)
+	if err != nil {
+		return nil, err
+	}
+	for _, authzURL := range order.Authorizations {
+		if err := m.authorize(ctx, s, authzURL); err != nil {
+			return nil, err
+		}
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: m.domains[0]},
+		DNSNames: m.domains,
+	}, certKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate request: %w", err)
+	}
+	chainPEM, err := s.finalize(ctx, order, orderURL, csr, m.pollInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(certKey)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	cert, err := tls.X509KeyPair(chainPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("acme: CA returned an unusable certificate: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(m.cacheDir, keyFile), keyPEM, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to cache certificate: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(m.cacheDir, certFile), chainPEM, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to cache certificate: %w", err)
+	}
+	return &cert, nil
+}
+
+// authorize proves control of one domain with the HTTP-01 challenge and waits for the CA to check it
+func (m *Manager) authorize(ctx context.Context, s *session, authzURL string) error {
+	var authz authorization
+	if err := s.fetch(ctx, authzURL, &authz); err != nil {
+		return err
+	}
+	if authz.Status == "valid" {
+		return nil // still valid from an earlier order
+	}
+	var chal *challenge
+	for i := range authz.Challenges {
+		if authz.Challenges[i].Type == "http-01" {
+			chal = &authz.Challenges[i]
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("acme: no http-01 challenge offered for %s", authz.Identifier.Value)
+	}
+
+	m.mu.Lock()
+	m.tokens[chal.Token] = chal.Token + "." + s.thumbprint
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.tokens, chal.Token)
+		m.mu.Unlock()
+	}()
+
+	if _, _, err := s.post(ctx, chal.URL, struct{}{}); err != nil {
+		return err
+	}
+	for {
+		switch authz.Status {
+		case "valid":
+			return nil
+		case "pending", "processing":
+		default:
+			return fmt.Errorf("acme: authorization for %s is %s: %v", authz.Identifier.Value, authz.Status, authz.challengeError())
+		}
+		if err := sleep(ctx, m.pollInterval); err != nil {
+			return err
+		}
+		if err := s.fetch(ctx, authzURL, &authz); err != nil {
+			return err
+		}
+	}
+}
+
+// accountKey loads the account key from the cache, creating it on first use
+func (m *Manager) accountKey() (*ecdsa.PrivateKey, error) {
+	path := filepath.Join(m.cacheDir, accountKeyFile)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("acme: %s is not a PEM file", path)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read ACME account key: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to save ACME account key: %w", err)
+	}
+	return key, nil
+}
+
+func (m *Manager) allowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, domain := range m.domains {
+		if host == domain {
+			return true
+		}
+	}
+	return false
+}
+
+func redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
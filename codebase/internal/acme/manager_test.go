@@ -0,0 +1,259 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCA is a minimal ACME server: it verifies the JWS signatures, checks the HTTP-01 answers through the
+// manager's challenge handler and issues self-signed certificates for the CSR
+type fakeCA struct {
+	t       *testing.T
+	server  *httptest.Server
+	manager *Manager
+
+	mu          sync.Mutex
+	accountKey  *ecdsa.PublicKey
+	nonces      int
+	domains     []string
+	validated   map[int]bool
+	certPEM     []byte
+	orders      int
+	staleNonces int // how many of the next requests get a badNonce error
+}
+
+func newFakeCA(t *testing.T) *fakeCA {
+	ca := &fakeCA{t: t, validated: map[int]bool{}}
+	ca.server = httptest.NewServer(http.HandlerFunc(ca.serve))
+	t.Cleanup(ca.server.Close)
+	return ca
+}
+
+func (ca *fakeCA) serve(w http.ResponseWriter, r *http.Request) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	base := ca.server.URL
+	ca.nonces++
+	w.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", ca.nonces))
+
+	if r.URL.Path == "/directory" {
+		json.NewEncoder(w).Encode(directory{NewNonce: base + "/nonce", NewAccount: base + "/account", NewOrder: base + "/order"})
+		return
+	}
+	if r.URL.Path == "/nonce" {
+		return
+	}
+
+	payload := ca.verify(r)
+	if ca.staleNonces > 0 {
+		ca.staleNonces--
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(problem{Type: "urn:ietf:params:acme:error:badNonce", Detail: "stale"})
+		return
+	}
+	switch {
+	case r.URL.Path == "/account":
+		w.Header().Set("Location", base+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"status":"valid"}`))
+	case r.URL.Path == "/order":
+		ca.orders++
+		var req struct{ Identifiers []identifier }
+		json.Unmarshal(payload, &req)
+		ca.domains = nil
+		var authzs []string
+		for i, id := range req.Identifiers {
+			ca.domains = append(ca.domains, id.Value)
+			authzs = append(authzs, fmt.Sprintf("%s/authz/%d", base, i))
+		}
+		w.Header().Set("Location", base+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(order{Status: "pending", Authorizations: authzs, Finalize: base + "/finalize"})
+	case strings.HasPrefix(r.URL.Path, "/authz/"):
+		var i int
+		fmt.Sscanf(r.URL.Path, "/authz/%d", &i)
+		status := "pending"
+		if ca.validated[i] {
+			status = "valid"
+		}
+		json.NewEncoder(w).Encode(authorization{
+			Status:     status,
+			Identifier: identifier{Type: "dns", Value: ca.domains[i]},
+			Challenges: []challenge{
+				{Type: "dns-01", URL: fmt.Sprintf("%s/dns/%d", base, i), Token: "unused"},
+				{Type: "http-01", URL: fmt.Sprintf("%s/chal/%d", base, i), Token: fmt.Sprintf("token-%d", i)},
+			},
+		})
+	case strings.HasPrefix(r.URL.Path, "/chal/"):
+		var i int
+		fmt.Sscanf(r.URL.Path, "/chal/%d", &i)
+		token := fmt.Sprintf("token-%d", i)
+		rec := httptest.NewRecorder()
+		ca.manager.HTTPHandler(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://"+ca.domains[i]+challengePath+token, nil))
+		if want := token + "." + ca.thumbprint(); rec.Body.String() != want {
+			ca.t.Errorf("Expected key authorization %q, got %q", want, rec.Body.String())
+		}
+		ca.validated[i] = true
+		w.Write([]byte(`{"status":"processing"}`))
+	case r.URL.Path == "/finalize":
+		var req struct{ CSR string }
+		json.Unmarshal(payload, &req)
+		der, _ := base64.RawURLEncoding.DecodeString(req.CSR)
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil {
+			ca.t.Errorf("Expected a valid CSR, got %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ca.certPEM = issue(ca.t, csr)
+		json.NewEncoder(w).Encode(order{Status: "processing", Finalize: base + "/finalize"})
+	case r.URL.Path == "/order/1":
+		json.NewEncoder(w).Encode(order{Status: "valid", Certificate: base + "/cert"})
+	case r.URL.Path == "/cert":
+		w.Write(ca.certPEM)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// verify checks the JWS of a request and returns its payload
+func (ca *fakeCA) verify(r *http.Request) []byte {
+	var jws struct{ Protected, Payload, Signature string }
+	json.NewDecoder(r.Body).Decode(&jws)
+	headerJSON, _ := base64.RawURLEncoding.DecodeString(jws.Protected)
+	var header struct {
+		URL string
+		Kid string
+		JWK *struct{ X, Y string }
+	}
+	json.Unmarshal(headerJSON, &header)
+	if header.URL != ca.server.URL+r.URL.Path {
+		ca.t.Errorf("Expected the url header to match %s, got %s", r.URL.Path, header.URL)
+	}
+	if header.JWK != nil {
+		x, _ := base64.RawURLEncoding.DecodeString(header.JWK.X)
+		y, _ := base64.RawURLEncoding.DecodeString(header.JWK.Y)
+		ca.accountKey = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	} else if header.Kid != ca.server.URL+"/account/1" {
+		ca.t.Errorf("Expected the account URL as kid, got %q", header.Kid)
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(jws.Signature)
+	digest := sha256.Sum256([]byte(jws.Protected + "." + jws.Payload))
+	if len(sig) != 64 || !ecdsa.Verify(ca.accountKey, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		ca.t.Errorf("Expected a valid signature on %s", r.URL.Path)
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(jws.Payload)
+	return payload
+}
+
+func (ca *fakeCA) thumbprint() string {
+	pub, _ := ca.accountKey.ECDH()
+	point := pub.Bytes()
+	jwk := fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`, b64(point[1:33]), b64(point[33:]))
+	sum := sha256.Sum256([]byte(jwk))
+	return b64(sum[:])
+}
+
+func issue(t *testing.T, csr *x509.CertificateRequest) []byte {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      csr.Subject,
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, csr.PublicKey, caKey)
+	if err != nil {
+		t.Error(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestManager_ObtainsAndCachesCertificate(t *testing.T) {
+	ca := newFakeCA(t)
+	cacheDir := t.TempDir()
+	domains := []string{"weather.example.com", "api.example.com"}
+	m, err := New(ca.server.URL+"/directory", "ops@example.com", domains, cacheDir, slog.Default())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	m.pollInterval = time.Millisecond
+	ca.manager = m
+	ca.staleNonces = 1
+
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "weather.example.com"}); err == nil {
+		t.Error("Expected no certificate before the first order")
+	}
+	if err := m.renewIfDue(context.Background()); err != nil {
+		t.Fatalf("Expected the order to succeed, got %v", err)
+	}
+	cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "API.example.com"})
+	if err != nil {
+		t.Fatalf("Expected a certificate, got %v", err)
+	}
+	if err := cert.Leaf.VerifyHostname("weather.example.com"); err != nil {
+		t.Errorf("Expected the certificate to cover every domain, got %v", err)
+	}
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.example.com"}); err == nil {
+		t.Error("Expected hosts outside the allowlist to be refused")
+	}
+
+	// A restart serves the cached certificate without a new order
+	restarted, err := New(ca.server.URL+"/directory", "ops@example.com", domains, cacheDir, slog.Default())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := restarted.renewIfDue(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ca.orders != 1 {
+		t.Errorf("Expected the cached certificate to be reused, got %d orders", ca.orders)
+	}
+
+	// A new domain needs a new certificate
+	added, _ := New(ca.server.URL+"/directory", "", append(domains, "new.example.com"), cacheDir, slog.Default())
+	added.pollInterval = time.Millisecond
+	ca.manager = added
+	if err := added.renewIfDue(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ca.orders != 2 {
+		t.Errorf("Expected a new order for the added domain, got %d orders", ca.orders)
+	}
+}
+
+func TestManager_HTTPHandlerRedirectsOtherRequests(t *testing.T) {
+	m, err := New("https://ca.invalid/directory", "", []string{"weather.example.com"}, t.TempDir(), slog.Default())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	rec := httptest.NewRecorder()
+	m.HTTPHandler(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://weather.example.com:80/weather?lat=1&lon=2", nil))
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "https://weather.example.com/weather?lat=1&lon=2" {
+		t.Errorf("Expected a redirect to HTTPS, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	m.HTTPHandler(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://weather.example.com"+challengePath+"unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown token, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,214 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/route"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// bucketWidth is the resolution of the store: windows are rounded up to whole buckets
+const bucketWidth = 5 * time.Minute
+
+// maxLocationsPerBucket bounds a bucket so random coordinates can't grow it without limit;
+// requests for further locations still count towards the totals
+const maxLocationsPerBucket = 10000
+
+type location struct {
+	lat, lon      float64
+	city, country string
+	count         int64
+}
+
+// bucket holds the counts of one bucketWidth slice of time
+type bucket struct {
+	start     time.Time
+	requests  int64
+	endpoints map[string]int64
+	locations map[string]*location
+}
+
+// Store aggregates request counts per endpoint and per location in time buckets, keeping
+// retention worth of history so the most requested locations and the request rate can be
+// reported over any window up to that long
+type Store struct {
+	mu      sync.Mutex
+	buckets []*bucket // ring indexed by bucket number modulo its length
+	started time.Time
+	now     func() time.Time
+}
+
+// New creates a Store keeping retentionHours of history
+// A non-positive retentionHours falls back to 24
+func New(retentionHours int) *Store {
+	if retentionHours <= 0 {
+		retentionHours = 24
+	}
+	return &Store{
+		buckets: make([]*bucket, int(time.Duration(retentionHours)*time.Hour/bucketWidth)),
+		started: time.Now(),
+		now:     time.Now,
+	}
+}
+
+// Retention returns how far back the store can report
+func (s *Store) Retention() time.Duration {
+	return time.Duration(len(s.buckets)) * bucketWidth
+}
+
+// current returns the bucket for now, recycling the slot of an expired one; s.mu must be held
+func (s *Store) current() *bucket {
+	start := s.now().Truncate(bucketWidth)
+	slot := int(start.UnixNano()/int64(bucketWidth)) % len(s.buckets)
+	b := s.buckets[slot]
+	if b == nil || !b.start.Equal(start) {
+		b = &bucket{start: start, endpoints: make(map[string]int64), locations: make(map[string]*location)}
+		s.buckets[slot] = b
+	}
+	return b
+}
+
+// RecordRequest counts one request served by endpoint
+func (s *Store) RecordRequest(endpoint string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.current()
+	b.requests++
+	b.endpoints[endpoint]++
+}
+
+// RecordLocation counts one lookup of the coordinates; city and country are what the provider
+// resolved them to and may be empty
+func (s *Store) RecordLocation(lat, lon float64, city, country string) {
+	key := strconv.FormatFloat(lat, 'f', 4, 64) + "," + strconv.FormatFloat(lon, 'f', 4, 64)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.current()
+	if loc, ok := b.locations[key]; ok {
+		loc.count++
+		if city != "" {
+			loc.city, loc.country = city, country
+		}
+		return
+	}
+	if len(b.locations) >= maxLocationsPerBucket {
+		return
+	}
+	b.locations[key] = &location{lat: lat, lon: lon, city: city, country: country, count: 1}
+}
+
+// EndpointCount is the number of requests one endpoint served
+type EndpointCount struct {
+	Endpoint string `json:"endpoint"`
+	Requests int64  `json:"requests"`
+}
+
+// LocationCount is the number of lookups of one location
+type LocationCount struct {
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
+	City     string  `json:"city,omitempty"`
+	Country  string  `json:"country,omitempty"`
+	Requests int64   `json:"requests"`
+}
+
+// Report summarizes the traffic of a window
+type Report struct {
+	Window    string          `json:"window"`
+	Since     time.Time       `json:"since"`
+	Requests  int64           `json:"requests"`
+	QPS       float64         `json:"qps"`
+	Endpoints []EndpointCount `json:"endpoints"`
+	Locations []LocationCount `json:"locations"`
+}
+
+// Top reports the total and per-endpoint request counts of the last window, plus the n most
+// requested locations
+// The request rate is averaged over the part of the window the store has been running for
+func (s *Store) Top(window time.Duration, n int) (Report, error) {
+	if window <= 0 || window > s.Retention() {
+		return Report{}, fmt.Errorf("window must be between %s and %s", bucketWidth, s.Retention())
+	}
+
+	s.mu.Lock()
+	now := s.now()
+	buckets := (window + bucketWidth - 1) / bucketWidth // the current, partial bucket counts as one
+	since := now.Truncate(bucketWidth).Add(-(buckets - 1) * bucketWidth)
+	report := Report{Window: window.String(), Since: since, Endpoints: []EndpointCount{}, Locations: []LocationCount{}}
+	endpoints := make(map[string]int64)
+	locations := make(map[string]*LocationCount)
+	for _, b := range s.buckets {
+		if b == nil || b.start.Before(since) {
+			continue
+		}
+		report.Requests += b.requests
+		for endpoint, count := range b.endpoints {
+			endpoints[endpoint] += count
+		}
+		for key, loc := range b.locations {
+			total, ok := locations[key]
+			if !ok {
+				total = &LocationCount{Lat: loc.lat, Lon: loc.lon}
+				locations[key] = total
+			}
+			total.Requests += loc.count
+			if loc.city != "" {
+				total.City, total.Country = loc.city, loc.country
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	from := since
+	if s.started.After(from) {
+		from = s.started
+	}
+	if elapsed := now.Sub(from).Seconds(); elapsed > 0 {
+		report.QPS = float64(report.Requests) / elapsed
+	}
+	for endpoint, count := range endpoints {
+		report.Endpoints = append(report.Endpoints, EndpointCount{Endpoint: endpoint, Requests: count})
+	}
+	sort.Slice(report.Endpoints, func(i, j int) bool { return report.Endpoints[i].Requests > report.Endpoints[j].Requests })
+	for _, loc := range locations {
+		report.Locations = append(report.Locations, *loc)
+	}
+	sort.Slice(report.Locations, func(i, j int) bool { return report.Locations[i].Requests > report.Locations[j].Requests })
+	if len(report.Locations) > n {
+		report.Locations = report.Locations[:n]
+	}
+	return report, nil
+}
+
+// storeKey is the context key carrying the Store of the current request
+type storeKey struct{}
+
+// RecordLocation counts a lookup of the coordinates in the Store of ctx, if any
+func RecordLocation(ctx context.Context, lat, lon float64, city, country string) {
+	if s, ok := ctx.Value(storeKey{}).(*Store); ok {
+		s.RecordLocation(lat, lon, city, country)
+	}
+}
+
+// Middleware counts every request by endpoint in s and lets handlers record looked up locations
+// with RecordLocation
+// The endpoint is the mux pattern that matched (see route.Track)
+func Middleware(s *Store, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner := r.WithContext(context.WithValue(r.Context(), storeKey{}, s))
+		next.ServeHTTP(w, inner)
+
+		endpoint := route.Pattern(inner)
+		if endpoint == "" {
+			endpoint = "unmatched"
+		}
+		s.RecordRequest(endpoint)
+	})
+}
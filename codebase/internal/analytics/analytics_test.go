@@ -0,0 +1,76 @@
+package analytics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStore_Top(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := New(24)
+	s.started = now.Add(-48 * time.Hour)
+	s.now = func() time.Time { return now }
+
+	// Two hours ago: outside a 1h window
+	now = now.Add(-2 * time.Hour)
+	s.RecordLocation(48.85, 2.35, "Paris", "FR")
+	s.RecordRequest("/weather")
+	now = now.Add(2 * time.Hour)
+
+	for range 3 {
+		s.RecordLocation(51.5074, -0.1278, "London", "GB")
+		s.RecordRequest("/weather")
+	}
+	s.RecordLocation(40.71, -74.0, "", "")
+	s.RecordRequest("/weather")
+	s.RecordRequest("/health")
+
+	report, err := s.Top(time.Hour, 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Requests != 5 {
+		t.Errorf("Expected 5 requests in the window, got %d", report.Requests)
+	}
+	if len(report.Locations) != 2 || report.Locations[0].City != "London" || report.Locations[0].Requests != 3 {
+		t.Errorf("Expected London first with 3 requests, got %+v", report.Locations)
+	}
+	if len(report.Endpoints) != 2 || report.Endpoints[0].Endpoint != "/weather" || report.Endpoints[0].Requests != 4 {
+		t.Errorf("Expected /weather first with 4 requests, got %+v", report.Endpoints)
+	}
+
+	report, _ = s.Top(24*time.Hour, 1)
+	if report.Requests != 6 || len(report.Locations) != 1 {
+		t.Errorf("Expected 6 requests and a single location over 24h, got %d and %+v", report.Requests, report.Locations)
+	}
+
+	if _, err := s.Top(48*time.Hour, 10); err == nil {
+		t.Error("Expected a window beyond the retention to be rejected")
+	}
+}
+
+func TestMiddleware_CountsMuxPattern(t *testing.T) {
+	s := New(1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/weather", func(w http.ResponseWriter, r *http.Request) {
+		RecordLocation(r.Context(), 1, 2, "Somewhere", "XX")
+	})
+	handler := Middleware(s, mux)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/weather?lat=1&lon=2", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/nope", nil))
+
+	report, _ := s.Top(time.Hour, 10)
+	counts := map[string]int64{}
+	for _, e := range report.Endpoints {
+		counts[e.Endpoint] = e.Requests
+	}
+	if counts["/weather"] != 1 || counts["unmatched"] != 1 {
+		t.Errorf("Expected one /weather and one unmatched request, got %v", counts)
+	}
+	if len(report.Locations) != 1 || report.Locations[0].City != "Somewhere" {
+		t.Errorf("Expected the handler's location to be recorded, got %+v", report.Locations)
+	}
+}
@@ -0,0 +1,48 @@
+package apidocs
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+)
+
+// spec is the OpenAPI 3 description of the API; keep it in step with the routes when adding or changing them
+//
+//go:embed openapi.json
+var spec []byte
+
+// Spec serves the OpenAPI specification, for client generators and API explorers
+func Spec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Write(spec)
+}
+
+// swaggerUIVersion pins the Swagger UI release loaded by the docs page
+const swaggerUIVersion = "5.17.14"
+
+var swaggerUIPage = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Weather API Server</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@{{.Version}}/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@{{.Version}}/swagger-ui-bundle.js" crossorigin></script>
+<script>
+window.onload = () => { window.ui = SwaggerUIBundle({ url: {{.SpecURL}}, dom_id: "#swagger-ui" }); };
+</script>
+</body>
+</html>
+`))
+
+// SwaggerUI returns a handler serving Swagger UI for the specification at specURL
+// The UI assets are loaded from a CDN by the browser, so the binary stays free of them
+func SwaggerUI(specURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		swaggerUIPage.Execute(w, struct{ Version, SpecURL string }{swaggerUIVersion, specURL})
+	}
+}
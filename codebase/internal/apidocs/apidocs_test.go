@@ -0,0 +1,72 @@
+package apidocs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSpec_IsValidOpenAPI(t *testing.T) {
+	w := httptest.NewRecorder()
+	Spec(w, httptest.NewRequest("GET", "/v1/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if version, _ := doc["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		t.Errorf("Expected an OpenAPI 3 document, got %q", version)
+	}
+	paths, _ := doc["paths"].(map[string]any)
+	for _, path := range []string{"/v1/weather", "/v1/weather/batch", "/health", "/livez", "/readyz", "/v1/version", "/metrics", "/v1/openapi.json"} {
+		if _, ok := paths[path]; !ok {
+			t.Errorf("Expected %s to be documented", path)
+		}
+	}
+}
+
+func TestSpec_RefsResolve(t *testing.T) {
+	var doc map[string]any
+	json.Unmarshal(spec, &doc)
+
+	var walk func(node any)
+	walk = func(node any) {
+		switch node := node.(type) {
+		case map[string]any:
+			if ref, ok := node["$ref"].(string); ok {
+				target := any(doc)
+				for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					object, _ := target.(map[string]any)
+					target = object[part]
+				}
+				if target == nil {
+					t.Errorf("Expected %s to resolve", ref)
+				}
+			}
+			for _, child := range node {
+				walk(child)
+			}
+		case []any:
+			for _, child := range node {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+}
+
+func TestSwaggerUI_LoadsSpec(t *testing.T) {
+	w := httptest.NewRecorder()
+	SwaggerUI("/v1/openapi.json")(w, httptest.NewRequest("GET", "/v1/docs", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"/v1/openapi.json"`) {
+		t.Errorf("Expected the page to load the spec, got %s", w.Body)
+	}
+}
@@ -0,0 +1,155 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/krizvi/weather-app-server/internal/clientip"
+	"github.com/krizvi/weather-app-server/internal/requestid"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is one audit log entry, written as a JSON line
+type Record struct {
+	Time          time.Time         `json:"time"`
+	RequestID     string            `json:"request_id,omitempty"`
+	Caller        string            `json:"caller"` // "anonymous" unless an authenticated identity was set
+	ClientIP      string            `json:"client_ip"`
+	UserAgent     string            `json:"user_agent,omitempty"`
+	Method        string            `json:"method"`
+	Path          string            `json:"path"`
+	Params        map[string]string `json:"params,omitempty"` // query parameters, credentials redacted
+	Status        int               `json:"status"`
+	DurationMs    int64             `json:"duration_ms"`
+	UpstreamCalls int               `json:"upstream_calls"` // provider calls made for the request, i.e. what it cost us
+}
+
+// Log writes audit records to w, e.g. a RotatingFile
+type Log struct {
+	mu     sync.Mutex
+	w      io.Writer
+	logger *slog.Logger
+}
+
+// New creates a Log writing to w; failing writes are reported to logger
+func New(w io.Writer, logger *slog.Logger) *Log {
+	return &Log{w: w, logger: logger}
+}
+
+// write appends one record; a failing write is logged but never fails the request
+func (l *Log) write(record *Record) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		l.logger.Error("audit record encoding failed", slog.String("error", err.Error()))
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(append(line, '\n')); err != nil {
+		l.logger.Error("audit log write failed", slog.String("error", err.Error()))
+	}
+}
+
+// requestAudit is the part of a record filled in while the request is served
+type requestAudit struct {
+	mu            sync.Mutex
+	caller        string
+	upstreamCalls int
+}
+
+type auditKey struct{}
+
+// SetCaller records the authenticated identity behind the request ctx belongs to, e.g. "admin"
+// It does nothing for requests that aren't audited
+func SetCaller(ctx context.Context, caller string) {
+	if ra, _ := ctx.Value(auditKey{}).(*requestAudit); ra != nil {
+		ra.mu.Lock()
+		ra.caller = caller
+		ra.mu.Unlock()
+	}
+}
+
+// CountUpstreamCall records one provider call made for the request ctx belongs to
+// It does nothing for requests that aren't audited (including background refreshes)
+func CountUpstreamCall(ctx context.Context) {
+	if ra, _ := ctx.Value(auditKey{}).(*requestAudit); ra != nil {
+		ra.mu.Lock()
+		ra.upstreamCalls++
+		ra.mu.Unlock()
+	}
+}
+
+// sensitiveParams are query parameters that never make it into the audit log
+var sensitiveParams = map[string]bool{"appid": true, "api_key": true, "apikey": true, "token": true, "password": true}
+
+// auditStatusWriter remembers the response status for the record
+type auditStatusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *auditStatusWriter) WriteHeader(statusCode int) {
+	if sw.status == 0 {
+		sw.status = statusCode
+	}
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sw *auditStatusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (e.g. to flush)
+func (sw *auditStatusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// Middleware writes an audit record for every request once it has been served
+// It must sit outside Recover so requests that panicked are recorded with their 500
+func Middleware(log *Log, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ra := &requestAudit{caller: "anonymous"}
+		sw := &auditStatusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), auditKey{}, ra)))
+
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		var params map[string]string
+		for name, values := range r.URL.Query() {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			if sensitiveParams[strings.ToLower(name)] {
+				params[name] = "[Filtered]"
+				continue
+			}
+			params[name] = strings.Join(values, ",")
+		}
+
+		ra.mu.Lock()
+		record := &Record{
+			Time:          start.UTC(),
+			RequestID:     requestid.FromContext(r.Context()),
+			Caller:        ra.caller,
+			ClientIP:      clientip.FromRequest(r),
+			UserAgent:     r.UserAgent(),
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			Params:        params,
+			Status:        sw.status,
+			DurationMs:    time.Since(start).Milliseconds(),
+			UpstreamCalls: ra.upstreamCalls,
+		}
+		ra.mu.Unlock()
+		log.write(record)
+	})
+}
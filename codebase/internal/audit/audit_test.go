@@ -0,0 +1,41 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware_RecordsRequest(t *testing.T) {
+	var buf bytes.Buffer
+	handler := Middleware(New(&buf, slog.Default()), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetCaller(r.Context(), "admin")
+		CountUpstreamCall(r.Context())
+		CountUpstreamCall(r.Context())
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	req := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0&appid=secret", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record Record
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON record, got %q", buf.String())
+	}
+	if record.Caller != "admin" || record.ClientIP != "203.0.113.7" || record.Status != 502 || record.UpstreamCalls != 2 {
+		t.Errorf("Expected caller, client IP, status and upstream calls, got %+v", record)
+	}
+	if record.Params["lat"] != "40.7" || record.Params["appid"] != "[Filtered]" {
+		t.Errorf("Expected params with the API key filtered, got %v", record.Params)
+	}
+}
+
+func TestCountUpstreamCall_WithoutMiddleware(t *testing.T) {
+	// Background refreshes aren't audited; must not panic
+	CountUpstreamCall(httptest.NewRequest("GET", "/", nil).Context())
+	SetCaller(httptest.NewRequest("GET", "/", nil).Context(), "admin")
+}
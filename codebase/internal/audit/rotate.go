@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RotatingFile is an append-only file that is rotated once it grows past a size limit or gets older
+// than a maximum age; rotated files get a timestamp suffix and only the newest maxBackups are kept
+type RotatingFile struct {
+	path       string
+	maxBytes   int64         // 0 for no size limit
+	maxAge     time.Duration // 0 for no age limit
+	maxBackups int           // 0 keeps every rotated file
+	now        func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// NewRotatingFile opens (or creates) the file at path, rotating it after maxSizeMB megabytes or
+// maxAgeHours hours, whichever comes first, and keeping maxBackups rotated files (0 disables each limit)
+func NewRotatingFile(path string, maxSizeMB, maxAgeHours, maxBackups int) (*RotatingFile, error) {
+	rf := &RotatingFile{
+		path:       path,
+		maxBytes:   int64(maxSizeMB) << 20,
+		maxAge:     time.Duration(maxAgeHours) * time.Hour,
+		maxBackups: maxBackups,
+		now:        time.Now,
+	}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+// open opens the current file for appending; caller must hold mu (or be the constructor)
+func (rf *RotatingFile) open() error {
+	file, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	rf.file = file
+	rf.size = info.Size()
+	// An existing file keeps its age across restarts
+	rf.opened = rf.now()
+	if rf.size > 0 {
+		rf.opened = info.ModTime()
+	}
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past its size limit or the file is too old
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.file == nil {
+		return 0, os.ErrClosed
+	}
+	tooBig := rf.maxBytes > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxBytes
+	tooOld := rf.maxAge > 0 && rf.size > 0 && rf.now().Sub(rf.opened) >= rf.maxAge
+	if tooBig || tooOld {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate moves the current file aside and starts a new one; caller must hold mu
+func (rf *RotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	rf.file = nil
+	rotated := rf.path + "." + rf.now().UTC().Format("20060102T150405.000")
+	if err := os.Rename(rf.path, rotated); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	if err := rf.open(); err != nil {
+		return err
+	}
+	return rf.prune()
+}
+
+// prune deletes the oldest rotated files beyond maxBackups
+func (rf *RotatingFile) prune() error {
+	if rf.maxBackups <= 0 {
+		return nil
+	}
+	backups, err := filepath.Glob(rf.path + ".*")
+	if err != nil {
+		return err
+	}
+	// The timestamp suffix sorts chronologically
+	sort.Strings(backups)
+	for len(backups) > rf.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return fmt.Errorf("failed to remove old audit log: %w", err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// Close closes the current file
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
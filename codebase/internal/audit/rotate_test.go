@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile_RotatesBySizeAndPrunes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	rf, err := NewRotatingFile(path, 0, 0, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer rf.Close()
+	rf.maxBytes = 10
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rf.now = func() time.Time { now = now.Add(time.Second); return now }
+
+	for i := 0; i < 5; i++ {
+		if _, err := rf.Write([]byte("12345678\n")); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 2 {
+		t.Errorf("Expected 2 rotated files to be kept, got %v", backups)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "12345678\n" {
+		t.Errorf("Expected only the last line in the current file, got %q", content)
+	}
+}
+
+func TestRotatingFile_RotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	rf, err := NewRotatingFile(path, 0, 1, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer rf.Close()
+	now := time.Now()
+	rf.now = func() time.Time { return now }
+
+	rf.Write([]byte("first\n"))
+	rf.Write([]byte("second\n"))
+	now = now.Add(2 * time.Hour)
+	rf.Write([]byte("third\n"))
+
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 1 {
+		t.Fatalf("Expected 1 rotated file, got %v", backups)
+	}
+	if content, _ := os.ReadFile(backups[0]); string(content) != "first\nsecond\n" {
+		t.Errorf("Expected the old lines in the rotated file, got %q", content)
+	}
+}
@@ -0,0 +1,45 @@
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X github.com/krizvi/weather-app-server/internal/buildinfo.Version=v1.4.0 \
+//	  -X github.com/krizvi/weather-app-server/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/krizvi/weather-app-server/internal/buildinfo.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// (the Makefile does this)
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build metadata, falling back to the VCS details the Go toolchain embeds
+// (when building from a git checkout) for anything not set through ldflags
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildDate: BuildDate, GoVersion: runtime.Version()}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				// Commit time rather than build time, but close enough to tell builds apart
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+	return info
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrPrefixFlushUnsupported is returned by Flush for a non-empty prefix on backends that can't list keys
+var ErrPrefixFlushUnsupported = errors.New("cache backend cannot flush by key prefix")
+
+// Entry is a cached value together with its freshness metadata
+type Entry struct {
+	Value     []byte    // serialized payload
+	StoredAt  time.Time // when the value was fetched from upstream
+	ExpiresAt time.Time // after this point the value is stale but may still be served
+}
+
+// IsFresh reports whether the entry has not yet reached its expiry time
+func (e *Entry) IsFresh(now time.Time) bool {
+	return now.Before(e.ExpiresAt)
+}
+
+// Stats is a point-in-time snapshot of cache usage
+type Stats struct {
+	Backend     string  `json:"backend"`
+	Entries     int     `json:"entries"`
+	Hits        uint64  `json:"hits"`
+	Misses      uint64  `json:"misses"`
+	HitRate     float64 `json:"hit_rate"`
+	Evictions   uint64  `json:"evictions"`    // entries dropped for capacity or after their retention elapsed
+	MemoryBytes int64   `json:"memory_bytes"` // approximate size of keys and values
+}
+
+// hitRate returns hits / (hits + misses), or 0 before the first lookup
+func hitRate(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// Cache defines the interface for cache backends
+// Backends keep entries for the given retention period, which is usually longer than the
+// freshness window so expired entries can still be served stale while being refreshed
+type Cache interface {
+	Get(ctx context.Context, key string) (*Entry, bool, error)
+	Set(ctx context.Context, key string, entry *Entry, retention time.Duration) error
+	// Delete removes the entry stored under key, reporting whether there was one
+	Delete(ctx context.Context, key string) (bool, error)
+	// Flush removes all entries whose key starts with prefix (everything if prefix is empty)
+	// and returns how many were removed
+	Flush(ctx context.Context, prefix string) (int, error)
+	Stats(ctx context.Context) (Stats, error)
+}
@@ -0,0 +1,311 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// diskFileSuffix marks cache files so unrelated files in the directory are left alone
+const diskFileSuffix = ".cache.json"
+
+// diskLowWater is the share of its limits a sweep evicts a cache that went over them down to, so a full
+// cache isn't swept again on every write
+const diskLowWater = 0.9
+
+// diskRecord is the on-disk representation of an entry
+// The retention deadline is persisted next to the entry so it is still enforced after a restart
+type diskRecord struct {
+	Key       string    `json:"key"`
+	Value     []byte    `json:"value"`
+	StoredAt  time.Time `json:"stored_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	DeleteAt  time.Time `json:"delete_at"`
+}
+
+// DiskCache is a file-backed Cache implementation that survives restarts
+// Each entry lives in its own file named after the hash of its key. Entries nobody asks for again are
+// removed by the sweeps of Run, which also evict the oldest entries once the cache is over its limits.
+type DiskCache struct {
+	dir           string
+	maxEntries    int   // 0 means unbounded
+	maxBytes      int64 // 0 means unbounded
+	sweepInterval time.Duration
+	sweeping      sync.Mutex
+	entries       atomic.Int64 // as of the last sweep plus the writes since, so an overestimate
+	bytes         atomic.Int64
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	evictions     atomic.Uint64
+	now           func() time.Time
+}
+
+// NewDisk creates a DiskCache storing entries in dir, creating the directory if needed
+// It holds at most maxEntries entries and maxBytes bytes of files (0 for no limit) and is swept every
+// sweepIntervalSec seconds by Run; a non-positive sweepIntervalSec falls back to 300 seconds
+func NewDisk(dir string, maxEntries int, maxBytes int64, sweepIntervalSec int) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if sweepIntervalSec <= 0 {
+		sweepIntervalSec = 300
+	}
+	return &DiskCache{
+		dir:           dir,
+		maxEntries:    maxEntries,
+		maxBytes:      maxBytes,
+		sweepInterval: time.Duration(sweepIntervalSec) * time.Second,
+		now:           time.Now,
+	}, nil
+}
+
+// Get returns the entry stored under key if it is still within its retention period
+func (dc *DiskCache) Get(_ context.Context, key string) (*Entry, bool, error) {
+	path := dc.path(key)
+
+	record, err := readDiskRecord(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		dc.misses.Add(1)
+		return nil, false, nil
+	}
+	if err != nil {
+		dc.misses.Add(1)
+		return nil, false, err
+	}
+
+	if !dc.now().Before(record.DeleteAt) {
+		if os.Remove(path) == nil {
+			dc.evictions.Add(1)
+		}
+		dc.misses.Add(1)
+		return nil, false, nil
+	}
+
+	dc.hits.Add(1)
+	return &Entry{
+		Value:     record.Value,
+		StoredAt:  record.StoredAt,
+		ExpiresAt: record.ExpiresAt,
+	}, true, nil
+}
+
+// Set writes the entry to disk and keeps it for the given retention period
+// The file is written to a temp file and renamed so readers never see a partial entry
+func (dc *DiskCache) Set(_ context.Context, key string, entry *Entry, retention time.Duration) error {
+	data, err := json.Marshal(diskRecord{
+		Key:       key,
+		Value:     entry.Value,
+		StoredAt:  entry.StoredAt,
+		ExpiresAt: entry.ExpiresAt,
+		DeleteAt:  dc.now().Add(retention),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dc.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), dc.path(key)); err != nil {
+		return fmt.Errorf("failed to store cache file: %w", err)
+	}
+
+	// Writers only sweep when the limits may be exceeded, and never wait for a sweep already running
+	dc.entries.Add(1)
+	dc.bytes.Add(int64(len(data)))
+	if dc.exceeds(dc.entries.Load(), dc.bytes.Load(), 1) && dc.sweeping.TryLock() {
+		defer dc.sweeping.Unlock()
+		dc.sweep() // a failed sweep is retried by the next write or by Run
+	}
+	return nil
+}
+
+// Delete removes the entry stored under key
+func (dc *DiskCache) Delete(_ context.Context, key string) (bool, error) {
+	err := os.Remove(dc.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to remove cache file: %w", err)
+	}
+	return true, nil
+}
+
+// Flush removes all entries whose key starts with prefix
+func (dc *DiskCache) Flush(_ context.Context, prefix string) (int, error) {
+	removed := 0
+	err := dc.walk(func(path string, record *diskRecord, _ int64) error {
+		if !strings.HasPrefix(record.Key, prefix) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// Stats returns the cache counters along with the number and size of entries on disk
+func (dc *DiskCache) Stats(_ context.Context) (Stats, error) {
+	hits, misses := dc.hits.Load(), dc.misses.Load()
+	stats := Stats{
+		Backend:   "disk",
+		Hits:      hits,
+		Misses:    misses,
+		HitRate:   hitRate(hits, misses),
+		Evictions: dc.evictions.Load(),
+	}
+
+	err := dc.walk(func(_ string, _ *diskRecord, size int64) error {
+		stats.Entries++
+		stats.MemoryBytes += size
+		return nil
+	})
+	return stats, err
+}
+
+// Run sweeps the directory right away and then every sweep interval until ctx is canceled
+func (dc *DiskCache) Run(ctx context.Context) {
+	ticker := time.NewTicker(dc.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		dc.Sweep()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Sweep removes the entries past their retention and, if the cache is over its limits, the entries stored
+// longest ago until it is back under them
+func (dc *DiskCache) Sweep() error {
+	dc.sweeping.Lock()
+	defer dc.sweeping.Unlock()
+	return dc.sweep()
+}
+
+// diskFile is a cache file kept by a sweep
+type diskFile struct {
+	path     string
+	storedAt time.Time
+	size     int64
+}
+
+// sweep does the work of Sweep; the caller holds dc.sweeping
+func (dc *DiskCache) sweep() error {
+	now := dc.now()
+	var kept []diskFile
+	var bytes int64
+	err := dc.walk(func(path string, record *diskRecord, size int64) error {
+		if !now.Before(record.DeleteAt) {
+			if os.Remove(path) == nil {
+				dc.evictions.Add(1)
+			}
+			return nil
+		}
+		kept = append(kept, diskFile{path: path, storedAt: record.StoredAt, size: size})
+		bytes += size
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if dc.exceeds(int64(len(kept)), bytes, 1) {
+		slices.SortFunc(kept, func(a, b diskFile) int { return a.storedAt.Compare(b.storedAt) })
+		for len(kept) > 0 && dc.exceeds(int64(len(kept)), bytes, diskLowWater) {
+			if os.Remove(kept[0].path) == nil {
+				dc.evictions.Add(1)
+			}
+			bytes -= kept[0].size
+			kept = kept[1:]
+		}
+	}
+	dc.entries.Store(int64(len(kept)))
+	dc.bytes.Store(bytes)
+	return nil
+}
+
+// exceeds reports whether entries or bytes go over the share of their limit
+func (dc *DiskCache) exceeds(entries, bytes int64, share float64) bool {
+	return dc.maxEntries > 0 && float64(entries) > share*float64(dc.maxEntries) ||
+		dc.maxBytes > 0 && float64(bytes) > share*float64(dc.maxBytes)
+}
+
+// walk calls fn for every readable cache file in the directory
+func (dc *DiskCache) walk(fn func(path string, record *diskRecord, size int64) error) error {
+	files, err := os.ReadDir(dc.dir)
+	if err != nil {
+		return fmt.Errorf("failed to list cache directory: %w", err)
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), diskFileSuffix) {
+			continue
+		}
+
+		path := filepath.Join(dc.dir, file.Name())
+		record, err := readDiskRecord(path)
+		if err != nil {
+			continue // removed concurrently or corrupt - not worth failing the whole walk
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+
+		if err := fn(path, record, info.Size()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// path returns the file holding the entry for key
+func (dc *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dc.dir, hex.EncodeToString(sum[:16])+diskFileSuffix)
+}
+
+// readDiskRecord loads and decodes a single cache file
+func readDiskRecord(path string) (*diskRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var record diskRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode cache file %s: %w", path, err)
+	}
+	return &record, nil
+}
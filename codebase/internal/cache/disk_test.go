@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiskCache_SurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	dc, err := NewDisk(dir, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := dc.Set(ctx, "weather:1,1", newEntry("payload"), time.Hour); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A new instance over the same directory sees the entry (restart)
+	reopened, _ := NewDisk(dir, 0, 0, 0)
+	entry, found, err := reopened.Get(ctx, "weather:1,1")
+	if err != nil || !found {
+		t.Fatalf("Expected entry after reopen, found=%v err=%v", found, err)
+	}
+	if string(entry.Value) != "payload" {
+		t.Errorf("Expected payload, got %q", entry.Value)
+	}
+}
+
+func TestDiskCache_RetentionEnforcedOnRead(t *testing.T) {
+	dc, _ := NewDisk(t.TempDir(), 0, 0, 0)
+	now := time.Now()
+	dc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	dc.Set(ctx, "weather:1,1", newEntry("payload"), time.Minute)
+
+	now = now.Add(2 * time.Minute)
+	if _, found, _ := dc.Get(ctx, "weather:1,1"); found {
+		t.Error("Expected entry past retention to be dropped")
+	}
+
+	stats, _ := dc.Stats(ctx)
+	if stats.Entries != 0 || stats.Evictions != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestDiskCache_FlushPrefix(t *testing.T) {
+	dc, _ := NewDisk(t.TempDir(), 0, 0, 0)
+	ctx := context.Background()
+
+	dc.Set(ctx, "weather:1,1", newEntry("1"), time.Minute)
+	dc.Set(ctx, "weather:2,2", newEntry("2"), time.Minute)
+
+	removed, err := dc.Flush(ctx, "weather:1,")
+	if err != nil || removed != 1 {
+		t.Errorf("Expected 1 removed, got %d (%v)", removed, err)
+	}
+
+	stats, _ := dc.Stats(ctx)
+	if stats.Entries != 1 {
+		t.Errorf("Expected 1 entry left, got %d", stats.Entries)
+	}
+}
+
+func TestDiskCache_SweepRemovesExpired(t *testing.T) {
+	dc, _ := NewDisk(t.TempDir(), 0, 0, 0)
+	now := time.Now()
+	dc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	dc.Set(ctx, "weather:1,1", newEntry("1"), time.Minute)
+	dc.Set(ctx, "weather:2,2", newEntry("2"), time.Hour)
+
+	now = now.Add(2 * time.Minute)
+	if err := dc.Sweep(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stats, _ := dc.Stats(ctx)
+	if stats.Entries != 1 || stats.Evictions != 1 {
+		t.Errorf("Expected the expired entry swept without being read, got %+v", stats)
+	}
+}
+
+func TestDiskCache_MaxEntries(t *testing.T) {
+	dc, _ := NewDisk(t.TempDir(), 10, 0, 0)
+	ctx := context.Background()
+
+	storedAt := time.Now()
+	for i := range 11 {
+		entry := &Entry{Value: []byte("payload"), StoredAt: storedAt.Add(time.Duration(i) * time.Second)}
+		if err := dc.Set(ctx, fmt.Sprintf("weather:%d,%d", i, i), entry, time.Hour); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	// Going over the limit evicts the oldest entries down to 90% of it
+	stats, _ := dc.Stats(ctx)
+	if stats.Entries != 9 || stats.Evictions != 2 {
+		t.Errorf("Expected 9 entries after evicting 2, got %+v", stats)
+	}
+	for key, want := range map[string]bool{"weather:0,0": false, "weather:1,1": false, "weather:2,2": true, "weather:10,10": true} {
+		if _, found, _ := dc.Get(ctx, key); found != want {
+			t.Errorf("Expected %s found=%v, got %v", key, want, found)
+		}
+	}
+}
+
+func TestDiskCache_MaxBytes(t *testing.T) {
+	dc, _ := NewDisk(t.TempDir(), 0, 1000, 0)
+	ctx := context.Background()
+
+	for i := range 20 {
+		dc.Set(ctx, fmt.Sprintf("weather:%d,%d", i, i), newEntry(strings.Repeat("x", 100)), time.Hour)
+	}
+
+	stats, _ := dc.Stats(ctx)
+	if stats.MemoryBytes > 1000 || stats.Entries == 0 {
+		t.Errorf("Expected the files kept under 1000 bytes, got %+v", stats)
+	}
+}
@@ -0,0 +1,461 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memcachedMaxRelativeExpiry is the largest exptime memcached treats as relative (30 days)
+// Anything larger is interpreted as an absolute unix timestamp
+const memcachedMaxRelativeExpiry = 30 * 24 * time.Hour
+
+// memcachedMaxIdleConns is the number of idle connections kept per server
+const memcachedMaxIdleConns = 4
+
+// errMemcachedMiss is returned internally when a key is not present
+var errMemcachedMiss = errors.New("memcached: cache miss")
+
+// memcachedRecord is the value stored in memcached; retention is enforced by memcached's exptime
+type memcachedRecord struct {
+	Value     []byte    `json:"value"`
+	StoredAt  time.Time `json:"stored_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// memcachedMaxKeyLength is the longest key memcached accepts
+const memcachedMaxKeyLength = 250
+
+// memcachedGenerationTTL is how long the namespace generation is trusted before it is read again, so a
+// flush by another instance takes effect here within it
+const memcachedGenerationTTL = 5 * time.Second
+
+// MemcachedCache is a Cache implementation backed by one or more memcached servers
+// Keys are distributed across servers by hashing, like most memcached clients do
+// The cluster may be shared with other applications, so every key is stored under the namespace and its
+// current generation: flushing bumps the generation instead of wiping the servers, and the orphaned
+// entries age out through their exptime
+type MemcachedCache struct {
+	servers   []string
+	namespace string
+	timeout   time.Duration
+
+	mu   sync.Mutex
+	idle map[string][]net.Conn // idle connections per server
+
+	genMu        sync.Mutex
+	generation   string
+	generationAt time.Time
+}
+
+// NewMemcached creates a MemcachedCache for the given "host:port" servers, keeping its entries under namespace
+func NewMemcached(servers []string, namespace string, timeoutSec int) (*MemcachedCache, error) {
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("at least one memcached server is required")
+	}
+	if namespace == "" || !validMemcachedKey(namespace) {
+		return nil, fmt.Errorf("invalid memcached namespace %q: it must be non-empty, without spaces or control characters", namespace)
+	}
+	return &MemcachedCache{
+		servers:   servers,
+		namespace: namespace,
+		timeout:   time.Duration(timeoutSec) * time.Second,
+		idle:      make(map[string][]net.Conn),
+	}, nil
+}
+
+// Get returns the entry stored under key; memcached has already dropped it if retention elapsed
+func (mc *MemcachedCache) Get(ctx context.Context, key string) (*Entry, bool, error) {
+	key, err := mc.key(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var data []byte
+	err = mc.do(ctx, mc.serverFor(key), func(rw *bufio.ReadWriter) error {
+		fmt.Fprintf(rw, "get %s\r\n", key)
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+
+		var err error
+		data, err = readMemcachedValue(rw.Reader)
+		return err
+	})
+	if errors.Is(err, errMemcachedMiss) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var record memcachedRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, false, fmt.Errorf("failed to decode memcached entry: %w", err)
+	}
+	return &Entry{Value: record.Value, StoredAt: record.StoredAt, ExpiresAt: record.ExpiresAt}, true, nil
+}
+
+// Set stores the entry with an exptime equal to the retention period
+func (mc *MemcachedCache) Set(ctx context.Context, key string, entry *Entry, retention time.Duration) error {
+	key, err := mc.key(ctx, key)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(memcachedRecord{Value: entry.Value, StoredAt: entry.StoredAt, ExpiresAt: entry.ExpiresAt})
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	return mc.do(ctx, mc.serverFor(key), func(rw *bufio.ReadWriter) error {
+		fmt.Fprintf(rw, "set %s 0 %d %d\r\n", key, memcachedExpiry(retention), len(data))
+		rw.Write(data)
+		rw.WriteString("\r\n")
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+		return expectMemcachedReply(rw.Reader, "STORED")
+	})
+}
+
+// Delete removes the entry stored under key
+func (mc *MemcachedCache) Delete(ctx context.Context, key string) (bool, error) {
+	key, err := mc.key(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	deleted := false
+	err = mc.do(ctx, mc.serverFor(key), func(rw *bufio.ReadWriter) error {
+		fmt.Fprintf(rw, "delete %s\r\n", key)
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+		line, err := readMemcachedLine(rw.Reader)
+		switch {
+		case err != nil:
+			return err
+		case line == "DELETED":
+			deleted = true
+		case line != "NOT_FOUND":
+			return fmt.Errorf("unexpected reply %q", line)
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// Flush drops every entry of the namespace by moving it to a new generation
+// memcached cannot enumerate keys, so a non-empty prefix fails with ErrPrefixFlushUnsupported, and the
+// number removed is always 0: the old generation's entries are left to expire
+func (mc *MemcachedCache) Flush(ctx context.Context, prefix string) (int, error) {
+	if prefix != "" {
+		return 0, ErrPrefixFlushUnsupported
+	}
+
+	generationKey := mc.generationKey()
+	var generation string
+	err := mc.do(ctx, mc.serverFor(generationKey), func(rw *bufio.ReadWriter) error {
+		fmt.Fprintf(rw, "incr %s 1\r\n", generationKey)
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+		line, err := readMemcachedLine(rw.Reader)
+		if err != nil {
+			return err
+		}
+		if line == "NOT_FOUND" {
+			return errMemcachedMiss
+		}
+		if _, err := strconv.ParseUint(line, 10, 64); err != nil {
+			return fmt.Errorf("unexpected reply %q", line)
+		}
+		generation = line
+		return nil
+	})
+	if errors.Is(err, errMemcachedMiss) {
+		// Evicted: any new generation will do, since it starts from the current time
+		generation, err = mc.addGeneration(ctx)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	mc.genMu.Lock()
+	mc.generation, mc.generationAt = generation, time.Now()
+	mc.genMu.Unlock()
+	return 0, nil
+}
+
+// key returns the key stored in memcached for key, checking that memcached can take it
+func (mc *MemcachedCache) key(ctx context.Context, key string) (string, error) {
+	if !validMemcachedKey(key) {
+		return "", fmt.Errorf("invalid memcached key %q: spaces and control characters aren't allowed", key)
+	}
+	generation, err := mc.currentGeneration(ctx)
+	if err != nil {
+		return "", err
+	}
+	full := mc.namespace + ":" + generation + ":" + key
+	if len(full) > memcachedMaxKeyLength {
+		return "", fmt.Errorf("memcached key longer than %d bytes: %q", memcachedMaxKeyLength, full)
+	}
+	return full, nil
+}
+
+// generationKey is where the namespace's current generation is kept
+func (mc *MemcachedCache) generationKey() string {
+	return mc.namespace + ":generation"
+}
+
+// currentGeneration returns the namespace's generation, reading it at most once per memcachedGenerationTTL
+func (mc *MemcachedCache) currentGeneration(ctx context.Context) (string, error) {
+	mc.genMu.Lock()
+	defer mc.genMu.Unlock()
+	if mc.generation != "" && time.Since(mc.generationAt) < memcachedGenerationTTL {
+		return mc.generation, nil
+	}
+
+	generation, err := mc.readGeneration(ctx)
+	if errors.Is(err, errMemcachedMiss) {
+		generation, err = mc.addGeneration(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+	mc.generation, mc.generationAt = generation, time.Now()
+	return generation, nil
+}
+
+// addGeneration starts the namespace's generation when there is none, e.g. after an eviction
+// It starts at the current time in nanoseconds, so it never goes back to one a flush already left behind
+func (mc *MemcachedCache) addGeneration(ctx context.Context) (string, error) {
+	generationKey := mc.generationKey()
+	generation := strconv.FormatInt(time.Now().UnixNano(), 10)
+	err := mc.do(ctx, mc.serverFor(generationKey), func(rw *bufio.ReadWriter) error {
+		fmt.Fprintf(rw, "add %s 0 0 %d\r\n%s\r\n", generationKey, len(generation), generation)
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+		line, err := readMemcachedLine(rw.Reader)
+		if err != nil {
+			return err
+		}
+		if line == "NOT_STORED" {
+			return errMemcachedMiss // another instance got there first
+		}
+		if line != "STORED" {
+			return fmt.Errorf("unexpected reply %q", line)
+		}
+		return nil
+	})
+	if errors.Is(err, errMemcachedMiss) {
+		return mc.readGeneration(ctx)
+	}
+	return generation, err
+}
+
+// readGeneration reads the namespace's generation, returning errMemcachedMiss if there is none
+func (mc *MemcachedCache) readGeneration(ctx context.Context) (string, error) {
+	generationKey := mc.generationKey()
+	var data []byte
+	err := mc.do(ctx, mc.serverFor(generationKey), func(rw *bufio.ReadWriter) error {
+		fmt.Fprintf(rw, "get %s\r\n", generationKey)
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+		var err error
+		data, err = readMemcachedValue(rw.Reader)
+		return err
+	})
+	return string(data), err
+}
+
+// validMemcachedKey reports whether key can be sent in the text protocol: no spaces or control
+// characters, which would end the key early or inject another command
+func validMemcachedKey(key string) bool {
+	if len(key) > memcachedMaxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// Stats aggregates server-side counters across all servers
+// These are server-wide numbers, so they include other applications sharing the cluster
+func (mc *MemcachedCache) Stats(ctx context.Context) (Stats, error) {
+	stats := Stats{Backend: "memcached"}
+
+	for _, server := range mc.servers {
+		err := mc.do(ctx, server, func(rw *bufio.ReadWriter) error {
+			rw.WriteString("stats\r\n")
+			if err := rw.Flush(); err != nil {
+				return err
+			}
+
+			for {
+				line, err := readMemcachedLine(rw.Reader)
+				if err != nil {
+					return err
+				}
+				if line == "END" {
+					return nil
+				}
+
+				fields := strings.Fields(line)
+				if len(fields) != 3 || fields[0] != "STAT" {
+					continue
+				}
+				value, _ := strconv.ParseUint(fields[2], 10, 64)
+				switch fields[1] {
+				case "curr_items":
+					stats.Entries += int(value)
+				case "get_hits":
+					stats.Hits += value
+				case "get_misses":
+					stats.Misses += value
+				case "evictions", "expired_unfetched":
+					stats.Evictions += value
+				case "bytes":
+					stats.MemoryBytes += int64(value)
+				}
+			}
+		})
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	stats.HitRate = hitRate(stats.Hits, stats.Misses)
+	return stats, nil
+}
+
+// serverFor picks the server responsible for key
+func (mc *MemcachedCache) serverFor(key string) string {
+	return mc.servers[crc32.ChecksumIEEE([]byte(key))%uint32(len(mc.servers))]
+}
+
+// do runs fn over a pooled connection to server, returning the connection to the pool on success
+func (mc *MemcachedCache) do(ctx context.Context, server string, fn func(rw *bufio.ReadWriter) error) error {
+	conn, err := mc.conn(ctx, server)
+	if err != nil {
+		return fmt.Errorf("memcached %s: %w", server, err)
+	}
+
+	deadline := time.Now().Add(mc.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+
+	err = fn(bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)))
+	if err != nil && !errors.Is(err, errMemcachedMiss) {
+		// The connection may be mid-response; never reuse it
+		conn.Close()
+		return fmt.Errorf("memcached %s: %w", server, err)
+	}
+
+	mc.release(server, conn)
+	return err
+}
+
+// conn returns an idle connection to server or dials a new one
+func (mc *MemcachedCache) conn(ctx context.Context, server string) (net.Conn, error) {
+	mc.mu.Lock()
+	if conns := mc.idle[server]; len(conns) > 0 {
+		conn := conns[len(conns)-1]
+		mc.idle[server] = conns[:len(conns)-1]
+		mc.mu.Unlock()
+		return conn, nil
+	}
+	mc.mu.Unlock()
+
+	dialer := net.Dialer{Timeout: mc.timeout}
+	return dialer.DialContext(ctx, "tcp", server)
+}
+
+// release puts a healthy connection back into the idle pool
+func (mc *MemcachedCache) release(server string, conn net.Conn) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if len(mc.idle[server]) >= memcachedMaxIdleConns {
+		conn.Close()
+		return
+	}
+	mc.idle[server] = append(mc.idle[server], conn)
+}
+
+// memcachedExpiry converts a retention period into memcached's exptime format
+func memcachedExpiry(retention time.Duration) int64 {
+	if retention > memcachedMaxRelativeExpiry {
+		return time.Now().Add(retention).Unix()
+	}
+	// Round up so an entry never disappears before its retention has elapsed
+	return int64(math.Ceil(retention.Seconds()))
+}
+
+// readMemcachedValue reads a single-key "get" response
+func readMemcachedValue(r *bufio.Reader) ([]byte, error) {
+	line, err := readMemcachedLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if line == "END" {
+		return nil, errMemcachedMiss
+	}
+
+	// VALUE <key> <flags> <bytes>
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[0] != "VALUE" {
+		return nil, fmt.Errorf("unexpected reply %q", line)
+	}
+	size, err := strconv.Atoi(fields[3])
+	if err != nil {
+		return nil, fmt.Errorf("unexpected reply %q", line)
+	}
+
+	data := make([]byte, size+2) // payload plus trailing \r\n
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	if err := expectMemcachedReply(r, "END"); err != nil {
+		return nil, err
+	}
+	return data[:size], nil
+}
+
+// expectMemcachedReply reads one line and fails unless it equals want
+func expectMemcachedReply(r *bufio.Reader, want string) error {
+	line, err := readMemcachedLine(r)
+	if err != nil {
+		return err
+	}
+	if line != want {
+		return fmt.Errorf("unexpected reply %q", line)
+	}
+	return nil
+}
+
+// readMemcachedLine reads a single \r\n terminated protocol line
+func readMemcachedLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,221 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMemcached speaks enough of the memcached text protocol for the client under test
+type fakeMemcached struct {
+	mu      sync.Mutex
+	items   map[string][]byte
+	expiry  map[string]int64
+	hits    int
+	misses  int
+	address string
+}
+
+func startFakeMemcached(t *testing.T) *fakeMemcached {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	fake := &fakeMemcached{items: map[string][]byte{}, expiry: map[string]int64{}, address: listener.Addr().String()}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go fake.serve(conn)
+		}
+	}()
+	return fake
+}
+
+func (f *fakeMemcached) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		f.mu.Lock()
+		switch fields[0] {
+		case "get":
+			if value, ok := f.items[fields[1]]; ok {
+				f.hits++
+				fmt.Fprintf(conn, "VALUE %s 0 %d\r\n%s\r\nEND\r\n", fields[1], len(value), value)
+			} else {
+				f.misses++
+				io.WriteString(conn, "END\r\n")
+			}
+		case "set":
+			size, _ := strconv.Atoi(fields[4])
+			exptime, _ := strconv.ParseInt(fields[3], 10, 64)
+			data := make([]byte, size+2)
+			io.ReadFull(r, data)
+			f.items[fields[1]] = data[:size]
+			f.expiry[fields[1]] = exptime
+			io.WriteString(conn, "STORED\r\n")
+		case "add":
+			size, _ := strconv.Atoi(fields[4])
+			data := make([]byte, size+2)
+			io.ReadFull(r, data)
+			if _, ok := f.items[fields[1]]; ok {
+				io.WriteString(conn, "NOT_STORED\r\n")
+			} else {
+				f.items[fields[1]] = data[:size]
+				io.WriteString(conn, "STORED\r\n")
+			}
+		case "incr":
+			if value, ok := f.items[fields[1]]; ok {
+				n, _ := strconv.ParseUint(string(value), 10, 64)
+				delta, _ := strconv.ParseUint(fields[2], 10, 64)
+				f.items[fields[1]] = []byte(strconv.FormatUint(n+delta, 10))
+				fmt.Fprintf(conn, "%d\r\n", n+delta)
+			} else {
+				io.WriteString(conn, "NOT_FOUND\r\n")
+			}
+		case "delete":
+			if _, ok := f.items[fields[1]]; ok {
+				delete(f.items, fields[1])
+				io.WriteString(conn, "DELETED\r\n")
+			} else {
+				io.WriteString(conn, "NOT_FOUND\r\n")
+			}
+		case "flush_all":
+			f.items = map[string][]byte{}
+			io.WriteString(conn, "OK\r\n")
+		case "stats":
+			fmt.Fprintf(conn, "STAT curr_items %d\r\nSTAT get_hits %d\r\nSTAT get_misses %d\r\nEND\r\n", len(f.items), f.hits, f.misses)
+		}
+		f.mu.Unlock()
+	}
+}
+
+// storedKey returns the key the fake holds for key, whatever the namespace generation
+func (f *fakeMemcached) storedKey(key string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for stored := range f.items {
+		if strings.HasSuffix(stored, ":"+key) {
+			return stored
+		}
+	}
+	return ""
+}
+
+func TestMemcachedCache_RoundTrip(t *testing.T) {
+	fake := startFakeMemcached(t)
+	mc, err := NewMemcached([]string{fake.address}, "weather-api", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	ctx := context.Background()
+
+	if _, found, err := mc.Get(ctx, "weather:1,1"); found || err != nil {
+		t.Fatalf("Expected miss, found=%v err=%v", found, err)
+	}
+
+	if err := mc.Set(ctx, "weather:1,1", newEntry("payload"), 90*time.Second); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	stored := fake.storedKey("weather:1,1")
+	if !strings.HasPrefix(stored, "weather-api:") {
+		t.Errorf("Expected the key under the namespace, got %q", stored)
+	}
+	if fake.expiry[stored] != 90 {
+		t.Errorf("Expected exptime 90, got %d", fake.expiry[stored])
+	}
+
+	entry, found, err := mc.Get(ctx, "weather:1,1")
+	if err != nil || !found {
+		t.Fatalf("Expected hit, found=%v err=%v", found, err)
+	}
+	if string(entry.Value) != "payload" {
+		t.Errorf("Expected payload, got %q", entry.Value)
+	}
+
+	stats, err := mc.Stats(ctx)
+	// The namespace generation is an entry too, and its first read missed
+	if err != nil || stats.Entries != 2 || stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("Unexpected stats: %+v (%v)", stats, err)
+	}
+}
+
+func TestMemcachedCache_Flush(t *testing.T) {
+	fake := startFakeMemcached(t)
+	mc, _ := NewMemcached([]string{fake.address}, "weather-api", 1)
+	ctx := context.Background()
+	fake.items["other-app:key"] = []byte("theirs")
+
+	mc.Set(ctx, "weather:1,1", newEntry("1"), time.Minute)
+	mc.Set(ctx, "weather:2,2", newEntry("2"), time.Minute)
+
+	if deleted, err := mc.Delete(ctx, "weather:1,1"); err != nil || !deleted {
+		t.Errorf("Expected the key deleted, got %v (%v)", deleted, err)
+	}
+	if _, found, _ := mc.Get(ctx, "weather:1,1"); found {
+		t.Error("Expected the deleted key to miss")
+	}
+
+	if _, err := mc.Flush(ctx, "weather:"); !errors.Is(err, ErrPrefixFlushUnsupported) {
+		t.Errorf("Expected prefix flushes to be refused, got %v", err)
+	}
+	if _, err := mc.Flush(ctx, ""); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, found, _ := mc.Get(ctx, "weather:2,2"); found {
+		t.Error("Expected the flush to hide every entry of the namespace")
+	}
+	if string(fake.items["other-app:key"]) != "theirs" {
+		t.Error("Expected other applications' keys to survive the flush")
+	}
+
+	// A second client sees the flush once its copy of the generation is refreshed
+	other, _ := NewMemcached([]string{fake.address}, "weather-api", 1)
+	mc.Set(ctx, "weather:3,3", newEntry("3"), time.Minute)
+	if _, found, _ := other.Get(ctx, "weather:3,3"); !found {
+		t.Error("Expected clients sharing the namespace to share entries")
+	}
+}
+
+func TestMemcachedCache_RejectsUnsafeKeys(t *testing.T) {
+	fake := startFakeMemcached(t)
+	mc, _ := NewMemcached([]string{fake.address}, "weather-api", 1)
+	ctx := context.Background()
+	mc.Set(ctx, "weather:1,1", newEntry("1"), time.Minute)
+
+	for _, key := range []string{"a\r\nflush_all", "a b", strings.Repeat("k", 250)} {
+		if _, err := mc.Delete(ctx, key); err == nil {
+			t.Errorf("Expected %q to be rejected", key)
+		}
+		if err := mc.Set(ctx, key, newEntry("x"), time.Minute); err == nil {
+			t.Errorf("Expected %q to be rejected", key)
+		}
+	}
+	if _, found, _ := mc.Get(ctx, "weather:1,1"); !found {
+		t.Error("Expected nothing to be flushed by an injected command")
+	}
+	if _, err := NewMemcached([]string{fake.address}, "bad namespace", 1); err == nil {
+		t.Error("Expected a namespace with a space to be rejected")
+	}
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryItemOverhead is a rough per-entry cost of the map slot, list element and metadata
+const memoryItemOverhead = 128
+
+type memoryItem struct {
+	key      string
+	entry    *Entry
+	deleteAt time.Time // entry is dropped once retention has elapsed
+}
+
+func (item *memoryItem) size() int64 {
+	return int64(len(item.key) + len(item.entry.Value) + memoryItemOverhead)
+}
+
+// MemoryCache is an in-process Cache implementation with LRU eviction once maxEntries is reached
+type MemoryCache struct {
+	mu         sync.Mutex
+	items      map[string]*list.Element
+	lru        *list.List // front is most recently used
+	maxEntries int        // 0 means unbounded
+	bytes      int64
+	hits       uint64
+	misses     uint64
+	evictions  uint64
+	now        func() time.Time
+}
+
+// NewMemory creates a new empty MemoryCache holding at most maxEntries entries (0 for no limit)
+func NewMemory(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		items:      make(map[string]*list.Element),
+		lru:        list.New(),
+		maxEntries: maxEntries,
+		now:        time.Now,
+	}
+}
+
+// Get returns the entry stored under key if it is still within its retention period
+func (mc *MemoryCache) Get(_ context.Context, key string) (*Entry, bool, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	elem, ok := mc.items[key]
+	if !ok {
+		mc.misses++
+		return nil, false, nil
+	}
+
+	item := elem.Value.(*memoryItem)
+	if !mc.now().Before(item.deleteAt) {
+		mc.remove(elem)
+		mc.evictions++
+		mc.misses++
+		return nil, false, nil
+	}
+
+	mc.lru.MoveToFront(elem)
+	mc.hits++
+	return item.entry, true, nil
+}
+
+// Set stores the entry under key and keeps it for the given retention period
+func (mc *MemoryCache) Set(_ context.Context, key string, entry *Entry, retention time.Duration) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if elem, ok := mc.items[key]; ok {
+		mc.remove(elem)
+	}
+
+	item := &memoryItem{
+		key:      key,
+		entry:    entry,
+		deleteAt: mc.now().Add(retention),
+	}
+	mc.items[key] = mc.lru.PushFront(item)
+	mc.bytes += item.size()
+
+	for mc.maxEntries > 0 && mc.lru.Len() > mc.maxEntries {
+		mc.remove(mc.lru.Back())
+		mc.evictions++
+	}
+	return nil
+}
+
+// Delete removes the entry stored under key
+func (mc *MemoryCache) Delete(_ context.Context, key string) (bool, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	elem, ok := mc.items[key]
+	if ok {
+		mc.remove(elem)
+	}
+	return ok, nil
+}
+
+// Flush removes all entries whose key starts with prefix
+func (mc *MemoryCache) Flush(_ context.Context, prefix string) (int, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	removed := 0
+	for key, elem := range mc.items {
+		if strings.HasPrefix(key, prefix) {
+			mc.remove(elem)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// Stats returns a snapshot of the cache counters
+func (mc *MemoryCache) Stats(_ context.Context) (Stats, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	return Stats{
+		Backend:     "memory",
+		Entries:     mc.lru.Len(),
+		Hits:        mc.hits,
+		Misses:      mc.misses,
+		HitRate:     hitRate(mc.hits, mc.misses),
+		Evictions:   mc.evictions,
+		MemoryBytes: mc.bytes,
+	}, nil
+}
+
+// remove drops an element from both the map and the LRU list; caller must hold mu
+func (mc *MemoryCache) remove(elem *list.Element) {
+	item := mc.lru.Remove(elem).(*memoryItem)
+	delete(mc.items, item.key)
+	mc.bytes -= item.size()
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newEntry(value string) *Entry {
+	now := time.Now()
+	return &Entry{Value: []byte(value), StoredAt: now, ExpiresAt: now.Add(time.Minute)}
+}
+
+func TestMemoryCache_Retention(t *testing.T) {
+	mc := NewMemory(0)
+	now := time.Now()
+	mc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	mc.Set(ctx, "a", newEntry("1"), time.Minute)
+
+	if _, found, _ := mc.Get(ctx, "a"); !found {
+		t.Fatal("Expected entry within retention")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, found, _ := mc.Get(ctx, "a"); found {
+		t.Error("Expected entry to be dropped after retention")
+	}
+
+	stats, _ := mc.Stats(ctx)
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Evictions != 1 || stats.Entries != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestMemoryCache_LRUEviction(t *testing.T) {
+	mc := NewMemory(2)
+	ctx := context.Background()
+
+	mc.Set(ctx, "a", newEntry("1"), time.Minute)
+	mc.Set(ctx, "b", newEntry("2"), time.Minute)
+	mc.Get(ctx, "a") // a is now most recently used
+	mc.Set(ctx, "c", newEntry("3"), time.Minute)
+
+	if _, found, _ := mc.Get(ctx, "b"); found {
+		t.Error("Expected least recently used entry to be evicted")
+	}
+	if _, found, _ := mc.Get(ctx, "a"); !found {
+		t.Error("Expected recently used entry to survive")
+	}
+
+	stats, _ := mc.Stats(ctx)
+	if stats.Entries != 2 || stats.Evictions != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestMemoryCache_FlushPrefix(t *testing.T) {
+	mc := NewMemory(0)
+	ctx := context.Background()
+
+	mc.Set(ctx, "weather:1,1", newEntry("1"), time.Minute)
+	mc.Set(ctx, "weather:1,2", newEntry("2"), time.Minute)
+	mc.Set(ctx, "weather:2,1", newEntry("3"), time.Minute)
+
+	removed, err := mc.Flush(ctx, "weather:1,")
+	if err != nil || removed != 2 {
+		t.Errorf("Expected 2 removed, got %d (%v)", removed, err)
+	}
+
+	removed, _ = mc.Flush(ctx, "")
+	if removed != 1 {
+		t.Errorf("Expected 1 removed, got %d", removed)
+	}
+
+	stats, _ := mc.Stats(ctx)
+	if stats.Entries != 0 || stats.MemoryBytes != 0 {
+		t.Errorf("Expected empty cache, got %+v", stats)
+	}
+}
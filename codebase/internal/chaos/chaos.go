@@ -0,0 +1,103 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrInjected is returned for upstream calls failed on purpose
+var ErrInjected = errors.New("chaos: injected connection failure")
+
+// Config sets how often each fault is injected, in percent of requests
+// Faults are rolled independently: a request can be delayed and then fail
+type Config struct {
+	ErrorPct int // answer with a 503 instead of the real response
+	DelayPct int // wait DelayMs before handling the request
+	DropPct  int // drop the connection without any response
+	DelayMs  int
+}
+
+// Injector injects faults into inbound requests or upstream calls, for resilience testing in staging
+type Injector struct {
+	cfg  Config
+	roll func() int // returns 0-99
+}
+
+// New creates an Injector with the given fault rates
+func New(cfg Config) *Injector {
+	return &Injector{cfg: cfg, roll: func() int { return rand.IntN(100) }}
+}
+
+// hit rolls the dice for a fault injected pct percent of the time
+func (inj *Injector) hit(pct int) bool {
+	return pct > 0 && inj.roll() < pct
+}
+
+// delay waits for the configured delay unless ctx is done first
+func (inj *Injector) delay(ctx context.Context) {
+	timer := time.NewTimer(time.Duration(inj.cfg.DelayMs) * time.Millisecond)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// Handler wraps inbound handlers so clients see injected delays, errors and dropped connections
+func (inj *Injector) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if inj.hit(inj.cfg.DelayPct) {
+			inj.delay(r.Context())
+		}
+		if inj.hit(inj.cfg.DropPct) {
+			// net/http closes the connection without writing a response
+			panic(http.ErrAbortHandler)
+		}
+		if inj.hit(inj.cfg.ErrorPct) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, `{"error":"Injected fault","code":"CHAOS"}`+"\n")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RoundTripper wraps an upstream transport so the provider appears slow, failing or unreachable
+func (inj *Injector) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if inj.hit(inj.cfg.DelayPct) {
+			inj.delay(req.Context())
+		}
+		if inj.hit(inj.cfg.DropPct) {
+			return nil, ErrInjected
+		}
+		if inj.hit(inj.cfg.ErrorPct) {
+			return &http.Response{
+				Status:     "503 Service Unavailable",
+				StatusCode: http.StatusServiceUnavailable,
+				Proto:      "HTTP/1.1",
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Header:     http.Header{"Content-Type": {"text/plain"}},
+				Body:       io.NopCloser(strings.NewReader("injected fault")),
+				Request:    req,
+			}, nil
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
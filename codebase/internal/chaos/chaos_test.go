@@ -0,0 +1,60 @@
+package chaos
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestInjector returns an Injector whose dice always roll value
+func newTestInjector(cfg Config, value int) *Injector {
+	inj := New(cfg)
+	inj.roll = func() int { return value }
+	return inj
+}
+
+func TestInjector_Handler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	newTestInjector(Config{ErrorPct: 10}, 5).Handler(ok).ServeHTTP(w, httptest.NewRequest("GET", "/weather", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected injected 503, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	newTestInjector(Config{ErrorPct: 10}, 50).Handler(ok).ServeHTTP(w, httptest.NewRequest("GET", "/weather", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected request to pass, got %d", w.Code)
+	}
+
+	defer func() {
+		if recover() != http.ErrAbortHandler {
+			t.Error("Expected dropped connection to abort the handler")
+		}
+	}()
+	newTestInjector(Config{DropPct: 100}, 0).Handler(ok).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/weather", nil))
+}
+
+func TestInjector_RoundTripper(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: newTestInjector(Config{ErrorPct: 100}, 0).RoundTripper(nil)}
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("Expected injected response, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected injected 503, got %d", resp.StatusCode)
+	}
+
+	client = &http.Client{Transport: newTestInjector(Config{DropPct: 100}, 0).RoundTripper(nil)}
+	if _, err := client.Get(upstream.URL); !errors.Is(err, ErrInjected) {
+		t.Errorf("Expected ErrInjected, got %v", err)
+	}
+}
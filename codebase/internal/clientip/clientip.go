@@ -0,0 +1,193 @@
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// FromRequest returns the client IP resolved by Middleware, or the address of the connection's
+// peer for requests that didn't go through it
+func FromRequest(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return peerIP(r)
+}
+
+// peerIP returns the host part of the connection's remote address
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Forwarding headers a Resolver can read the client IP from
+const (
+	HeaderXForwardedFor = "X-Forwarded-For"
+	HeaderForwarded     = "Forwarded"
+	HeaderXRealIP       = "X-Real-IP"
+)
+
+// Resolver finds the real client IP of requests arriving through trusted proxies (e.g. our load balancer)
+type Resolver struct {
+	trusted []netip.Prefix
+	header  string
+}
+
+// NewResolver creates a Resolver trusting the given forwarding header (X-Forwarded-For if empty) when
+// set by proxies in the given CIDRs (plain addresses are accepted too); with none, the connection's
+// peer is always the client
+// Only the header the proxies actually set may be read: any other one comes straight from the client
+func NewResolver(trustedProxies []string, header string) (*Resolver, error) {
+	if header == "" {
+		header = HeaderXForwardedFor
+	}
+	resolver := &Resolver{}
+	for _, known := range []string{HeaderXForwardedFor, HeaderForwarded, HeaderXRealIP} {
+		if strings.EqualFold(header, known) {
+			resolver.header = known
+		}
+	}
+	if resolver.header == "" {
+		return nil, fmt.Errorf("unsupported forwarding header %q, want %s, %s or %s", header, HeaderXForwardedFor, HeaderForwarded, HeaderXRealIP)
+	}
+	for _, cidr := range trustedProxies {
+		cidr = strings.TrimSpace(cidr)
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(cidr)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		resolver.trusted = append(resolver.trusted, prefix.Masked())
+	}
+	return resolver, nil
+}
+
+// isTrusted reports whether ip belongs to a trusted proxy
+func (res *Resolver) isTrusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range res.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the client IP of r
+// The forwarding header is only believed when the peer is a trusted proxy, and no other header is ever
+// read, since the proxy passes those through from the client. The chain in Forwarded or
+// X-Forwarded-For is walked from the nearest hop back, skipping trusted proxies, so a client can't
+// spoof its address by sending the header itself
+func (res *Resolver) Resolve(r *http.Request) string {
+	peer := peerIP(r)
+	if !res.isTrusted(peer) {
+		return peer
+	}
+
+	var chain []string
+	switch res.header {
+	case HeaderForwarded:
+		chain = forwardedFor(r.Header.Values(HeaderForwarded))
+	case HeaderXForwardedFor:
+		chain = xForwardedFor(r.Header.Values(HeaderXForwardedFor))
+	case HeaderXRealIP:
+		if realIP := strings.TrimSpace(r.Header.Get(HeaderXRealIP)); validIP(realIP) {
+			return realIP
+		}
+		return peer
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !res.isTrusted(chain[i]) {
+			return chain[i]
+		}
+	}
+	if len(chain) > 0 {
+		// Every hop is one of ours; the first one is the closest we get to the client
+		return chain[0]
+	}
+	return peer
+}
+
+// Middleware resolves the client IP once so logs and everything else use the same address (see FromRequest)
+func (res *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientIPKey{}, res.Resolve(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// xForwardedFor returns the addresses in X-Forwarded-For headers, client first
+// Anything that isn't an IP makes the chain untrustworthy past that point, so it ends there
+func xForwardedFor(values []string) []string {
+	var chain []string
+	for _, value := range values {
+		for _, hop := range strings.Split(value, ",") {
+			hop = strings.TrimSpace(hop)
+			if !validIP(hop) {
+				chain = nil
+				continue
+			}
+			chain = append(chain, hop)
+		}
+	}
+	return chain
+}
+
+// forwardedFor returns the for= addresses of RFC 7239 Forwarded headers, client first
+func forwardedFor(values []string) []string {
+	var chain []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				name, node, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(name, "for") {
+					continue
+				}
+				ip := forwardedNode(node)
+				if !validIP(ip) {
+					// Obfuscated or unknown hops can't be checked against the trusted list
+					chain = nil
+					continue
+				}
+				chain = append(chain, ip)
+			}
+		}
+	}
+	return chain
+}
+
+// forwardedNode strips quotes, brackets and the port from a Forwarded node, e.g. "[2001:db8::1]:4711"
+func forwardedNode(node string) string {
+	node = strings.Trim(node, `"`)
+	if strings.HasPrefix(node, "[") {
+		if end := strings.Index(node, "]"); end > 0 {
+			return node[1:end]
+		}
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return node
+}
+
+func validIP(ip string) bool {
+	_, err := netip.ParseAddr(ip)
+	return err == nil
+}
@@ -0,0 +1,99 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	resolver, err := NewResolver([]string{"10.0.0.0/8", "192.0.2.1"}, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		peer     string
+		headers  map[string]string
+		expected string
+	}{
+		{"untrusted peer ignores headers", "203.0.113.7:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.7"},
+		{"no headers", "10.0.0.5:1234", nil, "10.0.0.5"},
+		{"x-forwarded-for", "10.0.0.5:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"spoofed x-forwarded-for", "10.0.0.5:1234", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1, 10.1.1.1"}, "198.51.100.1"},
+		{"trusted single address", "192.0.2.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"client-sent forwarded ignored", "10.0.0.5:1234", map[string]string{"Forwarded": "for=1.2.3.4", "X-Forwarded-For": "203.0.113.9"}, "203.0.113.9"},
+		{"client-sent x-real-ip ignored", "10.0.0.5:1234", map[string]string{"X-Real-IP": "1.2.3.4"}, "10.0.0.5"},
+		{"garbage", "10.0.0.5:1234", map[string]string{"X-Forwarded-For": "not-an-ip"}, "10.0.0.5"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/weather", nil)
+		req.RemoteAddr = tt.peer
+		for name, value := range tt.headers {
+			req.Header.Set(name, value)
+		}
+		if got := resolver.Resolve(req); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestResolver_ResolveOtherHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		headers  map[string]string
+		expected string
+	}{
+		{"forwarded", "forwarded", map[string]string{"Forwarded": `for="[2001:db8::1]:4711";proto=https, for=10.0.0.9`}, "2001:db8::1"},
+		{"client-sent x-forwarded-for ignored", "Forwarded", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "10.0.0.5"},
+		{"x-real-ip", "X-Real-IP", map[string]string{"X-Real-IP": "198.51.100.2", "Forwarded": "for=1.2.3.4"}, "198.51.100.2"},
+		{"bad x-real-ip", "X-Real-IP", map[string]string{"X-Real-IP": "nope"}, "10.0.0.5"},
+	}
+
+	for _, tt := range tests {
+		resolver, err := NewResolver([]string{"10.0.0.0/8"}, tt.header)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", tt.name, err)
+		}
+		req := httptest.NewRequest("GET", "/weather", nil)
+		req.RemoteAddr = "10.0.0.5:1234"
+		for name, value := range tt.headers {
+			req.Header.Set(name, value)
+		}
+		if got := resolver.Resolve(req); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestNewResolver_InvalidCIDR(t *testing.T) {
+	if _, err := NewResolver([]string{"10.0.0.0/33"}, ""); err == nil {
+		t.Error("Expected an invalid CIDR to be rejected")
+	}
+}
+
+func TestNewResolver_UnsupportedHeader(t *testing.T) {
+	if _, err := NewResolver(nil, "X-Client-IP"); err == nil {
+		t.Error("Expected an unsupported header to be rejected")
+	}
+}
+
+func TestMiddleware_StoresClientIP(t *testing.T) {
+	resolver, _ := NewResolver([]string{"10.0.0.0/8"}, "")
+	var got string
+	handler := resolver.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromRequest(r)
+	}))
+
+	req := httptest.NewRequest("GET", "/weather", nil)
+	req.RemoteAddr = "10.0.0.5:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got != "198.51.100.1" {
+		t.Errorf("Expected the forwarded client IP, got %s", got)
+	}
+}
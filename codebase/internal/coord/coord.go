@@ -0,0 +1,21 @@
+package coord
+
+import (
+	"context"
+	"time"
+)
+
+// Coordinator provides the small amount of shared state needed to make several server instances
+// behave as one toward the upstream provider
+type Coordinator interface {
+	// TryLock acquires the named lock for ttl unless someone else holds it
+	// The returned release func frees the lock early; the lock expires on its own otherwise
+	TryLock(ctx context.Context, name string, ttl time.Duration) (release func(), acquired bool, err error)
+	// Incr increments the named counter for the current fixed window and returns the new value
+	Incr(ctx context.Context, name string, window time.Duration) (int64, error)
+}
+
+// windowKey returns the counter key for the fixed window containing now
+func windowKey(name string, window time.Duration, now time.Time) string {
+	return name + ":" + now.Truncate(window).Format("20060102T150405")
+}
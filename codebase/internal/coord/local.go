@@ -0,0 +1,64 @@
+package coord
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Local is an in-process Coordinator for single-instance deployments
+type Local struct {
+	mu       sync.Mutex
+	locks    map[string]time.Time // lock name -> expiry
+	counters map[string]int64     // window key -> count
+	windows  map[string]string    // counter name -> current window key
+	now      func() time.Time
+}
+
+// NewLocal creates a new Local coordinator
+func NewLocal() *Local {
+	return &Local{
+		locks:    make(map[string]time.Time),
+		counters: make(map[string]int64),
+		windows:  make(map[string]string),
+		now:      time.Now,
+	}
+}
+
+// TryLock acquires the named lock for ttl unless it is already held
+func (l *Local) TryLock(_ context.Context, name string, ttl time.Duration) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if expiry, held := l.locks[name]; held && now.Before(expiry) {
+		return nil, false, nil
+	}
+
+	expiry := now.Add(ttl)
+	l.locks[name] = expiry
+	release := func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		// Only release our own lock, not one re-acquired after ours expired
+		if l.locks[name].Equal(expiry) {
+			delete(l.locks, name)
+		}
+	}
+	return release, true, nil
+}
+
+// Incr increments the named counter for the current window
+func (l *Local) Incr(_ context.Context, name string, window time.Duration) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := windowKey(name, window, l.now())
+	if previous, ok := l.windows[name]; ok && previous != key {
+		delete(l.counters, previous)
+	}
+	l.windows[name] = key
+
+	l.counters[key]++
+	return l.counters[key], nil
+}
@@ -0,0 +1,50 @@
+package coord
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLocal_TryLock(t *testing.T) {
+	local := NewLocal()
+	now := time.Now()
+	local.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	release, acquired, _ := local.TryLock(ctx, "refresh", time.Minute)
+	if !acquired {
+		t.Fatal("Expected first lock to be acquired")
+	}
+	if _, acquired, _ := local.TryLock(ctx, "refresh", time.Minute); acquired {
+		t.Error("Expected lock to be held")
+	}
+
+	release()
+	if _, acquired, _ := local.TryLock(ctx, "refresh", time.Minute); !acquired {
+		t.Error("Expected lock to be free after release")
+	}
+
+	// Expired locks can be taken over
+	now = now.Add(2 * time.Minute)
+	if _, acquired, _ := local.TryLock(ctx, "refresh", time.Minute); !acquired {
+		t.Error("Expected expired lock to be acquired")
+	}
+}
+
+func TestLocal_IncrWindow(t *testing.T) {
+	local := NewLocal()
+	now := time.Date(2025, 6, 5, 12, 0, 10, 0, time.UTC)
+	local.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	local.Incr(ctx, "calls", time.Minute)
+	if count, _ := local.Incr(ctx, "calls", time.Minute); count != 2 {
+		t.Errorf("Expected 2, got %d", count)
+	}
+
+	now = now.Add(time.Minute)
+	if count, _ := local.Incr(ctx, "calls", time.Minute); count != 1 {
+		t.Errorf("Expected counter to reset in the next window, got %d", count)
+	}
+}
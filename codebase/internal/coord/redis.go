@@ -0,0 +1,238 @@
+package coord
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisMaxIdleConns is the number of idle connections kept to the Redis server
+const redisMaxIdleConns = 4
+
+// redisReleaseScript deletes a lock only if it still holds our token
+const redisReleaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+
+// redisIncrScript increments a counter and sets its expiry on the first increment, atomically, so a counter
+// can't be left without one when the connection fails in between
+const redisIncrScript = `local count = redis.call("incr", KEYS[1]) if count == 1 then redis.call("pexpire", KEYS[1], ARGV[1]) end return count`
+
+// errRedisNil is returned for Redis nil replies (e.g. SET NX on an existing key)
+var errRedisNil = errors.New("redis: nil reply")
+
+// Redis is a Coordinator backed by a single Redis server, shared by every instance of the fleet
+// It speaks just enough of the RESP protocol for locks and counters
+type Redis struct {
+	addr      string
+	password  string
+	keyPrefix string
+	timeout   time.Duration
+
+	mu   sync.Mutex
+	idle []net.Conn
+}
+
+// NewRedis creates a Redis coordinator; every key is namespaced with keyPrefix
+func NewRedis(addr, password, keyPrefix string, timeoutSec int) *Redis {
+	return &Redis{
+		addr:      addr,
+		password:  password,
+		keyPrefix: keyPrefix,
+		timeout:   time.Duration(timeoutSec) * time.Second,
+	}
+}
+
+// TryLock acquires the named lock with SET NX PX, using a random token so only the owner can release it
+func (rc *Redis) TryLock(ctx context.Context, name string, ttl time.Duration) (func(), bool, error) {
+	key := rc.keyPrefix + "lock:" + name
+	token, err := randomToken()
+	if err != nil {
+		return nil, false, err
+	}
+
+	_, err = rc.do(ctx, "SET", key, token, "NX", "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	if errors.Is(err, errRedisNil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	release := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), rc.timeout)
+		defer cancel()
+		rc.do(ctx, "EVAL", redisReleaseScript, "1", key, token)
+	}
+	return release, true, nil
+}
+
+// Incr increments the named counter for the current window; the key expires shortly after the window ends
+func (rc *Redis) Incr(ctx context.Context, name string, window time.Duration) (int64, error) {
+	key := rc.keyPrefix + "counter:" + windowKey(name, window, time.Now())
+
+	reply, err := rc.do(ctx, "EVAL", redisIncrScript, "1", key, strconv.FormatInt((2*window).Milliseconds(), 10))
+	if err != nil {
+		return 0, err
+	}
+	count, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected INCR reply %v", reply)
+	}
+	return count, nil
+}
+
+// Ping checks connectivity to the Redis server
+func (rc *Redis) Ping(ctx context.Context) error {
+	_, err := rc.do(ctx, "PING")
+	return err
+}
+
+// do sends one command and reads its reply over a pooled connection
+func (rc *Redis) do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := rc.conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("redis %s: %w", rc.addr, err)
+	}
+
+	deadline := time.Now().Add(rc.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+
+	reply, err := roundTrip(bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)), args)
+	var serverErr redisError
+	if err != nil && !errors.Is(err, errRedisNil) && !errors.As(err, &serverErr) {
+		// The connection may be mid-reply; never reuse it
+		conn.Close()
+		return nil, fmt.Errorf("redis %s: %w", rc.addr, err)
+	}
+
+	rc.release(conn)
+	return reply, err
+}
+
+// conn returns an idle connection or dials (and authenticates) a new one
+func (rc *Redis) conn(ctx context.Context) (net.Conn, error) {
+	rc.mu.Lock()
+	if len(rc.idle) > 0 {
+		conn := rc.idle[len(rc.idle)-1]
+		rc.idle = rc.idle[:len(rc.idle)-1]
+		rc.mu.Unlock()
+		return conn, nil
+	}
+	rc.mu.Unlock()
+
+	dialer := net.Dialer{Timeout: rc.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", rc.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if rc.password != "" {
+		conn.SetDeadline(time.Now().Add(rc.timeout))
+		if _, err := roundTrip(bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)), []string{"AUTH", rc.password}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("auth failed: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// release puts a healthy connection back into the idle pool
+func (rc *Redis) release(conn net.Conn) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if len(rc.idle) >= redisMaxIdleConns {
+		conn.Close()
+		return
+	}
+	rc.idle = append(rc.idle, conn)
+}
+
+// redisError is an error reply sent by the server (the connection is still usable)
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// roundTrip writes a command as a RESP array of bulk strings and reads the reply
+func roundTrip(rw *bufio.ReadWriter, args []string) (interface{}, error) {
+	fmt.Fprintf(rw, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(rw, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := rw.Flush(); err != nil {
+		return nil, err
+	}
+	return readReply(rw.Reader)
+}
+
+// readReply parses a single RESP reply
+// Simple strings and bulk strings become string, integers int64, arrays []interface{}
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, errRedisNil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, errRedisNil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil && !errors.Is(err, errRedisNil) {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected reply %q", line)
+	}
+}
+
+// randomToken returns a random lock token
+func randomToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate lock token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,151 @@
+package coord
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis implements the handful of commands the coordinator uses
+type fakeRedis struct {
+	mu     sync.Mutex
+	data   map[string]string
+	expiry map[string]string // PEXPIRE milliseconds by key
+}
+
+func startFakeRedis(t *testing.T) (string, *fakeRedis) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	fake := &fakeRedis{data: map[string]string{}, expiry: map[string]string{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go fake.serve(conn)
+		}
+	}()
+	return listener.Addr().String(), fake
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		f.mu.Lock()
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			io.WriteString(conn, "+PONG\r\n")
+		case "SET":
+			if _, exists := f.data[args[1]]; exists {
+				io.WriteString(conn, "$-1\r\n")
+			} else {
+				f.data[args[1]] = args[2]
+				io.WriteString(conn, "+OK\r\n")
+			}
+		case "EVAL":
+			if args[1] == redisIncrScript {
+				n, _ := strconv.Atoi(f.data[args[3]])
+				f.data[args[3]] = strconv.Itoa(n + 1)
+				if n == 0 {
+					f.expiry[args[3]] = args[4]
+				}
+				fmt.Fprintf(conn, ":%d\r\n", n+1)
+			} else if f.data[args[3]] == args[4] {
+				delete(f.data, args[3])
+				io.WriteString(conn, ":1\r\n")
+			} else {
+				io.WriteString(conn, ":0\r\n")
+			}
+		default:
+			io.WriteString(conn, "-ERR unknown command\r\n")
+		}
+		f.mu.Unlock()
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, count)
+	for i := range args {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestRedis_TryLock(t *testing.T) {
+	addr, _ := startFakeRedis(t)
+	rc := NewRedis(addr, "", "test:", 1)
+	ctx := context.Background()
+
+	release, acquired, err := rc.TryLock(ctx, "refresh", time.Minute)
+	if err != nil || !acquired {
+		t.Fatalf("Expected lock, acquired=%v err=%v", acquired, err)
+	}
+	if _, acquired, err := rc.TryLock(ctx, "refresh", time.Minute); err != nil || acquired {
+		t.Errorf("Expected lock to be held, acquired=%v err=%v", acquired, err)
+	}
+
+	release()
+	if _, acquired, _ := rc.TryLock(ctx, "refresh", time.Minute); !acquired {
+		t.Error("Expected lock to be free after release")
+	}
+}
+
+func TestRedis_IncrAndPing(t *testing.T) {
+	addr, fake := startFakeRedis(t)
+	rc := NewRedis(addr, "", "test:", 1)
+	ctx := context.Background()
+
+	if err := rc.Ping(ctx); err != nil {
+		t.Fatalf("Expected ping to succeed, got %v", err)
+	}
+
+	rc.Incr(ctx, "calls", time.Hour)
+	count, err := rc.Incr(ctx, "calls", time.Hour)
+	if err != nil || count != 2 {
+		t.Errorf("Expected 2, got %d (%v)", count, err)
+	}
+
+	// The expiry is set with the first increment, in the same command
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.expiry) != 1 {
+		t.Fatalf("Expected one counter with an expiry, got %v", fake.expiry)
+	}
+	for _, ms := range fake.expiry {
+		if ms != strconv.FormatInt((2*time.Hour).Milliseconds(), 10) {
+			t.Errorf("Expected the counter to expire after two windows, got %sms", ms)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+// Package dashboard serves a single-page dashboard for trying the API in a browser
+package dashboard
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// page is the dashboard; it only calls the public /v1 API, so it needs no server-side state
+//
+//go:embed index.html
+var page []byte
+
+// Page serves the dashboard
+func Page(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Write(page)
+}
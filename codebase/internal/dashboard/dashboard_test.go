@@ -0,0 +1,25 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPage(t *testing.T) {
+	w := httptest.NewRecorder()
+	Page(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Expected text/html, got %q", ct)
+	}
+	for _, call := range []string{`"v1/weather?"`, `"v1/weather/stream?"`} {
+		if !strings.Contains(w.Body.String(), call) {
+			t.Errorf("Expected the page to call %s", call)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// lookupTimeout bounds background refreshes, which have no request context to inherit
+const lookupTimeout = 5 * time.Second
+
+type entry struct {
+	addrs      []string
+	resolvedAt time.Time
+	refreshing bool
+}
+
+// Resolver caches host lookups in-process so cold upstream connections don't pay resolver latency
+// Expired entries are served while a background lookup refreshes them, and a failed refresh keeps
+// the last known addresses, so a short DNS outage doesn't turn into failed requests.
+// Go's resolver doesn't expose record TTLs, so entries live for a configured TTL instead.
+type Resolver struct {
+	ttl    time.Duration
+	lookup func(ctx context.Context, host string) ([]string, error)
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	logger  *slog.Logger
+}
+
+// New creates a Resolver that re-resolves hosts after ttlSec seconds
+func New(ttlSec int, logger *slog.Logger) *Resolver {
+	return &Resolver{
+		ttl:     time.Duration(ttlSec) * time.Second,
+		lookup:  net.DefaultResolver.LookupHost,
+		now:     time.Now,
+		entries: make(map[string]*entry),
+		logger:  logger,
+	}
+}
+
+// LookupHost returns the addresses for host, from cache when possible
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.mu.Lock()
+	cached, ok := r.entries[host]
+	if ok {
+		addrs := cached.addrs
+		if r.now().Sub(cached.resolvedAt) >= r.ttl && !cached.refreshing {
+			cached.refreshing = true
+			go r.refresh(host)
+		}
+		r.mu.Unlock()
+		return addrs, nil
+	}
+	r.mu.Unlock()
+
+	addrs, err := r.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.entries[host] = &entry{addrs: addrs, resolvedAt: r.now()}
+	r.mu.Unlock()
+	return addrs, nil
+}
+
+// DialContext returns a dial function for http.Transport that resolves through the cache and tries
+// each address in turn
+func (r *Resolver) DialContext(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, address)
+		}
+
+		// The dialer only sees IP addresses, so report our lookup to client traces ourselves
+		trace := httptrace.ContextClientTrace(ctx)
+		if trace != nil && trace.DNSStart != nil {
+			trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+		}
+		addrs, err := r.LookupHost(ctx, host)
+		if trace != nil && trace.DNSDone != nil {
+			trace.DNSDone(httptrace.DNSDoneInfo{Err: err})
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var dialErrs []error
+		for _, addr := range addrs {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+			if err == nil {
+				return conn, nil
+			}
+			dialErrs = append(dialErrs, err)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, errors.Join(dialErrs...)
+	}
+}
+
+// refresh re-resolves host in the background, keeping the old addresses if the lookup fails
+func (r *Resolver) refresh(host string) {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	addrs, err := r.lookup(ctx, host)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cached := r.entries[host]
+	cached.refreshing = false
+	if err != nil {
+		r.logger.Warn("dns refresh failed, keeping cached addresses", slog.String("host", host), slog.String("error", err.Error()))
+		return
+	}
+	cached.addrs = addrs
+	cached.resolvedAt = r.now()
+}
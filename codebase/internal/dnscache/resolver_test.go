@@ -0,0 +1,76 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedLookup returns the configured answer and counts calls
+type scriptedLookup struct {
+	mu    sync.Mutex
+	calls int
+	addrs []string
+	err   error
+	done  chan struct{}
+}
+
+func (s *scriptedLookup) lookup(ctx context.Context, host string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.done != nil {
+		defer close(s.done)
+		s.done = nil
+	}
+	return s.addrs, s.err
+}
+
+func TestResolver_CachesWithinTTL(t *testing.T) {
+	script := &scriptedLookup{addrs: []string{"10.0.0.1"}}
+	resolver := New(60, slog.Default())
+	resolver.lookup = script.lookup
+
+	for i := 0; i < 3; i++ {
+		addrs, err := resolver.LookupHost(context.Background(), "api.example.com")
+		if err != nil || addrs[0] != "10.0.0.1" {
+			t.Fatalf("Unexpected result %v (%v)", addrs, err)
+		}
+	}
+	if script.calls != 1 {
+		t.Errorf("Expected 1 lookup, got %d", script.calls)
+	}
+}
+
+func TestResolver_KeepsAddressesWhenRefreshFails(t *testing.T) {
+	script := &scriptedLookup{addrs: []string{"10.0.0.1"}}
+	resolver := New(60, slog.Default())
+	resolver.lookup = script.lookup
+	now := time.Now()
+	resolver.now = func() time.Time { return now }
+
+	resolver.LookupHost(context.Background(), "api.example.com")
+
+	// Expired: the cached answer is served and the failing refresh happens in the background
+	script.mu.Lock()
+	script.err = errors.New("dns blip")
+	script.addrs = nil
+	done := make(chan struct{})
+	script.done = done
+	script.mu.Unlock()
+	now = now.Add(2 * time.Minute)
+
+	addrs, err := resolver.LookupHost(context.Background(), "api.example.com")
+	if err != nil || len(addrs) != 1 {
+		t.Fatalf("Expected cached addresses, got %v (%v)", addrs, err)
+	}
+	<-done
+
+	addrs, err = resolver.LookupHost(context.Background(), "api.example.com")
+	if err != nil || len(addrs) != 1 || addrs[0] != "10.0.0.1" {
+		t.Errorf("Expected last known addresses after failed refresh, got %v (%v)", addrs, err)
+	}
+}
@@ -0,0 +1,46 @@
+package errreport
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Event is one error worth alerting on
+type Event struct {
+	Err         error
+	Panic       any           // the recovered value, for panics
+	Stack       []byte        // debug.Stack() output, if known
+	Fingerprint []string      // groups events into one issue; derived from the error type if empty
+	Request     *http.Request // set by Report
+	Time        time.Time
+}
+
+// Reporter sends error events to an error tracking service
+// Report must not block: it is called on the request path
+type Reporter interface {
+	Report(ctx context.Context, event Event)
+}
+
+type reporterKey struct{}
+
+// Middleware makes reporter available to Report for everything below it
+func Middleware(reporter Reporter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), reporterKey{}, reporter)))
+	})
+}
+
+// Report hands event for request r to the reporter Middleware installed, filling in the request and time
+// It does nothing when error reporting is disabled
+func Report(r *http.Request, event Event) {
+	reporter, _ := r.Context().Value(reporterKey{}).(Reporter)
+	if reporter == nil {
+		return
+	}
+	event.Request = r
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	reporter.Report(r.Context(), event)
+}
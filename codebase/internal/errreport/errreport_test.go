@@ -0,0 +1,94 @@
+package errreport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/krizvi/weather-app-server/internal/requestid"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestReport_DisabledWithoutMiddleware(t *testing.T) {
+	// Must not panic when no reporter is installed
+	Report(httptest.NewRequest("GET", "/weather", nil), Event{Err: errors.New("boom")})
+}
+
+func TestNewSentry_InvalidDSN(t *testing.T) {
+	for _, dsn := range []string{"", "not a url", "https://sentry.example.com/42", "https://key@sentry.example.com/", "https://key@sentry.example.com/abc"} {
+		if _, err := NewSentry(dsn, "", "", slog.Default()); err == nil {
+			t.Errorf("Expected %q to be rejected", dsn)
+		}
+	}
+}
+
+func TestSentry_ReportsPanicWithRequestContext(t *testing.T) {
+	var auth string
+	var lines []string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/42/envelope/" {
+			t.Errorf("Expected the envelope endpoint, got %s", r.URL.Path)
+		}
+		auth = r.Header.Get("X-Sentry-Auth")
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+	}))
+	defer collector.Close()
+
+	sentry, err := NewSentry(strings.Replace(collector.URL, "http://", "http://public123@", 1)+"/42", "staging", "v1.2.3", slog.Default())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	handler := requestid.Middleware(Middleware(sentry, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Report(r, Event{Panic: "boom appid=secret", Stack: debug.Stack()})
+	})))
+	req := httptest.NewRequest("GET", "/weather?lat=1&lon=2&appid=secret", nil)
+	req.Header.Set(requestid.Header, "req-1")
+	req.Header.Set("Authorization", "Bearer admin-token")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sentry.Run(ctx) // sends what was queued, then returns
+
+	if !strings.Contains(auth, "sentry_key=public123") {
+		t.Errorf("Expected the DSN key in the auth header, got %q", auth)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("Expected an envelope with 3 lines, got %d", len(lines))
+	}
+	if strings.Contains(lines[2], "secret") || strings.Contains(lines[2], "admin-token") {
+		t.Errorf("Expected secrets to be filtered, got %s", lines[2])
+	}
+
+	var event sentryEvent
+	if err := json.Unmarshal([]byte(lines[2]), &event); err != nil {
+		t.Fatalf("Expected a JSON event, got %v", err)
+	}
+	if event.Level != "fatal" || event.Environment != "staging" || event.Release != "v1.2.3" || event.Tags["request_id"] != "req-1" {
+		t.Errorf("Expected a fatal staging event for req-1, got %+v", event)
+	}
+	frames := event.Exception.Values[0].Stacktrace.Frames
+	if last := frames[len(frames)-1]; !strings.Contains(last.Function, "debug.Stack") || last.Lineno == 0 {
+		t.Errorf("Expected the innermost frame last, got %+v", last)
+	}
+}
+
+func TestSentry_DropsWhenQueueIsFull(t *testing.T) {
+	sentry, _ := NewSentry("https://key@sentry.example.com/1", "", "", slog.Default())
+	for i := 0; i < sentryQueueSize+5; i++ {
+		sentry.Report(context.Background(), Event{Err: errors.New("boom")})
+	}
+	if sentry.Dropped() != 5 {
+		t.Errorf("Expected 5 dropped events, got %d", sentry.Dropped())
+	}
+}
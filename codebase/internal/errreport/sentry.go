@@ -0,0 +1,272 @@
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/requestid"
+	"github.com/krizvi/weather-app-server/internal/route"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	sentryQueueSize = 100
+	sentryTimeout   = 10 * time.Second
+)
+
+// Sentry reports events to Sentry (or a compatible service) through its envelope API
+// Events are queued and sent by Run; when the queue is full they are dropped and counted
+type Sentry struct {
+	url         string
+	auth        string
+	environment string
+	release     string
+	serverName  string
+	client      *http.Client
+	queue       chan []byte
+	dropped     atomic.Uint64
+	logger      *slog.Logger
+}
+
+// NewSentry creates a Sentry reporter from a DSN (https://<public key>@<host>/<project id>)
+func NewSentry(dsn, environment, release string, logger *slog.Logger) (*Sentry, error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" || parsed.User == nil || parsed.User.Username() == "" {
+		return nil, errors.New("invalid Sentry DSN, expected https://<key>@<host>/<project id>")
+	}
+	path, project, _ := strings.Cut(strings.Trim(parsed.Path, "/"), "/")
+	if project == "" {
+		path, project = "", path
+	}
+	if _, err := strconv.Atoi(project); err != nil {
+		return nil, fmt.Errorf("invalid Sentry DSN project id %q", project)
+	}
+	if path != "" {
+		path = "/" + path
+	}
+
+	serverName, _ := os.Hostname()
+	return &Sentry{
+		url:         fmt.Sprintf("%s://%s%s/api/%s/envelope/", parsed.Scheme, parsed.Host, path, project),
+		auth:        "Sentry sentry_version=7, sentry_client=weather-api-server, sentry_key=" + parsed.User.Username(),
+		environment: environment,
+		release:     release,
+		serverName:  serverName,
+		client:      &http.Client{Timeout: sentryTimeout},
+		queue:       make(chan []byte, sentryQueueSize),
+		logger:      logger,
+	}, nil
+}
+
+// Dropped returns how many events were discarded because the queue was full
+func (s *Sentry) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Report encodes event right away, while its request is still valid, and queues it for Run
+func (s *Sentry) Report(ctx context.Context, event Event) {
+	envelope, err := s.encode(event)
+	if err != nil {
+		s.logger.WarnContext(ctx, "error event encoding failed", slog.String("error", err.Error()))
+		return
+	}
+	select {
+	case s.queue <- envelope:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Run sends queued events until ctx is canceled, then sends what is left
+func (s *Sentry) Run(ctx context.Context) {
+	for {
+		select {
+		case envelope := <-s.queue:
+			// Not bound to ctx: an event being sent when shutdown starts is still delivered
+			s.sendLogged(context.Background(), envelope)
+		case <-ctx.Done():
+			finalCtx, cancel := context.WithTimeout(context.Background(), sentryTimeout)
+			defer cancel()
+			for len(s.queue) > 0 {
+				s.sendLogged(finalCtx, <-s.queue)
+			}
+			return
+		}
+	}
+}
+
+func (s *Sentry) sendLogged(ctx context.Context, envelope []byte) {
+	if err := s.send(ctx, envelope); err != nil {
+		s.logger.Warn("error event delivery failed", slog.String("error", err.Error()))
+	}
+}
+
+// send posts one envelope
+func (s *Sentry) send(ctx context.Context, envelope []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(envelope))
+	if err != nil {
+		return fmt.Errorf("failed to create event request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", s.auth)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error tracker answered %s", resp.Status)
+	}
+	return nil
+}
+
+// sentryEvent is the subset of the Sentry event payload we fill in
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Fingerprint []string          `json:"fingerprint,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Request     *sentryRequest    `json:"request,omitempty"`
+	Exception   struct {
+		Values []sentryException `json:"values"`
+	} `json:"exception"`
+}
+
+type sentryRequest struct {
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	QueryString string            `json:"query_string,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+type sentryException struct {
+	Type       string            `json:"type"`
+	Value      string            `json:"value"`
+	Stacktrace *sentryStacktrace `json:"stacktrace,omitempty"`
+}
+
+type sentryStacktrace struct {
+	Frames []sentryFrame `json:"frames"`
+}
+
+type sentryFrame struct {
+	Function string `json:"function"`
+	Filename string `json:"filename"`
+	Lineno   int    `json:"lineno"`
+}
+
+// encode builds the envelope (header, item header, event) for event
+func (s *Sentry) encode(event Event) ([]byte, error) {
+	var id [16]byte
+	rand.Read(id[:])
+
+	payload := sentryEvent{
+		EventID:     hex.EncodeToString(id[:]),
+		Timestamp:   event.Time.UTC().Format(time.RFC3339Nano),
+		Platform:    "go",
+		Level:       "error",
+		ServerName:  s.serverName,
+		Environment: s.environment,
+		Release:     s.release,
+		Fingerprint: event.Fingerprint,
+	}
+
+	exception := sentryException{Type: strings.Join(event.Fingerprint, " ")}
+	switch {
+	case event.Panic != nil:
+		payload.Level = "fatal"
+		exception.Type = "panic"
+		exception.Value = scrub(fmt.Sprint(event.Panic))
+	case event.Err != nil:
+		if exception.Type == "" {
+			exception.Type = fmt.Sprintf("%T", event.Err)
+		}
+		exception.Value = scrub(event.Err.Error())
+	}
+	if frames := parseStack(event.Stack); len(frames) > 0 {
+		exception.Stacktrace = &sentryStacktrace{Frames: frames}
+	}
+	payload.Exception.Values = []sentryException{exception}
+
+	if r := event.Request; r != nil {
+		payload.Request = &sentryRequest{
+			Method:      r.Method,
+			URL:         scheme(r) + "://" + r.Host + r.URL.Path,
+			QueryString: scrub(r.URL.RawQuery),
+			Headers:     map[string]string{"User-Agent": r.UserAgent()}, // never Authorization
+		}
+		payload.Tags = map[string]string{"route": route.Pattern(r)}
+		if id := requestid.FromContext(r.Context()); id != "" {
+			payload.Tags["request_id"] = id
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var envelope bytes.Buffer
+	fmt.Fprintf(&envelope, "{\"event_id\":%q}\n{\"type\":\"event\",\"length\":%d}\n", payload.EventID, len(body))
+	envelope.Write(body)
+	envelope.WriteByte('\n')
+	return envelope.Bytes(), nil
+}
+
+func scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// secretPattern matches credentials that can end up in error messages, e.g. the upstream URL's appid
+var secretPattern = regexp.MustCompile(`(?i)(appid|api_?key|token|password)=[^&\s"]+`)
+
+func scrub(s string) string {
+	return secretPattern.ReplaceAllString(s, "$1=[Filtered]")
+}
+
+// parseStack turns debug.Stack output into Sentry frames, oldest call first
+// The output is a "goroutine N [running]:" line followed by function / "\tfile:line +0x.." line pairs
+func parseStack(stack []byte) []sentryFrame {
+	lines := strings.Split(strings.TrimSpace(string(stack)), "\n")
+	var frames []sentryFrame
+	for i := 1; i+1 < len(lines); i += 2 {
+		function := lines[i]
+		if paren := strings.LastIndex(function, "("); paren > 0 {
+			function = function[:paren]
+		}
+		location := strings.TrimSpace(lines[i+1])
+		if space := strings.LastIndex(location, " "); space > 0 {
+			location = location[:space]
+		}
+		file, line, _ := strings.Cut(location, ":")
+		lineno, _ := strconv.Atoi(line)
+		frames = append(frames, sentryFrame{Function: function, Filename: file, Lineno: lineno})
+	}
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return frames
+}
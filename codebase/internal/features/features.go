@@ -0,0 +1,99 @@
+package features
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Flags holds the on/off state of named features, so behaviors can be rolled out per environment and switched
+// without a redeploy
+// Reads are lock-free; Set replaces every flag at once, e.g. on a configuration reload
+type Flags struct {
+	defaults map[string]bool
+	state    atomic.Pointer[map[string]bool]
+}
+
+// New creates Flags for the known features, each starting at its default
+func New(defaults map[string]bool) *Flags {
+	f := &Flags{defaults: maps.Clone(defaults)}
+	f.state.Store(&f.defaults)
+	return f
+}
+
+// Parse reads feature settings like "hedging,caching=off": a bare name turns a feature on, name=value sets it
+// to a boolean or on/off
+// It returns the state of every known feature, with the unnamed ones at their defaults
+func (f *Flags) Parse(spec []string) (map[string]bool, error) {
+	state := maps.Clone(f.defaults)
+	for _, entry := range spec {
+		name, value, hasValue := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if _, ok := f.defaults[name]; !ok {
+			return nil, fmt.Errorf("unknown feature %q, known features are %s", name, strings.Join(f.Names(), ", "))
+		}
+		enabled := true
+		if hasValue {
+			var err error
+			if enabled, err = parseBool(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("feature %s must be on or off, got %q", name, value)
+			}
+		}
+		state[name] = enabled
+	}
+	return state, nil
+}
+
+// Set applies the settings in spec (see Parse), resetting the features it doesn't name to their defaults
+func (f *Flags) Set(spec []string) error {
+	state, err := f.Parse(spec)
+	if err != nil {
+		return err
+	}
+	f.state.Store(&state)
+	return nil
+}
+
+// Switch turns the feature called name on or off, until the next Set
+func (f *Flags) Switch(name string, enabled bool) error {
+	if _, ok := f.defaults[name]; !ok {
+		return fmt.Errorf("unknown feature %q, known features are %s", name, strings.Join(f.Names(), ", "))
+	}
+	for {
+		current := f.state.Load()
+		state := maps.Clone(*current)
+		state[name] = enabled
+		if f.state.CompareAndSwap(current, &state) {
+			return nil
+		}
+	}
+}
+
+// Enabled reports whether the feature called name is on; unknown features are off
+func (f *Flags) Enabled(name string) bool {
+	return (*f.state.Load())[name]
+}
+
+// All returns the state of every known feature
+func (f *Flags) All() map[string]bool {
+	return maps.Clone(*f.state.Load())
+}
+
+// Names lists the known features, sorted
+func (f *Flags) Names() []string {
+	return slices.Sorted(maps.Keys(f.defaults))
+}
+
+// parseBool accepts on/off besides the values of strconv.ParseBool
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
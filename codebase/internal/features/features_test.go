@@ -0,0 +1,55 @@
+package features
+
+import (
+	"testing"
+)
+
+func TestFlags_DefaultsUntilSet(t *testing.T) {
+	flags := New(map[string]bool{"caching": true, "hedging": false})
+	if !flags.Enabled("caching") || flags.Enabled("hedging") {
+		t.Errorf("Expected the defaults, got %v", flags.All())
+	}
+
+	if err := flags.Set([]string{"hedging", "caching=off"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if flags.Enabled("caching") || !flags.Enabled("hedging") {
+		t.Errorf("Expected caching off and hedging on, got %v", flags.All())
+	}
+
+	// Features no longer named go back to their defaults
+	if err := flags.Set(nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !flags.Enabled("caching") || flags.Enabled("hedging") {
+		t.Errorf("Expected the defaults again, got %v", flags.All())
+	}
+}
+
+func TestFlags_RejectsInvalidSettings(t *testing.T) {
+	flags := New(map[string]bool{"caching": true})
+	for _, spec := range [][]string{{"cachign"}, {"caching=maybe"}} {
+		if err := flags.Set(spec); err == nil {
+			t.Errorf("Expected an error for %v", spec)
+		}
+	}
+	if !flags.Enabled("caching") {
+		t.Error("Expected an invalid setting to leave the flags unchanged")
+	}
+	if flags.Enabled("unknown") {
+		t.Error("Expected unknown features to be off")
+	}
+}
+
+func TestFlags_Switch(t *testing.T) {
+	flags := New(map[string]bool{"caching": true, "hedging": false})
+	if err := flags.Switch("hedging", true); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !flags.Enabled("caching") || !flags.Enabled("hedging") {
+		t.Errorf("Expected only hedging to change, got %v", flags.All())
+	}
+	if err := flags.Switch("unknown", true); err == nil {
+		t.Error("Expected an error for an unknown feature")
+	}
+}
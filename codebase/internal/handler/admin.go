@@ -0,0 +1,291 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/analytics"
+	"github.com/krizvi/weather-app-server/internal/audit"
+	"github.com/krizvi/weather-app-server/internal/cache"
+	"github.com/krizvi/weather-app-server/internal/features"
+	"github.com/krizvi/weather-app-server/internal/metering"
+	"github.com/krizvi/weather-app-server/internal/middleware"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AdminHandler serves operator-only endpoints under /admin
+type AdminHandler struct {
+	cache       cache.Cache
+	breaker     *service.CircuitBreakerService
+	maintenance *middleware.MaintenanceMode
+	drainer     *middleware.Drainer
+	analytics   *analytics.Store // optional, set by UseAnalytics
+	features    *features.Flags  // optional, set by UseFeatures
+	meter       *metering.Meter  // optional, set by UseMetering
+	rotateKey   KeyRotator       // optional, set by UseKeyRotator
+	reload      func() error     // optional, set by UseReloader
+	logger      *slog.Logger
+}
+
+// NewAdmin creates a new AdminHandler for the given cache, upstream circuit breaker, maintenance switch and drainer
+func NewAdmin(c cache.Cache, breaker *service.CircuitBreakerService, maintenance *middleware.MaintenanceMode, drainer *middleware.Drainer, logger *slog.Logger) *AdminHandler {
+	return &AdminHandler{cache: c, breaker: breaker, maintenance: maintenance, drainer: drainer, logger: logger}
+}
+
+// UseAnalytics serves the usage report of store
+func (ah *AdminHandler) UseAnalytics(store *analytics.Store) {
+	ah.analytics = store
+}
+
+// AnalyticsTop handles GET requests to /admin/analytics/top
+// ?window= (a duration, default 24h) sets how far back to look and ?limit= (default 10) how many locations to list
+func (ah *AdminHandler) AnalyticsTop(w http.ResponseWriter, r *http.Request) {
+	window := 24 * time.Hour
+	if value := r.URL.Query().Get("window"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			sendErrorResponse(w, r, ah.logger, http.StatusBadRequest, CodeInvalidRequest, "window must be a duration, e.g. 24h")
+			return
+		}
+		window = parsed
+	}
+	limit := 10
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 1000 {
+			sendErrorResponse(w, r, ah.logger, http.StatusBadRequest, CodeInvalidRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = parsed
+	}
+
+	report, err := ah.analytics.Top(window, limit)
+	if err != nil {
+		sendErrorResponse(w, r, ah.logger, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	sendJSONResponse(w, r, ah.logger, http.StatusOK, report)
+}
+
+// UseMetering serves the per-key usage counted by meter
+func (ah *AdminHandler) UseMetering(meter *metering.Meter) {
+	ah.meter = meter
+}
+
+// Usage handles GET requests to /admin/usage, listing requests and upstream calls per API key per day,
+// as JSON or, for billing tools, CSV
+// ?days= (default and at most the retention) sets how many days to list, today included, and ?key= lists
+// one key only
+func (ah *AdminHandler) Usage(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiate(w, r, ah.logger, usageFormats)
+	if !ok {
+		return
+	}
+	days := ah.meter.Retention()
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > days {
+			sendErrorResponse(w, r, ah.logger, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("days must be between 1 and %d", days))
+			return
+		}
+		days = parsed
+	}
+	sendResponse(w, r, ah.logger, http.StatusOK, format, metering.Report(ah.meter.Usage(days, r.URL.Query().Get("key"))))
+}
+
+// UseReloader makes ReloadConfig call reload
+func (ah *AdminHandler) UseReloader(reload func() error) {
+	ah.reload = reload
+}
+
+// ReloadConfig handles POST requests to /admin/config/reload, applying the settings that can change at runtime
+func (ah *AdminHandler) ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	if err := ah.reload(); err != nil {
+		ah.logger.ErrorContext(r.Context(), "configuration reload failed", slog.String("error", err.Error()))
+		sendErrorResponse(w, r, ah.logger, http.StatusUnprocessableEntity, CodeInvalidRequest, "Configuration not reloaded: "+err.Error())
+		return
+	}
+	sendJSONResponse(w, r, ah.logger, http.StatusOK, map[string]string{"status": "reloaded"})
+}
+
+// UseFeatures makes Features report and switch flags
+func (ah *AdminHandler) UseFeatures(flags *features.Flags) {
+	ah.features = flags
+}
+
+// Features handles /admin/features
+// GET reports the state of every feature flag; POST ?name=..&enabled=true|false switches one until the next
+// configuration reload
+func (ah *AdminHandler) Features(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		name := r.URL.Query().Get("name")
+		enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+		if err != nil {
+			sendErrorResponse(w, r, ah.logger, http.StatusBadRequest, CodeInvalidRequest, "enabled must be true or false")
+			return
+		}
+		if err := ah.features.Switch(name, enabled); err != nil {
+			sendErrorResponse(w, r, ah.logger, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+		ah.logger.WarnContext(r.Context(), "feature flag switched", slog.String("feature", name), slog.Bool("enabled", enabled))
+	}
+
+	sendJSONResponse(w, r, ah.logger, http.StatusOK, ah.features.All())
+}
+
+// KeyRotator checks new upstream API keys and switches to them; an empty hedgeAPIKey leaves the hedge
+// provider's key as configured
+type KeyRotator func(ctx context.Context, apiKey, hedgeAPIKey string) error
+
+// UseKeyRotator makes RotateAPIKey call rotate
+func (ah *AdminHandler) UseKeyRotator(rotate KeyRotator) {
+	ah.rotateKey = rotate
+}
+
+// apiKeyRotation is the body of POST /admin/upstream/api-key
+type apiKeyRotation struct {
+	APIKey      string `json:"api_key"`
+	HedgeAPIKey string `json:"hedge_api_key"`
+}
+
+// RotateAPIKey handles POST requests to /admin/upstream/api-key with a JSON body of {"api_key": "...",
+// "hedge_api_key": "..."} (the hedge key is optional), switching the provider keys without a restart
+func (ah *AdminHandler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var rotation apiKeyRotation
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&rotation); err != nil || rotation.APIKey == "" {
+		sendErrorResponse(w, r, ah.logger, http.StatusBadRequest, CodeInvalidRequest, `Body must be JSON with a non-empty "api_key"`)
+		return
+	}
+	if err := ah.rotateKey(r.Context(), rotation.APIKey, rotation.HedgeAPIKey); err != nil {
+		ah.logger.ErrorContext(r.Context(), "API key rotation failed", slog.String("error", err.Error()))
+		sendErrorResponse(w, r, ah.logger, http.StatusUnprocessableEntity, CodeInvalidRequest, "API key not rotated: "+err.Error())
+		return
+	}
+	ah.logger.WarnContext(r.Context(), "API key rotated through the admin API", slog.Bool("hedge", rotation.HedgeAPIKey != ""))
+	sendJSONResponse(w, r, ah.logger, http.StatusOK, map[string]string{"status": "rotated"})
+}
+
+// CacheStats handles GET requests to /admin/cache/stats
+func (ah *AdminHandler) CacheStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := ah.cache.Stats(r.Context())
+	if err != nil {
+		ah.logger.ErrorContext(r.Context(), "cache stats failed", slog.String("error", err.Error()))
+		sendErrorResponse(w, r, ah.logger, http.StatusInternalServerError, CodeInternalError, "Unable to read cache stats")
+		return
+	}
+
+	sendJSONResponse(w, r, ah.logger, http.StatusOK, stats)
+}
+
+// CacheFlush handles POST requests to /admin/cache/flush
+// The flush can be scoped with ?lat=..&lon=.. (a single location's weather and forecast, any of their aliases) or ?prefix=..
+// (raw key prefix, which backends that can't list their keys refuse)
+func (ah *AdminHandler) CacheFlush(w http.ResponseWriter, r *http.Request) {
+	if hasCoordinateParams(r.URL.Query()) {
+		lat, lon, err := parseCoordinates(r)
+		if err != nil {
+			sendErrorResponse(w, r, ah.logger, http.StatusBadRequest, CodeInvalidCoordinates, err.Error())
+			return
+		}
+		// The location's forecast goes too, so the forecast feed doesn't keep serving what was flushed
+		key := service.CacheKey(lat, lon)
+		removed := 0
+		for _, locationKey := range []string{key, service.ForecastCacheKey(lat, lon)} {
+			deleted, err := ah.cache.Delete(r.Context(), locationKey)
+			if err != nil {
+				ah.logger.ErrorContext(r.Context(), "cache flush failed", slog.String("key", locationKey), slog.String("error", err.Error()))
+				sendErrorResponse(w, r, ah.logger, http.StatusInternalServerError, CodeInternalError, "Unable to flush cache")
+				return
+			}
+			ah.logger.InfoContext(r.Context(), "cache entry flushed", slog.String("key", locationKey), slog.Bool("deleted", deleted))
+			removed += boolCount(deleted)
+		}
+		sendJSONResponse(w, r, ah.logger, http.StatusOK, map[string]interface{}{
+			"prefix":  key,
+			"removed": removed,
+		})
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	removed, err := ah.cache.Flush(r.Context(), prefix)
+	if errors.Is(err, cache.ErrPrefixFlushUnsupported) {
+		sendErrorResponse(w, r, ah.logger, http.StatusBadRequest, CodeInvalidRequest, "This cache backend can only flush a location or everything")
+		return
+	}
+	if err != nil {
+		ah.logger.ErrorContext(r.Context(), "cache flush failed", slog.String("prefix", prefix), slog.String("error", err.Error()))
+		sendErrorResponse(w, r, ah.logger, http.StatusInternalServerError, CodeInternalError, "Unable to flush cache")
+		return
+	}
+
+	ah.logger.InfoContext(r.Context(), "cache flushed", slog.String("prefix", prefix), slog.Int("removed", removed))
+	sendJSONResponse(w, r, ah.logger, http.StatusOK, map[string]interface{}{
+		"prefix":  prefix,
+		"removed": removed,
+	})
+}
+
+// UpstreamBreaker handles GET requests to /admin/upstream/breaker
+func (ah *AdminHandler) UpstreamBreaker(w http.ResponseWriter, r *http.Request) {
+	sendJSONResponse(w, r, ah.logger, http.StatusOK, ah.breaker.Stats())
+}
+
+// Maintenance handles /admin/maintenance
+// GET reports the current state; POST ?enabled=true|false[&message=..] switches it
+func (ah *AdminHandler) Maintenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+		if err != nil {
+			sendErrorResponse(w, r, ah.logger, http.StatusBadRequest, CodeInvalidRequest, "enabled must be true or false")
+			return
+		}
+		ah.maintenance.Set(enabled, r.URL.Query().Get("message"))
+		ah.logger.WarnContext(r.Context(), "maintenance mode changed", slog.Bool("enabled", enabled))
+	}
+
+	enabled, message := ah.maintenance.Status()
+	sendJSONResponse(w, r, ah.logger, http.StatusOK, map[string]interface{}{
+		"enabled": enabled,
+		"message": message,
+	})
+}
+
+// Drain handles POST requests to /admin/drain
+// Health checks start failing and new requests are refused while in-flight requests complete,
+// so an orchestrator can take the instance out of the load balancer before sending SIGTERM
+func (ah *AdminHandler) Drain(w http.ResponseWriter, r *http.Request) {
+	ah.drainer.Drain()
+	ah.logger.WarnContext(r.Context(), "draining requested through the admin API")
+	sendJSONResponse(w, r, ah.logger, http.StatusAccepted, map[string]string{"status": "draining"})
+}
+
+// RequireAdmin wraps a handler so it is only reachable with "Authorization: Bearer <token>"
+func RequireAdmin(token string, logger *slog.Logger, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isAdmin(r, token) {
+			sendErrorResponse(w, r, logger, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+			return
+		}
+		audit.SetCaller(r.Context(), "admin")
+		next(w, r)
+	}
+}
+
+// isAdmin reports whether the request carries the admin bearer token
+// An empty token never matches so admin access can't be enabled by accident
+func isAdmin(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
@@ -0,0 +1,291 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/krizvi/weather-app-server/internal/analytics"
+	"github.com/krizvi/weather-app-server/internal/cache"
+	"github.com/krizvi/weather-app-server/internal/features"
+	"github.com/krizvi/weather-app-server/internal/metering"
+	"github.com/krizvi/weather-app-server/internal/middleware"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	admin := NewAdmin(cache.NewMemory(0), nil, nil, nil, slog.Default())
+	protected := RequireAdmin("secret", slog.Default(), admin.CacheStats)
+
+	req := httptest.NewRequest("GET", "/admin/cache/stats", nil)
+	w := httptest.NewRecorder()
+	protected(w, req)
+	if w.Code != 401 {
+		t.Errorf("Expected 401 without token, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/admin/cache/stats", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	protected(w, req)
+	if w.Code != 200 {
+		t.Errorf("Expected 200 with token, got %d", w.Code)
+	}
+}
+
+func TestAdminHandler_CacheFlushLocation(t *testing.T) {
+	c := cache.NewMemory(0)
+	ctx := context.Background()
+	entry := &cache.Entry{Value: []byte("{}"), ExpiresAt: time.Now().Add(time.Minute)}
+	c.Set(ctx, service.CacheKey(40.7, -74.0), entry, time.Minute)
+	c.Set(ctx, service.ForecastCacheKey(40.7, -74.0), entry, time.Minute)
+	c.Set(ctx, service.CacheKey(51.5, -0.12), entry, time.Minute)
+
+	admin := NewAdmin(c, nil, nil, nil, slog.Default())
+	req := httptest.NewRequest("POST", "/admin/cache/flush?lat=40.7&lon=-74.0", nil)
+	w := httptest.NewRecorder()
+	admin.CacheFlush(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	var body struct {
+		Removed int `json:"removed"`
+	}
+	json.NewDecoder(w.Body).Decode(&body)
+	if body.Removed != 2 {
+		t.Errorf("Expected the weather and forecast entries removed, got %d", body.Removed)
+	}
+
+	stats, _ := c.Stats(ctx)
+	if stats.Entries != 1 {
+		t.Errorf("Expected 1 entry left, got %d", stats.Entries)
+	}
+	if _, found, _ := c.Get(ctx, service.ForecastCacheKey(40.7, -74.0)); found {
+		t.Error("Expected the location's forecast to be flushed")
+	}
+}
+
+func TestAdminHandler_CacheFlushLocationAliases(t *testing.T) {
+	c := cache.NewMemory(0)
+	ctx := context.Background()
+	entry := &cache.Entry{Value: []byte("{}"), ExpiresAt: time.Now().Add(time.Minute)}
+	c.Set(ctx, service.CacheKey(40.7, -74.0), entry, time.Minute)
+	c.Set(ctx, service.CacheKey(51.5, -0.12), entry, time.Minute)
+	admin := NewAdmin(c, nil, nil, nil, slog.Default())
+
+	for _, query := range []string{"latitude=40.7&longitude=-74.0", "lat=40.7&lng=-74.0", "long=-74.0"} {
+		w := httptest.NewRecorder()
+		admin.CacheFlush(w, httptest.NewRequest("POST", "/admin/cache/flush?"+query, nil))
+		if stats, _ := c.Stats(ctx); stats.Entries == 0 {
+			t.Fatalf("%s: expected a scoped flush, got the whole cache flushed", query)
+		}
+	}
+	if stats, _ := c.Stats(ctx); stats.Entries != 1 {
+		t.Errorf("Expected only the 40.7,-74.0 entry removed, got %d entries left", stats.Entries)
+	}
+
+	w := httptest.NewRecorder()
+	admin.CacheFlush(w, httptest.NewRequest("POST", "/admin/cache/flush?long=-74.0", nil))
+	if w.Code != 400 {
+		t.Errorf("Expected 400 for a lone longitude, got %d", w.Code)
+	}
+}
+
+func TestAdminHandler_UpstreamBreaker(t *testing.T) {
+	admin := NewAdmin(nil, service.NewCircuitBreaker(&MockWeatherService{}, 5, 30, slog.Default()), nil, nil, slog.Default())
+
+	req := httptest.NewRequest("GET", "/admin/upstream/breaker", nil)
+	w := httptest.NewRecorder()
+	admin.UpstreamBreaker(w, req)
+
+	var stats service.BreakerStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Expected JSON stats, got %q", w.Body.String())
+	}
+	if stats.State != service.BreakerClosed {
+		t.Errorf("Expected closed breaker, got %q", stats.State)
+	}
+}
+
+func TestAdminHandler_Maintenance(t *testing.T) {
+	maintenance := middleware.NewMaintenanceMode(false, "Down for maintenance", 300)
+	admin := NewAdmin(nil, nil, maintenance, nil, slog.Default())
+	health := NewHealth(maintenance, nil, slog.Default())
+
+	w := httptest.NewRecorder()
+	admin.Maintenance(w, httptest.NewRequest("POST", "/admin/maintenance?enabled=true&message=Rotating+keys", nil))
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if enabled, message := maintenance.Status(); !enabled || message != "Rotating keys" {
+		t.Errorf("Expected maintenance on with new message, got %v %q", enabled, message)
+	}
+
+	// Health checks fail so load balancers drain the instance
+	w = httptest.NewRecorder()
+	health.HealthCheck(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != 503 {
+		t.Errorf("Expected health check to fail during maintenance, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	admin.Maintenance(w, httptest.NewRequest("POST", "/admin/maintenance?enabled=false", nil))
+	if enabled, _ := maintenance.Status(); enabled {
+		t.Error("Expected maintenance to be switched off")
+	}
+}
+
+func TestAdminHandler_Features(t *testing.T) {
+	flags := features.New(map[string]bool{"hedging": false})
+	admin := NewAdmin(nil, nil, nil, nil, slog.Default())
+	admin.UseFeatures(flags)
+
+	w := httptest.NewRecorder()
+	admin.Features(w, httptest.NewRequest("POST", "/admin/features?name=hedging&enabled=true", nil))
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if !flags.Enabled("hedging") {
+		t.Error("Expected hedging to be switched on")
+	}
+
+	w = httptest.NewRecorder()
+	admin.Features(w, httptest.NewRequest("POST", "/admin/features?name=unknown&enabled=true", nil))
+	if w.Code != 400 {
+		t.Errorf("Expected 400 for an unknown feature, got %d", w.Code)
+	}
+}
+
+func TestAdminHandler_Drain(t *testing.T) {
+	drainer := middleware.NewDrainer(nil)
+	admin := NewAdmin(nil, nil, nil, drainer, slog.Default())
+	health := NewHealth(nil, drainer, slog.Default())
+
+	w := httptest.NewRecorder()
+	admin.Drain(w, httptest.NewRequest("POST", "/admin/drain", nil))
+	if w.Code != 202 {
+		t.Fatalf("Expected 202, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	health.HealthCheck(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != 503 {
+		t.Errorf("Expected health check to fail while draining, got %d", w.Code)
+	}
+}
+
+func TestAdminHandler_AnalyticsTop(t *testing.T) {
+	store := analytics.New(24)
+	store.RecordLocation(51.5074, -0.1278, "London", "GB")
+	store.RecordRequest("/weather")
+
+	admin := NewAdmin(nil, nil, nil, nil, slog.Default())
+	admin.UseAnalytics(store)
+
+	w := httptest.NewRecorder()
+	admin.AnalyticsTop(w, httptest.NewRequest("GET", "/admin/analytics/top?window=1h", nil))
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var report analytics.Report
+	json.NewDecoder(w.Body).Decode(&report)
+	if report.Requests != 1 || len(report.Locations) != 1 || report.Locations[0].City != "London" {
+		t.Errorf("Expected one London request, got %+v", report)
+	}
+
+	w = httptest.NewRecorder()
+	admin.AnalyticsTop(w, httptest.NewRequest("GET", "/admin/analytics/top?window=forever", nil))
+	if w.Code != 400 {
+		t.Errorf("Expected 400 for an invalid window, got %d", w.Code)
+	}
+}
+
+func TestAdminHandler_Usage(t *testing.T) {
+	meter := metering.New(7)
+	meter.Record("team-a", 1)
+	meter.Record("team-b", 0)
+
+	admin := NewAdmin(nil, nil, nil, nil, slog.Default())
+	admin.UseMetering(meter)
+
+	w := httptest.NewRecorder()
+	admin.Usage(w, httptest.NewRequest("GET", "/admin/usage?key=team-a", nil))
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var usage []metering.Usage
+	json.NewDecoder(w.Body).Decode(&usage)
+	if len(usage) != 1 || usage[0].Key != "team-a" || usage[0].Requests != 1 || usage[0].UpstreamCalls != 1 {
+		t.Errorf("Expected team-a's request and upstream call, got %+v", usage)
+	}
+
+	w = httptest.NewRecorder()
+	admin.Usage(w, httptest.NewRequest("GET", "/admin/usage?format=csv", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("Expected CSV, got %q", ct)
+	}
+	if lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); len(lines) != 3 || lines[0] != "day,key,requests,upstream_calls" {
+		t.Errorf("Expected a header and 2 rows, got %q", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	admin.Usage(w, httptest.NewRequest("GET", "/admin/usage?days=8", nil))
+	if w.Code != 400 {
+		t.Errorf("Expected 400 for more days than are kept, got %d", w.Code)
+	}
+}
+
+func TestAdminHandler_ReloadConfig(t *testing.T) {
+	var reloadErr error
+	reloads := 0
+	admin := NewAdmin(nil, nil, nil, nil, slog.Default())
+	admin.UseReloader(func() error {
+		reloads++
+		return reloadErr
+	})
+
+	w := httptest.NewRecorder()
+	admin.ReloadConfig(w, httptest.NewRequest("POST", "/admin/config/reload", nil))
+	if w.Code != 200 || reloads != 1 {
+		t.Errorf("Expected 200 after one reload, got %d after %d", w.Code, reloads)
+	}
+
+	reloadErr = errors.New("APP_SERVER_CLIENT_TIMEOUT_SEC must be at least 1")
+	w = httptest.NewRecorder()
+	admin.ReloadConfig(w, httptest.NewRequest("POST", "/admin/config/reload", nil))
+	if w.Code != 422 {
+		t.Errorf("Expected 422 for a rejected configuration, got %d", w.Code)
+	}
+}
+
+func TestAdminHandler_RotateAPIKey(t *testing.T) {
+	var rotated []string
+	admin := NewAdmin(nil, nil, nil, nil, slog.Default())
+	admin.UseKeyRotator(func(ctx context.Context, apiKey, hedgeAPIKey string) error {
+		if apiKey == "inactive" {
+			return errors.New("the provider rejected the key")
+		}
+		rotated = append(rotated, apiKey, hedgeAPIKey)
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	admin.RotateAPIKey(w, httptest.NewRequest("POST", "/admin/upstream/api-key", strings.NewReader(`{"api_key":"new-key"}`)))
+	if w.Code != 200 || len(rotated) != 2 || rotated[0] != "new-key" || rotated[1] != "" {
+		t.Errorf("Expected 200 after rotating to new-key, got %d with %v", w.Code, rotated)
+	}
+
+	for body, code := range map[string]int{`{"api_key":"inactive"}`: 422, `{"hedge_api_key":"x"}`: 400, `not json`: 400} {
+		w = httptest.NewRecorder()
+		admin.RotateAPIKey(w, httptest.NewRequest("POST", "/admin/upstream/api-key", strings.NewReader(body)))
+		if w.Code != code {
+			t.Errorf("Expected %d for %s, got %d", code, body, w.Code)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/metering"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"github.com/krizvi/weather-app-server/internal/workpool"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// batchMaxBodyBytes bounds the request body of a batch query
+const batchMaxBodyBytes = 1 << 20
+
+// BatchRequest is the body of POST /weather/batch
+type BatchRequest struct {
+	Locations []BatchLocation `json:"locations"`
+}
+
+// BatchLocation is one requested location in a batch
+type BatchLocation struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// BatchResult is the outcome for one location of a batch
+// Index refers to the position in the request so streamed results can be matched up
+type BatchResult struct {
+	Index   int                  `json:"index"`
+	Lat     float64              `json:"lat"`
+	Lon     float64              `json:"lon"`
+	Weather *service.WeatherData `json:"weather,omitempty"`
+	Error   string               `json:"error,omitempty"`
+	Code    string               `json:"code,omitempty"` // machine-readable error code, set with Error
+}
+
+// BatchHandler serves weather for many locations in one request
+type BatchHandler struct {
+	weatherService service.WeatherService
+	pool           *workpool.Pool
+	settings       *service.RuntimeSettings // time each lookup gets
+	maxLocations   int
+	concurrency    int // parallel lookups per batch, on top of the pool's global limit
+	logger         *slog.Logger
+}
+
+// NewBatch creates a new BatchHandler whose lookups run on pool
+func NewBatch(weatherService service.WeatherService, pool *workpool.Pool, externalApiTimeout, maxLocations, concurrency int, logger *slog.Logger) *BatchHandler {
+	return &BatchHandler{
+		weatherService: weatherService,
+		pool:           pool,
+		settings:       service.NewRuntimeSettings(service.RuntimeConfig{ClientTimeout: time.Duration(externalApiTimeout) * time.Second}),
+		maxLocations:   maxLocations,
+		concurrency:    concurrency,
+		logger:         logger,
+	}
+}
+
+// UseRuntimeSettings makes each lookup get the client timeout in settings as of the start of its batch,
+// instead of the one given to NewBatch
+func (bh *BatchHandler) UseRuntimeSettings(settings *service.RuntimeSettings) {
+	bh.settings = settings
+}
+
+// GetWeatherBatch handles POST requests to /weather/batch
+// With "Accept: application/x-ndjson" each result is written and flushed as soon as it is ready,
+// in completion order; otherwise a JSON array (or CSV rows, or a protobuf or MessagePack document)
+// in request order is sent once every lookup finished
+func (bh *BatchHandler) GetWeatherBatch(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiate(w, r, bh.logger, batchFormats)
+	if !ok {
+		return
+	}
+
+	batch, ok := decodeBatch(w, r, bh.logger, bh.maxLocations)
+	if !ok {
+		return
+	}
+
+	// The whole batch runs with the settings it started with
+	timeout := bh.settings.Load().ClientTimeout
+	if format == FormatNDJSON {
+		bh.streamResults(w, r, batch.Locations, timeout)
+		return
+	}
+
+	results := make([]BatchResult, len(batch.Locations))
+	for i, location := range batch.Locations {
+		results[i] = BatchResult{Index: i, Lat: location.Lat, Lon: location.Lon, Error: "Request canceled", Code: CodeCanceled}
+	}
+	bh.lookup(r.Context(), batch.Locations, timeout, func(result BatchResult) {
+		results[result.Index] = result
+	})
+	sendResponse(w, r, bh.logger, http.StatusOK, format, results)
+}
+
+// batchCSVHeader names the columns of CSV batch results, one row per location
+var batchCSVHeader = []string{
+	"index", "lat", "lon", "observation_time", "country", "city", "condition", "temperature_category",
+	"cached", "fetched_at", "age_seconds", "stale", "degraded", "error", "code",
+}
+
+// encodeBatchCSV encodes batch results as CSV with a header row
+// Weather columns are empty for failed locations and error columns are empty for the others
+func encodeBatchCSV(results []BatchResult) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(batchCSVHeader)
+	for _, result := range results {
+		row := []string{
+			strconv.Itoa(result.Index),
+			strconv.FormatFloat(result.Lat, 'f', -1, 64),
+			strconv.FormatFloat(result.Lon, 'f', -1, 64),
+			"", "", "", "", "", "", "", "", "", "",
+			result.Error,
+			result.Code,
+		}
+		if weather := result.Weather; weather != nil {
+			copy(row[3:], []string{
+				weather.ObservationTime, weather.Country, weather.City, weather.Condition, weather.TemperatureCategory,
+				strconv.FormatBool(weather.Cached), weather.FetchedAt.UTC().Format(time.RFC3339), strconv.FormatInt(weather.AgeSeconds, 10),
+				strconv.FormatBool(weather.Stale), strconv.FormatBool(weather.Degraded),
+			})
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// decodeBatch reads and validates a request body listing up to maxLocations locations, answering the
+// request with an error and returning false if it is invalid
+func decodeBatch(w http.ResponseWriter, r *http.Request, logger *slog.Logger, maxLocations int) (BatchRequest, bool) {
+	var batch BatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, batchMaxBodyBytes)).Decode(&batch); err != nil {
+		sendErrorResponse(w, r, logger, http.StatusBadRequest, CodeInvalidRequest, "invalid batch request body")
+		return batch, false
+	}
+	if len(batch.Locations) == 0 {
+		sendErrorResponse(w, r, logger, http.StatusBadRequest, CodeInvalidRequest, "at least one location is required")
+		return batch, false
+	}
+	if len(batch.Locations) > maxLocations {
+		sendErrorResponse(w, r, logger, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("at most %d locations are allowed per batch", maxLocations))
+		return batch, false
+	}
+	for i, location := range batch.Locations {
+		if err := validateCoordinates(location.Lat, location.Lon); err != nil {
+			sendErrorResponse(w, r, logger, http.StatusBadRequest, CodeInvalidCoordinates, fmt.Sprintf("location %d: %v", i, err))
+			return batch, false
+		}
+	}
+	return batch, true
+}
+
+// streamResults writes one JSON line per location as soon as its lookup finishes
+// Every flushed line pushes the write deadline out again, so a large batch isn't cut off by the
+// server's WriteTimeout while results are still arriving
+func (bh *BatchHandler) streamResults(w http.ResponseWriter, r *http.Request, locations []BatchLocation, timeout time.Duration) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	encoder := json.NewEncoder(w)
+	var mu sync.Mutex
+
+	bh.lookup(r.Context(), locations, timeout, func(result BatchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		rc.SetWriteDeadline(time.Now().Add(timeout))
+		if err := encoder.Encode(result); err != nil {
+			bh.logger.WarnContext(r.Context(), "batch result write failed", slog.String("error", err.Error()))
+			return
+		}
+		rc.Flush()
+	})
+}
+
+// lookup fetches every location on the shared pool, each within timeout, and hands each result to emit as
+// it completes
+func (bh *BatchHandler) lookup(ctx context.Context, locations []BatchLocation, timeout time.Duration, emit func(BatchResult)) {
+	err := bh.pool.Run(ctx, len(locations), bh.concurrency, func(ctx context.Context, i int) {
+		location := locations[i]
+		result := BatchResult{Index: i, Lat: location.Lat, Lon: location.Lon}
+
+		lookupCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		data, err := bh.weatherService.GetWeather(lookupCtx, location.Lat, location.Lon)
+		if err != nil {
+			if !isRequestAborted(lookupCtx) {
+				bh.logger.WarnContext(ctx, "batch lookup failed", slog.Float64("lat", location.Lat), slog.Float64("lon", location.Lon), slog.String("error", err.Error()))
+			}
+			_, result.Code, result.Error, _ = serviceErrorStatus(err)
+		} else {
+			result.Weather = data
+			if cacheResult(data) == "miss" {
+				metering.RecordUpstreamCall(ctx)
+			}
+		}
+		emit(result)
+	})
+	if err != nil {
+		bh.logger.InfoContext(ctx, "batch canceled", slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"github.com/krizvi/weather-app-server/internal/workpool"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestBatchHandler(mockService service.WeatherService) *BatchHandler {
+	return NewBatch(mockService, workpool.New(4), 10, 3, 2, slog.Default())
+}
+
+func TestBatchHandler_JSON(t *testing.T) {
+	mockService := &MockWeatherService{returnData: &service.WeatherData{Condition: "Clear"}}
+	handler := newTestBatchHandler(mockService)
+
+	body := `{"locations":[{"lat":40.7,"lon":-74.0},{"lat":51.5,"lon":-0.1}]}`
+	req := httptest.NewRequest("POST", "/weather/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.GetWeatherBatch(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var results []BatchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatalf("Expected a JSON array, got %q", w.Body.String())
+	}
+	if len(results) != 2 || results[1].Lat != 51.5 || results[1].Weather == nil || results[1].Weather.Condition != "Clear" {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+}
+
+func TestBatchHandler_NDJSON(t *testing.T) {
+	mockService := &MockWeatherService{shouldError: true}
+	handler := newTestBatchHandler(mockService)
+
+	body := `{"locations":[{"lat":1,"lon":1},{"lat":2,"lon":2},{"lat":3,"lon":3}]}`
+	req := httptest.NewRequest("POST", "/weather/batch", strings.NewReader(body))
+	req.Header.Set("Accept", "application/x-ndjson")
+	w := httptest.NewRecorder()
+
+	handler.GetWeatherBatch(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Expected application/x-ndjson, got %q", ct)
+	}
+
+	seen := make(map[int]bool)
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var result BatchResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			t.Fatalf("Expected one JSON object per line, got %q", scanner.Text())
+		}
+		if result.Error == "" {
+			t.Errorf("Expected an error for location %d", result.Index)
+		}
+		seen[result.Index] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected 3 results, got %d", len(seen))
+	}
+}
+
+func TestBatchHandler_Validation(t *testing.T) {
+	handler := newTestBatchHandler(&MockWeatherService{})
+
+	tests := []string{
+		`not json`,
+		`{"locations":[]}`,
+		`{"locations":[{"lat":1,"lon":1},{"lat":1,"lon":1},{"lat":1,"lon":1},{"lat":1,"lon":1}]}`,
+		`{"locations":[{"lat":91,"lon":1}]}`,
+	}
+	for _, body := range tests {
+		w := httptest.NewRecorder()
+		handler.GetWeatherBatch(w, httptest.NewRequest("POST", "/weather/batch", strings.NewReader(body)))
+		if w.Code != 400 {
+			t.Errorf("Expected 400 for %s, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestBatchHandler_CSV(t *testing.T) {
+	mockService := &MockWeatherService{returnData: &service.WeatherData{City: "New York, NY", Condition: "Clear", Cached: true}}
+	handler := newTestBatchHandler(mockService)
+
+	body := `{"locations":[{"lat":40.7,"lon":-74.0},{"lat":51.5,"lon":-0.1}]}`
+	req := httptest.NewRequest("POST", "/weather/batch?format=csv", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.GetWeatherBatch(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Expected text/csv, got %q", ct)
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Expected valid CSV, got %v", err)
+	}
+	if len(rows) != 3 || rows[0][0] != "index" {
+		t.Fatalf("Expected a header and one row per location, got %q", rows)
+	}
+	if row := rows[2]; row[0] != "1" || row[1] != "51.5" || row[2] != "-0.1" || row[5] != "New York, NY" || row[6] != "Clear" || row[8] != "true" || row[13] != "" {
+		t.Errorf("Unexpected row: %q", row)
+	}
+}
+
+func TestBatchHandler_CSVErrors(t *testing.T) {
+	handler := newTestBatchHandler(&MockWeatherService{shouldError: true})
+
+	body := `{"locations":[{"lat":1,"lon":1}]}`
+	req := httptest.NewRequest("POST", "/weather/batch", strings.NewReader(body))
+	req.Header.Set("Accept", "text/csv")
+	w := httptest.NewRecorder()
+
+	handler.GetWeatherBatch(w, req)
+
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("Expected a header and one row, got %q (%v)", rows, err)
+	}
+	if row := rows[1]; row[6] != "" || row[14] != CodeUpstreamUnavailable {
+		t.Errorf("Expected an error row without weather, got %q", row)
+	}
+}
+
+func TestBatchHandler_UnsupportedFormat(t *testing.T) {
+	handler := newTestBatchHandler(&MockWeatherService{})
+
+	req := httptest.NewRequest("POST", "/weather/batch?format=xml", strings.NewReader(`{"locations":[{"lat":1,"lon":1}]}`))
+	w := httptest.NewRecorder()
+
+	handler.GetWeatherBatch(w, req)
+
+	if w.Code != 400 {
+		t.Errorf("Expected 400, got %d", w.Code)
+	}
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"github.com/krizvi/weather-app-server/internal/wire"
+)
+
+// encodeProtobuf encodes weather data or batch results as the messages in wire/weather.proto
+func encodeProtobuf(data interface{}) ([]byte, error) {
+	switch data := data.(type) {
+	case *service.WeatherData:
+		return weatherProto(data).Bytes(), nil
+	case []BatchResult:
+		var response wire.Proto
+		for _, result := range data {
+			var message wire.Proto
+			message.Int64(1, int64(result.Index))
+			message.Double(2, result.Lat)
+			message.Double(3, result.Lon)
+			if result.Weather != nil {
+				message.Message(4, weatherProto(result.Weather))
+			}
+			message.String(5, result.Error)
+			message.String(6, result.Code)
+			response.Message(1, &message)
+		}
+		return response.Bytes(), nil
+	}
+	return nil, fmt.Errorf("no protobuf message for %T", data)
+}
+
+// weatherProto builds the WeatherData message
+func weatherProto(data *service.WeatherData) *wire.Proto {
+	var message wire.Proto
+	message.String(1, data.ObservationTime)
+	message.String(2, data.Country)
+	message.String(3, data.City)
+	message.String(4, data.Condition)
+	message.String(5, data.TemperatureCategory)
+	message.Bool(6, data.Cached)
+	message.Timestamp(7, data.FetchedAt)
+	message.Int64(8, data.AgeSeconds)
+	message.Bool(9, data.Stale)
+	message.Bool(10, data.Degraded)
+	message.Int64(11, data.DataAgeSeconds)
+	message.Bool(12, data.Static)
+	return &message
+}
+
+// encodeMsgpack encodes weather data or batch results as MessagePack maps keyed like the JSON
+func encodeMsgpack(data interface{}) ([]byte, error) {
+	var doc wire.MsgPack
+	switch data := data.(type) {
+	case *service.WeatherData:
+		weatherMsgpack(&doc, data)
+	case []BatchResult:
+		doc.Array(len(data))
+		for _, result := range data {
+			doc.Map(3 + boolCount(result.Weather != nil, result.Error != "", result.Code != ""))
+			doc.String("index")
+			doc.Int(int64(result.Index))
+			doc.String("lat")
+			doc.Float(result.Lat)
+			doc.String("lon")
+			doc.Float(result.Lon)
+			if result.Weather != nil {
+				doc.String("weather")
+				weatherMsgpack(&doc, result.Weather)
+			}
+			if result.Error != "" {
+				doc.String("error")
+				doc.String(result.Error)
+			}
+			if result.Code != "" {
+				doc.String("code")
+				doc.String(result.Code)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("no MessagePack encoding for %T", data)
+	}
+	return doc.Bytes(), nil
+}
+
+// weatherMsgpack adds the weather data map, leaving out the fields JSON omits when empty
+func weatherMsgpack(doc *wire.MsgPack, data *service.WeatherData) {
+	doc.Map(8 + boolCount(data.Stale, data.Degraded, data.DataAgeSeconds != 0, data.Static))
+	doc.String("ObservationTime")
+	doc.String(data.ObservationTime)
+	doc.String("Country")
+	doc.String(data.Country)
+	doc.String("City")
+	doc.String(data.City)
+	doc.String("Condition")
+	doc.String(data.Condition)
+	doc.String("TemperatureCategory")
+	doc.String(data.TemperatureCategory)
+	doc.String("cached")
+	doc.Bool(data.Cached)
+	doc.String("fetched_at")
+	doc.Timestamp(data.FetchedAt)
+	doc.String("age_seconds")
+	doc.Int(data.AgeSeconds)
+	if data.Stale {
+		doc.String("stale")
+		doc.Bool(true)
+	}
+	if data.Degraded {
+		doc.String("degraded")
+		doc.Bool(true)
+	}
+	if data.DataAgeSeconds != 0 {
+		doc.String("data_age_seconds")
+		doc.Int(data.DataAgeSeconds)
+	}
+	if data.Static {
+		doc.String("static")
+		doc.Bool(true)
+	}
+}
+
+// boolCount counts the true values, for the sizes of maps with optional keys
+func boolCount(values ...bool) int {
+	n := 0
+	for _, value := range values {
+		if value {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"runtime/metrics"
+	"strings"
+	"time"
+)
+
+// Pprof serves the net/http/pprof profiles under /admin/debug/pprof/
+// Register it behind RequireAdmin: profiles expose memory contents and the command line
+func Pprof(w http.ResponseWriter, r *http.Request) {
+	// pprof expects its handlers at /debug/pprof/
+	r = r.Clone(r.Context())
+	r.URL.Path = strings.TrimPrefix(r.URL.Path, "/admin")
+
+	switch strings.TrimPrefix(r.URL.Path, "/debug/pprof/") {
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	case "profile":
+		pprof.Profile(w, longRunning(w, r))
+	case "trace":
+		pprof.Trace(w, longRunning(w, r))
+	default:
+		pprof.Index(w, r)
+	}
+}
+
+// longRunning lifts the server's write deadline for CPU profiles and execution traces, which
+// stream for as many seconds as requested, and hides the server's WriteTimeout from pprof,
+// which would otherwise refuse to run for longer
+func longRunning(w http.ResponseWriter, r *http.Request) *http.Request {
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	return r.WithContext(context.WithValue(r.Context(), http.ServerContextKey, &http.Server{}))
+}
+
+// RuntimeMetrics returns a handler for GET requests to /admin/debug/runtime, returning the current value of
+// every scalar runtime/metrics metric (GC, heap, scheduler, ...) by name
+func RuntimeMetrics(logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		descriptions := metrics.All()
+		samples := make([]metrics.Sample, len(descriptions))
+		for i, description := range descriptions {
+			samples[i].Name = description.Name
+		}
+		metrics.Read(samples)
+
+		values := make(map[string]any, len(samples))
+		for _, sample := range samples {
+			switch sample.Value.Kind() {
+			case metrics.KindUint64:
+				values[sample.Name] = sample.Value.Uint64()
+			case metrics.KindFloat64:
+				values[sample.Name] = sample.Value.Float64()
+			}
+		}
+		sendJSONResponse(w, r, logger, http.StatusOK, values)
+	}
+}
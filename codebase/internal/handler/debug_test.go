@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPprof_ServesProfiles(t *testing.T) {
+	w := httptest.NewRecorder()
+	Pprof(w, httptest.NewRequest("GET", "/admin/debug/pprof/", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("Expected the profile index, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	Pprof(w, httptest.NewRequest("GET", "/admin/debug/pprof/goroutine?debug=1", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "TestPprof_ServesProfiles") {
+		t.Errorf("Expected a goroutine dump including this test, got %d", w.Code)
+	}
+}
+
+func TestPprof_ProfileLongerThanWriteTimeout(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(Pprof))
+	server.Config.WriteTimeout = 500 * time.Millisecond // shorter than the profile
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/admin/debug/pprof/profile?seconds=1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || err != nil || len(body) == 0 {
+		t.Errorf("Expected a complete profile, got %d (%v)", resp.StatusCode, err)
+	}
+}
+
+func TestRuntimeMetrics(t *testing.T) {
+	w := httptest.NewRecorder()
+	RuntimeMetrics(slog.Default())(w, httptest.NewRequest("GET", "/admin/debug/runtime", nil))
+
+	var values map[string]float64
+	if err := json.NewDecoder(w.Body).Decode(&values); err != nil {
+		t.Fatalf("Expected JSON, got %v", err)
+	}
+	if values["/sched/goroutines:goroutines"] < 1 {
+		t.Errorf("Expected a goroutine count, got %v", values["/sched/goroutines:goroutines"])
+	}
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/krizvi/weather-app-server/internal/errreport"
+	"github.com/krizvi/weather-app-server/internal/middleware"
+	"github.com/krizvi/weather-app-server/internal/route"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Machine-readable error codes sent in the "code" field of every error response
+// These are part of the API contract: add new codes freely but never change or reuse existing ones
+const (
+	CodeMethodNotAllowed    = "METHOD_NOT_ALLOWED"
+	CodeInvalidRequest      = "INVALID_REQUEST"
+	CodeInvalidCoordinates  = "INVALID_COORDINATES"
+	CodeUnauthorized        = "UNAUTHORIZED"
+	CodeForbidden           = "FORBIDDEN"
+	CodeLocationNotFound    = "LOCATION_NOT_FOUND"
+	CodeRateLimited         = "RATE_LIMITED"
+	CodeUpstreamAuth        = "UPSTREAM_AUTH_MISCONFIGURED"
+	CodeUpstreamRejected    = "UPSTREAM_REJECTED"
+	CodeUpstreamInvalid     = "UPSTREAM_INVALID_RESPONSE"
+	CodeUpstreamTimeout     = "UPSTREAM_TIMEOUT"
+	CodeUpstreamUnavailable = "UPSTREAM_UNAVAILABLE"
+	CodeCanceled            = "CANCELED"
+	CodeInternalError       = "INTERNAL_ERROR"
+	CodeTooManyStreams      = "TOO_MANY_STREAMS"
+	CodeSubscriptionLimit   = "TOO_MANY_SUBSCRIPTIONS"
+	CodeNotFound            = "NOT_FOUND"
+	CodeJobLimit            = "TOO_MANY_JOBS"
+)
+
+// StatusClientClosedRequest is the non-standard status (from nginx) recorded when the client went away
+// The client never sees it, but it keeps access logs and metrics from blaming upstream
+const StatusClientClosedRequest = 499
+
+// defaultRetryAfter is advertised on 429s when the provider didn't say how long to back off
+const defaultRetryAfter = 60 * time.Second
+
+// serviceErrorStatus maps a weather service error to the HTTP status, error code and message clients see
+// Only outages (network errors, timeouts, 5xx) are 503s; problems on our side of the provider
+// relationship are 502s so clients don't retry something that won't fix itself
+func serviceErrorStatus(err error) (int, string, string, time.Duration) {
+	switch {
+	case errors.Is(err, service.ErrRateLimited):
+		retryAfter := defaultRetryAfter
+		var providerErr *service.ProviderError
+		if errors.As(err, &providerErr) && providerErr.RetryAfter > 0 {
+			retryAfter = providerErr.RetryAfter
+		}
+		return http.StatusTooManyRequests, CodeRateLimited, "Upstream rate limit exceeded, please retry later", retryAfter
+	case errors.Is(err, service.ErrUpstreamBudgetExhausted):
+		return http.StatusTooManyRequests, CodeRateLimited, "Upstream rate limit exceeded, please retry later", defaultRetryAfter
+	case errors.Is(err, service.ErrUnauthorized):
+		return http.StatusBadGateway, CodeUpstreamAuth, "Upstream auth misconfigured", 0
+	case errors.Is(err, service.ErrMalformedResponse):
+		return http.StatusBadGateway, CodeUpstreamInvalid, "Upstream returned an invalid response", 0
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound, CodeLocationNotFound, "Location not found", 0
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest, CodeCanceled, "Request canceled", 0
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, CodeUpstreamTimeout, "Timed out fetching weather data", 0
+	}
+
+	var providerErr *service.ProviderError
+	if errors.As(err, &providerErr) && !errors.Is(err, service.ErrUnavailable) {
+		return http.StatusBadGateway, CodeUpstreamRejected, "Upstream rejected the request", 0
+	}
+	return http.StatusServiceUnavailable, CodeUpstreamUnavailable, "Unable to fetch weather data", 0
+}
+
+// isRequestAborted reports whether the client left or the request ran out of time, going by ctx, the request's
+// context or one derived from it with the client timeout
+// Failures then aren't upstream failures and aren't worth an error log line each. The error can't tell:
+// an upstream attempt timing out while the request still has time also wraps context.DeadlineExceeded
+func isRequestAborted(ctx context.Context) bool {
+	return ctx.Err() != nil
+}
+
+// sendServiceError sends the error response matching a weather service error from a lookup made with ctx
+// Server-side failures are also sent to error reporting, grouped by error code
+func sendServiceError(ctx context.Context, w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error) {
+	status, code, message, retryAfter := serviceErrorStatus(err)
+	if status >= 500 && !isRequestAborted(ctx) && !isShedding(err) {
+		errreport.Report(r, errreport.Event{Err: err, Fingerprint: []string{code}})
+	}
+	switch {
+	case errors.Is(err, service.ErrRateLimited):
+		middleware.RecordRejection(r.Context(), middleware.RejectUpstreamLimit)
+	case errors.Is(err, service.ErrUpstreamBudgetExhausted):
+		middleware.RecordRejection(r.Context(), middleware.RejectUpstreamBudget)
+	}
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	}
+	sendErrorResponse(w, r, logger, status, code, message)
+}
+
+// isShedding reports whether err is the circuit breaker or bulkhead turning a lookup away, which they
+// do for every request during an outage; the failures that tripped them were reported already
+func isShedding(err error) bool {
+	return errors.Is(err, service.ErrCircuitOpen) || errors.Is(err, service.ErrBulkheadFull)
+}
+
+// Methods serves mux, answering OPTIONS for any of its routes with a 204 and an Allow header listing the
+// methods the route has, and requests with a method the route lacks with a JSON 405 and the same header
+// instead of ServeMux's plain text one
+func Methods(mux *http.ServeMux, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern == "" {
+			if allowed := route.Allowed(mux, r); len(allowed) > 0 {
+				w.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+				if r.Method == http.MethodOptions {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				sendErrorResponse(w, r, logger, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+				return
+			}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// forecastRefreshInterval is how often calendar clients are asked to fetch the feed again
+// OpenWeatherMap updates its forecast every 3 hours
+const forecastRefreshInterval = "PT3H"
+
+// ForecastHandler serves the daily forecast for a location as an iCalendar feed
+type ForecastHandler struct {
+	forecastService service.ForecastService
+	settings        *service.RuntimeSettings // time lookups get
+	logger          *slog.Logger
+}
+
+// NewForecast creates a new ForecastHandler instance
+func NewForecast(forecastService service.ForecastService, externalApiTimeout int, logger *slog.Logger) *ForecastHandler {
+	return &ForecastHandler{
+		forecastService: forecastService,
+		settings:        service.NewRuntimeSettings(service.RuntimeConfig{ClientTimeout: time.Duration(externalApiTimeout) * time.Second}),
+		logger:          logger,
+	}
+}
+
+// UseRuntimeSettings makes requests get the client timeout in settings as of their start, instead of the
+// one given to NewForecast, before they fail
+func (fh *ForecastHandler) UseRuntimeSettings(settings *service.RuntimeSettings) {
+	fh.settings = settings
+}
+
+// GetForecastICal handles GET requests to /forecast/ical
+// It answers with a calendar holding an all-day event per forecast day, titled with the day's condition
+// and temperature category, so calendar apps can subscribe to the weather at a location
+func (fh *ForecastHandler) GetForecastICal(w http.ResponseWriter, r *http.Request) {
+	lat, lon, err := parseCoordinates(r)
+	if err != nil {
+		sendErrorResponse(w, r, fh.logger, http.StatusBadRequest, CodeInvalidCoordinates, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), fh.settings.Load().ClientTimeout)
+	defer cancel()
+
+	forecast, err := fh.forecastService.GetForecast(ctx, lat, lon)
+	if err != nil {
+		if isRequestAborted(ctx) {
+			fh.logger.DebugContext(ctx, "forecast request aborted", slog.String("error", err.Error()))
+		} else {
+			fh.logger.ErrorContext(ctx, "forecast lookup failed", slog.String("error", err.Error()))
+		}
+		sendServiceError(ctx, w, r, fh.logger, err)
+		return
+	}
+
+	body := encodeICal(forecast, lat, lon)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// encodeICal renders forecast as an iCalendar (RFC 5545) feed
+// Event UIDs are derived from the location and date so a refreshed feed updates events instead of adding them
+func encodeICal(forecast *service.Forecast, lat, lon float64) []byte {
+	var b strings.Builder
+	line := func(content string) {
+		b.WriteString(foldICalLine(content))
+		b.WriteString("\r\n")
+	}
+
+	name := "Weather forecast"
+	if forecast.City != "" {
+		name += " for " + forecast.City
+	}
+	location := fmt.Sprintf("%.4f,%.4f", lat, lon)
+	stamp := forecast.FetchedAt.UTC().Format("20060102T150405Z")
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//krizvi//weather-app-server//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escapeICalText(name))
+	line("REFRESH-INTERVAL;VALUE=DURATION:" + forecastRefreshInterval)
+	line("X-PUBLISHED-TTL:" + forecastRefreshInterval)
+	for _, day := range forecast.Days {
+		line("BEGIN:VEVENT")
+		line("UID:" + day.Date.Format("20060102") + "-" + location + "@weather-app-server")
+		line("DTSTAMP:" + stamp)
+		line("DTSTART;VALUE=DATE:" + day.Date.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + day.Date.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + escapeICalText(day.Condition+" ("+day.TemperatureCategory+")"))
+		line("GEO:" + fmt.Sprintf("%.4f;%.4f", lat, lon))
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return []byte(b.String())
+}
+
+// escapeICalText escapes a TEXT value (RFC 5545 section 3.3.11)
+func escapeICalText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(text)
+}
+
+// foldICalLine splits a content line longer than 75 octets into continuation lines starting with a space
+// (RFC 5545 section 3.1), never inside a UTF-8 sequence
+func foldICalLine(content string) string {
+	const maxOctets = 75
+	var b strings.Builder
+	width := 0
+	for _, r := range content {
+		size := len(string(r))
+		if width+size > maxOctets {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
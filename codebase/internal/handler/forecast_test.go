@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Mock forecast service for testing
+type MockForecastService struct {
+	shouldError bool
+	returnData  *service.Forecast
+}
+
+func (m *MockForecastService) GetForecast(ctx context.Context, lat, lon float64) (*service.Forecast, error) {
+	if m.shouldError {
+		return nil, fmt.Errorf("mock error")
+	}
+	return m.returnData, nil
+}
+
+func TestForecastHandler_ICal(t *testing.T) {
+	mockService := &MockForecastService{returnData: &service.Forecast{
+		City:      "Paris, Texas",
+		FetchedAt: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Days: []service.ForecastDay{
+			{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Condition: "Clear", TemperatureCategory: "hot"},
+			{Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Condition: "Rain", TemperatureCategory: "cold"},
+		},
+	}}
+	handler := NewForecast(mockService, 10, slog.Default())
+
+	w := httptest.NewRecorder()
+	handler.GetForecastICal(w, httptest.NewRequest("GET", "/v1/forecast/ical?lat=33.66&lon=-95.55", nil))
+
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/calendar; charset=utf-8" {
+		t.Errorf("Expected text/calendar, got %q", contentType)
+	}
+	body := w.Body.String()
+	if !strings.HasPrefix(body, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(body, "END:VCALENDAR\r\n") {
+		t.Errorf("Expected a calendar with CRLF line endings, got %q", body)
+	}
+	if events := strings.Count(body, "BEGIN:VEVENT\r\n"); events != 2 {
+		t.Errorf("Expected 2 events, got %d", events)
+	}
+	for _, want := range []string{
+		"X-WR-CALNAME:Weather forecast for Paris\\, Texas\r\n",
+		"UID:20240101-33.6600,-95.5500@weather-app-server\r\n",
+		"DTSTAMP:20240101T120000Z\r\n",
+		"DTSTART;VALUE=DATE:20240102\r\nDTEND;VALUE=DATE:20240103\r\nSUMMARY:Rain (cold)\r\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected the calendar to contain %q, got %q", want, body)
+		}
+	}
+}
+
+func TestForecastHandler_Errors(t *testing.T) {
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"lat=91&lon=0", 400},
+		{"lat=40.7&lon=-74.0", 503},
+	}
+	handler := NewForecast(&MockForecastService{shouldError: true}, 10, slog.Default())
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.GetForecastICal(w, httptest.NewRequest("GET", "/v1/forecast/ical?"+tt.query, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: Expected %d, got %d", tt.query, tt.status, w.Code)
+		}
+	}
+}
+
+func TestFoldICalLine(t *testing.T) {
+	folded := foldICalLine("SUMMARY:" + strings.Repeat("é", 50))
+	for _, line := range strings.Split(folded, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Expected lines of at most 75 octets, got %d", len(line))
+		}
+	}
+	if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != "SUMMARY:"+strings.Repeat("é", 50) {
+		t.Errorf("Expected folding to be reversible, got %q", unfolded)
+	}
+}
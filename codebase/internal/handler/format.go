@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/servertiming"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Response formats selectable with ?format= or the Accept header
+const (
+	FormatJSON     = "json"
+	FormatNDJSON   = "ndjson"
+	FormatXML      = "xml"
+	FormatCSV      = "csv"
+	FormatProtobuf = "protobuf"
+	FormatMsgpack  = "msgpack"
+	FormatHTML     = "html"
+)
+
+// encoding describes how data is sent in one format
+type encoding struct {
+	contentType string
+	mediaTypes  []string                               // matched against the Accept header
+	encode      func(data interface{}) ([]byte, error) // nil for formats a handler writes itself (e.g. streamed)
+}
+
+var encodings = map[string]encoding{
+	FormatJSON:     {"application/json", []string{"application/json"}, encodeJSON},
+	FormatNDJSON:   {"application/x-ndjson", []string{"application/x-ndjson"}, nil},
+	FormatXML:      {"application/xml; charset=utf-8", []string{"application/xml", "text/xml"}, encodeXML},
+	FormatCSV:      {"text/csv; charset=utf-8", []string{"text/csv"}, encodeCSV},
+	FormatProtobuf: {"application/x-protobuf", []string{"application/x-protobuf", "application/protobuf"}, encodeProtobuf},
+	FormatMsgpack:  {"application/msgpack", []string{"application/msgpack", "application/x-msgpack"}, encodeMsgpack},
+	FormatHTML:     {"text/html; charset=utf-8", []string{"text/html"}, encodeHTML},
+}
+
+// Formats offered by each endpoint, most preferred first; the first one is the default
+var (
+	weatherFormats = []string{FormatJSON, FormatXML, FormatProtobuf, FormatMsgpack, FormatHTML}
+	batchFormats   = []string{FormatJSON, FormatNDJSON, FormatCSV, FormatProtobuf, FormatMsgpack}
+	usageFormats   = []string{FormatJSON, FormatCSV}
+	errorFormats   = []string{FormatJSON, FormatXML, FormatHTML}
+)
+
+// csvWriter is implemented by payloads that write themselves as CSV, e.g. metered usage
+type csvWriter interface {
+	WriteCSV(w io.Writer) error
+}
+
+// encodeCSV encodes batch results, or a payload that writes itself as CSV, with a header row
+func encodeCSV(data interface{}) ([]byte, error) {
+	switch data := data.(type) {
+	case []BatchResult:
+		return encodeBatchCSV(data)
+	case csvWriter:
+		var buf bytes.Buffer
+		err := data.WriteCSV(&buf)
+		return buf.Bytes(), err
+	}
+	return nil, fmt.Errorf("no CSV encoding for %T", data)
+}
+
+// negotiate picks the response format for r among offered, answering the request with a 400
+// and returning false if ?format= names a format the endpoint doesn't offer
+func negotiate(w http.ResponseWriter, r *http.Request, logger *slog.Logger, offered []string) (string, bool) {
+	w.Header().Add("Vary", "Accept")
+	format, err := negotiateFormat(r, offered)
+	if err != nil {
+		sendErrorResponse(w, r, logger, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return "", false
+	}
+	return format, true
+}
+
+// negotiateFormat works out which of the offered formats the client prefers
+// An explicit ?format= wins over the Accept header. Otherwise the format with the highest quality
+// value wins, ties going to the endpoint's preference; if the Accept header rules out every
+// offered format the default is sent anyway rather than a 406
+func negotiateFormat(r *http.Request, offered []string) (string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		if !contains(offered, format) {
+			return "", fmt.Errorf("unsupported format: %s", format)
+		}
+		return format, nil
+	}
+
+	accepted := parseAccept(r.Header.Get("Accept"))
+	best, bestQuality := offered[0], 0.0
+	for _, format := range offered {
+		for _, mediaType := range encodings[format].mediaTypes {
+			if quality := acceptQuality(accepted, mediaType); quality > bestQuality {
+				best, bestQuality = format, quality
+			}
+		}
+	}
+	return best, nil
+}
+
+// acceptRange is one media range of an Accept header
+type acceptRange struct {
+	mediaType string // type/subtype, type/* or */*
+	quality   float64
+	params    map[string]string // media type parameters other than q, e.g. indent
+}
+
+// parseAccept parses an Accept header, skipping malformed entries
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, entry := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(entry))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+		delete(params, "q")
+		ranges = append(ranges, acceptRange{mediaType: mediaType, quality: quality, params: params})
+	}
+	return ranges
+}
+
+// acceptQuality returns the quality the client gives mediaType, taken from the most specific
+// matching range (RFC 9110 section 12.5.1), or 0 if no range matches
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	quality, specificity := 0.0, 0
+	for _, accepted := range ranges {
+		var rangeSpecificity int
+		switch accepted.mediaType {
+		case mediaType:
+			rangeSpecificity = 3
+		case mainType + "/*":
+			rangeSpecificity = 2
+		case "*/*":
+			rangeSpecificity = 1
+		default:
+			continue
+		}
+		if rangeSpecificity > specificity {
+			quality, specificity = accepted.quality, rangeSpecificity
+		}
+	}
+	return quality
+}
+
+// maxJSONIndent bounds the indentation clients can ask for
+const maxJSONIndent = 8
+
+// jsonIndent returns the indentation for JSON responses: two spaces for ?pretty=true, the number of
+// spaces given with "Accept: application/json; indent=N", and otherwise none for compact output
+func jsonIndent(r *http.Request) string {
+	if pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty")); pretty {
+		return "  "
+	}
+	for _, accepted := range parseAccept(r.Header.Get("Accept")) {
+		if accepted.mediaType != "application/json" {
+			continue
+		}
+		if width, err := strconv.Atoi(accepted.params["indent"]); err == nil && width > 0 {
+			return strings.Repeat(" ", min(width, maxJSONIndent))
+		}
+	}
+	return ""
+}
+
+// formatETag keeps ETags of the different representations of the same data apart, including
+// JSON indented as r asked for (see jsonIndent)
+func formatETag(r *http.Request, etag string, format string) string {
+	if etag == "" {
+		return etag
+	}
+	suffix := format
+	if format == FormatJSON {
+		indent := jsonIndent(r)
+		if indent == "" {
+			return etag
+		}
+		suffix += "-indent" + strconv.Itoa(len(indent))
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + suffix + `"`
+}
+
+// encodeAs encodes data in format for the client making r
+func encodeAs(r *http.Request, format string, data interface{}) ([]byte, error) {
+	if format == FormatJSON {
+		return encodeJSONFor(r, data)
+	}
+	encode := encodings[format].encode
+	if encode == nil {
+		return nil, fmt.Errorf("no encoder for format %q", format)
+	}
+	return encode(data)
+}
+
+// writeEncoded sends a body already encoded in format
+// Content-Length is set up front, so HEAD responses carry the length the GET body would have
+func writeEncoded(w http.ResponseWriter, statusCode int, format string, body []byte) {
+	w.Header().Set("Content-Type", encodings[format].contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+// sendResponse sends data in format with the given status code
+// Encoding happens before anything is written, so the time it takes makes it into the Server-Timing header
+func sendResponse(w http.ResponseWriter, r *http.Request, logger *slog.Logger, statusCode int, format string, data interface{}) {
+	encodeStart := time.Now()
+	body, err := encodeAs(r, format, data)
+	servertiming.Add(r.Context(), "encode", time.Since(encodeStart))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "response encoding failed", slog.String("format", format), slog.String("error", err.Error()))
+		sendErrorResponse(w, r, logger, http.StatusInternalServerError, CodeInternalError, "Internal server error")
+		return
+	}
+	writeEncoded(w, statusCode, format, body)
+}
+
+// encodeXML encodes data as an XML document with a trailing newline
+func encodeXML(data interface{}) ([]byte, error) {
+	body, err := xml.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	body = append([]byte(xml.Header), body...)
+	return append(body, '\n'), nil
+}
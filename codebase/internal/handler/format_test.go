@@ -0,0 +1,272 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWeatherHandler_XML(t *testing.T) {
+	mockService := &MockWeatherService{
+		returnData: &service.WeatherData{
+			City:                "Boston",
+			Condition:           "Clear",
+			TemperatureCategory: "hot",
+			Cached:              true,
+			ETag:                `W/"abc123"`,
+		},
+	}
+	handler := New(mockService, 10, "", slog.Default())
+
+	for _, tc := range []struct {
+		name   string
+		url    string
+		accept string
+	}{
+		{"query parameter", "/weather?lat=40.7&lon=-74.0&format=xml", ""},
+		{"accept header", "/weather?lat=40.7&lon=-74.0", "text/plain, application/xml;q=0.9"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.url, nil)
+			req.Header.Set("Accept", tc.accept)
+			w := httptest.NewRecorder()
+			handler.GetWeather(w, req)
+
+			if w.Code != 200 {
+				t.Fatalf("Expected 200, got %d", w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/xml; charset=utf-8" {
+				t.Errorf("Expected XML content type, got %q", ct)
+			}
+			if etag := w.Header().Get("ETag"); etag != `W/"abc123-xml"` {
+				t.Errorf("Expected an XML specific ETag, got %q", etag)
+			}
+			if vary := w.Header().Get("Vary"); vary != "Accept" {
+				t.Errorf("Expected Vary: Accept, got %q", vary)
+			}
+
+			var data service.WeatherData
+			if err := xml.Unmarshal(w.Body.Bytes(), &data); err != nil {
+				t.Fatalf("Expected a valid XML document, got %v: %s", err, w.Body.String())
+			}
+			if data.XMLName.Local != "weather" || data.City != "Boston" || data.Condition != "Clear" || !data.Cached {
+				t.Errorf("Expected the weather data, got %+v", data)
+			}
+			if strings.Contains(w.Body.String(), "abc123") {
+				t.Errorf("Expected internal fields to be left out, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestWeatherHandler_JSONWinsOverXMLAccept(t *testing.T) {
+	mockService := &MockWeatherService{returnData: &service.WeatherData{Condition: "Clear"}}
+	handler := New(mockService, 10, "", slog.Default())
+
+	req := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0&format=json", nil)
+	req.Header.Set("Accept", "application/xml")
+	w := httptest.NewRecorder()
+	handler.GetWeather(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected ?format=json to win, got %q", ct)
+	}
+}
+
+func TestWeatherHandler_UnsupportedFormat(t *testing.T) {
+	handler := New(&MockWeatherService{}, 10, "", slog.Default())
+
+	req := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0&format=yaml", nil)
+	w := httptest.NewRecorder()
+	handler.GetWeather(w, req)
+
+	if w.Code != 400 {
+		t.Errorf("Expected 400, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), CodeInvalidRequest) {
+		t.Errorf("Expected %s, got %s", CodeInvalidRequest, w.Body.String())
+	}
+}
+
+func TestWeatherHandler_XMLError(t *testing.T) {
+	handler := New(&MockWeatherService{shouldError: true}, 10, "", slog.Default())
+
+	req := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0", nil)
+	req.Header.Set("Accept", "application/xml")
+	w := httptest.NewRecorder()
+	handler.GetWeather(w, req)
+
+	if w.Code != 503 {
+		t.Fatalf("Expected 503, got %d", w.Code)
+	}
+	var errorResp ErrorResponse
+	if err := xml.Unmarshal(w.Body.Bytes(), &errorResp); err != nil {
+		t.Fatalf("Expected an XML error, got %v: %s", err, w.Body.String())
+	}
+	if errorResp.Code != CodeUpstreamUnavailable || errorResp.Error == "" {
+		t.Errorf("Expected %s with a message, got %+v", CodeUpstreamUnavailable, errorResp)
+	}
+}
+
+func TestWeatherHandler_BinaryFormats(t *testing.T) {
+	mockService := &MockWeatherService{returnData: &service.WeatherData{City: "X", Cached: true}}
+	handler := New(mockService, 10, "", slog.Default())
+
+	for _, tc := range []struct {
+		accept      string
+		contentType string
+		expected    []byte
+	}{
+		{"application/x-protobuf", "application/x-protobuf", []byte{0x1a, 0x01, 'X', 0x30, 0x01}},
+		{"application/msgpack", "application/msgpack", []byte{0x88, 0xaf, 'O', 'b', 's'}},
+	} {
+		t.Run(tc.accept, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0", nil)
+			req.Header.Set("Accept", tc.accept)
+			w := httptest.NewRecorder()
+			handler.GetWeather(w, req)
+
+			if ct := w.Header().Get("Content-Type"); ct != tc.contentType {
+				t.Errorf("Expected %s, got %q", tc.contentType, ct)
+			}
+			if !bytes.HasPrefix(w.Body.Bytes(), tc.expected) {
+				t.Errorf("Expected a body starting with % x, got % x", tc.expected, w.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestBatchHandler_Protobuf(t *testing.T) {
+	handler := newTestBatchHandler(&MockWeatherService{shouldError: true})
+
+	req := httptest.NewRequest("POST", "/weather/batch?format=protobuf", strings.NewReader(`{"locations":[{"lat":1,"lon":0}]}`))
+	w := httptest.NewRecorder()
+	handler.GetWeatherBatch(w, req)
+
+	// One BatchResult with lat 1.0, no weather, and the error and code
+	result := []byte{0x11, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f, 0x2a, 28}
+	result = append(result, "Unable to fetch weather data"...)
+	result = append(append(result, 0x32, byte(len(CodeUpstreamUnavailable))), CodeUpstreamUnavailable...)
+	expected := append([]byte{0x0a, byte(len(result))}, result...)
+	if !bytes.Equal(w.Body.Bytes(), expected) {
+		t.Errorf("Expected % x, got % x", expected, w.Body.Bytes())
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	for _, tc := range []struct {
+		url      string
+		accept   string
+		offered  []string
+		expected string
+	}{
+		{"/weather", "", weatherFormats, FormatJSON},
+		{"/weather", "application/xml", weatherFormats, FormatXML},
+		{"/weather", "application/json;q=0.5, application/xml", weatherFormats, FormatXML},
+		{"/weather", "application/xml;q=0.5, application/json", weatherFormats, FormatJSON},
+		{"/weather", "image/png, */*;q=0.8", weatherFormats, FormatJSON},
+		{"/weather", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", weatherFormats, FormatHTML},
+		{"/weather/batch", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", batchFormats, FormatJSON},
+		{"/weather", "text/*", weatherFormats, FormatXML},
+		{"/weather", "application/*;q=0.9, application/json;q=0", weatherFormats, FormatXML},
+		{"/weather", "application/x-protobuf;q=0.9, application/msgpack", weatherFormats, FormatMsgpack},
+		{"/weather", "image/png", weatherFormats, FormatJSON},
+		{"/weather", "application/xml;q=2, application/msgpack;q=0.1", weatherFormats, FormatMsgpack},
+		{"/weather?format=xml", "application/json", weatherFormats, FormatXML},
+		{"/weather/batch", "application/x-ndjson", batchFormats, FormatNDJSON},
+		{"/weather/batch", "text/csv;q=0.8, application/json;q=0.7", batchFormats, FormatCSV},
+		{"/weather/batch", "application/xml", batchFormats, FormatJSON},
+	} {
+		req := httptest.NewRequest("GET", tc.url, nil)
+		req.Header.Set("Accept", tc.accept)
+		format, err := negotiateFormat(req, tc.offered)
+		if err != nil || format != tc.expected {
+			t.Errorf("%s with Accept %q: expected %s, got %s (%v)", tc.url, tc.accept, tc.expected, format, err)
+		}
+	}
+}
+
+func TestNegotiateFormat_UnsupportedFormat(t *testing.T) {
+	req := httptest.NewRequest("GET", "/weather?format=csv", nil)
+	if _, err := negotiateFormat(req, weatherFormats); err == nil {
+		t.Error("Expected an error for a format the endpoint doesn't offer")
+	}
+}
+
+func TestSendJSONResponse_Pretty(t *testing.T) {
+	for _, tc := range []struct {
+		url      string
+		accept   string
+		expected string
+	}{
+		{"/version", "", "{\"a\":1}\n"},
+		{"/version?pretty=true", "", "{\n  \"a\": 1\n}\n"},
+		{"/version?pretty=false", "", "{\"a\":1}\n"},
+		{"/version", "application/json; indent=4", "{\n    \"a\": 1\n}\n"},
+		{"/version", "application/json; indent=100", "{\n        \"a\": 1\n}\n"},
+		{"/version", "application/xml; indent=4", "{\"a\":1}\n"},
+	} {
+		req := httptest.NewRequest("GET", tc.url, nil)
+		req.Header.Set("Accept", tc.accept)
+		w := httptest.NewRecorder()
+		sendJSONResponse(w, req, slog.Default(), 200, map[string]int{"a": 1})
+
+		if w.Body.String() != tc.expected {
+			t.Errorf("%s with Accept %q: expected %q, got %q", tc.url, tc.accept, tc.expected, w.Body.String())
+		}
+	}
+}
+
+func TestWeatherHandler_PrettyErrors(t *testing.T) {
+	handler := New(&MockWeatherService{}, 10, "", slog.Default())
+
+	req := httptest.NewRequest("GET", "/weather?lat=91&lon=0&pretty=true", nil)
+	w := httptest.NewRecorder()
+	handler.GetWeather(w, req)
+
+	if !strings.HasPrefix(w.Body.String(), "{\n  \"error\": ") {
+		t.Errorf("Expected an indented error, got %q", w.Body.String())
+	}
+}
+
+func TestWeatherHandler_HTML(t *testing.T) {
+	mockService := &MockWeatherService{
+		returnData: &service.WeatherData{City: "<Boston>", Country: "US", Condition: "Clear", TemperatureCategory: "hot", Degraded: true, DataAgeSeconds: 90},
+	}
+	handler := New(mockService, 10, "", slog.Default())
+
+	req := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	w := httptest.NewRecorder()
+	handler.GetWeather(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Expected text/html, got %q", ct)
+	}
+	body := w.Body.String()
+	for _, expected := range []string{"<title>&lt;Boston&gt;</title>", "&lt;Boston&gt;, US", "Clear, hot", "90 seconds old"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected the page to contain %q, got %s", expected, body)
+		}
+	}
+}
+
+func TestWeatherHandler_HTMLError(t *testing.T) {
+	handler := New(&MockWeatherService{}, 10, "", slog.Default())
+
+	req := httptest.NewRequest("GET", "/weather?lat=91&lon=0", nil)
+	req.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	handler.GetWeather(w, req)
+
+	if w.Code != 400 || w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Fatalf("Expected a 400 HTML page, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), CodeInvalidCoordinates) {
+		t.Errorf("Expected the page to show the error code, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/middleware"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// deepCheckTimeout bounds all component checks of a deep health check together
+const deepCheckTimeout = 5 * time.Second
+
+// ComponentCheck reports whether a dependency is usable; a nil error means healthy
+type ComponentCheck func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check ComponentCheck
+}
+
+// ComponentHealth is the status of one component in a deep health check
+type ComponentHealth struct {
+	Status    string `json:"status"` // "ok" or "failing"
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// HealthResponse is the body of the health check
+type HealthResponse struct {
+	Status     string                     `json:"status"`
+	Timestamp  string                     `json:"timestamp"`
+	Components map[string]ComponentHealth `json:"components,omitempty"` // only for deep and readiness checks
+}
+
+// HealthHandler serves the health check used by load balancers and the Kubernetes liveness and readiness probes
+type HealthHandler struct {
+	maintenance *middleware.MaintenanceMode
+	drainer     *middleware.Drainer
+	checks      []namedCheck // run for ?deep=true
+	readiness   []namedCheck // run by /readyz
+	ready       atomic.Bool  // set once startup has finished
+	logger      *slog.Logger
+}
+
+// NewHealth creates a new HealthHandler reporting the given maintenance mode and drain state (either may be nil)
+func NewHealth(maintenance *middleware.MaintenanceMode, drainer *middleware.Drainer, logger *slog.Logger) *HealthHandler {
+	return &HealthHandler{maintenance: maintenance, drainer: drainer, logger: logger}
+}
+
+// UseDeepCheck adds a component check run by /health?deep=true
+func (hh *HealthHandler) UseDeepCheck(name string, check ComponentCheck) {
+	hh.checks = append(hh.checks, namedCheck{name: name, check: check})
+}
+
+// UseReadinessCheck adds a component check run by /readyz
+func (hh *HealthHandler) UseReadinessCheck(name string, check ComponentCheck) {
+	hh.readiness = append(hh.readiness, namedCheck{name: name, check: check})
+}
+
+// SetReady marks startup as finished (or not) for /readyz
+func (hh *HealthHandler) SetReady(ready bool) {
+	hh.ready.Store(ready)
+}
+
+// Livez answers 200 as long as the process can serve requests at all
+// It deliberately ignores dependencies: restarting the process wouldn't fix an upstream outage
+func (hh *HealthHandler) Livez(w http.ResponseWriter, r *http.Request) {
+	sendJSONResponse(w, r, hh.logger, http.StatusOK, HealthResponse{Status: "ok", Timestamp: time.Now().UTC().Format(time.RFC3339)})
+}
+
+// Readyz answers 503 while this instance shouldn't receive traffic: during startup, maintenance
+// and draining, or while a readiness check (e.g. recent upstream reachability) fails
+func (hh *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	response := hh.readinessStatus(r.Context())
+	statusCode := http.StatusOK
+	if response.Status != "ready" {
+		statusCode = http.StatusServiceUnavailable
+	}
+	sendJSONResponse(w, r, hh.logger, statusCode, response)
+}
+
+// Ready returns an error naming the reason while Readyz would answer 503
+func (hh *HealthHandler) Ready(ctx context.Context) error {
+	if status := hh.readinessStatus(ctx).Status; status != "ready" {
+		return fmt.Errorf("instance is %s", status)
+	}
+	return nil
+}
+
+// readinessStatus works out the readiness response
+func (hh *HealthHandler) readinessStatus(ctx context.Context) HealthResponse {
+	response := HealthResponse{Status: "ready", Timestamp: time.Now().UTC().Format(time.RFC3339)}
+	switch {
+	case !hh.ready.Load():
+		response.Status = "starting"
+	case hh.drainer != nil && hh.drainer.Draining():
+		response.Status = "draining"
+	case hh.maintenance != nil && hh.inMaintenance():
+		response.Status = "maintenance"
+	default:
+		response.Components = hh.runChecks(ctx, hh.readiness)
+		for _, component := range response.Components {
+			if component.Status != "ok" {
+				response.Status = "not_ready"
+			}
+		}
+	}
+	return response
+}
+
+func (hh *HealthHandler) inMaintenance() bool {
+	enabled, _ := hh.maintenance.Status()
+	return enabled
+}
+
+// HealthCheck provides a simple health check endpoint
+// During maintenance or draining it answers 503 so load balancers move traffic away from this instance
+// With ?deep=true it also runs the component checks and answers 503 if any of them fails
+func (hh *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
+	response := HealthResponse{Status: "ok", Timestamp: time.Now().UTC().Format(time.RFC3339)}
+	statusCode := http.StatusOK
+	if r.URL.Query().Get("deep") == "true" {
+		response.Components = hh.runChecks(r.Context(), hh.checks)
+		for _, component := range response.Components {
+			if component.Status != "ok" {
+				response.Status, statusCode = "failing", http.StatusServiceUnavailable
+			}
+		}
+	}
+	if hh.maintenance != nil {
+		if enabled, _ := hh.maintenance.Status(); enabled {
+			response.Status, statusCode = "maintenance", http.StatusServiceUnavailable
+		}
+	}
+	if hh.drainer != nil && hh.drainer.Draining() {
+		response.Status, statusCode = "draining", http.StatusServiceUnavailable
+	}
+
+	sendJSONResponse(w, r, hh.logger, statusCode, response)
+}
+
+// runChecks runs the component checks in parallel
+// Failure details are only logged since health endpoints are unauthenticated and errors can contain internal addresses
+func (hh *HealthHandler) runChecks(ctx context.Context, checks []namedCheck) map[string]ComponentHealth {
+	ctx, cancel := context.WithTimeout(ctx, deepCheckTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	components := make(map[string]ComponentHealth, len(checks))
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			err := c.check(ctx)
+			component := ComponentHealth{Status: "ok", LatencyMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				component.Status = "failing"
+				hh.logger.WarnContext(ctx, "health check failed", slog.String("component", c.name), slog.String("error", err.Error()))
+			}
+
+			mu.Lock()
+			components[c.name] = component
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return components
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/krizvi/weather-app-server/internal/middleware"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthHandler_DeepCheck(t *testing.T) {
+	health := NewHealth(nil, nil, slog.Default())
+	health.UseDeepCheck("cache", func(ctx context.Context) error { return nil })
+	health.UseDeepCheck("upstream", func(ctx context.Context) error { return errors.New("invalid API key") })
+
+	// Shallow checks don't run the component checks
+	w := httptest.NewRecorder()
+	health.HealthCheck(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != 200 {
+		t.Errorf("Expected 200 for a shallow check, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	health.HealthCheck(w, httptest.NewRequest("GET", "/health?deep=true", nil))
+	if w.Code != 503 {
+		t.Errorf("Expected 503 with a failing component, got %d", w.Code)
+	}
+
+	var response HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Status != "failing" || response.Components["cache"].Status != "ok" || response.Components["upstream"].Status != "failing" {
+		t.Errorf("Expected failing upstream and healthy cache, got %+v", response)
+	}
+}
+
+func TestHealthHandler_Readyz(t *testing.T) {
+	drainer := middleware.NewDrainer(nil)
+	health := NewHealth(nil, drainer, slog.Default())
+	upstreamErr := errors.New("connection refused")
+	health.UseReadinessCheck("upstream", func(ctx context.Context) error { return upstreamErr })
+
+	readyz := func() int {
+		w := httptest.NewRecorder()
+		health.Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+		return w.Code
+	}
+
+	if code := readyz(); code != 503 {
+		t.Errorf("Expected 503 during startup, got %d", code)
+	}
+	health.SetReady(true)
+	if code := readyz(); code != 503 {
+		t.Errorf("Expected 503 while upstream is unreachable, got %d", code)
+	}
+	upstreamErr = nil
+	if code := readyz(); code != 200 {
+		t.Errorf("Expected 200 once ready, got %d", code)
+	}
+	drainer.Drain()
+	if code := readyz(); code != 503 {
+		t.Errorf("Expected 503 while draining, got %d", code)
+	}
+
+	// Liveness doesn't care about any of it
+	w := httptest.NewRecorder()
+	health.Livez(w, httptest.NewRequest("GET", "/livez", nil))
+	if w.Code != 200 {
+		t.Errorf("Expected live process to answer 200, got %d", w.Code)
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"html/template"
+)
+
+// htmlPages renders weather data and errors for browsers
+var htmlPages = template.Must(template.New("pages").Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 32rem; margin: 3rem auto; padding: 0 1rem; color: #222; }
+h1 { margin-bottom: 0.25rem; }
+.condition { font-size: 2rem; margin: 1rem 0 0.25rem; }
+dl { display: grid; grid-template-columns: max-content auto; gap: 0.25rem 1rem; color: #555; }
+dd { margin: 0; }
+.notice { padding: 0.5rem 0.75rem; background: #fff4d6; border-radius: 4px; }
+</style>
+</head>
+<body>
+{{end}}
+
+{{define "weather"}}{{template "head" (or .City "Weather")}}
+<h1>{{if .City}}{{.City}}{{else}}Unknown location{{end}}{{if .Country}}, {{.Country}}{{end}}</h1>
+<p class="condition">{{.Condition}}, {{.TemperatureCategory}}</p>
+{{if .Static}}<p class="notice">The weather provider is unavailable; this is placeholder data.</p>
+{{else if .Degraded}}<p class="notice">The weather provider is unavailable; this data is {{.DataAgeSeconds}} seconds old.</p>
+{{else if .Stale}}<p class="notice">This data is past its freshness lifetime.</p>
+{{end}}<dl>
+<dt>Observed</dt><dd>{{.ObservationTime}}</dd>
+<dt>Fetched</dt><dd>{{.FetchedAt.UTC.Format "2006-01-02 15:04:05 MST"}}{{if .Cached}} (cached, {{.AgeSeconds}}s old){{end}}</dd>
+</dl>
+</body>
+</html>
+{{end}}
+
+{{define "error"}}{{template "head" "Error"}}
+<h1>Something went wrong</h1>
+<p>{{.Error}}</p>
+<dl>
+<dt>Code</dt><dd>{{.Code}}</dd>
+{{if .RequestID}}<dt>Request ID</dt><dd>{{.RequestID}}</dd>
+{{end}}</dl>
+</body>
+</html>
+{{end}}
+`))
+
+// encodeHTML renders weather data or an error as an HTML page
+func encodeHTML(data interface{}) ([]byte, error) {
+	var page string
+	switch data.(type) {
+	case *service.WeatherData:
+		page = "weather"
+	case ErrorResponse:
+		page = "error"
+	default:
+		return nil, fmt.Errorf("no HTML page for %T", data)
+	}
+
+	var buf bytes.Buffer
+	if err := htmlPages.ExecuteTemplate(&buf, page, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
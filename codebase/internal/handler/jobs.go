@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job states
+const (
+	JobRunning  = "running"
+	JobDone     = "done"
+	JobCanceled = "canceled" // stopped by a shutdown before every location was looked up
+)
+
+// Job is the status of an asynchronous batch lookup, as served by GET /jobs/{id}
+type Job struct {
+	ID         string        `json:"id"`
+	Status     string        `json:"status"`
+	Total      int           `json:"total"`
+	Completed  int           `json:"completed"`
+	CreatedAt  time.Time     `json:"created_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+	ExpiresAt  *time.Time    `json:"expires_at,omitempty"` // when the results are dropped, once finished
+	Results    []BatchResult `json:"results,omitempty"`    // in request order, once finished
+}
+
+// job is a Job and the lookups filling it in
+type job struct {
+	mu      sync.Mutex
+	status  Job
+	results []BatchResult
+}
+
+// JobHandler runs batch lookups in the background for clients that can't hold a connection open for them,
+// keeping the results for a while after they finish
+type JobHandler struct {
+	batch        *BatchHandler // does the lookups, sharing its pool, concurrency and timeout
+	maxLocations int
+	maxJobs      int
+	retention    time.Duration
+	ctx          context.Context // canceled by Close
+	cancel       context.CancelFunc
+	logger       *slog.Logger
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+// NewJobs creates a JobHandler looking up to maxLocations locations per job through batch, holding at most
+// maxJobs running or finished jobs, each kept for retentionSec seconds after it finishes
+func NewJobs(batch *BatchHandler, maxLocations, maxJobs, retentionSec int, logger *slog.Logger) *JobHandler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &JobHandler{
+		batch:        batch,
+		maxLocations: maxLocations,
+		maxJobs:      maxJobs,
+		retention:    time.Duration(retentionSec) * time.Second,
+		ctx:          ctx,
+		cancel:       cancel,
+		logger:       logger,
+		jobs:         make(map[string]*job),
+	}
+}
+
+// Close cancels the running jobs, e.g. on shutdown; their results so far stay readable
+func (jh *JobHandler) Close() {
+	jh.cancel()
+}
+
+// CreateWeatherJob handles POST requests to /jobs/weather
+// It takes the body of /weather/batch, with more locations, and answers 202 with the job to poll right away
+func (jh *JobHandler) CreateWeatherJob(w http.ResponseWriter, r *http.Request) {
+	batch, ok := decodeBatch(w, r, jh.logger, jh.maxLocations)
+	if !ok {
+		return
+	}
+
+	j, ok := jh.add(len(batch.Locations))
+	if !ok {
+		w.Header().Set("Retry-After", "60")
+		sendErrorResponse(w, r, jh.logger, http.StatusServiceUnavailable, CodeJobLimit, "Too many jobs, please retry later")
+		return
+	}
+	go jh.run(j, batch.Locations)
+
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/weather")+"/"+j.status.ID)
+	sendJSONResponse(w, r, jh.logger, http.StatusAccepted, j.snapshot())
+}
+
+// GetJob handles GET requests to /jobs/{id}
+func (jh *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	jh.mu.Lock()
+	jh.prune()
+	j, ok := jh.jobs[r.PathValue("id")]
+	jh.mu.Unlock()
+	if !ok {
+		sendErrorResponse(w, r, jh.logger, http.StatusNotFound, CodeNotFound, "Job not found")
+		return
+	}
+	sendJSONResponse(w, r, jh.logger, http.StatusOK, j.snapshot())
+}
+
+// add registers a new job for total locations, unless there are maxJobs already
+func (jh *JobHandler) add(total int) (*job, bool) {
+	jh.mu.Lock()
+	defer jh.mu.Unlock()
+
+	jh.prune()
+	if len(jh.jobs) >= jh.maxJobs {
+		return nil, false
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	j := &job{
+		status:  Job{ID: hex.EncodeToString(id), Status: JobRunning, Total: total, CreatedAt: time.Now().UTC()},
+		results: make([]BatchResult, total),
+	}
+	jh.jobs[j.status.ID] = j
+	return j, true
+}
+
+// prune drops the jobs past their retention; jh.mu must be held
+func (jh *JobHandler) prune() {
+	now := time.Now()
+	for id, j := range jh.jobs {
+		j.mu.Lock()
+		expired := j.status.ExpiresAt != nil && now.After(*j.status.ExpiresAt)
+		j.mu.Unlock()
+		if expired {
+			delete(jh.jobs, id)
+		}
+	}
+}
+
+// run looks the locations up and records the results as they come in
+func (jh *JobHandler) run(j *job, locations []BatchLocation) {
+	for i, location := range locations {
+		j.results[i] = BatchResult{Index: i, Lat: location.Lat, Lon: location.Lon, Error: "Request canceled", Code: CodeCanceled}
+	}
+	jh.batch.lookup(jh.ctx, locations, jh.batch.settings.Load().ClientTimeout, func(result BatchResult) {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.results[result.Index] = result
+		j.status.Completed++
+	})
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	finished := time.Now().UTC()
+	expires := finished.Add(jh.retention)
+	j.status.FinishedAt, j.status.ExpiresAt = &finished, &expires
+	j.status.Status = JobDone
+	if j.status.Completed < j.status.Total {
+		j.status.Status = JobCanceled
+	}
+	jh.logger.Info("job finished", slog.String("job_id", j.status.ID), slog.String("status", j.status.Status), slog.Int("locations", j.status.Total))
+}
+
+// snapshot returns the job's status, with the results once it finished
+func (j *job) snapshot() Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	status := j.status
+	if status.FinishedAt != nil {
+		status.Results = j.results
+	}
+	return status
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestJobHandler(mockService service.WeatherService, maxJobs int) (*JobHandler, *http.ServeMux) {
+	jobs := NewJobs(newTestBatchHandler(mockService), 5, maxJobs, 60, slog.Default())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jobs/weather", jobs.CreateWeatherJob)
+	mux.HandleFunc("/jobs/{id}", jobs.GetJob)
+	return jobs, mux
+}
+
+func TestJobHandler_RunsBatchInBackground(t *testing.T) {
+	_, mux := newTestJobHandler(&MockWeatherService{returnData: &service.WeatherData{Condition: "Clear"}}, 10)
+
+	body := `{"locations":[{"lat":1,"lon":1},{"lat":2,"lon":2},{"lat":3,"lon":3},{"lat":4,"lon":4}]}`
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/jobs/weather", strings.NewReader(body)))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", w.Code, w.Body)
+	}
+	var job Job
+	json.Unmarshal(w.Body.Bytes(), &job)
+	if job.ID == "" || job.Total != 4 || w.Header().Get("Location") != "/jobs/"+job.ID {
+		t.Fatalf("Unexpected job %+v at %q", job, w.Header().Get("Location"))
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for job.Status == JobRunning && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/jobs/"+job.ID, nil))
+		json.Unmarshal(w.Body.Bytes(), &job)
+	}
+	if job.Status != JobDone || job.Completed != 4 || len(job.Results) != 4 || job.ExpiresAt == nil {
+		t.Fatalf("Expected the job to finish with 4 results, got %+v", job)
+	}
+	if job.Results[3].Lat != 4 || job.Results[3].Weather == nil || job.Results[3].Weather.Condition != "Clear" {
+		t.Errorf("Expected the results in request order, got %+v", job.Results[3])
+	}
+}
+
+func TestJobHandler_LimitsJobs(t *testing.T) {
+	_, mux := newTestJobHandler(&MockWeatherService{returnData: &service.WeatherData{}}, 1)
+
+	body := `{"locations":[{"lat":1,"lon":1}]}`
+	for i, want := range []int{http.StatusAccepted, http.StatusServiceUnavailable} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", "/jobs/weather", strings.NewReader(body)))
+		if w.Code != want {
+			t.Errorf("Job %d: expected %d, got %d", i, want, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/jobs/unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown job, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/jobs/weather", strings.NewReader(`{"locations":[]}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an empty job, got %d", w.Code)
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StreamHandler pushes the weather at a location to browsers as Server-Sent Events
+type StreamHandler struct {
+	weatherService service.WeatherService
+	settings       *service.RuntimeSettings // time each lookup gets
+	interval       time.Duration
+	maxClients     int64
+	clients        atomic.Int64
+	done           chan struct{} // closed by Close to end every stream
+	closeOnce      sync.Once
+	logger         *slog.Logger
+}
+
+// NewStream creates a StreamHandler looking the weather up every intervalSec seconds for each of at most
+// maxClients concurrent streams
+func NewStream(weatherService service.WeatherService, externalApiTimeout, intervalSec, maxClients int, logger *slog.Logger) *StreamHandler {
+	return &StreamHandler{
+		weatherService: weatherService,
+		settings:       service.NewRuntimeSettings(service.RuntimeConfig{ClientTimeout: time.Duration(externalApiTimeout) * time.Second}),
+		interval:       time.Duration(intervalSec) * time.Second,
+		maxClients:     int64(maxClients),
+		done:           make(chan struct{}),
+		logger:         logger,
+	}
+}
+
+// UseRuntimeSettings makes each lookup get the client timeout in settings as of its start, instead of the
+// one given to NewStream
+func (sh *StreamHandler) UseRuntimeSettings(settings *service.RuntimeSettings) {
+	sh.settings = settings
+}
+
+// Close ends every open stream, e.g. on shutdown, which would otherwise wait for the clients to hang up
+func (sh *StreamHandler) Close() {
+	sh.closeOnce.Do(func() { close(sh.done) })
+}
+
+// StreamWeather handles GET requests to /weather/stream
+// It sends a "weather" event whenever the data for the location changes, checking every interval, and a
+// comment otherwise to keep proxies from closing the connection. Each event's id is the time the data was
+// fetched, so a reconnecting browser sending Last-Event-ID only gets data it hasn't seen
+// Failed lookups are sent as "error" events carrying the error body of /weather; the stream goes on
+func (sh *StreamHandler) StreamWeather(w http.ResponseWriter, r *http.Request) {
+	lat, lon, err := parseCoordinates(r)
+	if err != nil {
+		sendErrorResponse(w, r, sh.logger, http.StatusBadRequest, CodeInvalidCoordinates, err.Error())
+		return
+	}
+	if sh.clients.Add(1) > sh.maxClients {
+		sh.clients.Add(-1)
+		w.Header().Set("Retry-After", strconv.Itoa(int(sh.interval.Seconds())))
+		sendErrorResponse(w, r, sh.logger, http.StatusServiceUnavailable, CodeTooManyStreams, "Too many open streams, please retry later")
+		return
+	}
+	defer sh.clients.Add(-1)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx would otherwise hold events back
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return // the headers are all a HEAD gets, and the stream would never end
+	}
+
+	rc := http.NewResponseController(w)
+	lastID := r.Header.Get("Last-Event-ID")
+	// Browsers reconnect after the retry delay, so a dropped stream resumes at the next lookup
+	if !sh.send(w, rc, fmt.Sprintf("retry: %d\n\n", sh.interval.Milliseconds())) {
+		return
+	}
+
+	ticker := time.NewTicker(sh.interval)
+	defer ticker.Stop()
+	for {
+		event := sh.nextEvent(r, lat, lon, &lastID)
+		if !sh.send(w, rc, event) {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return
+		case <-sh.done:
+			return
+		}
+	}
+}
+
+// nextEvent looks the weather up and formats the event to send, updating lastID when the data changed
+func (sh *StreamHandler) nextEvent(r *http.Request, lat, lon float64, lastID *string) string {
+	ctx, cancel := context.WithTimeout(r.Context(), sh.settings.Load().ClientTimeout)
+	defer cancel()
+
+	data, err := sh.weatherService.GetWeather(ctx, lat, lon)
+	if err != nil {
+		if isRequestAborted(ctx) {
+			return ": canceled\n\n"
+		}
+		sh.logger.WarnContext(ctx, "stream lookup failed", slog.String("error", err.Error()))
+		_, code, message, _ := serviceErrorStatus(err)
+		body, _ := encodeJSON(ErrorResponse{Error: message, Code: code})
+		return "event: error\ndata: " + string(body) + "\n"
+	}
+
+	id := strconv.FormatInt(data.FetchedAt.UnixMilli(), 10)
+	if id == *lastID {
+		return ": unchanged\n\n"
+	}
+	body, err := encodeJSON(data)
+	if err != nil {
+		sh.logger.ErrorContext(ctx, "JSON event encoding failed", slog.String("error", err.Error()))
+		return ": encoding failed\n\n"
+	}
+	*lastID = id
+	return "id: " + id + "\nevent: weather\ndata: " + string(body) + "\n"
+}
+
+// send writes and flushes event, pushing the write deadline out first so the stream outlives the server's
+// WriteTimeout; it reports false once the client is gone
+func (sh *StreamHandler) send(w http.ResponseWriter, rc *http.ResponseController, event string) bool {
+	rc.SetWriteDeadline(time.Now().Add(sh.interval + sh.settings.Load().ClientTimeout))
+	if _, err := w.Write([]byte(event)); err != nil {
+		return false
+	}
+	return rc.Flush() == nil
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"bufio"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvent reads the next event or comment from an SSE stream, without the blank line ending it
+func readEvent(t *testing.T, reader *bufio.Reader) string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Expected another event, got %v", err)
+		}
+		if line == "\n" {
+			return strings.Join(lines, "")
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestStreamHandler_SendsChangedData(t *testing.T) {
+	fetchedAt := time.UnixMilli(1700000000000)
+	mockService := &MockWeatherService{returnData: &service.WeatherData{Condition: "Clear", FetchedAt: fetchedAt}}
+	handler := NewStream(mockService, 10, 60, 10, slog.Default())
+	server := httptest.NewServer(http.HandlerFunc(handler.StreamWeather))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/weather/stream?lat=40.7&lon=-74.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %q", ct)
+	}
+	reader := bufio.NewReader(resp.Body)
+	if event := readEvent(t, reader); event != "retry: 60000\n" {
+		t.Errorf("Expected the retry delay first, got %q", event)
+	}
+	event := readEvent(t, reader)
+	if !strings.HasPrefix(event, "id: 1700000000000\nevent: weather\ndata: {") || !strings.Contains(event, `"Condition":"Clear"`) {
+		t.Errorf("Expected a weather event, got %q", event)
+	}
+
+	handler.Close()
+	if _, err := io.ReadAll(reader); err != nil {
+		t.Errorf("Expected the stream to end cleanly on Close, got %v", err)
+	}
+}
+
+func TestStreamHandler_ResumesAfterLastEventID(t *testing.T) {
+	mockService := &MockWeatherService{returnData: &service.WeatherData{FetchedAt: time.UnixMilli(1700000000000)}}
+	handler := NewStream(mockService, 10, 60, 10, slog.Default())
+	server := httptest.NewServer(http.HandlerFunc(handler.StreamWeather))
+	defer server.Close()
+	defer handler.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/weather/stream?lat=40.7&lon=-74.0", nil)
+	req.Header.Set("Last-Event-ID", "1700000000000")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	readEvent(t, reader)
+	if event := readEvent(t, reader); event != ": unchanged\n" {
+		t.Errorf("Expected data the client has to be skipped, got %q", event)
+	}
+}
+
+func TestStreamHandler_SendsErrorEvents(t *testing.T) {
+	handler := NewStream(&MockWeatherService{shouldError: true}, 10, 60, 10, slog.Default())
+	server := httptest.NewServer(http.HandlerFunc(handler.StreamWeather))
+	defer server.Close()
+	defer handler.Close()
+
+	resp, err := http.Get(server.URL + "/weather/stream?lat=40.7&lon=-74.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	readEvent(t, reader)
+	if event := readEvent(t, reader); !strings.HasPrefix(event, "event: error\ndata: {") || !strings.Contains(event, `"code":"UPSTREAM_UNAVAILABLE"`) {
+		t.Errorf("Expected an error event, got %q", event)
+	}
+}
+
+func TestStreamHandler_LimitsClients(t *testing.T) {
+	handler := NewStream(&MockWeatherService{}, 10, 60, 0, slog.Default())
+	w := httptest.NewRecorder()
+	handler.StreamWeather(w, httptest.NewRequest("GET", "/weather/stream?lat=40.7&lon=-74.0", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), CodeTooManyStreams) {
+		t.Errorf("Expected 503 %s, got %d %s", CodeTooManyStreams, w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	handler.StreamWeather(w, httptest.NewRequest("GET", "/weather/stream?lat=100&lon=-74.0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid coordinates, got %d", w.Code)
+	}
+}
+
+func TestStreamHandler_HeadDoesNotStream(t *testing.T) {
+	mockService := &MockWeatherService{returnData: &service.WeatherData{Condition: "Clear"}}
+	handler := NewStream(mockService, 10, 60, 10, slog.Default())
+	w := httptest.NewRecorder()
+	handler.StreamWeather(w, httptest.NewRequest("HEAD", "/weather/stream?lat=40.7&lon=-74.0", nil))
+
+	if ct := w.Header().Get("Content-Type"); w.Code != http.StatusOK || ct != "text/event-stream" {
+		t.Errorf("Expected 200 with text/event-stream, got %d %q", w.Code, ct)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected no events, got %q", w.Body)
+	}
+}
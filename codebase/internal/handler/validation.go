@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// plainDecimal matches coordinates written as plain decimals, ruling out ParseFloat extras like
+// "NaN", "Inf", exponents, hex floats, underscores and leading "+"
+var plainDecimal = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// StrictValidation holds the tighter input rules of strict mode for public-facing deployments
+type StrictValidation struct {
+	MaxDecimals    int // most decimal places accepted in a coordinate (0 for no limit)
+	MaxQueryLength int // longest raw query string accepted (0 for no limit)
+}
+
+// check rejects queries that ParseFloat-based parsing would let through
+// allowed lists every query parameter the endpoint understands; anything else is an error
+func (sv *StrictValidation) check(r *http.Request, allowed ...string) (string, error) {
+	if sv.MaxQueryLength > 0 && len(r.URL.RawQuery) > sv.MaxQueryLength {
+		return CodeInvalidRequest, fmt.Errorf("query string must be at most %d characters", sv.MaxQueryLength)
+	}
+
+	query, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		return CodeInvalidRequest, fmt.Errorf("malformed query string")
+	}
+
+	for name, values := range query {
+		if !contains(allowed, name) {
+			return CodeInvalidRequest, fmt.Errorf("unknown query parameter: %s", name)
+		}
+		if len(values) > 1 {
+			return CodeInvalidRequest, fmt.Errorf("duplicate query parameter: %s", name)
+		}
+	}
+
+	lat, lon, _ := coordinateStrings(r) // parseCoordinates reports conflicting aliases
+	for _, coordinate := range [][2]string{{"lat", lat}, {"lon", lon}} {
+		name, value := coordinate[0], coordinate[1]
+		if value == "" {
+			continue // parseCoordinates reports missing values
+		}
+		if !plainDecimal.MatchString(value) {
+			return CodeInvalidCoordinates, fmt.Errorf("%s must be a plain decimal number, got: %s", name, value)
+		}
+		if _, decimals, ok := strings.Cut(value, "."); ok && sv.MaxDecimals > 0 && len(decimals) > sv.MaxDecimals {
+			return CodeInvalidCoordinates, fmt.Errorf("%s must have at most %d decimal places, got: %s", name, sv.MaxDecimals, value)
+		}
+	}
+	return "", nil
+}
+
+// contains reports whether values includes value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"github.com/krizvi/weather-app-server/internal/service"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWeatherHandler_StrictValidation(t *testing.T) {
+	mockService := &MockWeatherService{returnData: &service.WeatherData{Condition: "Clear"}}
+	handler := New(mockService, 10, "", slog.Default())
+	handler.UseStrictValidation(&StrictValidation{MaxDecimals: 4, MaxQueryLength: 64})
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"lat=40.7128&lon=-74.006", 200},
+		{"lat=40&lon=-74", 200},
+		{"lat=NaN&lon=1", 400},
+		{"lat=Inf&lon=1", 400},
+		{"lat=1e1&lon=1", 400},
+		{"lat=+40&lon=1", 400},
+		{"lat=40.71281&lon=1", 400},
+		{"lat=40&lat=41&lon=1", 400},
+		{"lat=40&lon=1&units=metric", 400},
+		{"latitude=40&lng=-74", 200},
+		{"latitude=4e1&lng=-74", 400},
+		{"lat=40&lon=1&refresh=" + strings.Repeat("x", 64), 400},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.GetWeather(w, httptest.NewRequest("GET", "/weather?"+tt.query, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.query, tt.status, w.Code)
+		}
+	}
+}
+
+func TestWeatherHandler_RejectsNaN(t *testing.T) {
+	handler := New(&MockWeatherService{}, 10, "", slog.Default())
+
+	w := httptest.NewRecorder()
+	handler.GetWeather(w, httptest.NewRequest("GET", "/weather?lat=NaN&lon=NaN", nil))
+	if w.Code != 400 {
+		t.Errorf("Expected 400 for NaN coordinates, got %d", w.Code)
+	}
+}
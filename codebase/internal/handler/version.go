@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"github.com/krizvi/weather-app-server/internal/buildinfo"
+	"log/slog"
+	"net/http"
+)
+
+// VersionResponse is the body of /version
+type VersionResponse struct {
+	buildinfo.Info
+	Provider string `json:"provider"` // weather data provider in use
+	Profile  string `json:"profile"`  // configuration profile, e.g. "production"
+}
+
+// Version returns a handler serving the running build and configuration, so support can tell
+// exactly what is deployed when triaging an issue
+func Version(info VersionResponse, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sendJSONResponse(w, r, logger, http.StatusOK, info)
+	}
+}
@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/krizvi/weather-app-server/internal/buildinfo"
+	"log/slog"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	w := httptest.NewRecorder()
+	Version(VersionResponse{Info: buildinfo.Get(), Provider: "openweathermap", Profile: "staging"}, slog.Default())(w, httptest.NewRequest("GET", "/version", nil))
+
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected JSON, got %v", err)
+	}
+	if body["version"] != "dev" || body["go_version"] != runtime.Version() || body["provider"] != "openweathermap" || body["profile"] != "staging" {
+		t.Errorf("Expected the build and config details, got %v", body)
+	}
+}
@@ -3,74 +3,218 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"github.com/krizvi/weather-app-server/internal/analytics"
+	"github.com/krizvi/weather-app-server/internal/audit"
+	"github.com/krizvi/weather-app-server/internal/metering"
+	"github.com/krizvi/weather-app-server/internal/middleware"
+	"github.com/krizvi/weather-app-server/internal/requestid"
 	"github.com/krizvi/weather-app-server/internal/service"
-	"log"
+	"github.com/krizvi/weather-app-server/internal/tracing"
 	"log/slog"
+	"math"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // ErrorResponse represents an error response
+// Code is a stable machine-readable identifier (see errors.go); Error is for humans and may change
+// RequestID and TraceID are there for users to quote in support tickets
 type ErrorResponse struct {
-	Error string `json:"error"`
+	XMLName   xml.Name `json:"-" xml:"error"`
+	Error     string   `json:"error" xml:"message"`
+	Code      string   `json:"code" xml:"code"`
+	RequestID string   `json:"request_id,omitempty" xml:"request_id,omitempty"`
+	TraceID   string   `json:"trace_id,omitempty" xml:"trace_id,omitempty"`
 }
 
 // WeatherHandler handles HTTP requests
 // will delegate all processing to the service
 type WeatherHandler struct {
-	weatherService     service.WeatherService
-	externalApiTimeout int
+	weatherService service.WeatherService
+	settings       *service.RuntimeSettings // client timeout of each lookup, swapped on config reloads
+	adminToken     string                   // required for ?refresh=true (empty disables forced refreshes)
+	strict         *StrictValidation        // optional tighter input validation
+	logger         *slog.Logger
 }
 
 // New creates a new WeatherHandler instance
-func New(weatherService service.WeatherService, externalApiTimeout int) *WeatherHandler {
+func New(weatherService service.WeatherService, externalApiTimeout int, adminToken string, logger *slog.Logger) *WeatherHandler {
 	return &WeatherHandler{
-		weatherService:     weatherService,
-		externalApiTimeout: externalApiTimeout,
+		weatherService: weatherService,
+		settings:       service.NewRuntimeSettings(service.RuntimeConfig{ClientTimeout: time.Duration(externalApiTimeout) * time.Second}),
+		adminToken:     adminToken,
+		logger:         logger,
 	}
 }
 
-// GetWeather handles GET requests to /weather endpoint
-func (wh *WeatherHandler) GetWeather(w http.ResponseWriter, r *http.Request) {
-	// Log the incoming request
-	slog.Info("GetWeather", slog.String("method", r.Method), slog.String("path", r.URL.Path), slog.String("remote-address", r.RemoteAddr))
+// UseRuntimeSettings makes requests get the client timeout in settings as of their start, instead of the
+// one given to New, before they fail
+func (wh *WeatherHandler) UseRuntimeSettings(settings *service.RuntimeSettings) {
+	wh.settings = settings
+}
 
-	// Only allow GET requests
-	if r.Method != http.MethodGet {
-		wh.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
+// UseStrictValidation turns on strict input validation for /weather
+func (wh *WeatherHandler) UseStrictValidation(strict *StrictValidation) {
+	wh.strict = strict
+}
 
+// GetWeather handles GET requests to /weather endpoint
+func (wh *WeatherHandler) GetWeather(w http.ResponseWriter, r *http.Request) {
 	// Parse and validate query parameters
-	lat, lon, err := wh.parseCoordinates(r)
+	if wh.strict != nil {
+		if code, err := wh.strict.check(r, slices.Concat(latitudeParams, longitudeParams, []string{"refresh", "format", "pretty"})...); err != nil {
+			sendErrorResponse(w, r, wh.logger, http.StatusBadRequest, code, err.Error())
+			return
+		}
+	}
+	lat, lon, err := parseCoordinates(r)
 	if err != nil {
-		wh.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		sendErrorResponse(w, r, wh.logger, http.StatusBadRequest, CodeInvalidCoordinates, err.Error())
+		return
+	}
+	format, ok := negotiate(w, r, wh.logger, weatherFormats)
+	if !ok {
 		return
 	}
 
 	// Create context with timeout for the external API call
-	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(wh.externalApiTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), wh.settings.Load().ClientTimeout)
 	defer cancel()
 
+	// Admins can bypass the cache to debug stale-data complaints
+	if r.URL.Query().Get("refresh") == "true" {
+		if !isAdmin(r, wh.adminToken) {
+			sendErrorResponse(w, r, wh.logger, http.StatusForbidden, CodeForbidden, "refresh requires admin authorization")
+			return
+		}
+		audit.SetCaller(ctx, "admin")
+		ctx = service.WithForceRefresh(ctx)
+	}
+
+	// Admins can have the upstream exchange behind this request logged in full
+	if r.Header.Get("X-Debug-Dump") == "true" && isAdmin(r, wh.adminToken) {
+		audit.SetCaller(ctx, "admin")
+		ctx = service.WithDebugDump(ctx)
+	}
+
 	// Fetch weather data
 	weatherData, err := wh.weatherService.GetWeather(ctx, lat, lon)
 	if err != nil {
-		log.Printf("Error fetching weather data: %v", err)
-		wh.sendErrorResponse(w, http.StatusServiceUnavailable, "Unable to fetch weather data")
+		if isRequestAborted(ctx) {
+			wh.logger.DebugContext(ctx, "weather request aborted", slog.String("error", err.Error()))
+		} else {
+			wh.logger.ErrorContext(ctx, "weather lookup failed", slog.String("error", err.Error()))
+		}
+		sendServiceError(ctx, w, r, wh.logger, err)
 		return
 	}
 
+	middleware.AddAccessLogAttrs(r.Context(), slog.String("cache", cacheResult(weatherData)))
+	analytics.RecordLocation(r.Context(), lat, lon, weatherData.City, weatherData.Country)
+	if cacheResult(weatherData) == "miss" {
+		metering.RecordUpstreamCall(r.Context())
+	}
+	wh.setCacheHeaders(w, weatherData)
+	if weatherData.Degraded {
+		// Tell clients (and monitoring) this is last-known-good data served during an upstream failure
+		w.Header().Set("X-Weather-Status", "degraded")
+	}
+	if weatherData.Static {
+		// Placeholders must not outlive the outage in client or proxy caches
+		w.Header().Set("Cache-Control", "no-store")
+	}
+
+	// Let polling clients revalidate without us encoding the payload again
+	if etag := formatETag(r, weatherData.ETag, format); etag != "" {
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
 	// Send successful response
-	wh.sendJSONResponse(w, http.StatusOK, weatherData)
-	log.Printf("Successfully served weather data for coordinates (%.4f, %.4f)", lat, lon)
+	sendResponse(w, r, wh.logger, http.StatusOK, format, weatherData)
 }
 
-// parseCoordinates extracts and validates latitude and longitude from query parameters
-func (wh *WeatherHandler) parseCoordinates(r *http.Request) (float64, float64, error) {
-	latStr := r.URL.Query().Get("lat")
-	lonStr := r.URL.Query().Get("lon")
+// cacheResult describes where the served data came from for the access log
+func cacheResult(data *service.WeatherData) string {
+	switch {
+	case data.Static:
+		return "static"
+	case data.Degraded:
+		return "last_known_good"
+	case data.Stale:
+		return "stale"
+	case data.Cached:
+		return "hit"
+	}
+	return "miss"
+}
+
+// Query parameter names accepted for the coordinates; some client SDKs send the long forms
+var (
+	latitudeParams  = []string{"lat", "latitude"}
+	longitudeParams = []string{"lon", "longitude", "lng", "long"}
+)
+
+// hasCoordinateParams reports whether query sets either coordinate under any of its names, even to ""
+func hasCoordinateParams(query url.Values) bool {
+	for _, name := range slices.Concat(latitudeParams, longitudeParams) {
+		if query.Has(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// coordinateStrings returns the raw latitude and longitude, from the path of /weather/{lat}/{lon}
+// or else from the query parameters
+// It returns an error if aliases of the same coordinate are given different values
+func coordinateStrings(r *http.Request) (string, string, error) {
+	if lat, lon := r.PathValue("lat"), r.PathValue("lon"); lat != "" || lon != "" {
+		return lat, lon, nil
+	}
+	query := r.URL.Query()
+	lat, err := queryParam(query, latitudeParams)
+	if err != nil {
+		return "", "", err
+	}
+	lon, err := queryParam(query, longitudeParams)
+	if err != nil {
+		return "", "", err
+	}
+	return lat, lon, nil
+}
+
+// queryParam returns the value of whichever of names is set, or an error if they disagree
+func queryParam(query url.Values, names []string) (string, error) {
+	var value, setBy string
+	for _, name := range names {
+		v := query.Get(name)
+		if v == "" {
+			continue
+		}
+		if value != "" && v != value {
+			return "", fmt.Errorf("conflicting values for %s and %s: %s and %s", setBy, name, value, v)
+		}
+		value, setBy = v, name
+	}
+	return value, nil
+}
+
+// parseCoordinates extracts and validates latitude and longitude from the path or query parameters
+func parseCoordinates(r *http.Request) (float64, float64, error) {
+	latStr, lonStr, err := coordinateStrings(r)
+	if err != nil {
+		return 0, 0, err
+	}
 
 	if latStr == "" || lonStr == "" {
 		return 0, 0, fmt.Errorf("lat and lon query parameters are required")
@@ -86,47 +230,128 @@ func (wh *WeatherHandler) parseCoordinates(r *http.Request) (float64, float64, e
 		return 0, 0, fmt.Errorf("invalid longitude value: %s", lonStr)
 	}
 
-	// Validate geographical bounds
+	if err := validateCoordinates(lat, lon); err != nil {
+		return 0, 0, err
+	}
+
+	return lat, lon, nil
+}
+
+// validateCoordinates checks that latitude and longitude are within geographical bounds
+func validateCoordinates(lat, lon float64) error {
+	// NaN slips through the range checks below since every comparison with it is false
+	if math.IsNaN(lat) || math.IsNaN(lon) {
+		return fmt.Errorf("latitude and longitude must be numbers")
+	}
+
 	if lat < -90 || lat > 90 {
-		return 0, 0, fmt.Errorf("latitude must be between -90 and 90, got: %.4f", lat)
+		return fmt.Errorf("latitude must be between -90 and 90, got: %.4f", lat)
 	}
 
 	if lon < -180 || lon > 180 {
-		return 0, 0, fmt.Errorf("longitude must be between -180 and 180, got: %.4f", lon)
+		return fmt.Errorf("longitude must be between -180 and 180, got: %.4f", lon)
 	}
 
-	return lat, lon, nil
+	return nil
 }
 
-// sendJSONResponse sends a JSON response with the given status code and data
-func (wh *WeatherHandler) sendJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+// setCacheHeaders advertises the freshness of cached data so CDNs and browsers can reuse it
+// Uncached responses get no Cache-Control header since we have no freshness information for them
+func (wh *WeatherHandler) setCacheHeaders(w http.ResponseWriter, data *service.WeatherData) {
+	if data.ExpiresAt.IsZero() {
+		return
+	}
 
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Printf("Error encoding JSON response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	now := time.Now()
+	maxAge := int(data.ExpiresAt.Sub(now).Seconds())
+	if maxAge < 0 {
+		maxAge = 0 // stale data must be revalidated on every use
+	}
+	age := int(now.Sub(data.FetchedAt).Seconds())
+	if age < 0 {
+		age = 0
 	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+	w.Header().Set("Age", strconv.Itoa(age))
 }
 
-// sendErrorResponse sends a JSON error response
-func (wh *WeatherHandler) sendErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-	errorResp := ErrorResponse{Error: message}
-	wh.sendJSONResponse(w, statusCode, errorResp)
+// etagMatches reports whether an If-None-Match header value matches the given ETag
+// Uses the weak comparison required for If-None-Match (RFC 9110 section 13.1.2)
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
 }
 
-// HealthCheck provides a simple health check endpoint
-func HealthCheck(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// sendJSONResponse sends a JSON response with the given status code and data
+// It is indented if the client asked for it (see jsonIndent)
+func sendJSONResponse(w http.ResponseWriter, r *http.Request, logger *slog.Logger, statusCode int, data interface{}) {
+	body, err := encodeJSONFor(r, data)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "JSON response encoding failed", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	writeJSON(w, statusCode, body)
+}
+
+// encodeJSON encodes data the way json.Encoder does, including the trailing newline
+func encodeJSON(data interface{}) ([]byte, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return append(body, '\n'), nil
+}
 
-	response := map[string]string{
-		"status":    "ok",
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
+// encodeJSONFor encodes data as JSON, indented if the client asked for it
+func encodeJSONFor(r *http.Request, data interface{}) ([]byte, error) {
+	indent := jsonIndent(r)
+	if indent == "" {
+		return encodeJSON(data)
 	}
+	body, err := json.MarshalIndent(data, "", indent)
+	if err != nil {
+		return nil, err
+	}
+	return append(body, '\n'), nil
+}
 
+// writeJSON sends an already encoded JSON body
+func writeJSON(w http.ResponseWriter, statusCode int, body []byte) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+// sendErrorResponse sends an error response identifying the request
+// It is XML or an HTML page if the client prefers those and JSON otherwise
+func sendErrorResponse(w http.ResponseWriter, r *http.Request, logger *slog.Logger, statusCode int, code string, message string) {
+	errorResp := ErrorResponse{
+		Error:     message,
+		Code:      code,
+		RequestID: requestid.FromContext(r.Context()),
+		TraceID:   tracing.TraceID(r.Context()),
+	}
+	format, err := negotiateFormat(r, errorFormats)
+	if err != nil {
+		format = FormatJSON // the error may well be about the unsupported ?format=
+	}
+	body, err := encodeAs(r, format, errorResp)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "error response encoding failed", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeEncoded(w, statusCode, format, body)
 }
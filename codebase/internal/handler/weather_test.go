@@ -2,10 +2,20 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"github.com/krizvi/weather-app-server/internal/cache"
+	"github.com/krizvi/weather-app-server/internal/errreport"
+	"github.com/krizvi/weather-app-server/internal/metering"
+	"github.com/krizvi/weather-app-server/internal/requestid"
+	"github.com/krizvi/weather-app-server/internal/servertiming"
 	"github.com/krizvi/weather-app-server/internal/service"
+	"log/slog"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 // Mock implementation for testing
@@ -32,7 +42,7 @@ func TestWeatherHandler_Success(t *testing.T) {
 	}
 
 	// Test handler with mock - this is where interface matters!
-	handler := New(mockService, 10) // Accepts WeatherService interface
+	handler := New(mockService, 10, "", slog.Default()) // Accepts WeatherService interface
 
 	req := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0", nil)
 	w := httptest.NewRecorder()
@@ -48,7 +58,7 @@ func TestWeatherHandler_Success(t *testing.T) {
 func TestWeatherHandler_ServiceError(t *testing.T) {
 	// Test error handling
 	mockService := &MockWeatherService{shouldError: true}
-	handler := New(mockService, 10)
+	handler := New(mockService, 10, "", slog.Default())
 
 	req := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0", nil)
 	w := httptest.NewRecorder()
@@ -60,3 +70,371 @@ func TestWeatherHandler_ServiceError(t *testing.T) {
 		t.Errorf("Expected 503, got %d", w.Code)
 	}
 }
+
+func TestWeatherHandler_CoordinateAliases(t *testing.T) {
+	mockService := &MockWeatherService{returnData: &service.WeatherData{Condition: "Clear"}}
+	handler := New(mockService, 10, "", slog.Default())
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"latitude=40.7&longitude=-74.0", 200},
+		{"lat=40.7&lng=-74.0", 200},
+		{"latitude=40.7&long=-74.0", 200},
+		{"lat=40.7&latitude=40.7&lon=-74.0", 200},
+		{"lat=40.7&latitude=41&lon=-74.0", 400},
+		{"lat=40.7&lon=-74.0&lng=-75", 400},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.GetWeather(w, httptest.NewRequest("GET", "/weather?"+tt.query, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.query, tt.status, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.GetWeather(w, httptest.NewRequest("GET", "/weather?lat=40.7&latitude=41&lon=-74.0", nil))
+	if !strings.Contains(w.Body.String(), "conflicting values for lat and latitude") {
+		t.Errorf("Expected the conflict to be explained, got %s", w.Body)
+	}
+}
+
+func TestWeatherHandler_NotModified(t *testing.T) {
+	mockService := &MockWeatherService{
+		returnData: &service.WeatherData{
+			Condition:           "Clear",
+			TemperatureCategory: "hot",
+			ETag:                `W/"abc123"`,
+		},
+	}
+	handler := New(mockService, 10, "", slog.Default())
+
+	req := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0", nil)
+	req.Header.Set("If-None-Match", `"other", W/"abc123"`)
+	w := httptest.NewRecorder()
+
+	handler.GetWeather(w, req)
+
+	if w.Code != 304 {
+		t.Errorf("Expected 304, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected empty body, got %q", w.Body.String())
+	}
+	if w.Header().Get("ETag") != `W/"abc123"` {
+		t.Errorf("Expected ETag header, got %q", w.Header().Get("ETag"))
+	}
+}
+
+func TestWeatherHandler_ETagMismatch(t *testing.T) {
+	mockService := &MockWeatherService{
+		returnData: &service.WeatherData{Condition: "Clear", ETag: `W/"abc123"`},
+	}
+	handler := New(mockService, 10, "", slog.Default())
+
+	req := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0", nil)
+	req.Header.Set("If-None-Match", `W/"stale"`)
+	w := httptest.NewRecorder()
+
+	handler.GetWeather(w, req)
+
+	if w.Code != 200 {
+		t.Errorf("Expected 200, got %d", w.Code)
+	}
+}
+
+func TestWeatherHandler_ETagVariesWithIndent(t *testing.T) {
+	mockService := &MockWeatherService{
+		returnData: &service.WeatherData{Condition: "Clear", ETag: `W/"abc123"`},
+	}
+	handler := New(mockService, 10, "", slog.Default())
+
+	tests := []struct {
+		target string
+		accept string
+		etag   string
+	}{
+		{"/weather?lat=40.7&lon=-74.0", "", `W/"abc123"`},
+		{"/weather?lat=40.7&lon=-74.0&pretty=true", "", `W/"abc123-json-indent2"`},
+		{"/weather?lat=40.7&lon=-74.0", "application/json; indent=4", `W/"abc123-json-indent4"`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.target, nil)
+		req.Header.Set("Accept", tt.accept)
+		req.Header.Set("If-None-Match", `W/"abc123"`)
+		w := httptest.NewRecorder()
+
+		handler.GetWeather(w, req)
+
+		if got := w.Header().Get("ETag"); got != tt.etag {
+			t.Errorf("%s (Accept %q): Expected ETag %s, got %s", tt.target, tt.accept, tt.etag, got)
+		}
+		if wantNotModified := tt.etag == `W/"abc123"`; (w.Code == 304) != wantNotModified {
+			t.Errorf("%s (Accept %q): Expected 304 only for the compact representation, got %d", tt.target, tt.accept, w.Code)
+		}
+	}
+}
+
+func TestWeatherHandler_CacheControl(t *testing.T) {
+	now := time.Now()
+	mockService := &MockWeatherService{
+		returnData: &service.WeatherData{
+			Condition: "Clear",
+			FetchedAt: now.Add(-100 * time.Second),
+			ExpiresAt: now.Add(200 * time.Second),
+		},
+	}
+	handler := New(mockService, 10, "", slog.Default())
+
+	req := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetWeather(w, req)
+
+	cacheControl := w.Header().Get("Cache-Control")
+	if cacheControl != "public, max-age=199" && cacheControl != "public, max-age=200" {
+		t.Errorf("Unexpected Cache-Control header: %q", cacheControl)
+	}
+	if age := w.Header().Get("Age"); age != "100" && age != "99" {
+		t.Errorf("Unexpected Age header: %q", age)
+	}
+}
+
+func TestWeatherHandler_CacheControlStale(t *testing.T) {
+	now := time.Now()
+	mockService := &MockWeatherService{
+		returnData: &service.WeatherData{
+			Condition: "Clear",
+			Stale:     true,
+			FetchedAt: now.Add(-400 * time.Second),
+			ExpiresAt: now.Add(-100 * time.Second),
+		},
+	}
+	handler := New(mockService, 10, "", slog.Default())
+
+	req := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetWeather(w, req)
+
+	if cacheControl := w.Header().Get("Cache-Control"); cacheControl != "public, max-age=0" {
+		t.Errorf("Expected max-age=0 for stale data, got %q", cacheControl)
+	}
+}
+
+func TestWeatherHandler_DegradedHeader(t *testing.T) {
+	mockService := &MockWeatherService{
+		returnData: &service.WeatherData{Condition: "Clear", Stale: true, Degraded: true, DataAgeSeconds: 900},
+	}
+	handler := New(mockService, 10, "", slog.Default())
+
+	req := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetWeather(w, req)
+
+	if w.Code != 200 {
+		t.Errorf("Expected 200, got %d", w.Code)
+	}
+	if w.Header().Get("X-Weather-Status") != "degraded" {
+		t.Errorf("Expected degraded status header, got %q", w.Header().Get("X-Weather-Status"))
+	}
+	if !strings.Contains(w.Body.String(), `"data_age_seconds":900`) {
+		t.Errorf("Expected data_age_seconds in body, got %s", w.Body.String())
+	}
+}
+
+func TestWeatherHandler_RefreshRequiresAdmin(t *testing.T) {
+	mockService := &MockWeatherService{returnData: &service.WeatherData{Condition: "Clear"}}
+	handler := New(mockService, 10, "secret", slog.Default())
+
+	req := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0&refresh=true", nil)
+	w := httptest.NewRecorder()
+	handler.GetWeather(w, req)
+	if w.Code != 403 {
+		t.Errorf("Expected 403 without admin token, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0&refresh=true", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	handler.GetWeather(w, req)
+	if w.Code != 200 {
+		t.Errorf("Expected 200 with admin token, got %d", w.Code)
+	}
+}
+
+// errorService fails every lookup with err
+type errorService struct {
+	err error
+}
+
+func (s *errorService) GetWeather(ctx context.Context, lat, lon float64) (*service.WeatherData, error) {
+	return nil, s.err
+}
+
+func TestWeatherHandler_UpstreamErrorStatus(t *testing.T) {
+	tests := []struct {
+		err        error
+		status     int
+		code       string
+		retryAfter string
+	}{
+		{&service.ProviderError{Code: 429, RetryAfter: 30 * time.Second}, 429, CodeRateLimited, "30"},
+		{&service.ProviderError{Code: 429}, 429, CodeRateLimited, "60"},
+		{&service.ProviderError{Code: 401}, 502, CodeUpstreamAuth, ""},
+		{&service.ProviderError{Code: 404}, 404, CodeLocationNotFound, ""},
+		{&service.ProviderError{Code: 500}, 503, CodeUpstreamUnavailable, ""},
+		{fmt.Errorf("wrapped: %w", &service.ProviderError{Code: 403}), 502, CodeUpstreamAuth, ""},
+		{service.ErrUpstreamBudgetExhausted, 429, CodeRateLimited, "60"},
+		{&service.ProviderError{Err: context.DeadlineExceeded}, 504, CodeUpstreamTimeout, ""},
+		{fmt.Errorf("request failed: %w", context.Canceled), 499, CodeCanceled, ""},
+		{fmt.Errorf("connection refused"), 503, CodeUpstreamUnavailable, ""},
+	}
+
+	for _, tt := range tests {
+		handler := New(&errorService{err: tt.err}, 10, "", slog.Default())
+		w := httptest.NewRecorder()
+		handler.GetWeather(w, httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0", nil))
+
+		if w.Code != tt.status {
+			t.Errorf("%v: expected %d, got %d", tt.err, tt.status, w.Code)
+		}
+		var resp ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Code != tt.code {
+			t.Errorf("%v: expected code %s, got %q", tt.err, tt.code, resp.Code)
+		}
+		if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+			t.Errorf("%v: expected Retry-After %q, got %q", tt.err, tt.retryAfter, got)
+		}
+	}
+}
+
+// recordingReporter collects reported error events
+type recordingReporter struct {
+	events []errreport.Event
+}
+
+func (rr *recordingReporter) Report(ctx context.Context, event errreport.Event) {
+	rr.events = append(rr.events, event)
+}
+
+func TestWeatherHandler_ReportsServerErrors(t *testing.T) {
+	// An upstream attempt timing out, while the request still has time
+	attemptTimeout := &service.ProviderError{Message: "failed to make HTTP request", Err: context.DeadlineExceeded}
+	tests := []struct {
+		err      error
+		canceled bool // the client left
+		reported bool
+	}{
+		{fmt.Errorf("connection refused"), false, true},
+		{&service.ProviderError{Code: 401}, false, true},
+		{&service.ProviderError{Code: 404}, false, false},
+		{&service.ProviderError{Code: 429}, false, false},
+		{attemptTimeout, false, true},
+		{service.ErrCircuitOpen, false, false},
+		{service.ErrBulkheadFull, false, false},
+		{fmt.Errorf("request failed: %w", context.Canceled), true, false},
+	}
+
+	for _, tt := range tests {
+		reporter := &recordingReporter{}
+		handler := errreport.Middleware(reporter, http.HandlerFunc(New(&errorService{err: tt.err}, 10, "", slog.Default()).GetWeather))
+		req := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0", nil)
+		if tt.canceled {
+			ctx, cancel := context.WithCancel(req.Context())
+			cancel()
+			req = req.WithContext(ctx)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if (len(reporter.events) == 1) != tt.reported {
+			t.Errorf("%v: expected reported=%v, got %d events", tt.err, tt.reported, len(reporter.events))
+		}
+	}
+}
+
+func TestWeatherHandler_ServerTiming(t *testing.T) {
+	mockService := &MockWeatherService{returnData: &service.WeatherData{Condition: "Clear"}}
+	handler := servertiming.Middleware(http.HandlerFunc(New(mockService, 10, "", slog.Default()).GetWeather))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0", nil))
+
+	header := w.Header().Get("Server-Timing")
+	if !strings.HasPrefix(header, "encode;dur=") || !strings.Contains(header, "total;dur=") {
+		t.Errorf("Expected encode and total timings, got %q", header)
+	}
+	var data service.WeatherData
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil || data.Condition != "Clear" {
+		t.Errorf("Expected the encoded weather data, got %q", w.Body.String())
+	}
+}
+
+func TestWeatherHandler_ErrorIncludesRequestID(t *testing.T) {
+	handler := New(&MockWeatherService{shouldError: true}, 10, "", slog.Default())
+
+	req := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0", nil)
+	req = req.WithContext(requestid.WithID(req.Context(), "req-123"))
+	w := httptest.NewRecorder()
+	handler.GetWeather(w, req)
+
+	var body ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected a JSON error, got %q", w.Body.String())
+	}
+	if body.RequestID != "req-123" || body.TraceID != "" {
+		t.Errorf("Expected the request ID and no trace ID, got %+v", body)
+	}
+}
+
+func TestWeatherHandler_MetersUpstreamCalls(t *testing.T) {
+	meter := metering.New(1)
+	for _, data := range []*service.WeatherData{{Condition: "Clear"}, {Condition: "Clear", Cached: true}} {
+		handler := metering.Middleware(meter, metering.HeaderKey("X-Api-Key"), http.HandlerFunc(New(&MockWeatherService{returnData: data}, 10, "", slog.Default()).GetWeather))
+		r := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0", nil)
+		r.Header.Set("X-Api-Key", "team-a")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	usage := meter.Usage(1, "team-a")
+	if len(usage) != 1 || usage[0].Requests != 2 || usage[0].UpstreamCalls != 1 {
+		t.Errorf("Expected 2 requests with only the miss billed as an upstream call, got %+v", usage)
+	}
+}
+
+// coldCacheHandler serves lookups through an empty cache in front of the mock provider, dumping upstream
+// exchanges to log
+func coldCacheHandler(log *strings.Builder) http.Handler {
+	logger := slog.New(slog.NewTextHandler(log, nil))
+	provider := service.New("key", "http://mock/data/2.5", 5, service.NewDumpTransport(service.NewMockTransport(), 0, logger), logger)
+	cached := service.NewCached(provider, cache.NewMemory(0), 60, 60, 0, 5, logger)
+	return servertiming.Middleware(http.HandlerFunc(New(cached, 10, "admin", logger).GetWeather))
+}
+
+func TestWeatherHandler_ServerTimingOnCacheMiss(t *testing.T) {
+	var log strings.Builder
+	w := httptest.NewRecorder()
+	coldCacheHandler(&log).ServeHTTP(w, httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0", nil))
+
+	if header := w.Header().Get("Server-Timing"); !strings.Contains(header, "upstream;dur=") {
+		t.Errorf("Expected the upstream call timed on a miss, got %q", header)
+	}
+}
+
+func TestWeatherHandler_DebugDumpOnCacheMiss(t *testing.T) {
+	var log strings.Builder
+	r := httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74.0", nil)
+	r.Header.Set("Authorization", "Bearer admin")
+	r.Header.Set("X-Debug-Dump", "true")
+	w := httptest.NewRecorder()
+	coldCacheHandler(&log).ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK || !strings.Contains(log.String(), "upstream exchange") {
+		t.Errorf("Expected the upstream exchange dumped on a miss, got %d and log %q", w.Code, log.String())
+	}
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/clientip"
+	"github.com/krizvi/weather-app-server/internal/webhook"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// webhookMaxBodyBytes bounds subscription requests
+const webhookMaxBodyBytes = 16 << 10
+
+// temperatureCategories are the values subscriptions can filter TemperatureCategory on
+var temperatureCategories = []string{"cold", "moderate", "hot"}
+
+// SubscriptionRequest is the body of POST /webhooks
+type SubscriptionRequest struct {
+	URL                   string   `json:"url"`
+	Lat                   float64  `json:"lat"`
+	Lon                   float64  `json:"lon"`
+	Conditions            []string `json:"conditions"`
+	TemperatureCategories []string `json:"temperature_categories"`
+}
+
+// WebhookHandler lets clients subscribe to weather changes at a location
+type WebhookHandler struct {
+	store        *webhook.Store
+	allowPrivate bool // accept subscriber URLs on private addresses
+	logger       *slog.Logger
+}
+
+// NewWebhooks creates a WebhookHandler keeping the subscriptions in store
+func NewWebhooks(store *webhook.Store, allowPrivate bool, logger *slog.Logger) *WebhookHandler {
+	return &WebhookHandler{store: store, allowPrivate: allowPrivate, logger: logger}
+}
+
+// Subscribe handles POST requests to /webhooks
+// The response holds the subscription's secret, which is not shown again: deliveries are signed with it,
+// and it authorizes reading and deleting the subscription
+func (wh *WebhookHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	var req SubscriptionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, webhookMaxBodyBytes)).Decode(&req); err != nil {
+		sendErrorResponse(w, r, wh.logger, http.StatusBadRequest, CodeInvalidRequest, "invalid subscription request body")
+		return
+	}
+	if err := webhook.ValidateURL(req.URL, wh.allowPrivate); err != nil {
+		sendErrorResponse(w, r, wh.logger, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	if err := validateCoordinates(req.Lat, req.Lon); err != nil {
+		sendErrorResponse(w, r, wh.logger, http.StatusBadRequest, CodeInvalidCoordinates, err.Error())
+		return
+	}
+	for _, category := range req.TemperatureCategories {
+		if !slices.Contains(temperatureCategories, strings.ToLower(category)) {
+			sendErrorResponse(w, r, wh.logger, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("temperature category must be one of %s, got %q", strings.Join(temperatureCategories, ", "), category))
+			return
+		}
+	}
+
+	sub, err := wh.store.Add(webhook.Subscription{
+		URL:                   req.URL,
+		Lat:                   req.Lat,
+		Lon:                   req.Lon,
+		Conditions:            req.Conditions,
+		TemperatureCategories: req.TemperatureCategories,
+		Client:                clientip.FromRequest(r),
+	})
+	if errors.Is(err, webhook.ErrTooManySubscriptions) {
+		sendErrorResponse(w, r, wh.logger, http.StatusServiceUnavailable, CodeSubscriptionLimit, "Too many webhook subscriptions")
+		return
+	}
+	if errors.Is(err, webhook.ErrTooManyClientSubscriptions) {
+		sendErrorResponse(w, r, wh.logger, http.StatusTooManyRequests, CodeSubscriptionLimit, "Too many webhook subscriptions from this client")
+		return
+	}
+	if err != nil {
+		wh.logger.ErrorContext(r.Context(), "webhook subscription failed", slog.String("error", err.Error()))
+		sendErrorResponse(w, r, wh.logger, http.StatusInternalServerError, CodeInternalError, "Internal server error")
+		return
+	}
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+sub.ID)
+	sub.Client = ""
+	sendJSONResponse(w, r, wh.logger, http.StatusCreated, sub)
+}
+
+// Subscription handles GET and DELETE requests to /webhooks/{id}, authorized with the subscription's secret
+// as a bearer token
+func (wh *WebhookHandler) Subscription(w http.ResponseWriter, r *http.Request) {
+	sub, ok := wh.store.Get(r.PathValue("id"))
+	provided, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(sub.Secret)) != 1 {
+		// Unknown subscriptions look the same, so IDs can't be probed for
+		sendErrorResponse(w, r, wh.logger, http.StatusNotFound, CodeNotFound, "Subscription not found")
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if _, err := wh.store.Delete(sub.ID); err != nil {
+			wh.logger.ErrorContext(r.Context(), "webhook unsubscribe failed", slog.String("error", err.Error()))
+			sendErrorResponse(w, r, wh.logger, http.StatusInternalServerError, CodeInternalError, "Internal server error")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	sub.Secret = ""
+	sub.Client = ""
+	sendJSONResponse(w, r, wh.logger, http.StatusOK, sub)
+}
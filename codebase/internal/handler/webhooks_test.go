@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/krizvi/weather-app-server/internal/webhook"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookHandler_SubscribeAndDelete(t *testing.T) {
+	store, _ := webhook.NewStore("", 10, 10)
+	handler := NewWebhooks(store, false, slog.Default())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhooks", handler.Subscribe)
+	mux.HandleFunc("/webhooks/{id}", handler.Subscription)
+
+	body := `{"url":"https://example.com/hook","lat":40.7,"lon":-74,"temperature_categories":["hot"]}`
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/webhooks", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var sub webhook.Subscription
+	json.Unmarshal(w.Body.Bytes(), &sub)
+	if sub.Secret == "" || w.Header().Get("Location") != "/webhooks/"+sub.ID {
+		t.Fatalf("Expected the secret and the location, got %+v %q", sub, w.Header().Get("Location"))
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/webhooks/"+sub.ID, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without the secret, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/webhooks/"+sub.ID, nil)
+	req.Header.Set("Authorization", "Bearer "+sub.Secret)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), sub.Secret) {
+		t.Errorf("Expected the subscription without its secret, got %d %s", w.Code, w.Body)
+	}
+
+	req = httptest.NewRequest("DELETE", "/webhooks/"+sub.ID, nil)
+	req.Header.Set("Authorization", "Bearer "+sub.Secret)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", w.Code)
+	}
+	if _, ok := store.Get(sub.ID); ok {
+		t.Error("Expected the subscription to be deleted")
+	}
+}
+
+func TestWebhookHandler_RejectsInvalidSubscriptions(t *testing.T) {
+	store, _ := webhook.NewStore("", 10, 10)
+	handler := NewWebhooks(store, false, slog.Default())
+
+	tests := []struct {
+		body string
+		code string
+	}{
+		{`{"url":"http://127.0.0.1/hook","lat":1,"lon":1}`, CodeInvalidRequest},
+		{`{"url":"https://example.com/hook","lat":91,"lon":1}`, CodeInvalidCoordinates},
+		{`{"url":"https://example.com/hook","lat":1,"lon":1,"temperature_categories":["warm"]}`, CodeInvalidRequest},
+		{`not json`, CodeInvalidRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.Subscribe(w, httptest.NewRequest("POST", "/webhooks", strings.NewReader(tt.body)))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.code) {
+			t.Errorf("%s: expected 400 %s, got %d %s", tt.body, tt.code, w.Code, w.Body)
+		}
+	}
+}
+
+func TestWebhookHandler_CapsSubscriptionsPerClient(t *testing.T) {
+	store, _ := webhook.NewStore("", 10, 1)
+	handler := NewWebhooks(store, false, slog.Default())
+
+	body := `{"url":"https://example.com/hook","lat":40.7,"lon":-74}`
+	w := httptest.NewRecorder()
+	handler.Subscribe(w, httptest.NewRequest("POST", "/webhooks", strings.NewReader(body)))
+	if w.Code != http.StatusCreated || strings.Contains(w.Body.String(), `"client"`) {
+		t.Fatalf("Expected 201 without the client address, got %d: %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	handler.Subscribe(w, httptest.NewRequest("POST", "/webhooks", strings.NewReader(body)))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 for the client's second subscription, got %d", w.Code)
+	}
+
+	r := httptest.NewRequest("POST", "/webhooks", strings.NewReader(body))
+	r.RemoteAddr = "198.51.100.1:1234"
+	w = httptest.NewRecorder()
+	handler.Subscribe(w, r)
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected another client to subscribe, got %d", w.Code)
+	}
+}
@@ -0,0 +1,222 @@
+package handoff
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	// listenersEnv lists the inherited listeners as name=fd pairs, e.g. "http=3,acme=4"
+	listenersEnv = "APP_SERVER_HANDOFF_LISTENERS"
+	// parentEnv holds the PID of the process to stop once the new one serves
+	parentEnv = "APP_SERVER_HANDOFF_PARENT"
+)
+
+var (
+	mu        sync.Mutex
+	parsed    bool
+	inherited map[string]*os.File
+	parentPID int
+	opened    = map[string]net.Listener{}
+	names     []string // opened, in order
+)
+
+// Listen returns the listener called name that the previous process handed over, if this process was started
+// by Upgrade, or that systemd passed with socket activation, or else a new one on network and addr
+// Every listener opened here is handed over by the next Upgrade
+func Listen(name, network, addr string) (net.Listener, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if err := parseInherited(); err != nil {
+		return nil, err
+	}
+
+	var ln net.Listener
+	if f, ok := inherited[name]; ok {
+		delete(inherited, name)
+		var err error
+		ln, err = net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to take over the %s listener: %w", name, err)
+		}
+	} else {
+		if network == "unix" {
+			removeStaleSocket(addr)
+		}
+		var err error
+		if ln, err = net.Listen(network, addr); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := opened[name]; !ok {
+		names = append(names, name)
+	}
+	opened[name] = ln
+	return ln, nil
+}
+
+// Addresses lists the open listeners as name=address, in the order they were opened
+func Addresses() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	addrs := make([]string, len(names))
+	for i, name := range names {
+		addrs[i] = name + "=" + opened[name].Addr().String()
+	}
+	return addrs
+}
+
+// Inherited reports whether this process took over listeners from a previous one
+func Inherited() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	parseInherited()
+	return parentPID != 0
+}
+
+// Ready tells the process that handed over the listeners to shut down gracefully, now that this one serves
+// It does nothing if this process was not started by Upgrade
+func Ready() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if err := parseInherited(); err != nil {
+		return err
+	}
+	// Listeners the new configuration no longer uses
+	for name, f := range inherited {
+		f.Close()
+		delete(inherited, name)
+	}
+	if parentPID == 0 {
+		return nil
+	}
+	pid := parentPID
+	parentPID = 0
+	if os.Getppid() != pid {
+		return nil // the old process is gone already; never signal whoever inherited us
+	}
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
+
+// Upgrade starts a new instance of the running binary with the same arguments, environment and working
+// directory and hands it the open listeners
+// The new instance calls Ready once it serves, which sends this process SIGTERM; if it fails to start instead,
+// this process keeps serving. The caller should Wait for the returned process to learn about that
+func Upgrade() (*os.Process, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the executable: %w", err)
+	}
+
+	files := []*os.File{os.Stdin, os.Stdout, os.Stderr}
+	pairs := make([]string, 0, len(names))
+	defer func() {
+		for _, f := range files[3:] {
+			f.Close()
+		}
+	}()
+	for _, name := range names {
+		if unixListener, ok := opened[name].(*net.UnixListener); ok {
+			unixListener.SetUnlinkOnClose(false) // the new instance serves on the same path
+		}
+		filer, ok := opened[name].(interface{ File() (*os.File, error) })
+		if !ok {
+			return nil, fmt.Errorf("the %s listener can't be handed over", name)
+		}
+		f, err := filer.File()
+		if err != nil {
+			return nil, fmt.Errorf("failed to hand over the %s listener: %w", name, err)
+		}
+		pairs = append(pairs, name+"="+strconv.Itoa(len(files)))
+		files = append(files, f)
+	}
+
+	env := make([]string, 0, len(os.Environ())+2)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, listenersEnv+"=") && !strings.HasPrefix(kv, parentEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env, listenersEnv+"="+strings.Join(pairs, ","), parentEnv+"="+strconv.Itoa(os.Getpid()))
+
+	process, err := os.StartProcess(exe, os.Args, &os.ProcAttr{Env: env, Files: files})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the new instance: %w", err)
+	}
+	return process, nil
+}
+
+// parseInherited reads the handed over listeners from the environment once
+func parseInherited() error {
+	if parsed {
+		return nil
+	}
+	parsed = true
+	inherited = make(map[string]*os.File)
+	if pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID")); pid != 0 {
+		return parseSocketActivation(pid)
+	}
+	value := os.Getenv(listenersEnv)
+	if value == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		name, fdStr, ok := strings.Cut(pair, "=")
+		fd, err := strconv.Atoi(fdStr)
+		if !ok || err != nil {
+			return fmt.Errorf("invalid %s entry %q", listenersEnv, pair)
+		}
+		inherited[name] = os.NewFile(uintptr(fd), name)
+	}
+	parentPID, _ = strconv.Atoi(os.Getenv(parentEnv))
+	return nil
+}
+
+// parseSocketActivation takes the sockets systemd passed (sd_listen_fds(3)), starting at fd 3 and named by
+// FileDescriptorName= in the socket unit; a single unnamed socket is taken as the "http" listener
+func parseSocketActivation(pid int) error {
+	fds := os.Getenv("LISTEN_FDS")
+	count, err := strconv.Atoi(fds)
+	fdNames := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	// Not meant for child processes, including the one started by Upgrade
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if pid != os.Getpid() {
+		return nil
+	}
+	if err != nil || count < 0 {
+		return fmt.Errorf("invalid LISTEN_FDS %q", fds)
+	}
+	for i := 0; i < count; i++ {
+		name := "http"
+		if i < len(fdNames) && fdNames[i] != "" && !(count == 1 && fdNames[i] == "unknown") {
+			name = fdNames[i]
+		}
+		syscall.CloseOnExec(3 + i)
+		inherited[name] = os.NewFile(uintptr(3+i), name)
+	}
+	return nil
+}
+
+// removeStaleSocket removes the socket file a crashed or killed process left at path, so it can be listened
+// on again; a socket someone still listens on stays, and listening on it fails
+func removeStaleSocket(path string) {
+	info, err := os.Stat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return
+	}
+	os.Remove(path)
+}
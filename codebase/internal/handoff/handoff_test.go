@@ -0,0 +1,117 @@
+package handoff
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// reset forgets the listeners and the environment read by earlier tests
+func reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	parsed, inherited, parentPID = false, nil, 0
+	opened, names = map[string]net.Listener{}, nil
+}
+
+func TestListen_TakesOverInheritedListener(t *testing.T) {
+	reset()
+	defer reset()
+	old, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+	f, err := old.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(listenersEnv, "http="+strconv.Itoa(int(f.Fd())))
+	t.Setenv(parentEnv, "1") // not our parent, so Ready must not signal it
+
+	if !Inherited() {
+		t.Error("Expected the handed over listener to be noticed")
+	}
+	ln, err := Listen("http", "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer ln.Close()
+	if ln.Addr().String() != old.Addr().String() {
+		t.Errorf("Expected the inherited address %s, got %s", old.Addr(), ln.Addr())
+	}
+
+	// The old process stops accepting; the taken over socket keeps serving
+	old.Close()
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	resp, err := http.Get("http://" + ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Expected the taken over listener to serve, got %v", err)
+	}
+	resp.Body.Close()
+
+	if err := Ready(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestListen_OpensNewListenerWithoutHandoff(t *testing.T) {
+	reset()
+	defer reset()
+	os.Unsetenv(listenersEnv)
+
+	ln, err := Listen("http", "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer ln.Close()
+	if Inherited() {
+		t.Error("Expected no handoff")
+	}
+	if len(names) != 1 || opened["http"] != ln {
+		t.Errorf("Expected the listener to be recorded for the next upgrade, got %v", names)
+	}
+	if addrs := Addresses(); len(addrs) != 1 || addrs[0] != "http="+ln.Addr().String() {
+		t.Errorf("Expected the listener address, got %v", addrs)
+	}
+}
+
+func TestListen_IgnoresSocketsMeantForAnotherProcess(t *testing.T) {
+	reset()
+	defer reset()
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+
+	ln, err := Listen("http", "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected a new listener, got %v", err)
+	}
+	defer ln.Close()
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("Expected the socket activation variables to be cleared for child processes")
+	}
+}
+
+func TestListen_ReplacesStaleUnixSocket(t *testing.T) {
+	reset()
+	defer reset()
+	path := filepath.Join(t.TempDir(), "weather.sock")
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close() // like a killed process, leaves the file behind
+
+	ln, err := Listen("http", "unix", path)
+	if err != nil {
+		t.Fatalf("Expected the stale socket to be replaced, got %v", err)
+	}
+	defer ln.Close()
+	if _, err := Listen("other", "unix", path); err == nil {
+		t.Error("Expected a socket in use not to be replaced")
+	}
+}
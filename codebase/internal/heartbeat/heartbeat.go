@@ -0,0 +1,87 @@
+package heartbeat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Heartbeat pings an external monitor (healthchecks.io, Opsgenie heartbeats, Cronitor, ...) on an interval
+// while the instance is healthy; the monitor alerts when pings stop, which also catches crashed or wedged
+// instances when the rest of the monitoring pipeline is down with them
+type Heartbeat struct {
+	url      string
+	headers  map[string]string
+	interval time.Duration
+	healthy  func(ctx context.Context) error
+	client   *http.Client
+	logger   *slog.Logger
+}
+
+// New creates a Heartbeat sending a GET with headers (e.g. an Opsgenie "Authorization: GenieKey ...") to url
+// every intervalSec seconds as long as healthy returns nil
+// A non-positive intervalSec falls back to 60 seconds
+func New(url string, headers map[string]string, intervalSec int, healthy func(ctx context.Context) error, logger *slog.Logger) *Heartbeat {
+	if intervalSec <= 0 {
+		intervalSec = 60
+	}
+	interval := time.Duration(intervalSec) * time.Second
+	return &Heartbeat{
+		url:      url,
+		headers:  headers,
+		interval: interval,
+		healthy:  healthy,
+		client:   &http.Client{Timeout: min(interval, 10*time.Second)},
+		logger:   logger,
+	}
+}
+
+// Run pings every interval until ctx is canceled
+func (hb *Heartbeat) Run(ctx context.Context) {
+	ticker := time.NewTicker(hb.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			hb.beat(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// beat sends one ping if the instance is healthy
+func (hb *Heartbeat) beat(ctx context.Context) {
+	if err := hb.healthy(ctx); err != nil {
+		// Staying silent is the signal; the monitor raises the alert once the grace period passes
+		hb.logger.Warn("skipping heartbeat while unhealthy", slog.String("error", err.Error()))
+		return
+	}
+	if err := hb.ping(ctx); err != nil && ctx.Err() == nil {
+		hb.logger.Warn("heartbeat ping failed", slog.String("error", err.Error()))
+	}
+}
+
+func (hb *Heartbeat) ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hb.url, nil)
+	if err != nil {
+		return err
+	}
+	for name, value := range hb.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := hb.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("monitor answered %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package heartbeat
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeartbeat_PingsOnlyWhileHealthy(t *testing.T) {
+	pings := 0
+	var auth string
+	monitor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pings++
+		auth = r.Header.Get("Authorization")
+	}))
+	defer monitor.Close()
+
+	var healthErr error
+	hb := New(monitor.URL+"/ping/abc", map[string]string{"Authorization": "GenieKey secret"}, 60, func(ctx context.Context) error { return healthErr }, slog.Default())
+
+	hb.beat(context.Background())
+	healthErr = errors.New("instance is draining")
+	hb.beat(context.Background())
+
+	if pings != 1 {
+		t.Errorf("Expected 1 ping, got %d", pings)
+	}
+	if auth != "GenieKey secret" {
+		t.Errorf("Expected the configured header, got %q", auth)
+	}
+}
+
+func TestHeartbeat_FailedPing(t *testing.T) {
+	monitor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer monitor.Close()
+
+	hb := New(monitor.URL, nil, 60, func(ctx context.Context) error { return nil }, slog.Default())
+	if err := hb.ping(context.Background()); err == nil {
+		t.Error("Expected a 404 from the monitor to be an error")
+	}
+}
@@ -0,0 +1,94 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Manager stops the subsystems of the server in the reverse of the order they were started, each within its
+// own timeout, so the servers drain before the cache closes and the exporters flush the last data at the end
+type Manager struct {
+	mu         sync.Mutex
+	components []component
+	logger     *slog.Logger
+}
+
+// component is a started subsystem and how to stop it
+type component struct {
+	name    string
+	timeout time.Duration
+	stop    func(ctx context.Context) error
+}
+
+// New creates a Manager without any components
+func New(logger *slog.Logger) *Manager {
+	return &Manager{logger: logger}
+}
+
+// Go starts a background worker, which runs until its context is canceled on shutdown or by calling the
+// returned function; it counts as stopped once run returns
+func (m *Manager) Go(name string, timeoutSec int, run func(ctx context.Context)) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		run(ctx)
+	}()
+	m.OnStop(name, timeoutSec, func(context.Context) error {
+		cancel()
+		<-done
+		return nil
+	})
+	return cancel
+}
+
+// OnStop registers how to stop a subsystem the caller started, e.g. by shutting down its server
+// stop gets a context that ends after timeoutSec; Shutdown stops waiting for it then
+func (m *Manager) OnStop(name string, timeoutSec int, stop func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.components = append(m.components, component{name: name, timeout: time.Duration(timeoutSec) * time.Second, stop: stop})
+}
+
+// Shutdown stops every component, the last one started first
+// A component that fails or doesn't stop in time is logged and left behind, and the rest are still stopped
+func (m *Manager) Shutdown() error {
+	m.mu.Lock()
+	components := m.components
+	m.components = nil
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		start := time.Now()
+		if err := stopWithin(c); err != nil {
+			m.logger.Error("component failed to stop", slog.String("component", c.name), slog.String("error", err.Error()))
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+			continue
+		}
+		m.logger.Debug("component stopped", slog.String("component", c.name), slog.Duration("duration", time.Since(start)))
+	}
+	return errors.Join(errs...)
+}
+
+// stopWithin stops c, giving up once its timeout has passed even if the stop function doesn't return
+func stopWithin(c component) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- c.stop(ctx)
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("not stopped within %s", c.timeout)
+	}
+}
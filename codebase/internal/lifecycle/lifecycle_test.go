@@ -0,0 +1,54 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"testing"
+)
+
+func TestManager_StopsInReverseOrder(t *testing.T) {
+	m := New(slog.Default())
+	var stopped []string
+	for _, name := range []string{"exporter", "cache", "server"} {
+		m.OnStop(name, 1, func(context.Context) error {
+			stopped = append(stopped, name)
+			return nil
+		})
+	}
+	workerStopped := false
+	m.Go("worker", 1, func(ctx context.Context) {
+		<-ctx.Done()
+		workerStopped = true
+	})
+
+	if err := m.Shutdown(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !workerStopped {
+		t.Error("Expected the worker to be stopped")
+	}
+	if want := []string{"server", "cache", "exporter"}; !slices.Equal(stopped, want) {
+		t.Errorf("Expected %v, got %v", want, stopped)
+	}
+}
+
+func TestManager_ContinuesPastFailedComponents(t *testing.T) {
+	m := New(slog.Default())
+	firstStopped := false
+	m.OnStop("first", 1, func(context.Context) error {
+		firstStopped = true
+		return nil
+	})
+	m.OnStop("failing", 1, func(context.Context) error { return errors.New("boom") })
+	m.OnStop("stuck", 0, func(context.Context) error { select {} })
+
+	err := m.Shutdown()
+	if err == nil {
+		t.Fatal("Expected the failures to be reported")
+	}
+	if !firstStopped {
+		t.Error("Expected the remaining components to be stopped")
+	}
+}
@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestContextHandler_AddsContextAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "text", "info")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	ctx := WithAttrs(t.Context(), slog.String("request_id", "abc123"))
+	ctx = WithAttrs(ctx, slog.String("route", "/weather"))
+	logger.InfoContext(ctx, "served")
+	logger.Info("background")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !strings.Contains(lines[0], "request_id=abc123") || !strings.Contains(lines[0], "route=/weather") {
+		t.Errorf("Expected the context attributes in %q", lines[0])
+	}
+	if strings.Contains(lines[1], "request_id") {
+		t.Errorf("Expected no context attributes in %q", lines[1])
+	}
+}
+
+func TestNew_JSONAndLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "warn")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	logger.Info("dropped")
+	logger.Warn("kept")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a single JSON record, got %q", buf.String())
+	}
+	if record["msg"] != "kept" {
+		t.Errorf("Expected msg kept, got %v", record["msg"])
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+	if _, err := New(&bytes.Buffer{}, "text", "loud"); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+}
@@ -0,0 +1,174 @@
+package metering
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxKeysPerDay bounds a day so made-up keys can't grow it without limit; requests with further
+// keys are counted under OverflowKey
+const maxKeysPerDay = 10000
+
+// OverflowKey is what requests are billed to once a day has maxKeysPerDay keys
+const OverflowKey = "other"
+
+// dayLayout names the UTC days usage is counted in
+const dayLayout = "2006-01-02"
+
+// KeyFunc returns the API key a request is billed to, or "" to leave the request unmetered
+type KeyFunc func(r *http.Request) string
+
+// HeaderKey bills requests to the value of header, e.g. one an API gateway sets to the key it
+// authenticated the client with
+func HeaderKey(header string) KeyFunc {
+	return func(r *http.Request) string { return r.Header.Get(header) }
+}
+
+// Usage is what one API key used on one day
+type Usage struct {
+	Day           string `json:"day"` // UTC, as YYYY-MM-DD
+	Key           string `json:"key"`
+	Requests      int64  `json:"requests"`
+	UpstreamCalls int64  `json:"upstream_calls"` // Requests answered by calling the provider rather than from the cache
+}
+
+// Meter counts requests and upstream calls per API key per UTC day, keeping retention worth of days
+type Meter struct {
+	mu        sync.Mutex
+	days      map[string]map[string]*Usage
+	retention int
+	now       func() time.Time
+}
+
+// New creates a Meter keeping retentionDays of usage
+// A non-positive retentionDays falls back to 31
+func New(retentionDays int) *Meter {
+	if retentionDays <= 0 {
+		retentionDays = 31
+	}
+	return &Meter{days: make(map[string]map[string]*Usage), retention: retentionDays, now: time.Now}
+}
+
+// Record bills one request that made upstreamCalls provider calls to key
+func (m *Meter) Record(key string, upstreamCalls int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	day := m.now().UTC().Format(dayLayout)
+	keys, ok := m.days[day]
+	if !ok {
+		keys = make(map[string]*Usage)
+		m.days[day] = keys
+		m.expire()
+	}
+	usage, ok := keys[key]
+	if !ok {
+		if len(keys) >= maxKeysPerDay {
+			key = OverflowKey
+		}
+		if usage, ok = keys[key]; !ok {
+			usage = &Usage{Day: day, Key: key}
+			keys[key] = usage
+		}
+	}
+	usage.Requests++
+	usage.UpstreamCalls += upstreamCalls
+}
+
+// expire drops the days past the retention; m.mu must be held
+func (m *Meter) expire() {
+	oldest := m.now().UTC().AddDate(0, 0, -(m.retention - 1)).Format(dayLayout)
+	for day := range m.days {
+		if day < oldest {
+			delete(m.days, day)
+		}
+	}
+}
+
+// Retention returns how many days the meter keeps, today included
+func (m *Meter) Retention() int {
+	return m.retention
+}
+
+// Usage returns the usage of the last days days, today included, ordered by day and then key
+// If key is not empty only that key's usage is returned
+func (m *Meter) Usage(days int, key string) []Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldest := m.now().UTC().AddDate(0, 0, -(days - 1)).Format(dayLayout)
+	usage := []Usage{}
+	for day, keys := range m.days {
+		if day < oldest {
+			continue
+		}
+		for k, u := range keys {
+			if key == "" || k == key {
+				usage = append(usage, *u)
+			}
+		}
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Day != usage[j].Day {
+			return usage[i].Day < usage[j].Day
+		}
+		return usage[i].Key < usage[j].Key
+	})
+	return usage
+}
+
+// WriteCSV writes usage as CSV with a header row, for billing and chargeback tools
+func WriteCSV(w io.Writer, usage []Usage) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"day", "key", "requests", "upstream_calls"})
+	for _, u := range usage {
+		cw.Write([]string{u.Day, u.Key, strconv.FormatInt(u.Requests, 10), strconv.FormatInt(u.UpstreamCalls, 10)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Report is usage as served by /admin/usage
+type Report []Usage
+
+// WriteCSV writes the report as CSV with a header row
+func (r Report) WriteCSV(w io.Writer) error {
+	return WriteCSV(w, r)
+}
+
+// tally counts the upstream calls of one request
+type tally struct {
+	upstreamCalls atomic.Int64
+}
+
+// tallyKey is the context key carrying the tally of the current request
+type tallyKey struct{}
+
+// RecordUpstreamCall bills a provider call to the request of ctx, if it is metered
+func RecordUpstreamCall(ctx context.Context) {
+	if t, ok := ctx.Value(tallyKey{}).(*tally); ok {
+		t.upstreamCalls.Add(1)
+	}
+}
+
+// Middleware bills every request with a key to m once it has been served, along with the upstream
+// calls handlers recorded for it with RecordUpstreamCall
+func Middleware(m *Meter, key KeyFunc, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k := key(r)
+		if k == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		t := &tally{}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tallyKey{}, t)))
+		m.Record(k, t.upstreamCalls.Load())
+	})
+}
@@ -0,0 +1,99 @@
+package metering
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMeter_UsagePerKeyPerDay(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	m := New(7)
+	m.now = func() time.Time { return now }
+
+	m.Record("team-a", 1)
+	now = now.Add(24 * time.Hour)
+	m.Record("team-a", 0)
+	m.Record("team-a", 1)
+	m.Record("team-b", 1)
+
+	usage := m.Usage(7, "")
+	want := []Usage{
+		{Day: "2024-06-01", Key: "team-a", Requests: 1, UpstreamCalls: 1},
+		{Day: "2024-06-02", Key: "team-a", Requests: 2, UpstreamCalls: 1},
+		{Day: "2024-06-02", Key: "team-b", Requests: 1, UpstreamCalls: 1},
+	}
+	if len(usage) != len(want) {
+		t.Fatalf("Expected %+v, got %+v", want, usage)
+	}
+	for i := range want {
+		if usage[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], usage[i])
+		}
+	}
+
+	if usage := m.Usage(1, ""); len(usage) != 2 {
+		t.Errorf("Expected only today's 2 rows, got %+v", usage)
+	}
+	if usage := m.Usage(7, "team-b"); len(usage) != 1 || usage[0].Key != "team-b" {
+		t.Errorf("Expected only team-b's row, got %+v", usage)
+	}
+}
+
+func TestMeter_ExpiresOldDays(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	m := New(2)
+	m.now = func() time.Time { return now }
+
+	m.Record("team-a", 0)
+	now = now.Add(2 * 24 * time.Hour)
+	m.Record("team-a", 0)
+
+	if len(m.days) != 1 {
+		t.Errorf("Expected the day past the retention to be dropped, got %v", m.days)
+	}
+}
+
+func TestMeter_BoundsKeysPerDay(t *testing.T) {
+	m := New(1)
+	for i := range maxKeysPerDay + 5 {
+		m.Record("key-"+strconv.Itoa(i), 0)
+	}
+
+	usage := m.Usage(1, OverflowKey)
+	if len(usage) != 1 || usage[0].Requests != 5 {
+		t.Errorf("Expected 5 requests billed to %q, got %+v", OverflowKey, usage)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var b strings.Builder
+	err := WriteCSV(&b, []Usage{{Day: "2024-06-01", Key: "team,a", Requests: 3, UpstreamCalls: 1}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := "day,key,requests,upstream_calls\n2024-06-01,\"team,a\",3,1\n"
+	if b.String() != want {
+		t.Errorf("Expected %q, got %q", want, b.String())
+	}
+}
+
+func TestMiddleware_BillsKeyedRequests(t *testing.T) {
+	m := New(1)
+	handler := Middleware(m, HeaderKey("X-Api-Key"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RecordUpstreamCall(r.Context())
+	}))
+
+	r := httptest.NewRequest("GET", "/v1/weather", nil)
+	r.Header.Set("X-Api-Key", "team-a")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/weather", nil))
+
+	usage := m.Usage(1, "")
+	if len(usage) != 1 || usage[0].Key != "team-a" || usage[0].Requests != 1 || usage[0].UpstreamCalls != 1 {
+		t.Errorf("Expected one request with one upstream call billed to team-a, got %+v", usage)
+	}
+}
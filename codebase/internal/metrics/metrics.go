@@ -0,0 +1,359 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are latency histogram buckets in seconds, from 5ms to 10s
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// series is one labeled time series of a family
+type series struct {
+	labelValues []string
+	value       float64  // counters and gauges
+	buckets     []uint64 // histograms: observations per bucket (not cumulative)
+	sum         float64
+	count       uint64
+}
+
+// family is a named metric with its series
+type family struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	fn      func() float64 // set for function-backed metrics, which have no series
+	fnLabel []Label        // fixed labels of a function-backed metric
+	sinks   *sinks
+	last    float64 // fn value at the previous FlushFuncs, for counter deltas
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// get returns the series for labelValues, creating it on first use
+// Callers hold f.mu
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if f.kind == kindHistogram {
+			s.buckets = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// labelPairs pairs the family's label names with labelValues for sinks
+func (f *family) labelPairs(labelValues []string) []Label {
+	if len(f.labels) == 0 {
+		return nil
+	}
+	pairs := make([]Label, len(f.labels))
+	for i, name := range f.labels {
+		pairs[i] = Label{Name: name, Value: labelValues[i]}
+	}
+	return pairs
+}
+
+// Label is a label name and value passed to sinks
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sink receives every metric update as it happens, e.g. to push metrics to StatsD
+// The registry's own store, served in the Prometheus format, always receives them too
+// Sinks are called on the request path and must not block
+type Sink interface {
+	Count(name string, labels []Label, delta float64)
+	Gauge(name string, labels []Label, value float64)
+	Observe(name string, labels []Label, value float64)
+}
+
+// sinks is the list of sinks shared by a registry and its families
+type sinks struct {
+	mu   sync.RWMutex
+	list []Sink
+}
+
+func (s *sinks) each(fn func(Sink)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, sink := range s.list {
+		fn(sink)
+	}
+}
+
+// Registry holds metric families in registration order and exposes them in the Prometheus text format
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+	names    map[string]bool
+	sinks    sinks
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// AddSink forwards all future metric updates to sink as well
+func (r *Registry) AddSink(sink Sink) {
+	r.sinks.mu.Lock()
+	r.sinks.list = append(r.sinks.list, sink)
+	r.sinks.mu.Unlock()
+}
+
+// FlushFuncs reports function-backed metrics, which have no updates of their own, to the sinks:
+// gauges with their current value and counters with their increase since the previous call
+// Push sinks call it before each push
+func (r *Registry) FlushFuncs() {
+	r.mu.Lock()
+	families := append([]*family(nil), r.families...)
+	r.mu.Unlock()
+
+	for _, f := range families {
+		if f.fn == nil {
+			continue
+		}
+		value := f.fn()
+		f.mu.Lock()
+		delta := value - f.last
+		f.last = value
+		f.mu.Unlock()
+
+		r.sinks.each(func(sink Sink) {
+			if f.kind == kindCounter {
+				if delta > 0 {
+					sink.Count(f.name, f.fnLabel, delta)
+				}
+				return
+			}
+			sink.Gauge(f.name, f.fnLabel, value)
+		})
+	}
+}
+
+func (r *Registry) register(f *family) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[f.name] {
+		panic("metrics: duplicate metric " + f.name)
+	}
+	r.names[f.name] = true
+	f.series = make(map[string]*series)
+	f.sinks = &r.sinks
+	r.families = append(r.families, f)
+	return f
+}
+
+// CounterVec is a monotonically increasing value per label combination
+// Methods on a nil CounterVec do nothing, so instrumented code works without a registry
+type CounterVec struct{ f *family }
+
+// NewCounterVec registers a counter with the given label names
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{r.register(&family{name: name, help: help, kind: kindCounter, labels: labels})}
+}
+
+// Inc adds 1 to the series for labelValues
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v (which must not be negative) to the series for labelValues
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if c == nil {
+		return
+	}
+	c.f.mu.Lock()
+	c.f.get(labelValues).value += v
+	c.f.mu.Unlock()
+	c.f.sinks.each(func(sink Sink) { sink.Count(c.f.name, c.f.labelPairs(labelValues), v) })
+}
+
+// GaugeVec is a value that can go up and down per label combination
+// Methods on a nil GaugeVec do nothing
+type GaugeVec struct{ f *family }
+
+// NewGaugeVec registers a gauge with the given label names
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{r.register(&family{name: name, help: help, kind: kindGauge, labels: labels})}
+}
+
+// Add adds v (which may be negative) to the series for labelValues
+func (g *GaugeVec) Add(v float64, labelValues ...string) {
+	if g == nil {
+		return
+	}
+	g.f.mu.Lock()
+	s := g.f.get(labelValues)
+	s.value += v
+	value := s.value
+	g.f.mu.Unlock()
+	g.f.sinks.each(func(sink Sink) { sink.Gauge(g.f.name, g.f.labelPairs(labelValues), value) })
+}
+
+// Set sets the series for labelValues to v
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	if g == nil {
+		return
+	}
+	g.f.mu.Lock()
+	g.f.get(labelValues).value = v
+	g.f.mu.Unlock()
+	g.f.sinks.each(func(sink Sink) { sink.Gauge(g.f.name, g.f.labelPairs(labelValues), v) })
+}
+
+// HistogramVec counts observations into buckets per label combination
+// Methods on a nil HistogramVec do nothing
+type HistogramVec struct{ f *family }
+
+// NewHistogramVec registers a histogram with the given upper bucket bounds (ascending) and label names
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{r.register(&family{name: name, help: help, kind: kindHistogram, labels: labels, buckets: buckets})}
+}
+
+// Observe records v in the series for labelValues
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	if h == nil {
+		return
+	}
+	i := sort.SearchFloat64s(h.f.buckets, v) // first bucket with bound >= v, len(buckets) for +Inf
+
+	h.f.mu.Lock()
+	s := h.f.get(labelValues)
+	if i < len(s.buckets) {
+		s.buckets[i]++
+	}
+	s.sum += v
+	s.count++
+	h.f.mu.Unlock()
+	h.f.sinks.each(func(sink Sink) { sink.Observe(h.f.name, h.f.labelPairs(labelValues), v) })
+}
+
+// NewCounterFunc registers a counter whose value is read from fn at collection time
+// Its only series carries the given fixed labels, if any
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64, labels ...Label) {
+	r.register(&family{name: name, help: help, kind: kindCounter, fn: fn, fnLabel: labels})
+}
+
+// NewGaugeFunc registers a gauge whose value is read from fn at collection time
+// Its only series carries the given fixed labels, if any
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64, labels ...Label) {
+	r.register(&family{name: name, help: help, kind: kindGauge, fn: fn, fnLabel: labels})
+}
+
+// Handler serves the metrics in the Prometheus text exposition format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WritePrometheus(w)
+	})
+}
+
+// WritePrometheus writes all metrics in the Prometheus text exposition format
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.Lock()
+	families := append([]*family(nil), r.families...)
+	r.mu.Unlock()
+
+	var b strings.Builder
+	for _, f := range families {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+		if f.fn != nil {
+			names := make([]string, len(f.fnLabel))
+			values := make([]string, len(f.fnLabel))
+			for i, label := range f.fnLabel {
+				names[i], values[i] = label.Name, label.Value
+			}
+			fmt.Fprintf(&b, "%s%s %s\n", f.name, formatLabels(names, values, "", ""), formatValue(f.fn()))
+			continue
+		}
+
+		f.mu.Lock()
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := f.series[key]
+			if f.kind != kindHistogram {
+				fmt.Fprintf(&b, "%s%s %s\n", f.name, formatLabels(f.labels, s.labelValues, "", ""), formatValue(s.value))
+				continue
+			}
+
+			var cumulative uint64
+			for i, bound := range f.buckets {
+				cumulative += s.buckets[i]
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.labelValues, "le", formatValue(bound)), cumulative)
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.labelValues, "le", "+Inf"), s.count)
+			fmt.Fprintf(&b, "%s_sum%s %s\n", f.name, formatLabels(f.labels, s.labelValues, "", ""), formatValue(s.sum))
+			fmt.Fprintf(&b, "%s_count%s %d\n", f.name, formatLabels(f.labels, s.labelValues, "", ""), s.count)
+		}
+		f.mu.Unlock()
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// formatLabels renders {name="value",...}, appending the extra label if extraName is set
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabelValue(values[i])+`"`)
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+extraValue+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
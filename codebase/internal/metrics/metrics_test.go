@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WritePrometheus(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.NewCounterVec("http_requests_total", "Requests served.", "route", "code")
+	latency := registry.NewHistogramVec("http_request_duration_seconds", "Request latency.", []float64{0.1, 1}, "route")
+	registry.NewGaugeFunc("in_flight", "Requests in flight.", func() float64 { return 3 })
+	registry.NewGaugeFunc("cache_entries", "Cache entries.", func() float64 { return 7 }, Label{Name: "backend", Value: "memory"})
+
+	requests.Inc("/weather", "200")
+	requests.Inc("/weather", "200")
+	requests.Inc(`/we"ird`, "404")
+	latency.Observe(0.05, "/weather")
+	latency.Observe(0.5, "/weather")
+	latency.Observe(5, "/weather")
+
+	w := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+
+	expected := []string{
+		"# TYPE http_requests_total counter",
+		`http_requests_total{route="/weather",code="200"} 2`,
+		`http_requests_total{route="/we\"ird",code="404"} 1`,
+		"# TYPE http_request_duration_seconds histogram",
+		`http_request_duration_seconds_bucket{route="/weather",le="0.1"} 1`,
+		`http_request_duration_seconds_bucket{route="/weather",le="1"} 2`,
+		`http_request_duration_seconds_bucket{route="/weather",le="+Inf"} 3`,
+		`http_request_duration_seconds_sum{route="/weather"} 5.55`,
+		`http_request_duration_seconds_count{route="/weather"} 3`,
+		"in_flight 3",
+		`cache_entries{backend="memory"} 7`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, body)
+		}
+	}
+}
+
+func TestNilInstrumentsAreNoOps(t *testing.T) {
+	var counter *CounterVec
+	var gauge *GaugeVec
+	var histogram *HistogramVec
+
+	counter.Inc("a")
+	gauge.Set(1, "a")
+	histogram.Observe(1, "a")
+}
@@ -0,0 +1,261 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const otlpExportTimeout = 10 * time.Second
+
+// OTLPExporter pushes the registry's metrics to an OTLP/HTTP collector using the JSON encoding,
+// next to the Prometheus scrape; it sends the same instruments with cumulative temporality, so a
+// missed push loses no data
+type OTLPExporter struct {
+	url         string
+	headers     map[string]string
+	serviceName string
+	interval    time.Duration
+	start       time.Time
+	client      *http.Client
+	logger      *slog.Logger
+}
+
+// NewOTLPExporter creates an OTLPExporter posting to endpoint + "/v1/metrics" every intervalSec seconds,
+// with the same service.name resource as exported spans
+// A non-positive intervalSec falls back to 60 seconds
+func NewOTLPExporter(endpoint, serviceName string, headers map[string]string, intervalSec int, logger *slog.Logger) *OTLPExporter {
+	if intervalSec <= 0 {
+		intervalSec = 60
+	}
+	return &OTLPExporter{
+		url:         endpoint + "/v1/metrics",
+		headers:     headers,
+		serviceName: serviceName,
+		interval:    time.Duration(intervalSec) * time.Second,
+		start:       time.Now(),
+		client:      &http.Client{Timeout: otlpExportTimeout},
+		logger:      logger,
+	}
+}
+
+// Run exports the metrics of registry every interval until ctx is canceled, then exports once more
+func (e *OTLPExporter) Run(ctx context.Context, registry *Registry) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.exportLogged(ctx, registry)
+		case <-ctx.Done():
+			finalCtx, cancel := context.WithTimeout(context.Background(), otlpExportTimeout)
+			e.exportLogged(finalCtx, registry)
+			cancel()
+			return
+		}
+	}
+}
+
+func (e *OTLPExporter) exportLogged(ctx context.Context, registry *Registry) {
+	if err := e.export(ctx, registry); err != nil {
+		e.logger.Warn("metrics export failed", slog.String("error", err.Error()))
+	}
+}
+
+// export posts the current value of every series
+func (e *OTLPExporter) export(ctx context.Context, registry *Registry) error {
+	body, err := json.Marshal(e.encode(registry, time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create export request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector answered %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP/JSON payload (opentelemetry-proto ExportMetricsServiceRequest); int64s are strings
+
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+}
+
+// otlpCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE
+const otlpCumulative = 2
+
+type otlpSum struct {
+	DataPoints             []otlpNumberPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberPoint `json:"dataPoints"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramPoint `json:"dataPoints"`
+	AggregationTemporality int                  `json:"aggregationTemporality"`
+}
+
+type otlpNumberPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsDouble          float64         `json:"asDouble"`
+}
+
+type otlpHistogramPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	Count             string          `json:"count"`
+	Sum               float64         `json:"sum"`
+	BucketCounts      []string        `json:"bucketCounts"`
+	ExplicitBounds    []float64       `json:"explicitBounds"`
+}
+
+type otlpAttribute struct {
+	Key   string            `json:"key"`
+	Value map[string]string `json:"value"`
+}
+
+func otlpAttributes(names, values []string) []otlpAttribute {
+	attributes := make([]otlpAttribute, len(names))
+	for i, name := range names {
+		attributes[i] = otlpAttribute{Key: name, Value: map[string]string{"stringValue": values[i]}}
+	}
+	return attributes
+}
+
+// encode snapshots every family of registry as of now
+func (e *OTLPExporter) encode(registry *Registry, now time.Time) otlpMetricsRequest {
+	registry.mu.Lock()
+	families := append([]*family(nil), registry.families...)
+	registry.mu.Unlock()
+
+	start := strconv.FormatInt(e.start.UnixNano(), 10)
+	at := strconv.FormatInt(now.UnixNano(), 10)
+	numberPoint := func(names, values []string, value float64, kind string) otlpNumberPoint {
+		point := otlpNumberPoint{Attributes: otlpAttributes(names, values), TimeUnixNano: at, AsDouble: value}
+		if kind == kindCounter {
+			point.StartTimeUnixNano = start
+		}
+		return point
+	}
+
+	encoded := make([]otlpMetric, 0, len(families))
+	for _, f := range families {
+		metric := otlpMetric{Name: f.name, Description: f.help}
+		var points []otlpNumberPoint
+		var histogramPoints []otlpHistogramPoint
+
+		if f.fn != nil {
+			names := make([]string, len(f.fnLabel))
+			values := make([]string, len(f.fnLabel))
+			for i, label := range f.fnLabel {
+				names[i], values[i] = label.Name, label.Value
+			}
+			points = append(points, numberPoint(names, values, f.fn(), f.kind))
+		} else {
+			f.mu.Lock()
+			for _, s := range f.series {
+				if f.kind != kindHistogram {
+					points = append(points, numberPoint(f.labels, s.labelValues, s.value, f.kind))
+					continue
+				}
+				counts := make([]string, len(s.buckets)+1)
+				var bounded uint64
+				for i, n := range s.buckets {
+					counts[i] = strconv.FormatUint(n, 10)
+					bounded += n
+				}
+				counts[len(s.buckets)] = strconv.FormatUint(s.count-bounded, 10) // the +Inf bucket
+				histogramPoints = append(histogramPoints, otlpHistogramPoint{
+					Attributes:        otlpAttributes(f.labels, s.labelValues),
+					StartTimeUnixNano: start,
+					TimeUnixNano:      at,
+					Count:             strconv.FormatUint(s.count, 10),
+					Sum:               s.sum,
+					BucketCounts:      counts,
+					ExplicitBounds:    f.buckets,
+				})
+			}
+			f.mu.Unlock()
+		}
+
+		switch f.kind {
+		case kindCounter:
+			if len(points) == 0 {
+				continue
+			}
+			metric.Sum = &otlpSum{DataPoints: points, AggregationTemporality: otlpCumulative, IsMonotonic: true}
+		case kindGauge:
+			if len(points) == 0 {
+				continue
+			}
+			metric.Gauge = &otlpGauge{DataPoints: points}
+		case kindHistogram:
+			if len(histogramPoints) == 0 {
+				continue
+			}
+			metric.Histogram = &otlpHistogram{DataPoints: histogramPoints, AggregationTemporality: otlpCumulative}
+		}
+		encoded = append(encoded, metric)
+	}
+
+	return otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     otlpResource{Attributes: otlpAttributes([]string{"service.name"}, []string{e.serviceName})},
+		ScopeMetrics: []otlpScopeMetrics{{Scope: otlpScope{Name: e.serviceName}, Metrics: encoded}},
+	}}}
+}
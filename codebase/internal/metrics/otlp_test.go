@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOTLPExporter_PushesRegistryMetrics(t *testing.T) {
+	var got otlpMetricsRequest
+	var auth string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" {
+			t.Errorf("Expected /v1/metrics, got %s", r.URL.Path)
+		}
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer collector.Close()
+
+	registry := NewRegistry()
+	requests := registry.NewCounterVec("http_requests_total", "Requests served.", "route")
+	latency := registry.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1})
+	registry.NewGaugeFunc("cache_entries", "Entries.", func() float64 { return 7 })
+	registry.NewCounterVec("unused_total", "Never incremented.")
+
+	requests.Inc("/weather")
+	requests.Inc("/weather")
+	latency.Observe(0.05)
+	latency.Observe(5)
+
+	exporter := NewOTLPExporter(collector.URL, "weather-api-server", map[string]string{"Authorization": "Bearer secret"}, 60, slog.Default())
+	if err := exporter.export(context.Background(), registry); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if auth != "Bearer secret" {
+		t.Errorf("Expected the configured header, got %q", auth)
+	}
+	metrics := map[string]otlpMetric{}
+	for _, m := range got.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+	if len(metrics) != 3 {
+		t.Errorf("Expected 3 metrics (empty families skipped), got %d", len(metrics))
+	}
+	if sum := metrics["http_requests_total"].Sum; sum == nil || !sum.IsMonotonic || sum.DataPoints[0].AsDouble != 2 ||
+		sum.DataPoints[0].Attributes[0].Value["stringValue"] != "/weather" {
+		t.Errorf("Expected a monotonic sum of 2 for /weather, got %+v", sum)
+	}
+	if gauge := metrics["cache_entries"].Gauge; gauge == nil || gauge.DataPoints[0].AsDouble != 7 {
+		t.Errorf("Expected a gauge of 7, got %+v", gauge)
+	}
+	histogram := metrics["latency_seconds"].Histogram
+	if histogram == nil || histogram.DataPoints[0].Count != "2" {
+		t.Fatalf("Expected a histogram with 2 observations, got %+v", histogram)
+	}
+	if counts := histogram.DataPoints[0].BucketCounts; len(counts) != 3 || counts[0] != "1" || counts[2] != "1" {
+		t.Errorf("Expected one observation in the first and the overflow bucket, got %v", counts)
+	}
+}
@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	statsdMaxPacket       = 1432  // fits a typical MTU without fragmentation
+	statsdMaxObservations = 10000 // per flush interval, so a burst can't grow the buffer without bound
+)
+
+// StatsD is a Sink pushing metrics to a StatsD or DogStatsD agent over UDP
+// Counters are summed and gauges keep their last value until the next flush; histogram observations
+// are sent individually (as DogStatsD histograms). Labels become DogStatsD tags.
+type StatsD struct {
+	addr     string
+	prefix   string
+	tags     []string
+	interval time.Duration
+	logger   *slog.Logger
+
+	mu           sync.Mutex
+	counters     map[string]float64
+	gauges       map[string]float64
+	observations []string
+	dropped      atomic.Uint64
+}
+
+// NewStatsD creates a StatsD sink sending to addr (host:port) every intervalSec seconds
+// Metric names get prefix + "." prepended (unless prefix is empty) and every metric carries tags ("key:value")
+// A non-positive intervalSec falls back to 10 seconds
+func NewStatsD(addr, prefix string, tags []string, intervalSec int, logger *slog.Logger) *StatsD {
+	if intervalSec <= 0 {
+		intervalSec = 10
+	}
+	if prefix != "" {
+		prefix += "."
+	}
+	return &StatsD{
+		addr:     addr,
+		prefix:   prefix,
+		tags:     tags,
+		interval: time.Duration(intervalSec) * time.Second,
+		logger:   logger,
+		counters: make(map[string]float64),
+		gauges:   make(map[string]float64),
+	}
+}
+
+// Dropped returns how many histogram observations were discarded because the buffer was full
+func (s *StatsD) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// key renders the metric name and tags, the part of a StatsD line before the value
+func (s *StatsD) key(name string, labels []Label) string {
+	tags := append([]string(nil), s.tags...)
+	for _, label := range labels {
+		tags = append(tags, label.Name+":"+label.Value)
+	}
+	key := s.prefix + name
+	if len(tags) > 0 {
+		key += "|#" + strings.Join(tags, ",")
+	}
+	return key
+}
+
+// Count adds delta to the counter until the next flush
+func (s *StatsD) Count(name string, labels []Label, delta float64) {
+	key := s.key(name, labels)
+	s.mu.Lock()
+	s.counters[key] += delta
+	s.mu.Unlock()
+}
+
+// Gauge records value as the gauge's latest value
+func (s *StatsD) Gauge(name string, labels []Label, value float64) {
+	key := s.key(name, labels)
+	s.mu.Lock()
+	s.gauges[key] = value
+	s.mu.Unlock()
+}
+
+// Observe buffers one histogram observation
+func (s *StatsD) Observe(name string, labels []Label, value float64) {
+	line := statsdLine(s.key(name, labels), value, "h")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.observations) >= statsdMaxObservations {
+		s.dropped.Add(1)
+		return
+	}
+	s.observations = append(s.observations, line)
+}
+
+// statsdLine formats "name:value|type|#tags" from a key rendered by StatsD.key
+func statsdLine(key string, value float64, kind string) string {
+	name, tags, hasTags := strings.Cut(key, "|")
+	line := name + ":" + formatValue(value) + "|" + kind
+	if hasTags {
+		line += "|" + tags
+	}
+	return line
+}
+
+// Run flushes the collected metrics of registry every interval until ctx is canceled, then flushes once more
+func (s *StatsD) Run(ctx context.Context, registry *Registry) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			registry.FlushFuncs()
+			s.flushLogged()
+			return
+		}
+		registry.FlushFuncs()
+		s.flushLogged()
+	}
+}
+
+func (s *StatsD) flushLogged() {
+	if err := s.flush(); err != nil {
+		s.logger.Warn("statsd flush failed", slog.String("error", err.Error()))
+	}
+}
+
+// flush sends everything collected since the previous flush
+// Gauges are sent again on every flush so agents that expire idle metrics keep seeing them
+func (s *StatsD) flush() error {
+	s.mu.Lock()
+	lines := make([]string, 0, len(s.counters)+len(s.gauges)+len(s.observations))
+	for key, value := range s.counters {
+		lines = append(lines, statsdLine(key, value, "c"))
+	}
+	for key, value := range s.gauges {
+		lines = append(lines, statsdLine(key, value, "g"))
+	}
+	lines = append(lines, s.observations...)
+	s.counters = make(map[string]float64)
+	s.observations = nil
+	s.mu.Unlock()
+
+	if len(lines) == 0 {
+		return nil
+	}
+	sort.Strings(lines)
+
+	conn, err := net.Dial("udp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to reach statsd agent: %w", err)
+	}
+	defer conn.Close()
+
+	// Pack lines into as few packets as possible
+	var packet strings.Builder
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacket {
+			if _, err := conn.Write([]byte(packet.String())); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	_, err = conn.Write([]byte(packet.String()))
+	return err
+}
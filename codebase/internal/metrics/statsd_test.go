@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsD_PushesRegistryMetrics(t *testing.T) {
+	agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer agent.Close()
+
+	registry := NewRegistry()
+	statsd := NewStatsD(agent.LocalAddr().String(), "weather", []string{"env:test"}, 60, slog.Default())
+	registry.AddSink(statsd)
+
+	requests := registry.NewCounterVec("http_requests_total", "Requests served.", "route", "code")
+	inFlight := registry.NewGaugeVec("in_flight", "Requests in flight.")
+	latency := registry.NewHistogramVec("latency_seconds", "Latency.", DefaultBuckets)
+	trips := 0.0
+	registry.NewCounterFunc("trips_total", "Breaker trips.", func() float64 { return trips })
+
+	requests.Inc("/weather", "200")
+	requests.Inc("/weather", "200")
+	inFlight.Add(2)
+	inFlight.Add(-1)
+	latency.Observe(0.25)
+	trips = 3
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	statsd.Run(ctx, registry) // flushes once and returns
+
+	buf := make([]byte, 2048)
+	agent.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := agent.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Expected a packet, got %v", err)
+	}
+	lines := strings.Split(string(buf[:n]), "\n")
+	sort.Strings(lines)
+	expected := []string{
+		"weather.http_requests_total:2|c|#env:test,route:/weather,code:200",
+		"weather.in_flight:1|g|#env:test",
+		"weather.latency_seconds:0.25|h|#env:test",
+		"weather.trips_total:3|c|#env:test",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+	}
+}
+
+func TestRegistry_FlushFuncsSendsCounterDeltas(t *testing.T) {
+	registry := NewRegistry()
+	sink := &recordingSink{}
+	registry.AddSink(sink)
+	total := 5.0
+	registry.NewCounterFunc("panics_total", "Panics.", func() float64 { return total })
+
+	registry.FlushFuncs()
+	total = 7
+	registry.FlushFuncs()
+	registry.FlushFuncs()
+
+	if len(sink.counts) != 2 || sink.counts[0] != 5 || sink.counts[1] != 2 {
+		t.Errorf("Expected deltas [5 2], got %v", sink.counts)
+	}
+}
+
+// recordingSink collects counter deltas
+type recordingSink struct {
+	counts []float64
+}
+
+func (rs *recordingSink) Count(name string, labels []Label, delta float64) {
+	rs.counts = append(rs.counts, delta)
+}
+
+func (rs *recordingSink) Gauge(name string, labels []Label, value float64)   {}
+func (rs *recordingSink) Observe(name string, labels []Label, value float64) {}
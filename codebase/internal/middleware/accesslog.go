@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"github.com/krizvi/weather-app-server/internal/clientip"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// accessLogWriter remembers the status and size of the response for the access log
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (aw *accessLogWriter) WriteHeader(statusCode int) {
+	if aw.status == 0 {
+		aw.status = statusCode
+	}
+	aw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (aw *accessLogWriter) Write(b []byte) (int, error) {
+	if aw.status == 0 {
+		aw.status = http.StatusOK
+	}
+	n, err := aw.ResponseWriter.Write(b)
+	aw.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (e.g. to flush)
+func (aw *accessLogWriter) Unwrap() http.ResponseWriter {
+	return aw.ResponseWriter
+}
+
+// accessLogAttrs collects attributes handlers add to the access log line of their request
+type accessLogAttrs struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+type accessLogKey struct{}
+
+// AddAccessLogAttrs adds attributes to the access log line of the request ctx belongs to
+// It does nothing for requests that aren't access logged
+func AddAccessLogAttrs(ctx context.Context, attrs ...slog.Attr) {
+	collected, _ := ctx.Value(accessLogKey{}).(*accessLogAttrs)
+	if collected == nil {
+		return
+	}
+	collected.mu.Lock()
+	collected.attrs = append(collected.attrs, attrs...)
+	collected.mu.Unlock()
+}
+
+// AccessLog writes one structured log line per request once it has been served
+// sampleEvery maps paths to N so only every Nth successful (below 400) request to the path is logged,
+// with its sample_rate, while errors are always logged; paths not in it are logged in full
+func AccessLog(sampleEvery map[string]int, logger *slog.Logger, next http.Handler) http.Handler {
+	counters := make(map[string]*atomic.Uint64, len(sampleEvery))
+	for path, n := range sampleEvery {
+		if n > 1 {
+			counters[path] = &atomic.Uint64{}
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		aw := &accessLogWriter{ResponseWriter: w}
+		collected := &accessLogAttrs{}
+		ctx := context.WithValue(r.Context(), accessLogKey{}, collected)
+		next.ServeHTTP(aw, r.WithContext(ctx))
+
+		if aw.status == 0 {
+			aw.status = http.StatusOK
+		}
+		var sampleRate int
+		if counter := counters[r.URL.Path]; counter != nil && aw.status < 400 {
+			sampleRate = sampleEvery[r.URL.Path]
+			if (counter.Add(1)-1)%uint64(sampleRate) != 0 {
+				return
+			}
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", aw.status),
+			slog.Int("bytes", aw.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("client_ip", clientip.FromRequest(r)),
+		}
+		if sampleRate > 1 {
+			attrs = append(attrs, slog.Int("sample_rate", sampleRate))
+		}
+		collected.mu.Lock()
+		attrs = append(attrs, collected.attrs...)
+		collected.mu.Unlock()
+		logger.LogAttrs(ctx, slog.LevelInfo, "request served", attrs...)
+	})
+}
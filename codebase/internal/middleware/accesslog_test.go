@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog_OneLinePerRequest(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	handler := AccessLog(nil, logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AddAccessLogAttrs(r.Context(), slog.String("cache", "hit"))
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("hello"))
+	}))
+	req := httptest.NewRequest("GET", "/weather?lat=1&lon=2", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	line := strings.TrimSpace(buf.String())
+	if strings.Count(line, "\n") != 0 {
+		t.Fatalf("Expected one log line, got %q", line)
+	}
+	for _, field := range []string{"method=GET", "path=/weather", "status=418", "bytes=5", "client_ip=203.0.113.7", "cache=hit", "duration="} {
+		if !strings.Contains(line, field) {
+			t.Errorf("Expected %s in %q", field, line)
+		}
+	}
+}
+
+func TestAddAccessLogAttrs_WithoutAccessLog(t *testing.T) {
+	// Must not panic for requests that aren't access logged
+	AddAccessLogAttrs(httptest.NewRequest("GET", "/", nil).Context(), slog.String("cache", "hit"))
+}
+
+func TestAccessLog_SamplesSuccessfulRequests(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	handler := AccessLog(map[string]int{"/v1/weather": 3}, logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") == "true" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	for i := 0; i < 7; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/weather", nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/weather?fail=true", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+
+	log := buf.String()
+	if n := strings.Count(log, "path=/v1/weather status=200"); n != 3 {
+		t.Errorf("Expected 3 of 7 successful requests logged, got %d", n)
+	}
+	if !strings.Contains(log, "status=502") || !strings.Contains(log, "path=/health") {
+		t.Errorf("Expected errors and unsampled paths to be logged in full, got:\n%s", log)
+	}
+	if !strings.Contains(log, "sample_rate=3") {
+		t.Errorf("Expected sampled lines to carry their sample rate, got:\n%s", log)
+	}
+}
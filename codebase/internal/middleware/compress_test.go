@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"github.com/krizvi/weather-app-server/internal/metrics"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func jsonHandler(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, body)
+	})
+}
+
+func TestCompress_LargeResponse(t *testing.T) {
+	body := `{"data":"` + strings.Repeat("x", 2000) + `"}`
+	handler := Compress(1024, jsonHandler(body))
+
+	req := httptest.NewRequest("GET", "/weather", nil)
+	req.Header.Set("Accept-Encoding", "br;q=1.0, gzip;q=0.8")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip encoding, got %q", w.Header().Get("Content-Encoding"))
+	}
+	if w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected Vary header, got %q", w.Header().Get("Vary"))
+	}
+
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Expected valid gzip stream, got %v", err)
+	}
+	decoded, _ := io.ReadAll(reader)
+	if string(decoded) != body {
+		t.Error("Decompressed body does not match")
+	}
+}
+
+func TestCompress_SmallResponseUncompressed(t *testing.T) {
+	handler := Compress(1024, jsonHandler(`{"status":"ok"}`))
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Expected no encoding for small body, got %q", w.Header().Get("Content-Encoding"))
+	}
+	if w.Body.String() != `{"status":"ok"}` {
+		t.Errorf("Unexpected body %q", w.Body.String())
+	}
+}
+
+func TestCompress_NotAccepted(t *testing.T) {
+	body := strings.Repeat("x", 2000)
+	handler := Compress(0, jsonHandler(body))
+
+	req := httptest.NewRequest("GET", "/weather", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0, *")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != body {
+		t.Error("Expected identity response when gzip is refused")
+	}
+}
+
+func TestCompress_NotModifiedPassthrough(t *testing.T) {
+	handler := Compress(0, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotModified)
+	}))
+
+	req := httptest.NewRequest("GET", "/weather", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != 304 || w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 0 {
+		t.Errorf("Expected bare 304, got %d %q", w.Code, w.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestCompress_FlushReachesConnectionThroughWrappers(t *testing.T) {
+	handler := Compress(1024, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "retry: 1000\n\n")
+		http.NewResponseController(w).Flush()
+	}))
+	// Instrument's writer sits outside Compress and only exposes Flush through Unwrap
+	handler = Instrument(metrics.NewRegistry(), handler)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/weather/stream", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(w, req)
+	if !w.Flushed {
+		t.Error("Expected the flush to reach the connection")
+	}
+}
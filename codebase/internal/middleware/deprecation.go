@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Deprecated marks the responses of a route that is being phased out, so clients notice before it goes away
+// successor is the path replacing it, and a non-zero sunset the date it stops being served (RFC 8594)
+func Deprecated(successor string, sunset time.Time, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Add("Link", "<"+successor+">; rel=\"successor-version\"")
+		if !sunset.IsZero() {
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		// Tells who still has to migrate
+		AddAccessLogAttrs(r.Context(), slog.Bool("deprecated", true))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeprecated(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	w := httptest.NewRecorder()
+	Deprecated("/v1/weather", time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC), ok).ServeHTTP(w, httptest.NewRequest("GET", "/weather", nil))
+	if w.Header().Get("Deprecation") != "true" {
+		t.Errorf("Expected Deprecation: true, got %q", w.Header().Get("Deprecation"))
+	}
+	if want := `</v1/weather>; rel="successor-version"`; w.Header().Get("Link") != want {
+		t.Errorf("Expected Link %s, got %q", want, w.Header().Get("Link"))
+	}
+	if want := "Wed, 30 Jun 2027 00:00:00 GMT"; w.Header().Get("Sunset") != want {
+		t.Errorf("Expected Sunset %s, got %q", want, w.Header().Get("Sunset"))
+	}
+
+	w = httptest.NewRecorder()
+	Deprecated("/v1/weather", time.Time{}, ok).ServeHTTP(w, httptest.NewRequest("GET", "/weather", nil))
+	if w.Header().Get("Sunset") != "" {
+		t.Errorf("Expected no Sunset without a date, got %q", w.Header().Get("Sunset"))
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Drainer takes the instance out of rotation ahead of a shutdown
+// Once drained, health checks fail and new requests are refused while in-flight ones finish;
+// there is no way back short of a restart, since draining is only ever followed by SIGTERM.
+type Drainer struct {
+	once    sync.Once
+	mu      sync.RWMutex
+	drained bool
+	onDrain func() // e.g. disable keep-alives and stop background work
+}
+
+// NewDrainer creates a Drainer that calls onDrain (may be nil) the first time Drain is called
+func NewDrainer(onDrain func()) *Drainer {
+	return &Drainer{onDrain: onDrain}
+}
+
+// Drain starts draining; calling it again has no effect
+func (d *Drainer) Drain() {
+	d.once.Do(func() {
+		d.mu.Lock()
+		d.drained = true
+		d.mu.Unlock()
+
+		if d.onDrain != nil {
+			d.onDrain()
+		}
+	})
+}
+
+// Draining reports whether Drain has been called
+func (d *Drainer) Draining() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.drained
+}
+
+// RejectWhenDraining refuses new requests with 503 once the drainer has been triggered, asking the
+// client to close the connection so it reconnects to another instance
+// Paths starting with any of exemptPrefixes (health checks, the admin API) still work
+func RejectWhenDraining(drainer *Drainer, exemptPrefixes []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !drainer.Draining() || hasAnyPrefix(r.URL.Path, exemptPrefixes) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		RecordRejection(r.Context(), RejectDraining)
+		w.Header().Set("Connection", "close")
+		writeError(w, r, http.StatusServiceUnavailable, "DRAINING", "Server is shutting down, please retry")
+	})
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRejectWhenDraining(t *testing.T) {
+	drains := 0
+	drainer := NewDrainer(func() { drains++ })
+	handler := RejectWhenDraining(drainer, []string{"/health"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/weather", nil))
+	if w.Code != 200 {
+		t.Fatalf("Expected 200 before draining, got %d", w.Code)
+	}
+
+	drainer.Drain()
+	drainer.Drain()
+	if drains != 1 {
+		t.Errorf("Expected onDrain to run once, ran %d times", drains)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/weather", nil))
+	if w.Code != 503 || w.Header().Get("Connection") != "close" {
+		t.Errorf("Expected 503 with Connection: close while draining, got %d %q", w.Code, w.Header().Get("Connection"))
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != 200 {
+		t.Errorf("Expected exempt path to be served, got %d", w.Code)
+	}
+}
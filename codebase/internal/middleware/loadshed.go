@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"encoding/json"
+	"github.com/krizvi/weather-app-server/internal/requestid"
+	"github.com/krizvi/weather-app-server/internal/tracing"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ConcurrencyLimiter caps the number of requests being processed at once
+// In adaptive mode the cap follows observed latency (AIMD): it shrinks by 10% whenever a request
+// is slower than the target and grows by one after a full cap's worth of fast requests
+type ConcurrencyLimiter struct {
+	mu            sync.Mutex
+	inFlight      int
+	limit         float64
+	minLimit      float64
+	maxLimit      float64
+	targetLatency time.Duration // zero disables adaptation
+	fastStreak    int
+}
+
+// NewConcurrencyLimiter creates a limiter allowing maxInFlight concurrent requests
+// If targetLatencyMs is positive the limit adapts between minInFlight and maxInFlight
+func NewConcurrencyLimiter(maxInFlight, minInFlight, targetLatencyMs int) *ConcurrencyLimiter {
+	if minInFlight < 1 || minInFlight > maxInFlight {
+		minInFlight = maxInFlight
+	}
+	return &ConcurrencyLimiter{
+		limit:         float64(maxInFlight),
+		minLimit:      float64(minInFlight),
+		maxLimit:      float64(maxInFlight),
+		targetLatency: time.Duration(targetLatencyMs) * time.Millisecond,
+	}
+}
+
+// Acquire reserves a slot, returning false if the server is saturated
+func (cl *ConcurrencyLimiter) Acquire() bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if cl.inFlight >= int(cl.limit) {
+		return false
+	}
+	cl.inFlight++
+	return true
+}
+
+// Release frees a slot and feeds the request latency into the adaptive limit
+func (cl *ConcurrencyLimiter) Release(latency time.Duration) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.inFlight--
+	if cl.targetLatency <= 0 {
+		return
+	}
+
+	if latency > cl.targetLatency {
+		cl.limit = math.Max(cl.minLimit, cl.limit*0.9)
+		cl.fastStreak = 0
+		return
+	}
+
+	cl.fastStreak++
+	if cl.fastStreak >= int(cl.limit) {
+		cl.limit = math.Min(cl.maxLimit, cl.limit+1)
+		cl.fastStreak = 0
+	}
+}
+
+// Limit returns the current concurrency cap
+func (cl *ConcurrencyLimiter) Limit() int {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	return int(cl.limit)
+}
+
+// LoadShed rejects requests with 503 and Retry-After once the limiter is saturated instead of letting
+// them queue until timeouts cascade; paths starting with any of exemptPrefixes (e.g. health checks)
+// are never shed
+func LoadShed(limiter *ConcurrencyLimiter, retryAfterSec int, exemptPrefixes []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hasAnyPrefix(r.URL.Path, exemptPrefixes) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !limiter.Acquire() {
+			RecordRejection(r.Context(), RejectOverloaded)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSec))
+			writeError(w, r, http.StatusServiceUnavailable, "OVERLOADED", "Server is overloaded, please retry later")
+			return
+		}
+
+		start := time.Now()
+		defer func() { limiter.Release(time.Since(start)) }()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeError sends a JSON error response in the same shape as the handlers' error responses
+func writeError(w http.ResponseWriter, r *http.Request, statusCode int, code string, message string) {
+	body := map[string]string{"error": message, "code": code}
+	if id := requestid.FromContext(r.Context()); id != "" {
+		body["request_id"] = id
+	}
+	if traceID := tracing.TraceID(r.Context()); traceID != "" {
+		body["trace_id"] = traceID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadShed_RejectsWhenSaturated(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1, 1, 0)
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	handler := LoadShed(limiter, 2, []string{"/health"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/weather" {
+			close(started)
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/weather", nil))
+	<-started
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/other", nil))
+	if w.Code != 503 || w.Header().Get("Retry-After") != "2" {
+		t.Errorf("Expected 503 with Retry-After, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	// Health checks bypass shedding
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != 200 {
+		t.Errorf("Expected exempt path to be served, got %d", w.Code)
+	}
+
+	close(release)
+}
+
+func TestConcurrencyLimiter_Adaptive(t *testing.T) {
+	limiter := NewConcurrencyLimiter(100, 10, 100)
+
+	// Slow requests shrink the limit
+	for i := 0; i < 5; i++ {
+		limiter.Acquire()
+		limiter.Release(time.Second)
+	}
+	shrunk := limiter.Limit()
+	if shrunk >= 100 {
+		t.Fatalf("Expected limit to shrink, got %d", shrunk)
+	}
+
+	// A full limit's worth of fast requests grows it again
+	for i := 0; i < shrunk; i++ {
+		limiter.Acquire()
+		limiter.Release(time.Millisecond)
+	}
+	if limiter.Limit() != shrunk+1 {
+		t.Errorf("Expected limit %d, got %d", shrunk+1, limiter.Limit())
+	}
+
+	// Never below the minimum
+	for i := 0; i < 100; i++ {
+		limiter.Acquire()
+		limiter.Release(time.Second)
+	}
+	if limiter.Limit() != 10 {
+		t.Errorf("Expected limit to floor at 10, got %d", limiter.Limit())
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MaintenanceMode is a switch that takes the API out of service without stopping the process
+// It can be set at startup and toggled at runtime through the admin API
+type MaintenanceMode struct {
+	mu            sync.RWMutex
+	enabled       bool
+	message       string
+	retryAfterSec int
+}
+
+// NewMaintenanceMode creates a MaintenanceMode answering with message and Retry-After while enabled
+func NewMaintenanceMode(enabled bool, message string, retryAfterSec int) *MaintenanceMode {
+	return &MaintenanceMode{enabled: enabled, message: message, retryAfterSec: retryAfterSec}
+}
+
+// Set turns maintenance mode on or off; a non-empty message replaces the current one
+func (mm *MaintenanceMode) Set(enabled bool, message string) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	mm.enabled = enabled
+	if message != "" {
+		mm.message = message
+	}
+}
+
+// Status reports whether maintenance mode is on and the message sent to clients
+func (mm *MaintenanceMode) Status() (bool, string) {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	return mm.enabled, mm.message
+}
+
+// Maintenance answers every request with 503 and Retry-After while maintenance mode is on
+// Paths starting with any of exemptPrefixes (health checks, the admin API to switch it off) still work
+func Maintenance(mode *MaintenanceMode, exemptPrefixes []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enabled, message := mode.Status()
+		if !enabled || hasAnyPrefix(r.URL.Path, exemptPrefixes) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		RecordRejection(r.Context(), RejectMaintenance)
+		w.Header().Set("Retry-After", strconv.Itoa(mode.retryAfterSec))
+		writeError(w, r, http.StatusServiceUnavailable, "MAINTENANCE", message)
+	})
+}
+
+// hasAnyPrefix reports whether path starts with one of prefixes
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMaintenance(t *testing.T) {
+	mode := NewMaintenanceMode(true, "Down for maintenance", 120)
+	handler := Maintenance(mode, []string{"/health", "/admin/"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/weather", 503},
+		{"/health", 200},
+		{"/admin/maintenance", 200},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.status, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/weather", nil))
+	if w.Header().Get("Retry-After") != "120" {
+		t.Errorf("Expected Retry-After 120, got %q", w.Header().Get("Retry-After"))
+	}
+
+	mode.Set(false, "")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/weather", nil))
+	if w.Code != 200 {
+		t.Errorf("Expected requests to pass once maintenance is off, got %d", w.Code)
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"github.com/krizvi/weather-app-server/internal/metrics"
+	"github.com/krizvi/weather-app-server/internal/route"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// statusWriter remembers the response status for metrics
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(statusCode int) {
+	if sw.status == 0 {
+		sw.status = statusCode
+	}
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (e.g. to flush)
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// methodLabel keeps the method label bounded: clients can send any method name they like
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
+
+// Instrument records the rate, errors (5xx responses) and duration of requests by route and method,
+// plus the requests in flight, so every route in the mux gets RED metrics without any code of its own
+// The route is the mux pattern that matched (see route.Track), which keeps the label set small no
+// matter which paths clients make up ("unmatched" for 404s)
+func Instrument(registry *metrics.Registry, next http.Handler) http.Handler {
+	requests := registry.NewCounterVec("http_requests_total", "HTTP requests served, by route, method and status code.", "route", "method", "code")
+	errors := registry.NewCounterVec("http_request_errors_total", "HTTP requests answered with a 5xx status, by route and method.", "route", "method")
+	duration := registry.NewHistogramVec("http_request_duration_seconds", "HTTP request latency by route and method.", metrics.DefaultBuckets, "route", "method")
+	inFlight := registry.NewGaugeVec("http_requests_in_flight", "HTTP requests currently being served.")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		pattern := route.Pattern(r)
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		method := methodLabel(r.Method)
+		requests.Inc(pattern, method, strconv.Itoa(sw.status))
+		if sw.status >= 500 {
+			errors.Inc(pattern, method)
+		}
+		duration.Observe(time.Since(start).Seconds(), pattern, method)
+	})
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"github.com/krizvi/weather-app-server/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInstrument_LabelsByRoute(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/weather", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	mux.HandleFunc("/batch", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	registry := metrics.NewRegistry()
+	handler := Instrument(registry, mux)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/weather?lat=x", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/random/path", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/batch", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/batch", nil))
+
+	w := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range []string{
+		`http_requests_total{route="/weather",method="GET",code="400"} 1`,
+		`http_requests_total{route="unmatched",method="GET",code="404"} 1`,
+		`http_requests_total{route="/batch",method="OTHER",code="502"} 1`,
+		`http_request_errors_total{route="/batch",method="POST"} 1`,
+		`http_request_duration_seconds_count{route="/weather",method="GET"} 1`,
+		`http_requests_in_flight 0`,
+	} {
+		if !strings.Contains(w.Body.String(), line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/krizvi/weather-app-server/internal/errreport"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// panicsRecovered counts panics caught by Recover since startup
+var panicsRecovered atomic.Uint64
+
+// PanicsRecovered returns the number of handler panics Recover has caught
+func PanicsRecovered() uint64 {
+	return panicsRecovered.Load()
+}
+
+// recoverWriter remembers whether the response has started so Recover knows if it can still send an error
+type recoverWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (rw *recoverWriter) WriteHeader(statusCode int) {
+	rw.wroteHeader = true
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rw *recoverWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (e.g. to flush)
+func (rw *recoverWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Recover turns a panic anywhere below it into a logged stack trace and a 500 JSON error
+// instead of a dropped connection; if the response had already started it can only be cut short
+func Recover(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &recoverWriter{ResponseWriter: w}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(recovered) // deliberate abort - let net/http handle it quietly
+			}
+
+			panicsRecovered.Add(1)
+			stack := debug.Stack()
+			logger.ErrorContext(r.Context(), "panic serving request",
+				slog.Any("panic", recovered),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("stack", string(stack)))
+			errreport.Report(r, errreport.Event{Panic: recovered, Stack: stack})
+
+			if !rw.wroteHeader {
+				writeError(rw, r, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+			}
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"github.com/krizvi/weather-app-server/internal/errreport"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecover_ReturnsInternalError(t *testing.T) {
+	before := PanicsRecovered()
+	handler := Recover(slog.Default(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/weather", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"INTERNAL_ERROR"`) {
+		t.Errorf("Expected an INTERNAL_ERROR response, got %q", w.Body.String())
+	}
+	if PanicsRecovered() != before+1 {
+		t.Errorf("Expected the panic to be counted")
+	}
+}
+
+func TestRecover_ReraisesAbortHandler(t *testing.T) {
+	handler := Recover(slog.Default(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if recover() != http.ErrAbortHandler {
+			t.Error("Expected http.ErrAbortHandler to be re-raised")
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/weather", nil))
+}
+
+// recordingReporter collects reported error events
+type recordingReporter struct {
+	events []errreport.Event
+}
+
+func (rr *recordingReporter) Report(ctx context.Context, event errreport.Event) {
+	rr.events = append(rr.events, event)
+}
+
+func TestRecover_ReportsPanic(t *testing.T) {
+	reporter := &recordingReporter{}
+	handler := errreport.Middleware(reporter, Recover(slog.Default(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/weather", nil))
+
+	if len(reporter.events) != 1 || reporter.events[0].Panic != "boom" || len(reporter.events[0].Stack) == 0 {
+		t.Errorf("Expected the panic with its stack to be reported, got %+v", reporter.events)
+	}
+}
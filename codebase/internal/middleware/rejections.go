@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"context"
+	"github.com/krizvi/weather-app-server/internal/metrics"
+	"net/http"
+)
+
+// Rejection reasons used as the reason label
+const (
+	RejectOverloaded     = "overloaded"      // shed by LoadShed
+	RejectMaintenance    = "maintenance"     // maintenance mode is on
+	RejectDraining       = "draining"        // the server is shutting down
+	RejectUpstreamLimit  = "upstream_limit"  // the provider answered 429
+	RejectUpstreamBudget = "upstream_budget" // our own per-minute upstream call budget is used up
+)
+
+type rejectionsKey struct{}
+
+// RecordRejection counts a request turned away for reason (one of the Reject constants)
+// It does nothing outside a request served through CountRejections
+func RecordRejection(ctx context.Context, reason string) {
+	rejected, _ := ctx.Value(rejectionsKey{}).(*metrics.CounterVec)
+	rejected.Inc(reason)
+}
+
+// CountRejections exposes http_requests_rejected_total{reason}, counting requests turned away by load
+// shedding, maintenance mode, draining and rate limits, so capacity decisions can be based on how often
+// that happens; it must wrap every middleware and handler that rejects requests
+func CountRejections(registry *metrics.Registry, next http.Handler) http.Handler {
+	rejected := registry.NewCounterVec("http_requests_rejected_total", "Requests rejected without being served, by reason.", "reason")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), rejectionsKey{}, rejected)))
+	})
+}
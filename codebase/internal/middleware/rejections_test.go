@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"github.com/krizvi/weather-app-server/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCountRejections_ByReason(t *testing.T) {
+	mode := NewMaintenanceMode(true, "Back soon", 60)
+	drainer := NewDrainer(nil)
+	registry := metrics.NewRegistry()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := CountRejections(registry, Maintenance(mode, []string{"/health"}, RejectWhenDraining(drainer, nil, ok)))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/weather", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/weather", nil))
+	mode.Set(false, "")
+	drainer.Drain()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/weather", nil))
+
+	var body strings.Builder
+	registry.WritePrometheus(&body)
+	for _, line := range []string{
+		`http_requests_rejected_total{reason="maintenance"} 2`,
+		`http_requests_rejected_total{reason="draining"} 1`,
+	} {
+		if !strings.Contains(body.String(), line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, body.String())
+		}
+	}
+}
+
+func TestRecordRejection_WithoutCounter(t *testing.T) {
+	// Must not panic outside CountRejections
+	RecordRejection(httptest.NewRequest("GET", "/", nil).Context(), RejectOverloaded)
+}
@@ -0,0 +1,80 @@
+package requestid
+
+import (
+	"bytes"
+	"github.com/krizvi/weather-app-server/internal/logging"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware_GeneratesID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(logging.NewContextHandler(slog.NewTextHandler(&buf, nil)))
+	var seen string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = FromContext(r.Context())
+		logger.InfoContext(r.Context(), "serving")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/weather", nil))
+
+	if len(seen) != 32 {
+		t.Errorf("Expected a generated 32 character ID, got %q", seen)
+	}
+	if rec.Header().Get(Header) != seen {
+		t.Errorf("Expected the response to carry %q, got %q", seen, rec.Header().Get(Header))
+	}
+	if !strings.Contains(buf.String(), "request_id="+seen) {
+		t.Errorf("Expected the request ID in the log line, got %q", buf.String())
+	}
+}
+
+func TestMiddleware_AcceptsIncomingID(t *testing.T) {
+	var seen string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = FromContext(r.Context())
+	}))
+
+	for header, keep := range map[string]bool{
+		"lb-1234-abcd":                 true,
+		"has space":                    false,
+		"line\nbreak":                  false,
+		strings.Repeat("a", 129):       false,
+		strings.Repeat("a", maxLength): true,
+	} {
+		req := httptest.NewRequest("GET", "/weather", nil)
+		req.Header.Set(Header, header)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if (seen == header) != keep {
+			t.Errorf("Expected keep=%v for %q, got %q", keep, header, seen)
+		}
+	}
+}
+
+func TestRoundTripper_ForwardsID(t *testing.T) {
+	var received string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(Header)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: RoundTripper(nil)}
+	req, _ := http.NewRequestWithContext(WithID(t.Context(), "abc123"), "GET", upstream.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	if received != "abc123" {
+		t.Errorf("Expected upstream to receive abc123, got %q", received)
+	}
+	if req.Header.Get(Header) != "" {
+		t.Error("Expected the caller's request to be left unchanged")
+	}
+}
@@ -0,0 +1,69 @@
+// Package route helps with method-qualified ServeMux patterns such as "GET /v1/weather"
+package route
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// matched carries the pattern the mux matched back up to middleware above it
+type matched struct {
+	pattern string
+}
+
+type matchedKey struct{}
+
+// Track lets Pattern find the matched pattern from any request of the chain below it
+// The mux only sets Pattern on the request it is given, so middleware holding a request from before a
+// WithContext further down would otherwise never see it. Wrap the whole stack with Track and the mux
+// with Record
+func Track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(matchedKey{}).(*matched); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), matchedKey{}, &matched{})))
+	})
+}
+
+// Record wraps the mux, handing the pattern it matched to Track; it is recorded even if the handler panics
+func Record(mux http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m, ok := r.Context().Value(matchedKey{}).(*matched); ok {
+			defer func() { m.pattern = r.Pattern }()
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// Pattern returns the path of the pattern that matched r, without its method, or "" if none matched
+// Metrics, traces and logs label requests with it, so the labels don't depend on how a route was registered
+func Pattern(r *http.Request) string {
+	pattern := r.Pattern
+	if m, ok := r.Context().Value(matchedKey{}).(*matched); ok && pattern == "" {
+		pattern = m.pattern
+	}
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return path
+	}
+	return pattern
+}
+
+// methods are the methods Allowed looks for
+var methods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// Allowed returns the methods mux has a route for at the path of r
+// GET routes also serve HEAD, so HEAD is listed with GET
+func Allowed(mux *http.ServeMux, r *http.Request) []string {
+	var allowed []string
+	for _, method := range methods {
+		probe := r.WithContext(r.Context())
+		probe.Method = method
+		if _, pattern := mux.Handler(probe); pattern != "" {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,69 @@
+package route
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestPattern(t *testing.T) {
+	mux := http.NewServeMux()
+	var seen string
+	mux.HandleFunc("GET /v1/weather/{lat}/{lon}", func(w http.ResponseWriter, r *http.Request) { seen = Pattern(r) })
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) { seen = Pattern(r) })
+
+	for _, tc := range []struct{ path, expected string }{
+		{"/v1/weather/1/2", "/v1/weather/{lat}/{lon}"},
+		{"/metrics", "/metrics"},
+	} {
+		seen = ""
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tc.path, nil))
+		if seen != tc.expected {
+			t.Errorf("Expected %s, got %q", tc.expected, seen)
+		}
+	}
+
+	if pattern := Pattern(httptest.NewRequest("GET", "/nowhere", nil)); pattern != "" {
+		t.Errorf("Expected no pattern for an unmatched request, got %q", pattern)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/weather", func(w http.ResponseWriter, r *http.Request) {})
+	var seen string
+	handler := Track(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A middleware in between hands the mux a copy of the request
+		Record(mux).ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), struct{}{}, 1)))
+		seen = Pattern(r)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/weather", nil))
+	if seen != "/v1/weather" {
+		t.Errorf("Expected the pattern matched below the copy, got %q", seen)
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	mux := http.NewServeMux()
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	mux.HandleFunc("GET /webhooks/{id}", noop)
+	mux.HandleFunc("DELETE /webhooks/{id}", noop)
+	mux.HandleFunc("POST /webhooks", noop)
+
+	for _, tc := range []struct {
+		path     string
+		expected []string
+	}{
+		{"/webhooks/abc", []string{"GET", "HEAD", "DELETE"}},
+		{"/webhooks", []string{"POST"}},
+		{"/nowhere", nil},
+	} {
+		allowed := Allowed(mux, httptest.NewRequest("PUT", tc.path, nil))
+		if !slices.Equal(allowed, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.path, tc.expected, allowed)
+		}
+	}
+}
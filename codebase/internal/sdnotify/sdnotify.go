@@ -0,0 +1,32 @@
+package sdnotify
+
+import (
+	"net"
+	"os"
+)
+
+// Standard states, see sd_notify(3)
+const (
+	Ready     = "READY=1"
+	Reloading = "RELOADING=1"
+	Stopping  = "STOPPING=1"
+)
+
+// Notify sends state (newline-separated assignments such as Ready) to the service manager
+// It does nothing when the process is not run by systemd with Type=notify, i.e. NOTIFY_SOCKET is unset
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:] // abstract namespace
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
@@ -0,0 +1,33 @@
+package sdnotify
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestNotify_SendsState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	if err := Notify(Ready + "\nSTATUS=serving"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	buf := make([]byte, 64)
+	n, _ := conn.Read(buf)
+	if got := string(buf[:n]); got != "READY=1\nSTATUS=serving" {
+		t.Errorf("Expected the state to be sent, got %q", got)
+	}
+}
+
+func TestNotify_NoopOutsideSystemd(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := Notify(Ready); err != nil {
+		t.Errorf("Expected no error without NOTIFY_SOCKET, got %v", err)
+	}
+}
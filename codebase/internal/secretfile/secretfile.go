@@ -0,0 +1,88 @@
+package secretfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Read returns the secret in the file at path, e.g. an API key mounted from a secret store, without the
+// surrounding whitespace
+func Read(path string) (string, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	value := strings.TrimSpace(string(body))
+	if value == "" {
+		return "", errors.New("secret file " + path + " is empty")
+	}
+	return value, nil
+}
+
+// Watcher notices when the secret in a file is replaced, e.g. by a secret store rotating it, and passes the
+// new value on without a restart
+type Watcher struct {
+	path     string
+	interval time.Duration
+	onChange func(value string) error
+	logger   *slog.Logger
+
+	mu    sync.Mutex
+	value string
+}
+
+// New creates a Watcher reading the file at path every checkIntervalSec seconds and calling onChange with
+// the secret when it differs from current; a secret onChange fails for is passed on again at the next check
+// A non-positive checkIntervalSec falls back to 30 seconds
+func New(path, current string, checkIntervalSec int, onChange func(value string) error, logger *slog.Logger) *Watcher {
+	if checkIntervalSec <= 0 {
+		checkIntervalSec = 30
+	}
+	return &Watcher{path: path, interval: time.Duration(checkIntervalSec) * time.Second, onChange: onChange, logger: logger, value: current}
+}
+
+// Reload reads the file and calls onChange if the secret changed, reporting whether it did
+func (w *Watcher) Reload() (bool, error) {
+	value, err := Read(w.path)
+	if err != nil {
+		return false, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if value == w.value {
+		return false, nil
+	}
+	if err := w.onChange(value); err != nil {
+		return false, err
+	}
+	w.value = value
+	return true, nil
+}
+
+// Run checks the file every interval until ctx is canceled
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			changed, err := w.Reload()
+			if err != nil {
+				// Secret stores may replace the file in steps, and new keys may take a while to become active;
+				// the next check tries again
+				w.logger.Warn("keeping the current secret", slog.String("file", w.path), slog.String("error", err.Error()))
+			} else if changed {
+				w.logger.Info("secret rotated", slog.String("file", w.path))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
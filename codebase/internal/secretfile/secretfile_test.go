@@ -0,0 +1,72 @@
+package secretfile
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWatcher_PassesOnRotatedSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	if err := os.WriteFile(path, []byte("old-key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	current, err := Read(path)
+	if err != nil || current != "old-key" {
+		t.Fatalf("Expected old-key, got %q %v", current, err)
+	}
+
+	var rotated []string
+	w := New(path, current, 1, func(value string) error {
+		rotated = append(rotated, value)
+		return nil
+	}, slog.Default())
+	if changed, err := w.Reload(); err != nil || changed {
+		t.Errorf("Expected no change, got %v %v", changed, err)
+	}
+
+	os.WriteFile(path, []byte("new-key\n"), 0o600)
+	if changed, err := w.Reload(); err != nil || !changed {
+		t.Errorf("Expected the change to be noticed, got %v %v", changed, err)
+	}
+	if len(rotated) != 1 || rotated[0] != "new-key" {
+		t.Errorf("Expected new-key to be passed on once, got %v", rotated)
+	}
+}
+
+func TestWatcher_KeepsSecretWhenFileIsEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	os.WriteFile(path, []byte(" \n"), 0o600)
+
+	w := New(path, "old-key", 1, func(value string) error {
+		t.Errorf("Expected no rotation, got %q", value)
+		return nil
+	}, slog.Default())
+	if _, err := w.Reload(); err == nil {
+		t.Error("Expected an error for an empty file")
+	}
+}
+
+func TestWatcher_RetriesRejectedSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	os.WriteFile(path, []byte("new-key"), 0o600)
+
+	active := false
+	calls := 0
+	w := New(path, "old-key", 1, func(value string) error {
+		calls++
+		if !active {
+			return errors.New("key not active yet")
+		}
+		return nil
+	}, slog.Default())
+	if _, err := w.Reload(); err == nil {
+		t.Error("Expected the rejection to be reported")
+	}
+	active = true
+	if changed, err := w.Reload(); err != nil || !changed || calls != 2 {
+		t.Errorf("Expected the key to be passed on again, got %v %v after %d calls", changed, err, calls)
+	}
+}
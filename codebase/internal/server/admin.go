@@ -0,0 +1,82 @@
+package server
+
+import (
+	"github.com/krizvi/weather-app-server/internal/analytics"
+	"github.com/krizvi/weather-app-server/internal/cache"
+	"github.com/krizvi/weather-app-server/internal/features"
+	"github.com/krizvi/weather-app-server/internal/handler"
+	"github.com/krizvi/weather-app-server/internal/metering"
+	"github.com/krizvi/weather-app-server/internal/middleware"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"log/slog"
+	"net/http"
+)
+
+// AdminConfig holds what the operator endpoints act on; nil fields leave their endpoints out
+type AdminConfig struct {
+	Token       string // Required in "Authorization: Bearer <token>" by every endpoint
+	Cache       cache.Cache
+	Breaker     *service.CircuitBreakerService
+	Maintenance *middleware.MaintenanceMode
+	Drainer     *middleware.Drainer
+	Analytics   *analytics.Store
+	Meter       *metering.Meter
+	Features    *features.Flags
+	Reload      func() error
+	RotateKey   handler.KeyRotator
+	Pprof       bool // Profiles expose memory contents and the command line
+}
+
+// RegisterAdmin adds the operator endpoints under /admin to mux, behind the admin token
+// It returns the AdminHandler serving them
+func RegisterAdmin(mux *http.ServeMux, cfg AdminConfig, logger *slog.Logger) *handler.AdminHandler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	admin := handler.NewAdmin(cfg.Cache, cfg.Breaker, cfg.Maintenance, cfg.Drainer, logger)
+	protect := func(h http.HandlerFunc) http.HandlerFunc {
+		return handler.RequireAdmin(cfg.Token, logger, h)
+	}
+
+	if cfg.Reload != nil {
+		admin.UseReloader(cfg.Reload)
+		mux.HandleFunc("POST /admin/config/reload", protect(admin.ReloadConfig))
+	}
+	if cfg.Analytics != nil {
+		admin.UseAnalytics(cfg.Analytics)
+		mux.HandleFunc("GET /admin/analytics/top", protect(admin.AnalyticsTop))
+	}
+	if cfg.Meter != nil {
+		admin.UseMetering(cfg.Meter)
+		mux.HandleFunc("GET /admin/usage", protect(admin.Usage))
+	}
+	if cfg.Maintenance != nil {
+		mux.HandleFunc("GET /admin/maintenance", protect(admin.Maintenance))
+		mux.HandleFunc("POST /admin/maintenance", protect(admin.Maintenance))
+	}
+	if cfg.Drainer != nil {
+		mux.HandleFunc("POST /admin/drain", protect(admin.Drain))
+	}
+	if cfg.Features != nil {
+		admin.UseFeatures(cfg.Features)
+		mux.HandleFunc("GET /admin/features", protect(admin.Features))
+		mux.HandleFunc("POST /admin/features", protect(admin.Features))
+	}
+	if cfg.Cache != nil {
+		mux.HandleFunc("GET /admin/cache/stats", protect(admin.CacheStats))
+		mux.HandleFunc("POST /admin/cache/flush", protect(admin.CacheFlush))
+	}
+	if cfg.RotateKey != nil {
+		admin.UseKeyRotator(cfg.RotateKey)
+		mux.HandleFunc("POST /admin/upstream/api-key", protect(admin.RotateAPIKey))
+	}
+	if cfg.Breaker != nil {
+		mux.HandleFunc("GET /admin/upstream/breaker", protect(admin.UpstreamBreaker))
+	}
+	if cfg.Pprof {
+		// For profiling latency and leaks in production
+		mux.HandleFunc("/admin/debug/pprof/", protect(handler.Pprof))
+		mux.HandleFunc("GET /admin/debug/runtime", protect(handler.RuntimeMetrics(logger)))
+	}
+	return admin
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"github.com/krizvi/weather-app-server/internal/cache"
+	"github.com/krizvi/weather-app-server/internal/metering"
+	"github.com/krizvi/weather-app-server/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegisterAdmin_RequiresToken(t *testing.T) {
+	mux := http.NewServeMux()
+	RegisterAdmin(mux, AdminConfig{Token: "secret", Cache: cache.NewMemory(0)}, nil)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/admin/cache/stats", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without the token, got %d", w.Code)
+	}
+
+	r := httptest.NewRequest("GET", "/admin/cache/stats", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 with the token, got %d", w.Code)
+	}
+}
+
+func TestRegisterAdmin_LeavesOutUnconfiguredEndpoints(t *testing.T) {
+	mux := http.NewServeMux()
+	RegisterAdmin(mux, AdminConfig{Token: "secret", Maintenance: middleware.NewMaintenanceMode(false, "", 0)}, nil)
+
+	for path, want := range map[string]int{
+		"/admin/maintenance":      http.StatusOK,
+		"/admin/cache/stats":      http.StatusNotFound,
+		"/admin/upstream/breaker": http.StatusNotFound,
+		"/admin/analytics/top":    http.StatusNotFound,
+		"/admin/usage":            http.StatusNotFound,
+		"/admin/debug/runtime":    http.StatusNotFound,
+	} {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("Expected %d for %s, got %d", want, path, w.Code)
+		}
+	}
+
+	RegisterAdmin(mux, AdminConfig{Token: "secret", Meter: metering.New(1), Pprof: true}, nil)
+	for _, path := range []string{"/admin/debug/runtime", "/admin/usage"} {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected 200 for %s once configured, got %d", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"errors"
+	"github.com/krizvi/weather-app-server/internal/handler"
+	"github.com/krizvi/weather-app-server/internal/middleware"
+	"github.com/krizvi/weather-app-server/internal/route"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"github.com/krizvi/weather-app-server/internal/workpool"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Config holds the settings of the weather API server
+// Zero values fall back to the defaults of the server binary, except the HTTP timeouts, which like those of
+// http.Server are off at zero
+type Config struct {
+	Addr              string // host:port to serve on, ":8080" if empty
+	ReadTimeoutSec    int
+	WriteTimeoutSec   int
+	IdleTimeoutSec    int
+	ClientTimeoutSec  int    // Deadline of each /weather request
+	AdminToken        string // Lets admins bypass the cache with refresh=true (disabled if empty)
+	BatchMaxLocations int    // Most locations accepted by POST /weather/batch
+	BatchConcurrency  int    // Parallel lookups per batch request
+	StreamIntervalSec int    // How often each GET /weather/stream checks for new data
+	StreamMaxClients  int    // Most concurrent streams
+	JobMaxLocations   int    // Most locations accepted by POST /jobs/weather
+	MaxJobs           int    // Most jobs running or kept for their results at once
+	JobRetentionSec   int    // How long a finished job's results are kept
+
+	// OpenWeatherMap is called directly if no provider is given with WithProvider
+	OpenWeatherAPIKey  string
+	OpenWeatherBaseURL string
+	UpstreamTimeoutSec int
+
+	// The routes are served under APIVersion, and at their unprefixed paths as deprecated aliases unless
+	// NoLegacyRoutes is set
+	NoLegacyRoutes bool
+	LegacySunset   time.Time // Announced to clients of the aliases when set
+}
+
+// APIVersion prefixes the routes of the API, so breaking changes can ship under the next version
+const APIVersion = "/v1"
+
+// Middleware wraps a handler with cross-cutting behavior
+type Middleware func(http.Handler) http.Handler
+
+// Option customizes a Server built by New
+type Option func(*options)
+
+// options are what the Options set
+type options struct {
+	logger     *slog.Logger
+	mux        *http.ServeMux
+	middleware []Middleware
+	provider   service.WeatherService
+	pool       *workpool.Pool
+	strict     *handler.StrictValidation
+	settings   *service.RuntimeSettings
+	forecasts  service.ForecastService
+}
+
+// WithLogger logs through logger instead of slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+// WithMux adds the weather routes to mux, which can hold more routes, instead of a new one
+func WithMux(mux *http.ServeMux) Option {
+	return func(o *options) { o.mux = mux }
+}
+
+// WithMiddleware wraps the routes with middleware; each one wraps the routes and the middleware given before
+// it, so the last one sees requests first
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(o *options) { o.middleware = append(o.middleware, middleware...) }
+}
+
+// WithProvider answers lookups from provider, e.g. a stack of cached, retrying, rate-limited and hedged
+// services, instead of calling OpenWeatherMap directly
+func WithProvider(provider service.WeatherService) Option {
+	return func(o *options) { o.provider = provider }
+}
+
+// WithForecasts serves /v1/forecast/ical from forecasts instead of calling OpenWeatherMap directly; without
+// it and without an OpenWeatherMap API key the route is left out
+func WithForecasts(forecasts service.ForecastService) Option {
+	return func(o *options) { o.forecasts = forecasts }
+}
+
+// WithWorkerPool runs batch lookups and cache refreshes in pool, to share its limit with other fan-outs
+func WithWorkerPool(pool *workpool.Pool) Option {
+	return func(o *options) { o.pool = pool }
+}
+
+// WithStrictValidation turns on strict input validation for /weather
+func WithStrictValidation(strict *handler.StrictValidation) Option {
+	return func(o *options) { o.strict = strict }
+}
+
+// WithRuntimeSettings makes the handlers take the client timeout from settings, so configuration reloads
+// reach them, instead of the Config's ClientTimeoutSec
+func WithRuntimeSettings(settings *service.RuntimeSettings) Option {
+	return func(o *options) { o.settings = settings }
+}
+
+// Server is the weather API: its handlers, routes and HTTP server
+type Server struct {
+	HTTP     *http.Server
+	Mux      *http.ServeMux
+	Weather  *handler.WeatherHandler
+	Batch    *handler.BatchHandler
+	Stream   *handler.StreamHandler
+	Jobs     *handler.JobHandler
+	Forecast *handler.ForecastHandler // nil without a forecast provider
+
+	noLegacyRoutes bool
+	legacySunset   time.Time
+}
+
+// HandleFunc adds an API route for pattern, e.g. "GET /version", at APIVersion+path, and at path as a
+// deprecated alias unless legacy routes are off
+func (s *Server) HandleFunc(pattern string, h http.HandlerFunc) {
+	method, path := "", pattern
+	if m, p, ok := strings.Cut(pattern, " "); ok {
+		method, path = m+" ", p
+	}
+	s.Mux.HandleFunc(method+APIVersion+path, h)
+	if !s.noLegacyRoutes {
+		s.Mux.Handle(method+path, middleware.Deprecated(APIVersion+path, s.legacySunset, h))
+	}
+}
+
+// New assembles the weather API from cfg and opts: the weather, batch, stream, jobs and forecast routes
+// under APIVersion on the mux, wrapped in the middleware, served by an HTTP server with the configured timeouts
+// It returns an error if there is no provider: neither WithProvider nor an OpenWeatherMap API key
+func New(cfg Config, opts ...Option) (*Server, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	cfg = withDefaults(cfg)
+	if o.logger == nil {
+		o.logger = slog.Default()
+	}
+	if o.mux == nil {
+		o.mux = http.NewServeMux()
+	}
+	if o.pool == nil {
+		o.pool = workpool.New(0)
+	}
+
+	provider, forecasts := o.provider, o.forecasts
+	if cfg.OpenWeatherAPIKey != "" && (provider == nil || forecasts == nil) {
+		openWeatherMap := service.New(cfg.OpenWeatherAPIKey, cfg.OpenWeatherBaseURL, cfg.UpstreamTimeoutSec, http.DefaultTransport, o.logger)
+		if provider == nil {
+			provider = openWeatherMap
+		}
+		if forecasts == nil {
+			forecasts = openWeatherMap
+		}
+	}
+	if provider == nil {
+		return nil, errors.New("no weather provider: use WithProvider or set OpenWeatherAPIKey")
+	}
+
+	srv := &Server{Mux: o.mux, noLegacyRoutes: cfg.NoLegacyRoutes, legacySunset: cfg.LegacySunset}
+	srv.Weather = handler.New(provider, cfg.ClientTimeoutSec, cfg.AdminToken, o.logger)
+	if o.strict != nil {
+		srv.Weather.UseStrictValidation(o.strict)
+	}
+	srv.Batch = handler.NewBatch(provider, o.pool, cfg.ClientTimeoutSec, cfg.BatchMaxLocations, cfg.BatchConcurrency, o.logger)
+	srv.HandleFunc("GET /weather", srv.Weather.GetWeather)
+	srv.HandleFunc("POST /weather/batch", srv.Batch.GetWeatherBatch)
+	o.mux.HandleFunc("GET "+APIVersion+"/weather/{lat}/{lon}", srv.Weather.GetWeather)
+	srv.Stream = handler.NewStream(provider, cfg.ClientTimeoutSec, cfg.StreamIntervalSec, cfg.StreamMaxClients, o.logger)
+	o.mux.HandleFunc("GET "+APIVersion+"/weather/stream", srv.Stream.StreamWeather)
+	if o.settings != nil {
+		srv.Weather.UseRuntimeSettings(o.settings)
+		srv.Batch.UseRuntimeSettings(o.settings)
+		srv.Stream.UseRuntimeSettings(o.settings)
+	}
+	srv.Jobs = handler.NewJobs(srv.Batch, cfg.JobMaxLocations, cfg.MaxJobs, cfg.JobRetentionSec, o.logger)
+	o.mux.HandleFunc("POST "+APIVersion+"/jobs/weather", srv.Jobs.CreateWeatherJob)
+	o.mux.HandleFunc("GET "+APIVersion+"/jobs/{id}", srv.Jobs.GetJob)
+	if forecasts != nil {
+		srv.Forecast = handler.NewForecast(forecasts, cfg.ClientTimeoutSec, o.logger)
+		if o.settings != nil {
+			srv.Forecast.UseRuntimeSettings(o.settings)
+		}
+		// Like the other routes added since versioning, the feed is only served under APIVersion: the
+		// unversioned aliases keep the first release's clients working, and the feed never had any
+		o.mux.HandleFunc("GET "+APIVersion+"/forecast/ical", srv.Forecast.GetForecastICal)
+	}
+
+	// OPTIONS is answered for every route, and wrong methods get the API's JSON errors rather than the
+	// mux's plain text
+	// route.Record and route.Track let every middleware label requests with the matched pattern
+	root := route.Record(handler.Methods(o.mux, o.logger))
+	for _, mw := range o.middleware {
+		root = mw(root)
+	}
+	root = route.Track(root)
+	srv.HTTP = &http.Server{
+		Addr:         cfg.Addr,
+		Handler:      root,
+		ReadTimeout:  time.Duration(cfg.ReadTimeoutSec) * time.Second,
+		WriteTimeout: time.Duration(cfg.WriteTimeoutSec) * time.Second,
+		IdleTimeout:  time.Duration(cfg.IdleTimeoutSec) * time.Second,
+	}
+	// Streams only end when the client hangs up, which would hold up a graceful shutdown, and jobs outlive
+	// the requests that started them
+	srv.HTTP.RegisterOnShutdown(srv.Stream.Close)
+	srv.HTTP.RegisterOnShutdown(srv.Jobs.Close)
+	return srv, nil
+}
+
+// withDefaults fills in the settings cfg leaves at zero
+func withDefaults(cfg Config) Config {
+	if cfg.Addr == "" {
+		cfg.Addr = ":8080"
+	}
+	if cfg.ClientTimeoutSec == 0 {
+		cfg.ClientTimeoutSec = 10
+	}
+	if cfg.BatchMaxLocations == 0 {
+		cfg.BatchMaxLocations = 100
+	}
+	if cfg.BatchConcurrency == 0 {
+		cfg.BatchConcurrency = 8
+	}
+	if cfg.StreamIntervalSec == 0 {
+		cfg.StreamIntervalSec = 30
+	}
+	if cfg.StreamMaxClients == 0 {
+		cfg.StreamMaxClients = 1000
+	}
+	if cfg.JobMaxLocations == 0 {
+		cfg.JobMaxLocations = 1000
+	}
+	if cfg.MaxJobs == 0 {
+		cfg.MaxJobs = 100
+	}
+	if cfg.JobRetentionSec == 0 {
+		cfg.JobRetentionSec = 3600
+	}
+	if cfg.OpenWeatherBaseURL == "" {
+		cfg.OpenWeatherBaseURL = "https://api.openweathermap.org/data/2.5"
+	}
+	if cfg.UpstreamTimeoutSec == 0 {
+		cfg.UpstreamTimeoutSec = cfg.ClientTimeoutSec
+	}
+	return cfg
+}
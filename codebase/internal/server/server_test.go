@@ -0,0 +1,254 @@
+package server
+
+import (
+	"context"
+	"github.com/krizvi/weather-app-server/internal/cache"
+	"github.com/krizvi/weather-app-server/internal/metrics"
+	"github.com/krizvi/weather-app-server/internal/middleware"
+	"github.com/krizvi/weather-app-server/internal/route"
+	"github.com/krizvi/weather-app-server/internal/servertiming"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// countingProvider answers every lookup with clear weather and counts the calls
+type countingProvider struct {
+	calls int
+}
+
+func (p *countingProvider) GetWeather(ctx context.Context, lat, lon float64) (*service.WeatherData, error) {
+	p.calls++
+	return &service.WeatherData{Condition: "Clear", TemperatureCategory: "moderate"}, nil
+}
+
+func TestNew_ServesWeatherThroughMiddleware(t *testing.T) {
+	provider := &countingProvider{}
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {})
+
+	srv, err := New(Config{}, WithProvider(service.NewCached(provider, cache.NewMemory(0), 300, 0, 0, 10, slog.Default())), WithMux(mux), WithMiddleware(trace("inner"), trace("outer")))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		srv.HTTP.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+		}
+	}
+
+	if provider.calls != 1 {
+		t.Errorf("Expected the second lookup to come from the cache, got %d provider calls", provider.calls)
+	}
+	if want := []string{"outer", "inner", "outer", "inner"}; !slices.Equal(order, want) {
+		t.Errorf("Expected the last middleware outermost, got %v", order)
+	}
+	if srv.HTTP.Addr != ":8080" {
+		t.Errorf("Expected the default address, got %q", srv.HTTP.Addr)
+	}
+	w := httptest.NewRecorder()
+	srv.HTTP.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the routes already on the mux to be kept, got %d", w.Code)
+	}
+}
+
+func TestNew_NeedsProvider(t *testing.T) {
+	if _, err := New(Config{}); err == nil {
+		t.Error("Expected an error without a provider or an API key")
+	}
+	if _, err := New(Config{OpenWeatherAPIKey: "key"}); err != nil {
+		t.Errorf("Expected OpenWeatherMap as the default provider, got %v", err)
+	}
+}
+
+func TestNew_ServesVersionedRoutes(t *testing.T) {
+	srv, err := New(Config{}, WithProvider(&countingProvider{}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	w := httptest.NewRecorder()
+	srv.HTTP.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/weather?lat=40.7&lon=-74", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Deprecation") != "" {
+		t.Errorf("Expected /v1/weather to be served as is, got %d %v", w.Code, w.Header())
+	}
+	w = httptest.NewRecorder()
+	srv.HTTP.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Deprecation") != "true" {
+		t.Errorf("Expected /weather to be a deprecated alias, got %d %v", w.Code, w.Header())
+	}
+
+	srv, _ = New(Config{NoLegacyRoutes: true}, WithProvider(&countingProvider{}))
+	w = httptest.NewRecorder()
+	srv.HTTP.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/weather?lat=40.7&lon=-74", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected no /weather without legacy routes, got %d", w.Code)
+	}
+}
+
+// countingForecasts answers every forecast lookup with one clear day and counts the calls
+type countingForecasts struct {
+	calls int
+}
+
+func (f *countingForecasts) GetForecast(ctx context.Context, lat, lon float64) (*service.Forecast, error) {
+	f.calls++
+	return &service.Forecast{Days: []service.ForecastDay{{Condition: "Clear", TemperatureCategory: "moderate"}}}, nil
+}
+
+func TestNew_ServesForecastFeed(t *testing.T) {
+	forecasts := &countingForecasts{}
+	srv, err := New(Config{}, WithProvider(&countingProvider{}), WithForecasts(service.NewCachedForecast(forecasts, cache.NewMemory(0), 1800, slog.Default())))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		srv.HTTP.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/forecast/ical?lat=40.7&lon=-74", nil))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "SUMMARY:Clear (moderate)") {
+			t.Fatalf("Expected the forecast feed, got %d: %s", w.Code, w.Body)
+		}
+	}
+	if forecasts.calls != 1 {
+		t.Errorf("Expected the second lookup to come from the cache, got %d forecast calls", forecasts.calls)
+	}
+
+	srv, _ = New(Config{}, WithProvider(&countingProvider{}))
+	w := httptest.NewRecorder()
+	srv.HTTP.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/forecast/ical?lat=40.7&lon=-74", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected no forecast feed without a forecast provider, got %d", w.Code)
+	}
+}
+
+func TestNew_ServesCoordinatesInPath(t *testing.T) {
+	srv, err := New(Config{}, WithProvider(&countingProvider{}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	w := httptest.NewRecorder()
+	srv.HTTP.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/weather/40.7/-74", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	w = httptest.NewRecorder()
+	srv.HTTP.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/weather/91/-74", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an out of range latitude, got %d", w.Code)
+	}
+}
+
+func TestNew_RejectsWrongMethod(t *testing.T) {
+	srv, err := New(Config{}, WithProvider(&countingProvider{}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	w := httptest.NewRecorder()
+	srv.HTTP.Handler.ServeHTTP(w, httptest.NewRequest("DELETE", "/v1/weather?lat=40.7&lon=-74", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected 405, got %d", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS" {
+		t.Errorf("Expected Allow: GET, HEAD, OPTIONS, got %q", allow)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected a JSON error, got %q", ct)
+	}
+
+	w = httptest.NewRecorder()
+	srv.HTTP.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/weather/batch", nil))
+	if allow := w.Header().Get("Allow"); w.Code != http.StatusMethodNotAllowed || allow != "POST, OPTIONS" {
+		t.Errorf("Expected 405 with Allow: POST, OPTIONS, got %d %q", w.Code, allow)
+	}
+}
+
+func TestNew_ServesHeadAndOptions(t *testing.T) {
+	srv, err := New(Config{}, WithProvider(service.NewCached(&countingProvider{}, cache.NewMemory(0), 300, 0, 0, 10, slog.Default())))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	ts := httptest.NewServer(srv.HTTP.Handler)
+	defer ts.Close()
+
+	// Both from the cache, so the bodies are the same
+	var get *http.Response
+	var body []byte
+	for i := 0; i < 2; i++ {
+		if get, err = http.Get(ts.URL + "/v1/weather?lat=40.7&lon=-74"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		body, _ = io.ReadAll(get.Body)
+		get.Body.Close()
+	}
+	head, err := http.Head(ts.URL + "/v1/weather?lat=40.7&lon=-74")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	head.Body.Close()
+	if head.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", head.StatusCode)
+	}
+	if head.ContentLength != int64(len(body)) {
+		t.Errorf("Expected Content-Length %d, got %d", len(body), head.ContentLength)
+	}
+	if etag := head.Header.Get("ETag"); etag == "" || etag != get.Header.Get("ETag") {
+		t.Errorf("Expected the ETag of the GET response %q, got %q", get.Header.Get("ETag"), etag)
+	}
+
+	req, _ := http.NewRequest("OPTIONS", ts.URL+"/v1/weather/batch", nil)
+	options, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	options.Body.Close()
+	if options.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", options.StatusCode)
+	}
+	if allow := options.Header.Get("Allow"); allow != "POST, OPTIONS" {
+		t.Errorf("Expected Allow: POST, OPTIONS, got %q", allow)
+	}
+}
+
+func TestNew_RoutePatternSurvivesRequestCopies(t *testing.T) {
+	registry := metrics.NewRegistry()
+	var traced string
+	stack := func(next http.Handler) http.Handler {
+		// servertiming and CountRejections hand the mux a copy of the request, like the production stack
+		next = middleware.CountRejections(registry, servertiming.Middleware(next))
+		next = middleware.Instrument(registry, next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			traced = route.Pattern(r)
+		})
+	}
+	srv, err := New(Config{}, WithProvider(&countingProvider{}), WithMiddleware(stack))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	srv.HTTP.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/weather/40.7/-74", nil))
+
+	if traced != "/v1/weather/{lat}/{lon}" {
+		t.Errorf("Expected the outermost middleware to see the matched route, got %q", traced)
+	}
+	w := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(w.Body.String(), `http_requests_total{route="/v1/weather/{lat}/{lon}",method="GET",code="200"} 1`) {
+		t.Errorf("Expected the request counted under its route, got:\n%s", w.Body)
+	}
+}
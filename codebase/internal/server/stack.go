@@ -0,0 +1,134 @@
+package server
+
+import (
+	"github.com/krizvi/weather-app-server/internal/analytics"
+	"github.com/krizvi/weather-app-server/internal/audit"
+	"github.com/krizvi/weather-app-server/internal/chaos"
+	"github.com/krizvi/weather-app-server/internal/clientip"
+	"github.com/krizvi/weather-app-server/internal/errreport"
+	"github.com/krizvi/weather-app-server/internal/handler"
+	"github.com/krizvi/weather-app-server/internal/metering"
+	"github.com/krizvi/weather-app-server/internal/metrics"
+	"github.com/krizvi/weather-app-server/internal/middleware"
+	"github.com/krizvi/weather-app-server/internal/requestid"
+	"github.com/krizvi/weather-app-server/internal/route"
+	"github.com/krizvi/weather-app-server/internal/servertiming"
+	"github.com/krizvi/weather-app-server/internal/tracing"
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+// StackConfig holds the cross-cutting middleware of the listeners; nil and zero fields leave their
+// middleware out
+type StackConfig struct {
+	Logger      *slog.Logger      // slog.Default() if nil
+	Registry    *metrics.Registry // RED metrics and rejection counts
+	Analytics   *analytics.Store
+	Meter       *metering.Meter // Counts requests per API key, as returned by MeterKey
+	MeterKey    metering.KeyFunc
+	Maintenance *middleware.MaintenanceMode
+	Drainer     *middleware.Drainer
+	Limiter     *middleware.ConcurrencyLimiter // Sheds load once too many requests are in flight
+	Chaos       *chaos.Injector                // Injects faults into inbound requests
+	Tracer      *tracing.Tracer
+	Reporter    errreport.Reporter
+	Auditor     *audit.Log
+	ClientIPs   *clientip.Resolver // Takes the client address from the proxy headers it trusts
+
+	// Probes and scrapes answer for themselves, so they are never put in maintenance, drained or shed
+	ProbePaths []string
+
+	Compression         bool
+	CompressionMinBytes int
+	ServerTiming        bool
+	ShedRetryAfterSec   int
+	AccessLog           bool
+	AccessLogSample     map[string]int // Logs 1 in n successful requests per path
+}
+
+// Stack returns the middleware of the API listener, for WithMiddleware
+func Stack(cfg StackConfig) Middleware {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	exempt := slices.Concat(cfg.ProbePaths, []string{"/admin/"})
+	return func(root http.Handler) http.Handler {
+		if cfg.Analytics != nil {
+			root = analytics.Middleware(cfg.Analytics, root)
+		}
+		if cfg.Meter != nil {
+			// Inside the middleware that rejects requests, so only served requests are billed
+			root = metering.Middleware(cfg.Meter, cfg.MeterKey, root)
+		}
+		if cfg.Registry != nil {
+			root = middleware.Instrument(cfg.Registry, root)
+		}
+		if cfg.Maintenance != nil {
+			root = middleware.Maintenance(cfg.Maintenance, exempt, root)
+		}
+		if cfg.Drainer != nil {
+			root = middleware.RejectWhenDraining(cfg.Drainer, exempt, root)
+		}
+		if cfg.Compression {
+			root = middleware.Compress(cfg.CompressionMinBytes, root)
+		}
+		if cfg.ServerTiming {
+			// Shows cache, upstream and encoding time per request in browser devtools
+			root = servertiming.Middleware(root)
+		}
+		if cfg.Limiter != nil {
+			// Outside the other middleware so shed requests cost as little as possible; probes are never shed,
+			// and streams, which are open for minutes and limited on their own, would throw the latency target off
+			shedExempt := slices.Concat(cfg.ProbePaths, []string{APIVersion + "/weather/stream"})
+			root = middleware.LoadShed(cfg.Limiter, cfg.ShedRetryAfterSec, shedExempt, root)
+		}
+		if cfg.Registry != nil {
+			root = middleware.CountRejections(cfg.Registry, root)
+		}
+		if cfg.Chaos != nil {
+			root = cfg.Chaos.Handler(root)
+		}
+		if cfg.Tracer != nil {
+			root = tracing.Middleware(cfg.Tracer, root)
+		}
+		// So a panic anywhere in the stack becomes a 500 instead of a dropped connection
+		root = middleware.Recover(cfg.Logger, root)
+		if cfg.Reporter != nil {
+			// Outside Recover so panics are reported
+			root = errreport.Middleware(cfg.Reporter, root)
+		}
+		if cfg.AccessLog {
+			// Outside Recover so requests that panicked are logged with their 500
+			root = middleware.AccessLog(cfg.AccessLogSample, cfg.Logger, root)
+		}
+		if cfg.Auditor != nil {
+			// Outside Recover so panics are recorded
+			root = audit.Middleware(cfg.Auditor, root)
+		}
+		if cfg.ClientIPs != nil {
+			root = cfg.ClientIPs.Middleware(root)
+		}
+		// Outermost so every response, and every log line about the request, carries its ID
+		return requestid.Middleware(root)
+	}
+}
+
+// InternalHandler serves mux, e.g. the operator endpoints and scrapes of the internal listener, through
+// a shorter stack than the API's: operator actions are still logged and audited
+func InternalHandler(mux *http.ServeMux, cfg StackConfig) http.Handler {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	root := middleware.Recover(cfg.Logger, route.Record(handler.Methods(mux, cfg.Logger)))
+	if cfg.AccessLog {
+		root = middleware.AccessLog(nil, cfg.Logger, root)
+	}
+	if cfg.Auditor != nil {
+		root = audit.Middleware(cfg.Auditor, root)
+	}
+	if cfg.ClientIPs != nil {
+		root = cfg.ClientIPs.Middleware(root)
+	}
+	return route.Track(requestid.Middleware(root))
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"bytes"
+	"github.com/krizvi/weather-app-server/internal/metering"
+	"github.com/krizvi/weather-app-server/internal/middleware"
+	"github.com/krizvi/weather-app-server/internal/requestid"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStack_MaintenanceSparesProbesAndAdmin(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("GET /admin/maintenance", func(w http.ResponseWriter, r *http.Request) {})
+	maintenance := middleware.NewMaintenanceMode(true, "upgrading", 60)
+	srv, err := New(Config{}, WithProvider(&countingProvider{}), WithMux(mux), WithMiddleware(Stack(StackConfig{
+		Maintenance: maintenance,
+		ProbePaths:  []string{"/health"},
+	})))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for path, want := range map[string]int{
+		"/v1/weather?lat=40.7&lon=-74": http.StatusServiceUnavailable,
+		"/health":                      http.StatusOK,
+		"/admin/maintenance":           http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		srv.HTTP.Handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != want {
+			t.Errorf("Expected %d for %s, got %d", want, path, w.Code)
+		}
+		if w.Header().Get(requestid.Header) == "" {
+			t.Errorf("Expected a request ID on the response for %s", path)
+		}
+	}
+}
+
+func TestStack_RecoversAndLogsThroughLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /boom", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	srv, err := New(Config{}, WithProvider(&countingProvider{}), WithMux(mux), WithMiddleware(Stack(StackConfig{
+		Logger:    logger,
+		AccessLog: true,
+	})))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.HTTP.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/boom", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500, got %d", w.Code)
+	}
+	for _, line := range []string{"panic serving request", "path=/boom status=500"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("Expected %q in the log, got %q", line, buf.String())
+		}
+	}
+}
+
+func TestStack_LoadSheddingSparesProbesAndStreams(t *testing.T) {
+	limiter := middleware.NewConcurrencyLimiter(1, 1, 0)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {})
+	srv, err := New(Config{}, WithProvider(&countingProvider{}), WithMux(mux), WithMiddleware(Stack(StackConfig{
+		Limiter:    limiter,
+		ProbePaths: []string{"/health"},
+	})))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		srv.HTTP.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+		close(done)
+	}()
+	<-started
+	defer func() {
+		close(release)
+		<-done
+	}()
+
+	w := httptest.NewRecorder()
+	srv.HTTP.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/weather?lat=40.7&lon=-74", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected the lookup to be shed, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	srv.HTTP.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the probe not to be shed, got %d", w.Code)
+	}
+}
+
+func TestStack_MetersServedRequests(t *testing.T) {
+	meter := metering.New(1)
+	maintenance := middleware.NewMaintenanceMode(false, "", 60)
+	srv, err := New(Config{}, WithProvider(&countingProvider{}), WithMiddleware(Stack(StackConfig{
+		Meter:       meter,
+		MeterKey:    metering.HeaderKey("X-Api-Key"),
+		Maintenance: maintenance,
+	})))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	lookup := func() {
+		r := httptest.NewRequest("GET", "/v1/weather?lat=40.7&lon=-74", nil)
+		r.Header.Set("X-Api-Key", "team-a")
+		srv.HTTP.Handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	lookup()
+	maintenance.Set(true, "upgrading")
+	lookup()
+
+	usage := meter.Usage(1, "")
+	if len(usage) != 1 || usage[0].Requests != 1 || usage[0].UpstreamCalls != 1 {
+		t.Errorf("Expected only the served request billed to team-a, got %+v", usage)
+	}
+}
+
+func TestInternalHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /admin/drain", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("GET /admin/boom", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	h := InternalHandler(mux, StackConfig{})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/admin/drain", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected a JSON 405, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/admin/boom", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500, got %d", w.Code)
+	}
+	if w.Header().Get(requestid.Header) == "" {
+		t.Error("Expected a request ID on the response")
+	}
+}
@@ -0,0 +1,92 @@
+package servertiming
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metric is one named duration; repeated measurements under the same name add up
+type metric struct {
+	name     string
+	duration time.Duration
+}
+
+// timings collects the durations measured while serving one request
+type timings struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type timingsKey struct{}
+
+// Add records d under name for the Server-Timing header of the request ctx belongs to
+// It does nothing outside a request served through Middleware
+func Add(ctx context.Context, name string, d time.Duration) {
+	collected, _ := ctx.Value(timingsKey{}).(*timings)
+	if collected == nil {
+		return
+	}
+	collected.mu.Lock()
+	defer collected.mu.Unlock()
+	for i := range collected.metrics {
+		if collected.metrics[i].name == name {
+			collected.metrics[i].duration += d
+			return
+		}
+	}
+	collected.metrics = append(collected.metrics, metric{name: name, duration: d})
+}
+
+// header renders the collected metrics plus the total so far
+func (t *timings) header(total time.Duration) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	parts := make([]string, 0, len(t.metrics)+1)
+	for _, m := range append(t.metrics, metric{name: "total", duration: total}) {
+		parts = append(parts, fmt.Sprintf("%s;dur=%.1f", m.name, float64(m.duration.Microseconds())/1000))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// timingWriter adds the Server-Timing header just before the response headers go out
+type timingWriter struct {
+	http.ResponseWriter
+	timings     *timings
+	start       time.Time
+	wroteHeader bool
+}
+
+func (tw *timingWriter) WriteHeader(statusCode int) {
+	if !tw.wroteHeader {
+		tw.wroteHeader = true
+		tw.Header().Set("Server-Timing", tw.timings.header(time.Since(tw.start)))
+	}
+	tw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (tw *timingWriter) Write(b []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (e.g. to flush)
+func (tw *timingWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// Middleware sends a Server-Timing header with the durations recorded through Add while the
+// request was handled (e.g. cache lookup, upstream fetch, encoding) and the total time until
+// the response started, so browser devtools show where the latency comes from
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		collected := &timings{}
+		tw := &timingWriter{ResponseWriter: w, timings: collected, start: time.Now()}
+		next.ServeHTTP(tw, r.WithContext(context.WithValue(r.Context(), timingsKey{}, collected)))
+	})
+}
@@ -0,0 +1,32 @@
+package servertiming
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestMiddleware_SendsRecordedTimings(t *testing.T) {
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Add(r.Context(), "cache", 2*time.Millisecond)
+		Add(r.Context(), "upstream", 80*time.Millisecond)
+		Add(r.Context(), "cache", time.Millisecond)
+		w.Write([]byte("ok"))
+		Add(r.Context(), "late", time.Second) // after the headers went out
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/weather", nil))
+
+	header := w.Header().Get("Server-Timing")
+	if !regexp.MustCompile(`^cache;dur=3\.0, upstream;dur=80\.0, total;dur=\d+\.\d$`).MatchString(header) {
+		t.Errorf("Expected cache, upstream and total timings, got %q", header)
+	}
+}
+
+func TestAdd_WithoutMiddleware(t *testing.T) {
+	// Must not panic outside a timed request
+	Add(httptest.NewRequest("GET", "/", nil).Context(), "cache", time.Millisecond)
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrBulkheadFull is returned when every slot for the provider stays busy for the whole wait
+var ErrBulkheadFull = errors.New("too many concurrent upstream calls")
+
+// BulkheadWeatherService caps concurrent calls to the wrapped provider
+// A slow provider can then only tie up its own slots instead of the server's whole goroutine and
+// connection budget. Calls beyond the cap wait up to maxWait for a slot, then fail fast.
+type BulkheadWeatherService struct {
+	upstream WeatherService
+	slots    chan struct{}
+	maxWait  time.Duration
+}
+
+// NewBulkhead creates a BulkheadWeatherService allowing maxConcurrent calls at once
+// Excess calls wait up to maxWaitMs (0 fails them immediately)
+func NewBulkhead(upstream WeatherService, maxConcurrent, maxWaitMs int) *BulkheadWeatherService {
+	return &BulkheadWeatherService{
+		upstream: upstream,
+		slots:    make(chan struct{}, maxConcurrent),
+		maxWait:  time.Duration(maxWaitMs) * time.Millisecond,
+	}
+}
+
+// GetWeather calls the wrapped service once a slot is free
+func (srv *BulkheadWeatherService) GetWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	if err := srv.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer func() { <-srv.slots }()
+
+	return srv.upstream.GetWeather(ctx, lat, lon)
+}
+
+// Forecasts returns a ForecastService calling upstream within the same slots as GetWeather
+func (srv *BulkheadWeatherService) Forecasts(upstream ForecastService) ForecastService {
+	return forecastFunc(func(ctx context.Context, lat, lon float64) (*Forecast, error) {
+		if err := srv.acquire(ctx); err != nil {
+			return nil, err
+		}
+		defer func() { <-srv.slots }()
+
+		return upstream.GetForecast(ctx, lat, lon)
+	})
+}
+
+// acquire takes a slot, waiting at most maxWait
+func (srv *BulkheadWeatherService) acquire(ctx context.Context) error {
+	select {
+	case srv.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if srv.maxWait <= 0 {
+		return ErrBulkheadFull
+	}
+
+	timer := time.NewTimer(srv.maxWait)
+	defer timer.Stop()
+
+	select {
+	case srv.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrBulkheadFull
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
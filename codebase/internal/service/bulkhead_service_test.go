@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blockingService blocks every call until release is closed
+type blockingService struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingService) GetWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return &WeatherData{Condition: "Clear"}, nil
+}
+
+func TestBulkhead_RejectsBeyondCap(t *testing.T) {
+	upstream := &blockingService{started: make(chan struct{}, 2), release: make(chan struct{})}
+	bulkhead := NewBulkhead(upstream, 2, 20)
+
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := bulkhead.GetWeather(context.Background(), 1, 2)
+			done <- err
+		}()
+		<-upstream.started
+	}
+
+	start := time.Now()
+	if _, err := bulkhead.GetWeather(context.Background(), 1, 2); !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("Expected ErrBulkheadFull, got %v", err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Error("Expected the call to wait for a slot before failing")
+	}
+
+	close(upstream.release)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("Expected in-flight calls to succeed, got %v", err)
+		}
+	}
+
+	// Slots are freed once calls complete
+	if _, err := bulkhead.GetWeather(context.Background(), 1, 2); err != nil {
+		t.Errorf("Expected a free slot, got %v", err)
+	}
+}
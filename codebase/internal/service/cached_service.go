@@ -0,0 +1,488 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/cache"
+	"github.com/krizvi/weather-app-server/internal/coord"
+	"github.com/krizvi/weather-app-server/internal/metrics"
+	"github.com/krizvi/weather-app-server/internal/requestid"
+	"github.com/krizvi/weather-app-server/internal/servertiming"
+	"github.com/krizvi/weather-app-server/internal/tracing"
+	"github.com/krizvi/weather-app-server/internal/workpool"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// forceRefreshKey is the context key marking a lookup that must bypass the cache
+type forceRefreshKey struct{}
+
+// WithForceRefresh returns a context that makes CachedWeatherService skip the cache lookup and
+// fetch fresh data from upstream, storing the result as usual
+func WithForceRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceRefreshKey{}, true)
+}
+
+// isForceRefresh reports whether ctx was created with WithForceRefresh
+func isForceRefresh(ctx context.Context) bool {
+	force, _ := ctx.Value(forceRefreshKey{}).(bool)
+	return force
+}
+
+// CachedWeatherService wraps another WeatherService with a cache
+// Fresh entries are served directly; entries within staleTTL of expiry are served immediately with
+// Stale set while a background refresh fetches new data (stale-while-revalidate). Older entries are
+// kept for lastKnownGoodTTL and only served, marked Degraded, when the upstream call fails.
+type CachedWeatherService struct {
+	upstream       WeatherService
+	cache          cache.Cache
+	ttl            time.Duration // how long an entry is considered fresh
+	staleTTL       time.Duration // how long past ttl an entry may still be served stale
+	lkgTTL         time.Duration // how long past ttl an entry is kept as a fallback for upstream outages
+	refreshTimeout time.Duration // timeout for background refreshes (no request context to inherit)
+	now            func() time.Time
+	hot            *HotLocations       // optional request frequency tracker used for prefetching
+	coordinator    coord.Coordinator   // optional fleet-wide locks for refreshes of a shared cache
+	pool           *workpool.Pool      // caps upstream calls made by warm-up and prefetch fan-outs
+	lookups        *metrics.CounterVec // optional hit/stale/miss counter
+	avoided        *metrics.CounterVec // optional estimate of upstream calls saved by the cache
+	backend        string              // cache backend name for metric labels
+	logger         *slog.Logger
+
+	statsMu   sync.Mutex
+	stats     cache.Stats // last backend stats read for metrics
+	statsRead time.Time
+
+	mu         sync.Mutex
+	refreshing map[string]bool       // keys with a background refresh in flight
+	fetching   map[string]*missFetch // keys with a miss fetch in flight, shared by concurrent misses
+	wg         sync.WaitGroup
+}
+
+// missFetch is an upstream fetch for a cache miss that concurrent misses on the same key wait for
+type missFetch struct {
+	done     chan struct{}
+	data     *WeatherData
+	err      error
+	duration time.Duration // of the upstream call, for the Server-Timing of every waiter
+}
+
+// missLockWait is how long a miss waits for another instance holding the fetch lock to store the entry
+// before fetching it itself
+const missLockWait = 2 * time.Second
+
+// missLockPoll is how often a miss waiting on another instance re-reads the cache
+const missLockPoll = 50 * time.Millisecond
+
+// NewCached creates a new CachedWeatherService in front of the given upstream service
+func NewCached(upstream WeatherService, c cache.Cache, ttlSec, staleTTLSec, lastKnownGoodTTLSec, refreshTimeoutSec int, logger *slog.Logger) *CachedWeatherService {
+	return &CachedWeatherService{
+		upstream:       upstream,
+		cache:          c,
+		ttl:            time.Duration(ttlSec) * time.Second,
+		staleTTL:       time.Duration(staleTTLSec) * time.Second,
+		lkgTTL:         time.Duration(lastKnownGoodTTLSec) * time.Second,
+		refreshTimeout: time.Duration(refreshTimeoutSec) * time.Second,
+		pool:           workpool.New(0),
+		now:            time.Now,
+		refreshing:     make(map[string]bool),
+		fetching:       make(map[string]*missFetch),
+		logger:         logger,
+	}
+}
+
+// GetWeather returns cached weather data for the coordinates, falling back to the upstream service
+func (srv *CachedWeatherService) GetWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	ctx, span := tracing.Start(ctx, "cache.GetWeather", tracing.KindInternal)
+	defer span.End()
+
+	key := CacheKey(lat, lon)
+	if srv.hot != nil {
+		srv.hot.Record(lat, lon)
+	}
+
+	if isForceRefresh(ctx) {
+		span.SetAttribute("cache.result", "bypass")
+		return srv.fetchAndStore(ctx, key, lat, lon)
+	}
+
+	lookupStart := time.Now()
+	entry, found, err := srv.cache.Get(ctx, key)
+	servertiming.Add(ctx, "cache", time.Since(lookupStart))
+	if err != nil {
+		// A broken cache should never take the endpoint down with it
+		srv.logger.WarnContext(ctx, "cache lookup failed", slog.String("key", key), slog.String("error", err.Error()))
+		found = false
+	}
+
+	if !found {
+		srv.lookups.Inc(srv.backend, "miss")
+		span.SetAttribute("cache.result", "miss")
+		return srv.fetchMiss(ctx, key, lat, lon)
+	}
+
+	data, err := decodeEntry(entry)
+	if err != nil {
+		srv.logger.WarnContext(ctx, "discarding undecodable cache entry", slog.String("key", key), slog.String("error", err.Error()))
+		srv.lookups.Inc(srv.backend, "miss")
+		span.SetAttribute("cache.result", "miss")
+		return srv.fetchMiss(ctx, key, lat, lon)
+	}
+
+	now := srv.now()
+	data.AgeSeconds = int64(now.Sub(data.FetchedAt).Seconds())
+	switch {
+	case entry.IsFresh(now):
+		srv.lookups.Inc(srv.backend, "hit")
+		srv.avoided.Inc(srv.backend)
+		span.SetAttribute("cache.result", "hit")
+		return data, nil
+	case now.Before(entry.ExpiresAt.Add(srv.staleTTL)):
+		// Serve what we have right away and let the refresh happen off the request path
+		srv.lookups.Inc(srv.backend, "stale")
+		span.SetAttribute("cache.result", "stale")
+		if !srv.refreshAsync(key, lat, lon) {
+			// Only the first stale hit on a key triggers an upstream call
+			srv.avoided.Inc(srv.backend)
+		}
+		data.Stale = true
+		return data, nil
+	}
+
+	// Too old to serve by default - only fall back to it if upstream can't give us anything better
+	srv.lookups.Inc(srv.backend, "miss")
+	span.SetAttribute("cache.result", "expired")
+	fresh, err := srv.fetchMiss(ctx, key, lat, lon)
+	if err != nil {
+		srv.logger.WarnContext(ctx, "serving last-known-good data", slog.String("key", key), slog.String("error", err.Error()))
+		data.Stale = true
+		data.Degraded = true
+		data.DataAgeSeconds = data.AgeSeconds
+		return data, nil
+	}
+	return fresh, nil
+}
+
+// Warm pre-populates the cache for the given locations using up to concurrency parallel upstream calls
+// Locations that already have a fresh entry (e.g. from a persistent backend) are skipped
+// Returns the number of locations that are warm afterwards
+func (srv *CachedWeatherService) Warm(ctx context.Context, locations []Location, concurrency int) int {
+	populated := srv.populate(ctx, locations, concurrency, 0)
+	return populated.fetched + populated.skipped
+}
+
+// TrackHotLocations records every lookup in hot so popular locations can be prefetched
+func (srv *CachedWeatherService) TrackHotLocations(hot *HotLocations) {
+	srv.hot = hot
+}
+
+// UseCoordinator makes cache misses, background refreshes and prefetch rounds take fleet-wide locks so
+// only one instance fetches a given entry; only useful when instances share the cache backend
+func (srv *CachedWeatherService) UseCoordinator(coordinator coord.Coordinator) {
+	srv.coordinator = coordinator
+}
+
+// UseMetrics counts cache hits, stale hits, misses and the upstream calls they saved in registry, and
+// exposes the entry and eviction counts of the cache, all labeled with the backend name
+func (srv *CachedWeatherService) UseMetrics(registry *metrics.Registry, backend string) {
+	srv.backend = backend
+	srv.lookups = registry.NewCounterVec("cache_lookups_total", "Weather cache lookups, by backend and result (hit, stale or miss).", "backend", "result")
+	srv.avoided = registry.NewCounterVec("cache_upstream_calls_avoided_total", "Estimated upstream calls saved by serving from the cache.", "backend")
+	label := metrics.Label{Name: "backend", Value: backend}
+	registry.NewGaugeFunc("cache_entries", "Entries currently held by the cache backend.", func() float64 {
+		return float64(srv.backendStats().Entries)
+	}, label)
+	registry.NewCounterFunc("cache_evictions_total", "Entries dropped by the cache backend for capacity or after their retention elapsed.", func() float64 {
+		return float64(srv.backendStats().Evictions)
+	}, label)
+}
+
+// backendStats returns the cache backend's stats, reading them at most once a second
+// One scrape reads several values and remote backends answer with a network round trip
+func (srv *CachedWeatherService) backendStats() cache.Stats {
+	srv.statsMu.Lock()
+	defer srv.statsMu.Unlock()
+	if time.Since(srv.statsRead) < time.Second {
+		return srv.stats
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	stats, err := srv.cache.Stats(ctx)
+	if err != nil {
+		// Keep reporting the last known values rather than dropping to zero
+		srv.logger.Warn("reading cache stats failed", slog.String("error", err.Error()))
+		return srv.stats
+	}
+	srv.stats = stats
+	srv.statsRead = time.Now()
+	return stats
+}
+
+// UseWorkerPool makes warm-up and prefetch fan-outs share pool with other fan-out operations
+func (srv *CachedWeatherService) UseWorkerPool(pool *workpool.Pool) {
+	srv.pool = pool
+}
+
+// populateResult counts the outcome of a populate run
+type populateResult struct {
+	fetched int // fetched from upstream and stored
+	skipped int // entry was still fresh enough
+}
+
+// populate fetches and stores the given locations unless their entry stays fresh for at least freshFor
+func (srv *CachedWeatherService) populate(ctx context.Context, locations []Location, concurrency int, freshFor time.Duration) populateResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var fetched, skipped atomic.Int32
+	srv.pool.Run(ctx, len(locations), concurrency, func(ctx context.Context, i int) {
+		location := locations[i]
+		key := CacheKey(location.Lat, location.Lon)
+		if entry, found, err := srv.cache.Get(ctx, key); err == nil && found && entry.IsFresh(srv.now().Add(freshFor)) {
+			skipped.Add(1)
+			return
+		}
+
+		if _, err := srv.fetchAndStore(ctx, key, location.Lat, location.Lon); err != nil {
+			srv.logger.Warn("cache populate failed", slog.String("key", key), slog.String("error", err.Error()))
+			return
+		}
+		fetched.Add(1)
+	})
+	return populateResult{fetched: int(fetched.Load()), skipped: int(skipped.Load())}
+}
+
+// Close waits for in-flight background refreshes and shared miss fetches to finish
+func (srv *CachedWeatherService) Close() {
+	srv.wg.Wait()
+}
+
+// fetchMiss fetches the entry for key after a cache miss
+// Concurrent misses on the same key share one upstream call, and with a coordinator the instances sharing
+// the cache do too: the instance holding the fetch lock fetches while the others wait for its entry
+func (srv *CachedWeatherService) fetchMiss(ctx context.Context, key string, lat, lon float64) (*WeatherData, error) {
+	// The dump belongs to this request, so like a forced refresh it makes its own upstream call
+	if isDebugDump(ctx) {
+		return srv.fetchAndStore(ctx, key, lat, lon)
+	}
+
+	srv.mu.Lock()
+	fetch, shared := srv.fetching[key]
+	if !shared {
+		fetch = &missFetch{done: make(chan struct{})}
+		srv.fetching[key] = fetch
+		srv.wg.Add(1)
+		fetchCtx, cancel := sharedFetchContext(ctx, srv.refreshTimeout)
+		go srv.runMissFetch(fetchCtx, cancel, fetch, key, lat, lon)
+	}
+	srv.mu.Unlock()
+
+	select {
+	case <-fetch.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	servertiming.Add(ctx, "upstream", fetch.duration)
+	if fetch.err != nil {
+		return nil, fetch.err
+	}
+	if shared {
+		srv.avoided.Inc(srv.backend)
+	}
+	// Callers may mark their copy stale or degraded
+	data := *fetch.data
+	return &data, nil
+}
+
+// sharedFetchContext returns the context of a shared fetch started by the caller of ctx
+// The fetch outlives that caller, so that caller leaving doesn't fail the others: it keeps the caller's
+// deadline (or timeout without one), request ID and trace, but not its cancellation, and it leaves out the
+// caller's Server-Timing recorder and debug dump, which belong to that one request
+func sharedFetchContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	shared := tracing.WithParent(requestid.WithID(context.Background(), requestid.FromContext(ctx)), ctx)
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(timeout)
+	}
+	return context.WithDeadline(shared, deadline)
+}
+
+// runMissFetch runs the shared fetch of fetchMiss
+func (srv *CachedWeatherService) runMissFetch(ctx context.Context, cancel context.CancelFunc, fetch *missFetch, key string, lat, lon float64) {
+	defer srv.wg.Done()
+	defer cancel()
+
+	start := time.Now()
+	fetch.data, fetch.err = srv.fetchLocked(ctx, key, lat, lon)
+	fetch.duration = time.Since(start)
+	srv.mu.Lock()
+	delete(srv.fetching, key)
+	srv.mu.Unlock()
+	close(fetch.done)
+}
+
+// fetchLocked fetches and stores the entry for key under the coordinator's fetch lock
+// When another instance holds the lock it waits up to missLockWait for that instance's entry, and fetches
+// the entry itself if none shows up or the lock can't be taken
+func (srv *CachedWeatherService) fetchLocked(ctx context.Context, key string, lat, lon float64) (*WeatherData, error) {
+	if srv.coordinator == nil {
+		return srv.fetchAndStore(ctx, key, lat, lon)
+	}
+
+	release, acquired, err := srv.coordinator.TryLock(ctx, "fetch:"+key, srv.refreshTimeout)
+	if err != nil {
+		srv.logger.WarnContext(ctx, "fetch lock failed", slog.String("key", key), slog.String("error", err.Error()))
+		return srv.fetchAndStore(ctx, key, lat, lon)
+	}
+	if acquired {
+		defer release()
+		return srv.fetchAndStore(ctx, key, lat, lon)
+	}
+
+	if data, ok := srv.awaitEntry(ctx, key); ok {
+		srv.avoided.Inc(srv.backend)
+		return data, nil
+	}
+	return srv.fetchAndStore(ctx, key, lat, lon)
+}
+
+// awaitEntry re-reads the cache until it holds a fresh entry for key, for at most missLockWait
+func (srv *CachedWeatherService) awaitEntry(ctx context.Context, key string) (*WeatherData, bool) {
+	ticker := time.NewTicker(missLockPoll)
+	defer ticker.Stop()
+	deadline := time.NewTimer(missLockWait)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return nil, false
+		case <-ctx.Done():
+			return nil, false
+		}
+
+		entry, found, err := srv.cache.Get(ctx, key)
+		if err != nil || !found || !entry.IsFresh(srv.now()) {
+			continue
+		}
+		data, err := decodeEntry(entry)
+		if err != nil {
+			return nil, false
+		}
+		data.AgeSeconds = int64(srv.now().Sub(data.FetchedAt).Seconds())
+		return data, true
+	}
+}
+
+// fetchAndStore calls the upstream service and caches a successful result
+func (srv *CachedWeatherService) fetchAndStore(ctx context.Context, key string, lat, lon float64) (*WeatherData, error) {
+	fetchStart := time.Now()
+	data, err := srv.upstream.GetWeather(ctx, lat, lon)
+	servertiming.Add(ctx, "upstream", time.Since(fetchStart))
+	if err != nil {
+		return nil, err
+	}
+
+	// Use our clock for the fetch time so it lines up with the entry's expiry
+	now := srv.now()
+	data.FetchedAt = now
+
+	value, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	entry := &cache.Entry{
+		Value:     value,
+		StoredAt:  now,
+		ExpiresAt: now.Add(srv.ttl),
+	}
+	if err := srv.cache.Set(ctx, key, entry, srv.ttl+max(srv.staleTTL, srv.lkgTTL)); err != nil {
+		srv.logger.WarnContext(ctx, "cache store failed", slog.String("key", key), slog.String("error", err.Error()))
+	}
+
+	data.ETag = payloadETag(value)
+	data.ExpiresAt = entry.ExpiresAt
+	return data, nil
+}
+
+// refreshAsync refreshes the entry for key in the background
+// Only one refresh per key runs at a time so a burst of stale hits results in a single upstream call;
+// it reports whether a refresh was started
+func (srv *CachedWeatherService) refreshAsync(key string, lat, lon float64) bool {
+	srv.mu.Lock()
+	if srv.refreshing[key] {
+		srv.mu.Unlock()
+		return false
+	}
+	srv.refreshing[key] = true
+	srv.mu.Unlock()
+
+	srv.wg.Add(1)
+	go func() {
+		defer srv.wg.Done()
+		defer func() {
+			srv.mu.Lock()
+			delete(srv.refreshing, key)
+			srv.mu.Unlock()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), srv.refreshTimeout)
+		defer cancel()
+
+		// Another instance sharing the cache may already be refreshing this entry
+		if srv.coordinator != nil {
+			release, acquired, err := srv.coordinator.TryLock(ctx, "refresh:"+key, srv.refreshTimeout)
+			if err != nil {
+				srv.logger.Warn("refresh lock failed", slog.String("key", key), slog.String("error", err.Error()))
+			} else if !acquired {
+				return
+			} else {
+				defer release()
+			}
+		}
+
+		if _, err := srv.fetchAndStore(ctx, key, lat, lon); err != nil {
+			srv.logger.Warn("background refresh failed", slog.String("key", key), slog.String("error", err.Error()))
+		}
+	}()
+	return true
+}
+
+// decodeEntry turns a cache entry back into WeatherData along with its cache metadata
+func decodeEntry(entry *cache.Entry) (*WeatherData, error) {
+	var data WeatherData
+	if err := json.Unmarshal(entry.Value, &data); err != nil {
+		return nil, err
+	}
+
+	data.Cached = true
+	data.ETag = payloadETag(entry.Value)
+	data.FetchedAt = entry.StoredAt
+	data.ExpiresAt = entry.ExpiresAt
+	return &data, nil
+}
+
+// payloadETag derives a weak ETag from the cached payload
+// It is weak because the response may carry per-request markers (e.g. stale) on top of the payload
+func payloadETag(value []byte) string {
+	sum := sha256.Sum256(value)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// CacheKeyPrefix is the prefix shared by all weather cache keys
+const CacheKeyPrefix = "weather:"
+
+// CacheKey builds the cache key for a coordinate pair
+// Coordinates are rounded to 4 decimal places (~11m) so nearby lookups share an entry
+func CacheKey(lat, lon float64) string {
+	return fmt.Sprintf("%s%.4f,%.4f", CacheKeyPrefix, lat, lon)
+}
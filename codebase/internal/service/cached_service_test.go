@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/cache"
+	"github.com/krizvi/weather-app-server/internal/coord"
+	"github.com/krizvi/weather-app-server/internal/metrics"
+	"github.com/krizvi/weather-app-server/internal/requestid"
+	"github.com/krizvi/weather-app-server/internal/tracing"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Fake upstream that counts calls
+type countingService struct {
+	calls       atomic.Int32
+	shouldError bool
+}
+
+func (c *countingService) GetWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	n := c.calls.Add(1)
+	if c.shouldError {
+		return nil, fmt.Errorf("upstream down")
+	}
+	return &WeatherData{Condition: fmt.Sprintf("call-%d", n), TemperatureCategory: "hot"}, nil
+}
+
+func TestCachedWeatherService_FreshHit(t *testing.T) {
+	upstream := &countingService{}
+	srv := NewCached(upstream, cache.NewMemory(0), 60, 60, 0, 5, slog.Default())
+
+	for i := 0; i < 3; i++ {
+		data, err := srv.GetWeather(context.Background(), 40.7, -74.0)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if data.Stale {
+			t.Error("Expected fresh data")
+		}
+	}
+
+	if upstream.calls.Load() != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", upstream.calls.Load())
+	}
+}
+
+func TestCachedWeatherService_StaleWhileRevalidate(t *testing.T) {
+	upstream := &countingService{}
+	srv := NewCached(upstream, cache.NewMemory(0), 60, 600, 0, 5, slog.Default())
+
+	now := time.Now()
+	srv.now = func() time.Time { return now }
+
+	if _, err := srv.GetWeather(context.Background(), 40.7, -74.0); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Move past the TTL but stay within the stale window
+	now = now.Add(90 * time.Second)
+
+	data, err := srv.GetWeather(context.Background(), 40.7, -74.0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !data.Stale {
+		t.Error("Expected stale data to be served")
+	}
+	if data.Condition != "call-1" {
+		t.Errorf("Expected the cached value, got %s", data.Condition)
+	}
+
+	// Background refresh should have replaced the entry
+	srv.Close()
+	if upstream.calls.Load() != 2 {
+		t.Errorf("Expected 2 upstream calls, got %d", upstream.calls.Load())
+	}
+
+	data, err = srv.GetWeather(context.Background(), 40.7, -74.0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if data.Stale || data.Condition != "call-2" {
+		t.Errorf("Expected refreshed data, got %+v", data)
+	}
+}
+
+func TestCachedWeatherService_UpstreamError(t *testing.T) {
+	upstream := &countingService{shouldError: true}
+	srv := NewCached(upstream, cache.NewMemory(0), 60, 60, 0, 5, slog.Default())
+
+	if _, err := srv.GetWeather(context.Background(), 40.7, -74.0); err == nil {
+		t.Error("Expected error on cache miss with failing upstream")
+	}
+}
+
+func TestCachedWeatherService_Warm(t *testing.T) {
+	upstream := &countingService{}
+	srv := NewCached(upstream, cache.NewMemory(0), 60, 60, 0, 5, slog.Default())
+
+	locations := []Location{{Lat: 40.7, Lon: -74.0}, {Lat: 51.5, Lon: -0.12}, {Lat: 35.7, Lon: 139.7}}
+	if warmed := srv.Warm(context.Background(), locations, 2); warmed != 3 {
+		t.Errorf("Expected 3 warmed locations, got %d", warmed)
+	}
+
+	// Already fresh entries are not fetched again
+	srv.Warm(context.Background(), locations, 2)
+	srv.GetWeather(context.Background(), 51.5, -0.12)
+	if upstream.calls.Load() != 3 {
+		t.Errorf("Expected 3 upstream calls, got %d", upstream.calls.Load())
+	}
+}
+
+func TestCachedWeatherService_LastKnownGoodFallback(t *testing.T) {
+	upstream := &countingService{}
+	srv := NewCached(upstream, cache.NewMemory(0), 60, 60, 3600, 5, slog.Default())
+
+	now := time.Now()
+	srv.now = func() time.Time { return now }
+
+	if _, err := srv.GetWeather(context.Background(), 40.7, -74.0); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Past the stale window: upstream is tried first and its failure falls back to the old entry
+	now = now.Add(10 * time.Minute)
+	upstream.shouldError = true
+
+	data, err := srv.GetWeather(context.Background(), 40.7, -74.0)
+	if err != nil {
+		t.Fatalf("Expected last-known-good data, got error %v", err)
+	}
+	if !data.Degraded || data.DataAgeSeconds != 600 {
+		t.Errorf("Expected degraded data aged 600s, got %+v", data)
+	}
+	if upstream.calls.Load() != 2 {
+		t.Errorf("Expected upstream to be tried, got %d calls", upstream.calls.Load())
+	}
+}
+
+func TestCachedWeatherService_OldEntryRefreshedSynchronously(t *testing.T) {
+	upstream := &countingService{}
+	srv := NewCached(upstream, cache.NewMemory(0), 60, 60, 3600, 5, slog.Default())
+
+	now := time.Now()
+	srv.now = func() time.Time { return now }
+
+	srv.GetWeather(context.Background(), 40.7, -74.0)
+	now = now.Add(10 * time.Minute)
+
+	data, err := srv.GetWeather(context.Background(), 40.7, -74.0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if data.Stale || data.Degraded || data.Condition != "call-2" {
+		t.Errorf("Expected fresh upstream data, got %+v", data)
+	}
+}
+
+func TestCachedWeatherService_CacheIndicators(t *testing.T) {
+	upstream := &countingService{}
+	srv := NewCached(upstream, cache.NewMemory(0), 60, 60, 0, 5, slog.Default())
+
+	now := time.Now()
+	srv.now = func() time.Time { return now }
+
+	data, _ := srv.GetWeather(context.Background(), 40.7, -74.0)
+	if data.Cached || data.AgeSeconds != 0 || !data.FetchedAt.Equal(now) {
+		t.Errorf("Expected fresh upstream data, got %+v", data)
+	}
+
+	fetchedAt := now
+	now = now.Add(25 * time.Second)
+
+	data, _ = srv.GetWeather(context.Background(), 40.7, -74.0)
+	if !data.Cached || data.AgeSeconds != 25 || !data.FetchedAt.Equal(fetchedAt) {
+		t.Errorf("Expected cached data aged 25s, got %+v", data)
+	}
+}
+
+func TestCachedWeatherService_ForceRefresh(t *testing.T) {
+	upstream := &countingService{}
+	srv := NewCached(upstream, cache.NewMemory(0), 60, 60, 0, 5, slog.Default())
+
+	srv.GetWeather(context.Background(), 40.7, -74.0)
+
+	data, err := srv.GetWeather(WithForceRefresh(context.Background()), 40.7, -74.0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if data.Cached || data.Condition != "call-2" {
+		t.Errorf("Expected fresh upstream data, got %+v", data)
+	}
+
+	// The refreshed value replaced the cache entry
+	data, _ = srv.GetWeather(context.Background(), 40.7, -74.0)
+	if data.Condition != "call-2" {
+		t.Errorf("Expected refreshed value from cache, got %s", data.Condition)
+	}
+}
+
+func TestCachedWeatherService_Metrics(t *testing.T) {
+	upstream := &countingService{}
+	srv := NewCached(upstream, cache.NewMemory(0), 60, 600, 0, 5, slog.Default())
+	registry := metrics.NewRegistry()
+	srv.UseMetrics(registry, "memory")
+
+	now := time.Now()
+	srv.now = func() time.Time { return now }
+
+	srv.GetWeather(context.Background(), 40.7, -74.0) // miss
+	srv.GetWeather(context.Background(), 40.7, -74.0) // hit
+	srv.GetWeather(context.Background(), 10, 10)      // miss
+
+	// Hold the background refresh on the lock so the second stale hit finds it in flight
+	srv.mu.Lock()
+	now = now.Add(90 * time.Second)
+	srv.refreshing[CacheKey(40.7, -74.0)] = true
+	srv.mu.Unlock()
+	srv.GetWeather(context.Background(), 40.7, -74.0) // stale, refresh already running
+	srv.Close()
+
+	var body strings.Builder
+	registry.WritePrometheus(&body)
+	for _, line := range []string{
+		`cache_lookups_total{backend="memory",result="hit"} 1`,
+		`cache_lookups_total{backend="memory",result="miss"} 2`,
+		`cache_lookups_total{backend="memory",result="stale"} 1`,
+		`cache_upstream_calls_avoided_total{backend="memory"} 2`,
+		`cache_entries{backend="memory"} 2`,
+		`cache_evictions_total{backend="memory"} 0`,
+	} {
+		if !strings.Contains(body.String(), line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, body.String())
+		}
+	}
+}
+
+func TestCachedWeatherService_ConcurrentMissesShareFetch(t *testing.T) {
+	upstream := &blockingService{started: make(chan struct{}, 5), release: make(chan struct{})}
+	srv := NewCached(upstream, cache.NewMemory(0), 60, 60, 0, 5, slog.Default())
+
+	var wg sync.WaitGroup
+	results := make([]*WeatherData, 5)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := srv.GetWeather(context.Background(), 40.7, -74.0)
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			results[i] = data
+		}()
+	}
+	// Let every lookup miss before the first fetch completes
+	time.Sleep(50 * time.Millisecond)
+	close(upstream.release)
+	wg.Wait()
+
+	if calls := len(upstream.started); calls != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", calls)
+	}
+	for _, data := range results {
+		if data == nil || data.Condition != "Clear" {
+			t.Errorf("Expected every lookup to get the fetched data, got %+v", data)
+		}
+	}
+}
+
+func TestCachedWeatherService_MissWaitsForLockHolder(t *testing.T) {
+	upstream := &countingService{}
+	shared := cache.NewMemory(0)
+	coordinator := coord.NewLocal()
+	srv := NewCached(upstream, shared, 60, 60, 0, 5, slog.Default())
+	srv.UseCoordinator(coordinator)
+
+	// Another instance is fetching the entry
+	other := NewCached(&countingService{}, shared, 60, 60, 0, 5, slog.Default())
+	key := CacheKey(40.7, -74.0)
+	release, acquired, _ := coordinator.TryLock(context.Background(), "fetch:"+key, time.Minute)
+	if !acquired {
+		t.Fatal("Expected to acquire the fetch lock")
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		other.fetchAndStore(context.Background(), key, 40.7, -74.0)
+		release()
+	}()
+
+	data, err := srv.GetWeather(context.Background(), 40.7, -74.0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !data.Cached {
+		t.Error("Expected the entry stored by the lock holder")
+	}
+	if upstream.calls.Load() != 0 {
+		t.Errorf("Expected 0 upstream calls, got %d", upstream.calls.Load())
+	}
+}
+
+func TestCachedWeatherService_SharedFetchOutlivesFirstCaller(t *testing.T) {
+	upstream := &blockingService{started: make(chan struct{}, 1), release: make(chan struct{})}
+	srv := NewCached(upstream, cache.NewMemory(0), 60, 60, 0, 5, slog.Default())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := srv.GetWeather(ctx, 40.7, -74.0)
+		first <- err
+	}()
+	<-upstream.started
+
+	second := make(chan *WeatherData, 1)
+	go func() {
+		data, err := srv.GetWeather(context.Background(), 40.7, -74.0)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		second <- data
+	}()
+	// Let the second lookup join the fetch before the first caller leaves
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the first caller to be canceled, got %v", err)
+	}
+
+	close(upstream.release)
+	if data := <-second; data == nil || data.Condition != "Clear" {
+		t.Errorf("Expected the second caller to get the fetched data, got %+v", data)
+	}
+	srv.Close()
+	if len(upstream.started) != 0 {
+		t.Errorf("Expected 1 upstream call, got %d", 1+len(upstream.started))
+	}
+}
+
+// contextRecordingService records the context of the last lookup
+type contextRecordingService struct {
+	ctx context.Context
+}
+
+func (c *contextRecordingService) GetWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	c.ctx = ctx
+	return &WeatherData{Condition: "Clear"}, nil
+}
+
+type callerValueKey struct{}
+
+func TestCachedWeatherService_SharedFetchKeepsRequestIDTraceAndDeadline(t *testing.T) {
+	upstream := &contextRecordingService{}
+	srv := NewCached(upstream, cache.NewMemory(0), 60, 60, 0, 5, slog.Default())
+
+	ctx := context.WithValue(requestid.WithID(context.Background(), "req-1"), callerValueKey{}, "caller")
+	ctx, span := tracing.Start(tracing.WithTracer(ctx, tracing.NewTracer(nil, 0)), "request", tracing.KindServer)
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	if _, err := srv.GetWeather(ctx, 40.7, -74.0); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if id := requestid.FromContext(upstream.ctx); id != "req-1" {
+		t.Errorf("Expected the request ID to be kept, got %q", id)
+	}
+	if got := tracing.SpanFromContext(upstream.ctx).Context().TraceID; got != span.Context().TraceID {
+		t.Errorf("Expected the fetch in the caller's trace %x, got %x", span.Context().TraceID, got)
+	}
+	if got, ok := upstream.ctx.Deadline(); !ok || !got.Equal(deadline) {
+		t.Errorf("Expected the caller's deadline %v, got %v", deadline, got)
+	}
+	if v := upstream.ctx.Value(callerValueKey{}); v != nil {
+		t.Errorf("Expected the caller's other values to be dropped, got %v", v)
+	}
+}
+
+func TestCachedWeatherService_DebugDumpSkipsSharedFetch(t *testing.T) {
+	upstream := &contextRecordingService{}
+	srv := NewCached(upstream, cache.NewMemory(0), 60, 60, 0, 5, slog.Default())
+
+	if _, err := srv.GetWeather(WithDebugDump(context.Background()), 40.7, -74.0); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !isDebugDump(upstream.ctx) {
+		t.Error("Expected the upstream call to be dumped")
+	}
+}
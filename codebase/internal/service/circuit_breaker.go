@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling upstream while the circuit breaker is open
+var ErrCircuitOpen = errors.New("upstream circuit breaker is open")
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// BreakerStats is a snapshot of the circuit breaker for operators
+type BreakerStats struct {
+	State               string    `json:"state"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	Trips               uint64    `json:"trips"`               // how often the breaker opened since startup
+	OpenedAt            time.Time `json:"opened_at,omitempty"` // when the breaker last opened
+	RetryAt             time.Time `json:"retry_at,omitempty"`  // when an open breaker lets a probe through
+}
+
+// CircuitBreakerService stops calling the wrapped service after repeated failures
+// Once failureThreshold consecutive calls fail the breaker opens and calls fail fast with
+// ErrCircuitOpen (so the cache can serve last-known-good data) instead of waiting out the full
+// timeout. After openDuration one probe call is let through (half-open): success closes the breaker,
+// failure opens it again. Client errors (4xx) and callers giving up don't count as failures.
+type CircuitBreakerService struct {
+	upstream WeatherService
+	settings *RuntimeSettings // failure threshold and open duration
+	now      func() time.Time
+	logger   *slog.Logger
+
+	mu                  sync.Mutex
+	state               string
+	consecutiveFailures int
+	trips               uint64
+	openedAt            time.Time
+	probing             bool // a half-open probe is in flight
+}
+
+// NewCircuitBreaker creates a CircuitBreakerService opening after failureThreshold consecutive
+// failures and staying open for openSec seconds before probing upstream again
+func NewCircuitBreaker(upstream WeatherService, failureThreshold, openSec int, logger *slog.Logger) *CircuitBreakerService {
+	return &CircuitBreakerService{
+		upstream: upstream,
+		settings: NewRuntimeSettings(RuntimeConfig{BreakerFailureThreshold: failureThreshold, BreakerOpen: time.Duration(openSec) * time.Second}),
+		now:      time.Now,
+		state:    BreakerClosed,
+		logger:   logger,
+	}
+}
+
+// UseRuntimeSettings makes the breaker follow the failure threshold and open duration in settings instead
+// of the ones given to NewCircuitBreaker; an open breaker keeps its opening time, so a shorter open duration
+// can make it probe right away
+func (srv *CircuitBreakerService) UseRuntimeSettings(settings *RuntimeSettings) {
+	srv.settings = settings
+}
+
+// GetWeather calls the wrapped service unless the breaker is open
+func (srv *CircuitBreakerService) GetWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	if !srv.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	data, err := srv.upstream.GetWeather(ctx, lat, lon)
+	srv.record(err == nil, err != nil && isBreakerFailure(ctx, err))
+	return data, err
+}
+
+// Forecasts returns a ForecastService calling upstream through the breaker: forecast calls are refused while
+// it is open and their failures count towards opening it
+func (srv *CircuitBreakerService) Forecasts(upstream ForecastService) ForecastService {
+	return forecastFunc(func(ctx context.Context, lat, lon float64) (*Forecast, error) {
+		if !srv.allow() {
+			return nil, ErrCircuitOpen
+		}
+
+		forecast, err := upstream.GetForecast(ctx, lat, lon)
+		srv.record(err == nil, err != nil && isBreakerFailure(ctx, err))
+		return forecast, err
+	})
+}
+
+// Stats returns the current breaker state
+func (srv *CircuitBreakerService) Stats() BreakerStats {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	stats := BreakerStats{
+		State:               srv.state,
+		ConsecutiveFailures: srv.consecutiveFailures,
+		Trips:               srv.trips,
+		OpenedAt:            srv.openedAt,
+	}
+	if srv.state == BreakerOpen {
+		stats.RetryAt = srv.openedAt.Add(srv.settings.Load().BreakerOpen)
+	}
+	return stats
+}
+
+// allow reports whether a call may go through, moving an expired open breaker to half-open
+func (srv *CircuitBreakerService) allow() bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	switch srv.state {
+	case BreakerOpen:
+		if srv.now().Before(srv.openedAt.Add(srv.settings.Load().BreakerOpen)) {
+			return false
+		}
+		srv.setState(BreakerHalfOpen)
+		srv.probing = true
+		return true
+	case BreakerHalfOpen:
+		if srv.probing {
+			return false // only one probe at a time
+		}
+		srv.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record updates the breaker with the outcome of a call
+// Calls that neither succeeded nor failed on upstream's account leave the counters untouched
+func (srv *CircuitBreakerService) record(success, failure bool) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if srv.state == BreakerHalfOpen {
+		srv.probing = false
+	}
+
+	switch {
+	case success:
+		srv.consecutiveFailures = 0
+		if srv.state != BreakerClosed {
+			srv.setState(BreakerClosed)
+		}
+	case failure:
+		srv.consecutiveFailures++
+		if srv.state == BreakerHalfOpen || srv.consecutiveFailures >= srv.settings.Load().BreakerFailureThreshold {
+			srv.trips++
+			srv.openedAt = srv.now()
+			srv.setState(BreakerOpen)
+		}
+	}
+}
+
+// setState switches state and logs the transition; callers hold mu
+func (srv *CircuitBreakerService) setState(state string) {
+	srv.logger.Warn("upstream circuit breaker state changed", slog.String("from", srv.state), slog.String("to", state), slog.Int("consecutive_failures", srv.consecutiveFailures))
+	srv.state = state
+}
+
+// isBreakerFailure reports whether err says something about upstream health
+// Only failures worth retrying (connection errors, timeouts, 5xx) count, unless the caller canceled
+func isBreakerFailure(ctx context.Context, err error) bool {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return false
+	}
+	return errors.Is(err, ErrUnavailable) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// switchableService fails with err while it is set
+type switchableService struct {
+	err   error
+	calls int
+}
+
+func (s *switchableService) GetWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &WeatherData{Condition: "Clear"}, nil
+}
+
+func (s *switchableService) GetForecast(ctx context.Context, lat, lon float64) (*Forecast, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &Forecast{Days: []ForecastDay{{Condition: "Clear"}}}, nil
+}
+
+func TestCircuitBreaker_OpensAndRecovers(t *testing.T) {
+	upstream := &switchableService{err: &ProviderError{Provider: "test", Message: "connection refused"}}
+	breaker := NewCircuitBreaker(upstream, 3, 30, slog.Default())
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		breaker.GetWeather(ctx, 1, 2)
+	}
+	if breaker.Stats().State != BreakerOpen {
+		t.Fatalf("Expected breaker to open, got %s", breaker.Stats().State)
+	}
+
+	// Open breakers fail fast without calling upstream
+	if _, err := breaker.GetWeather(ctx, 1, 2); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if upstream.calls != 3 {
+		t.Errorf("Expected 3 upstream calls, got %d", upstream.calls)
+	}
+
+	// A failed probe opens it again
+	now = now.Add(31 * time.Second)
+	breaker.GetWeather(ctx, 1, 2)
+	if stats := breaker.Stats(); stats.State != BreakerOpen || stats.Trips != 2 {
+		t.Errorf("Expected breaker to reopen after failed probe, got %+v", stats)
+	}
+
+	// A successful probe closes it
+	now = now.Add(31 * time.Second)
+	upstream.err = nil
+	if _, err := breaker.GetWeather(ctx, 1, 2); err != nil {
+		t.Fatalf("Expected probe to succeed, got %v", err)
+	}
+	if breaker.Stats().State != BreakerClosed {
+		t.Errorf("Expected breaker to close, got %s", breaker.Stats().State)
+	}
+}
+
+func TestCircuitBreaker_IgnoresClientErrors(t *testing.T) {
+	upstream := &switchableService{err: &ProviderError{Provider: "test", Code: 400, Message: "wrong latitude"}}
+	breaker := NewCircuitBreaker(upstream, 2, 30, slog.Default())
+
+	for i := 0; i < 5; i++ {
+		breaker.GetWeather(context.Background(), 1, 2)
+	}
+	if breaker.Stats().State != BreakerClosed {
+		t.Errorf("Expected client errors to leave the breaker closed, got %s", breaker.Stats().State)
+	}
+}
+
+func TestCircuitBreaker_SharedWithForecasts(t *testing.T) {
+	upstream := &switchableService{err: &ProviderError{Provider: "test", Message: "connection refused"}}
+	breaker := NewCircuitBreaker(upstream, 3, 30, slog.Default())
+	forecasts := breaker.Forecasts(upstream)
+	ctx := context.Background()
+
+	// Forecast failures open the breaker for lookups, and the other way around
+	for i := 0; i < 2; i++ {
+		forecasts.GetForecast(ctx, 1, 2)
+	}
+	breaker.GetWeather(ctx, 1, 2)
+	if breaker.Stats().State != BreakerOpen {
+		t.Fatalf("Expected breaker to open, got %s", breaker.Stats().State)
+	}
+	if _, err := forecasts.GetForecast(ctx, 1, 2); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if upstream.calls != 3 {
+		t.Errorf("Expected 3 upstream calls, got %d", upstream.calls)
+	}
+}
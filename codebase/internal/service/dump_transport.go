@@ -0,0 +1,103 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxDumpedBody caps how much of a response body a dump logs
+const maxDumpedBody = 64 << 10
+
+// debugDumpKey is the context key marking a request whose upstream exchanges must be dumped
+type debugDumpKey struct{}
+
+// WithDebugDump returns a context whose upstream requests and responses are logged in full by the
+// transport from NewDumpTransport, regardless of its sample rate
+func WithDebugDump(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugDumpKey{}, true)
+}
+
+func isDebugDump(ctx context.Context) bool {
+	dump, _ := ctx.Value(debugDumpKey{}).(bool)
+	return dump
+}
+
+// dumpTransport logs upstream exchanges for troubleshooting mapping and encoding issues
+type dumpTransport struct {
+	next      http.RoundTripper
+	samplePct int
+	logger    *slog.Logger
+}
+
+// NewDumpTransport wraps next so samplePct percent of upstream requests (and any made under
+// WithDebugDump) are logged with their URL, the API key redacted, and the response body
+// A nil next uses http.DefaultTransport
+func NewDumpTransport(next http.RoundTripper, samplePct int, logger *slog.Logger) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &dumpTransport{next: next, samplePct: samplePct, logger: logger}
+}
+
+func (t *dumpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isDebugDump(req.Context()) && (t.samplePct <= 0 || rand.IntN(100) >= t.samplePct) {
+		return t.next.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", redactURL(req.URL)),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+		t.logger.LogAttrs(req.Context(), slog.LevelInfo, "upstream exchange", attrs...)
+		return resp, err
+	}
+
+	// Read the body so it can be logged, then hand the caller an identical copy
+	body, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if readErr != nil {
+		resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{readErr}))
+		attrs = append(attrs, slog.String("body_error", readErr.Error()))
+	}
+
+	logged := body
+	if len(logged) > maxDumpedBody {
+		logged = logged[:maxDumpedBody]
+		attrs = append(attrs, slog.Bool("body_truncated", true))
+	}
+	attrs = append(attrs, slog.Int("status", resp.StatusCode), slog.String("body", string(logged)))
+	t.logger.LogAttrs(req.Context(), slog.LevelInfo, "upstream exchange", attrs...)
+	return resp, nil
+}
+
+// errReader replays a read error after the part of the body that could be read
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+// redactURL renders u with credentials in the query replaced
+func redactURL(u *url.URL) string {
+	redacted := *u
+	query := redacted.Query()
+	for name := range query {
+		switch strings.ToLower(name) {
+		case "appid", "api_key", "apikey", "key", "token":
+			query.Set(name, "REDACTED")
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
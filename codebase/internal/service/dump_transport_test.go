@@ -0,0 +1,51 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDumpTransport_LogsMarkedRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name":"London"}`))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client := &http.Client{Transport: NewDumpTransport(nil, 0, slog.New(slog.NewTextHandler(&buf, nil)))}
+
+	// Not sampled and not marked
+	resp, err := client.Get(server.URL + "/data/2.5/weather?lat=1&lon=2&appid=secret")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if buf.Len() != 0 {
+		t.Fatalf("Expected nothing logged, got %q", buf.String())
+	}
+
+	req, _ := http.NewRequestWithContext(WithDebugDump(context.Background()), "GET", server.URL+"/data/2.5/weather?lat=1&lon=2&appid=secret", nil)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(body) != `{"name":"London"}` {
+		t.Errorf("Expected the caller to still get the body, got %q", body)
+	}
+	line := buf.String()
+	if strings.Contains(line, "secret") || !strings.Contains(line, "appid=REDACTED") {
+		t.Errorf("Expected the API key to be redacted, got %q", line)
+	}
+	if !strings.Contains(line, `London`) || !strings.Contains(line, "status=200") {
+		t.Errorf("Expected the status and body to be logged, got %q", line)
+	}
+}
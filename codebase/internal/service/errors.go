@@ -0,0 +1,55 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Error kinds a ProviderError can be matched against with errors.Is
+var (
+	ErrRateLimited  = errors.New("provider rate limit exceeded")
+	ErrUnauthorized = errors.New("provider rejected credentials")
+	ErrNotFound     = errors.New("location not found by provider")
+	ErrUnavailable  = errors.New("provider unavailable") // connection errors, timeouts and 5xx - worth retrying
+
+	// ErrMalformedResponse is the Err of a ProviderError for a response we couldn't make sense of
+	ErrMalformedResponse = errors.New("malformed provider response")
+)
+
+// ProviderError is a failed call to a weather provider
+// Code is the provider's HTTP status, or 0 if no response arrived (connection error, timeout)
+// Use errors.Is with ErrRateLimited, ErrUnauthorized, ErrNotFound or ErrUnavailable to branch on the
+// kind of failure, and errors.As to get at the details
+type ProviderError struct {
+	Provider   string
+	Code       int
+	Message    string
+	RetryAfter time.Duration // how long the provider asked us to back off (429 only, zero if unknown)
+	Err        error         // underlying transport error when Code is 0, or ErrMalformedResponse
+}
+
+func (e *ProviderError) Error() string {
+	if e.Code == 0 {
+		return fmt.Sprintf("%s: %s: %v", e.Provider, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s API error (code %d): %s", e.Provider, e.Code, e.Message)
+}
+
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// Is matches the error kind sentinels based on Code
+func (e *ProviderError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return e.Code == http.StatusTooManyRequests
+	case ErrUnauthorized:
+		return e.Code == http.StatusUnauthorized || e.Code == http.StatusForbidden
+	case ErrNotFound:
+		return e.Code == http.StatusNotFound
+	case ErrUnavailable:
+		return e.Code == 0 || e.Code >= 500
+	}
+	return false
+}
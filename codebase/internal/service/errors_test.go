@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestProviderError_Kinds(t *testing.T) {
+	tests := []struct {
+		err  *ProviderError
+		kind error
+	}{
+		{&ProviderError{Code: 429}, ErrRateLimited},
+		{&ProviderError{Code: 401}, ErrUnauthorized},
+		{&ProviderError{Code: 403}, ErrUnauthorized},
+		{&ProviderError{Code: 404}, ErrNotFound},
+		{&ProviderError{Code: 502}, ErrUnavailable},
+		{&ProviderError{Code: 0, Err: context.DeadlineExceeded}, ErrUnavailable},
+	}
+	for _, tt := range tests {
+		if !errors.Is(fmt.Errorf("wrapped: %w", tt.err), tt.kind) {
+			t.Errorf("Expected code %d to match %v", tt.err.Code, tt.kind)
+		}
+	}
+	if errors.Is(&ProviderError{Code: 400}, ErrUnavailable) {
+		t.Error("Expected 400 not to be treated as unavailable")
+	}
+}
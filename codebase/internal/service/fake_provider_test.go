@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStep is one scripted provider answer: wait delay, then fail with status, or answer normally when status is 0
+type fakeStep struct {
+	delay  time.Duration
+	status int
+}
+
+// failTimes scripts n failures with status followed by normal answers
+func failTimes(n, status int) []fakeStep {
+	steps := make([]fakeStep, n)
+	for i := range steps {
+		steps[i] = fakeStep{status: status}
+	}
+	return steps
+}
+
+// percentiles returns a deterministic latency distribution: every 100th call takes p99, the rest take p50
+func percentiles(p50, p99 time.Duration) func(call int) time.Duration {
+	return func(call int) time.Duration {
+		if call%100 == 99 {
+			return p99
+		}
+		return p50
+	}
+}
+
+// fakeProvider is a scripted stand-in for OpenWeatherMap
+// It plays its steps in order and then answers normally with latency (if set), either as an
+// http.Handler behind httptest (to exercise the real client and its retries) or as a WeatherService
+type fakeProvider struct {
+	mu      sync.Mutex
+	steps   []fakeStep
+	latency func(call int) time.Duration
+	calls   int
+}
+
+func newFakeProvider(steps ...fakeStep) *fakeProvider {
+	return &fakeProvider{steps: steps}
+}
+
+// next returns the step for the next call
+func (p *fakeProvider) next() fakeStep {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	call := p.calls
+	p.calls++
+	if call < len(p.steps) {
+		return p.steps[call]
+	}
+	if p.latency != nil {
+		return fakeStep{delay: p.latency(call)}
+	}
+	return fakeStep{}
+}
+
+// Calls returns how many calls the provider has received
+func (p *fakeProvider) Calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+func (p *fakeProvider) GetWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	step := p.next()
+	if err := sleepContext(ctx, step.delay); err != nil {
+		return nil, err
+	}
+	if step.status != 0 {
+		return nil, &ProviderError{Provider: "fake", Code: step.status, Message: http.StatusText(step.status)}
+	}
+	return &WeatherData{City: "Testville", Condition: "Clear"}, nil
+}
+
+func (p *fakeProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	step := p.next()
+	if sleepContext(r.Context(), step.delay) != nil {
+		return
+	}
+	if step.status != 0 {
+		w.WriteHeader(step.status)
+		io.WriteString(w, "<html>scripted failure</html>")
+		return
+	}
+	io.WriteString(w, testWeatherBody)
+}
+
+func TestFakeProvider_FailTwiceThenSucceedIsRetried(t *testing.T) {
+	provider := newFakeProvider(failTimes(2, http.StatusServiceUnavailable)...)
+	upstream := httptest.NewServer(provider)
+	defer upstream.Close()
+
+	var delays []time.Duration
+	srv := newRetryingService(upstream.URL, RetryPolicy{MaxAttempts: 3, BaseDelayMs: 10}, &delays)
+
+	data, err := srv.GetWeather(context.Background(), 1, 2)
+	if err != nil || data.City != "Testville" {
+		t.Fatalf("Expected success after retries, got %+v %v", data, err)
+	}
+	if provider.Calls() != 3 {
+		t.Errorf("Expected 3 calls, got %d", provider.Calls())
+	}
+}
+
+func TestFakeProvider_SlowAttemptTimesOutAndIsRetried(t *testing.T) {
+	provider := newFakeProvider(fakeStep{delay: time.Second})
+	upstream := httptest.NewServer(provider)
+	defer upstream.Close()
+
+	var delays []time.Duration
+	srv := newRetryingService(upstream.URL, RetryPolicy{MaxAttempts: 2, BaseDelayMs: 10}, &delays)
+	srv.settings.Update(func(config *RuntimeConfig) { config.Primary.Timeout = 50 * time.Millisecond })
+
+	if _, err := srv.GetWeather(context.Background(), 1, 2); err != nil {
+		t.Fatalf("Expected the second attempt to succeed, got %v", err)
+	}
+	if provider.Calls() != 2 {
+		t.Errorf("Expected 2 calls, got %d", provider.Calls())
+	}
+}
+
+func TestFakeProvider_BreakerTripsOnFailureSequence(t *testing.T) {
+	provider := newFakeProvider(failTimes(3, http.StatusBadGateway)...)
+	breaker := NewCircuitBreaker(provider, 3, 30, slog.Default())
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		breaker.GetWeather(context.Background(), 1, 2)
+	}
+	if _, err := breaker.GetWeather(context.Background(), 1, 2); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen after 3 scripted failures, got %v", err)
+	}
+
+	// The script has run out, so the probe succeeds
+	now = now.Add(31 * time.Second)
+	if _, err := breaker.GetWeather(context.Background(), 1, 2); err != nil {
+		t.Errorf("Expected probe to succeed, got %v", err)
+	}
+	if provider.Calls() != 4 {
+		t.Errorf("Expected 4 provider calls, got %d", provider.Calls())
+	}
+}
+
+func TestFakeProvider_HedgingCutsTailLatency(t *testing.T) {
+	primary := newFakeProvider()
+	primary.latency = percentiles(0, 3*time.Second)
+	primary.calls = 99 // the next call is the p99 one
+	secondary := newFakeProvider()
+
+	srv := NewHedged(primary, secondary, 20)
+	start := time.Now()
+	if _, err := srv.GetWeather(context.Background(), 1, 2); err != nil {
+		t.Fatalf("Expected hedged answer, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the hedge to answer well before the p99 latency, took %v", elapsed)
+	}
+	if secondary.Calls() != 1 {
+		t.Errorf("Expected 1 hedged call, got %d", secondary.Calls())
+	}
+}
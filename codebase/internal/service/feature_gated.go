@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+)
+
+// FeatureGatedWeatherService sends calls through a layer (e.g. the cache or hedging) only while its feature
+// flag is on, and straight to the service underneath otherwise, so the layer can be switched at runtime
+type FeatureGatedWeatherService struct {
+	on      WeatherService
+	off     WeatherService
+	enabled func() bool
+}
+
+// NewFeatureGated creates a FeatureGatedWeatherService calling on while enabled returns true and off otherwise
+func NewFeatureGated(on, off WeatherService, enabled func() bool) *FeatureGatedWeatherService {
+	return &FeatureGatedWeatherService{on: on, off: off, enabled: enabled}
+}
+
+// NewFeatureGatedForecasts returns a ForecastService calling on while enabled returns true and off otherwise
+func NewFeatureGatedForecasts(on, off ForecastService, enabled func() bool) ForecastService {
+	return forecastFunc(func(ctx context.Context, lat, lon float64) (*Forecast, error) {
+		if enabled() {
+			return on.GetForecast(ctx, lat, lon)
+		}
+		return off.GetForecast(ctx, lat, lon)
+	})
+}
+
+// GetWeather calls the service the flag currently selects
+func (srv *FeatureGatedWeatherService) GetWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	if srv.enabled() {
+		return srv.on.GetWeather(ctx, lat, lon)
+	}
+	return srv.off.GetWeather(ctx, lat, lon)
+}
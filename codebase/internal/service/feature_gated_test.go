@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFeatureGated_FollowsTheFlag(t *testing.T) {
+	on, off := &switchableService{}, &switchableService{}
+	enabled := true
+	srv := NewFeatureGated(on, off, func() bool { return enabled })
+
+	srv.GetWeather(context.Background(), 1, 2)
+	enabled = false
+	srv.GetWeather(context.Background(), 1, 2)
+	srv.GetWeather(context.Background(), 1, 2)
+
+	if on.calls != 1 || off.calls != 2 {
+		t.Errorf("Expected 1 call with the flag on and 2 with it off, got %d and %d", on.calls, off.calls)
+	}
+}
+
+func TestFeatureGatedForecasts_FollowsTheFlag(t *testing.T) {
+	on, off := &switchableService{}, &switchableService{}
+	enabled := true
+	srv := NewFeatureGatedForecasts(on, off, func() bool { return enabled })
+
+	srv.GetForecast(context.Background(), 1, 2)
+	enabled = false
+	srv.GetForecast(context.Background(), 1, 2)
+
+	if on.calls != 1 || off.calls != 1 {
+		t.Errorf("Expected 1 call with the flag on and 1 with it off, got %d and %d", on.calls, off.calls)
+	}
+}
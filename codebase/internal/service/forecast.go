@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/cache"
+	"log/slog"
+	"time"
+)
+
+// Forecast is the daily forecast for a location
+type Forecast struct {
+	City      string        `json:"city"`
+	Country   string        `json:"country"`
+	Days      []ForecastDay `json:"days"`
+	FetchedAt time.Time     `json:"fetched_at"`
+}
+
+// ForecastDay summarizes the forecast for one day
+type ForecastDay struct {
+	Date                time.Time `json:"date"`                 // midnight at the start of the day, UTC
+	Condition           string    `json:"condition"`            // the most frequent condition of the day
+	TemperatureCategory string    `json:"temperature_category"` // category of the day's highest temperature
+}
+
+// ForecastService defines the interface for daily forecast retrieval
+type ForecastService interface {
+	GetForecast(ctx context.Context, lat, lon float64) (*Forecast, error)
+}
+
+// forecastFunc adapts a function to ForecastService, for layers that apply the policy of a WeatherService
+// wrapper to forecasts
+type forecastFunc func(ctx context.Context, lat, lon float64) (*Forecast, error)
+
+func (f forecastFunc) GetForecast(ctx context.Context, lat, lon float64) (*Forecast, error) {
+	return f(ctx, lat, lon)
+}
+
+// forecastResponse represents the response of OpenWeatherMap's 5 day / 3 hour forecast
+type forecastResponse struct {
+	List []struct {
+		UnixSeconds int64 `json:"dt"`
+		Main        struct {
+			Temp *float64 `json:"temp"`
+		} `json:"main"`
+		Weather []struct {
+			Main string `json:"main"`
+		} `json:"weather"`
+	} `json:"list"`
+	City struct {
+		Name           string `json:"name"`
+		Country        string `json:"country"`
+		TimezoneOffset int    `json:"timezone"` // seconds east of UTC
+	} `json:"city"`
+	HttpCode responseCode    `json:"cod"`
+	Message  responseMessage `json:"message"`
+}
+
+// responseMessage is the "message" field, which the forecast endpoint sends as a number on success
+type responseMessage string
+
+func (message *responseMessage) UnmarshalJSON(data []byte) error {
+	var text string
+	if json.Unmarshal(data, &text) == nil {
+		*message = responseMessage(text)
+	}
+	return nil
+}
+
+func (response *forecastResponse) status() (responseCode, string) {
+	return response.HttpCode, string(response.Message)
+}
+
+// GetForecast fetches the daily forecast for the given coordinates
+func (srv *OpenWeatherMapService) GetForecast(ctx context.Context, lat, lon float64) (*Forecast, error) {
+	var response forecastResponse
+	if err := srv.call(ctx, "/forecast", lat, lon, &response); err != nil {
+		return nil, err
+	}
+	return response.toForecast()
+}
+
+// toForecast groups the 3-hourly entries of a successful response into days, in the location's time zone
+func (response *forecastResponse) toForecast() (*Forecast, error) {
+	if len(response.List) == 0 {
+		return nil, &ProviderError{Provider: openWeatherMapProvider, Code: int(response.HttpCode), Message: "malformed response: empty forecast list", Err: ErrMalformedResponse}
+	}
+
+	type daySummary struct {
+		conditions map[string]int
+		condition  string
+		high       *float64
+	}
+	zone := time.FixedZone("", response.City.TimezoneOffset)
+	var dates []time.Time
+	days := make(map[time.Time]*daySummary)
+	for _, entry := range response.List {
+		local := time.Unix(entry.UnixSeconds, 0).In(zone)
+		date := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+		day, ok := days[date]
+		if !ok {
+			day = &daySummary{conditions: make(map[string]int)}
+			days[date] = day
+			dates = append(dates, date)
+		}
+
+		if len(entry.Weather) > 0 && entry.Weather[0].Main != "" {
+			condition := entry.Weather[0].Main
+			day.conditions[condition]++
+			// Ties go to the condition seen first
+			if day.condition == "" || day.conditions[condition] > day.conditions[day.condition] {
+				day.condition = condition
+			}
+		}
+		if temp := entry.Main.Temp; temp != nil && (day.high == nil || *temp > *day.high) {
+			day.high = temp
+		}
+	}
+
+	forecast := &Forecast{
+		City:      response.City.Name,
+		Country:   response.City.Country,
+		FetchedAt: time.Now(),
+	}
+	for _, date := range dates {
+		day := days[date]
+		summary := ForecastDay{Date: date, Condition: unknownValue, TemperatureCategory: unknownValue}
+		if day.condition != "" {
+			summary.Condition = day.condition
+		}
+		if day.high != nil {
+			summary.TemperatureCategory = categorizeTemperature((*day.high-273.15)*9/5 + 32)
+		}
+		forecast.Days = append(forecast.Days, summary)
+	}
+	return forecast, nil
+}
+
+// CachedForecastService wraps another ForecastService with a cache
+// Forecasts only change every few hours, so fresh entries are served and anything older is fetched again
+type CachedForecastService struct {
+	upstream ForecastService
+	cache    cache.Cache
+	ttl      time.Duration
+	now      func() time.Time
+	logger   *slog.Logger
+}
+
+// NewCachedForecast creates a new CachedForecastService keeping forecasts for ttlSec seconds
+func NewCachedForecast(upstream ForecastService, c cache.Cache, ttlSec int, logger *slog.Logger) *CachedForecastService {
+	return &CachedForecastService{
+		upstream: upstream,
+		cache:    c,
+		ttl:      time.Duration(ttlSec) * time.Second,
+		now:      time.Now,
+		logger:   logger,
+	}
+}
+
+// GetForecast returns the cached forecast for the coordinates, falling back to the upstream service
+func (srv *CachedForecastService) GetForecast(ctx context.Context, lat, lon float64) (*Forecast, error) {
+	key := ForecastCacheKey(lat, lon)
+	entry, found, err := srv.cache.Get(ctx, key)
+	if err != nil {
+		srv.logger.WarnContext(ctx, "cache lookup failed", slog.String("key", key), slog.String("error", err.Error()))
+	} else if found && entry.IsFresh(srv.now()) {
+		var forecast Forecast
+		if err := json.Unmarshal(entry.Value, &forecast); err == nil {
+			return &forecast, nil
+		}
+		srv.logger.WarnContext(ctx, "discarding undecodable cache entry", slog.String("key", key))
+	}
+
+	forecast, err := srv.upstream.GetForecast(ctx, lat, lon)
+	if err != nil {
+		return nil, err
+	}
+
+	now := srv.now()
+	forecast.FetchedAt = now
+	value, err := json.Marshal(forecast)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+	entry = &cache.Entry{Value: value, StoredAt: now, ExpiresAt: now.Add(srv.ttl)}
+	if err := srv.cache.Set(ctx, key, entry, srv.ttl); err != nil {
+		srv.logger.WarnContext(ctx, "cache store failed", slog.String("key", key), slog.String("error", err.Error()))
+	}
+	return forecast, nil
+}
+
+// ForecastCacheKeyPrefix is the prefix shared by all forecast cache keys
+const ForecastCacheKeyPrefix = "forecast:"
+
+// ForecastCacheKey builds the forecast cache key for a coordinate pair, rounded like CacheKey
+func ForecastCacheKey(lat, lon float64) string {
+	return fmt.Sprintf("%s%.4f,%.4f", ForecastCacheKeyPrefix, lat, lon)
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"github.com/krizvi/weather-app-server/internal/cache"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 2024-01-01 21:00 UTC is 2024-01-02 00:00 at UTC+3, so the second entry starts the second day
+const forecastBody = `{"cod":"200","message":0,"cnt":4,"list":[
+	{"dt":1704132000,"main":{"temp":270},"weather":[{"main":"Snow"}]},
+	{"dt":1704142800,"main":{"temp":275},"weather":[{"main":"Clouds"}]},
+	{"dt":1704153600,"main":{"temp":280},"weather":[{"main":"Clouds"}]},
+	{"dt":1704164400,"main":{"temp":295},"weather":[{"main":"Rain"}]}
+],"city":{"name":"Ankara","country":"TR","timezone":10800}}`
+
+func TestOpenWeatherMapService_GetForecast(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/forecast" {
+			t.Errorf("Expected /forecast, got %s", r.URL.Path)
+		}
+		io.WriteString(w, forecastBody)
+	}))
+	defer upstream.Close()
+
+	forecast, err := New("key", upstream.URL, 5, nil, slog.Default()).GetForecast(context.Background(), 39.9, 32.8)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if forecast.City != "Ankara" || forecast.Country != "TR" {
+		t.Errorf("Expected Ankara, TR, got %s, %s", forecast.City, forecast.Country)
+	}
+	want := []ForecastDay{
+		{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Condition: "Snow", TemperatureCategory: "cold"},
+		{Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Condition: "Clouds", TemperatureCategory: "hot"},
+	}
+	if len(forecast.Days) != len(want) {
+		t.Fatalf("Expected %d days, got %+v", len(want), forecast.Days)
+	}
+	for i, day := range forecast.Days {
+		if !day.Date.Equal(want[i].Date) || day.Condition != want[i].Condition || day.TemperatureCategory != want[i].TemperatureCategory {
+			t.Errorf("Expected day %d to be %+v, got %+v", i, want[i], day)
+		}
+	}
+}
+
+func TestOpenWeatherMapService_GetForecastEmpty(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"cod":"200","message":0,"list":[]}`)
+	}))
+	defer upstream.Close()
+
+	_, err := New("key", upstream.URL, 5, nil, slog.Default()).GetForecast(context.Background(), 1, 2)
+	if err == nil {
+		t.Error("Expected an error for an empty forecast")
+	}
+}
+
+// Fake forecast upstream that counts calls
+type countingForecasts struct {
+	calls atomic.Int32
+}
+
+func (c *countingForecasts) GetForecast(ctx context.Context, lat, lon float64) (*Forecast, error) {
+	c.calls.Add(1)
+	return &Forecast{City: "Ankara", Days: []ForecastDay{{Condition: "Clear", TemperatureCategory: "hot"}}}, nil
+}
+
+func TestCachedForecastService(t *testing.T) {
+	upstream := &countingForecasts{}
+	c := cache.NewMemory(0)
+	srv := NewCachedForecast(upstream, c, 60, slog.Default())
+	now := time.Now()
+	srv.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		forecast, err := srv.GetForecast(context.Background(), 39.9, 32.8)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if forecast.City != "Ankara" || len(forecast.Days) != 1 {
+			t.Errorf("Expected the upstream forecast, got %+v", forecast)
+		}
+	}
+	if upstream.calls.Load() != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", upstream.calls.Load())
+	}
+	if _, found, _ := c.Get(context.Background(), ForecastCacheKey(39.9, 32.8)); !found {
+		t.Error("Expected the forecast under its own cache key")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := srv.GetForecast(context.Background(), 39.9, 32.8); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if upstream.calls.Load() != 2 {
+		t.Errorf("Expected an expired forecast to be fetched again, got %d upstream calls", upstream.calls.Load())
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// HedgedWeatherService improves tail latency by racing a secondary service against a slow primary
+// The secondary is only called if the primary hasn't answered within the hedging delay (or has
+// already failed transiently); whichever succeeds first wins and the other call is canceled.
+// Failures the secondary can't fix are returned as they are: an open breaker, a full bulkhead or an
+// exhausted call budget shed load the secondary must not pick up, and 4xx answers would be the same there.
+type HedgedWeatherService struct {
+	primary   WeatherService
+	secondary WeatherService
+	delay     time.Duration
+}
+
+// hedgeResult is the outcome of one leg of a hedged call
+type hedgeResult struct {
+	data *WeatherData
+	err  error
+}
+
+// NewHedged creates a HedgedWeatherService calling secondary after delayMs without a primary answer
+func NewHedged(primary, secondary WeatherService, delayMs int) *HedgedWeatherService {
+	return &HedgedWeatherService{
+		primary:   primary,
+		secondary: secondary,
+		delay:     time.Duration(delayMs) * time.Millisecond,
+	}
+}
+
+// GetWeather returns the first successful answer, or the primary's error if both calls fail or the primary
+// failed in a way hedging can't help with
+func (srv *HedgedWeatherService) GetWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // stops the losing call
+
+	primary := make(chan hedgeResult, 1)
+	go func() {
+		data, err := srv.primary.GetWeather(ctx, lat, lon)
+		primary <- hedgeResult{data, err}
+	}()
+
+	timer := time.NewTimer(srv.delay)
+	defer timer.Stop()
+
+	var primaryErr error
+	select {
+	case result := <-primary:
+		if result.err == nil {
+			return result.data, nil
+		}
+		if !isHedgeable(result.err) {
+			return nil, result.err
+		}
+		primaryErr = result.err
+		primary = nil // hedge right away rather than waiting out the delay
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	secondary := make(chan hedgeResult, 1)
+	go func() {
+		data, err := srv.secondary.GetWeather(ctx, lat, lon)
+		secondary <- hedgeResult{data, err}
+	}()
+
+	var secondaryErr error
+	for primary != nil || secondary != nil {
+		select {
+		case result := <-primary:
+			if result.err == nil {
+				return result.data, nil
+			}
+			primaryErr, primary = result.err, nil
+		case result := <-secondary:
+			if result.err == nil {
+				return result.data, nil
+			}
+			secondaryErr, secondary = result.err, nil
+		}
+	}
+
+	if primaryErr != nil {
+		return nil, primaryErr
+	}
+	return nil, secondaryErr
+}
+
+// isHedgeable reports whether a failed primary call is worth racing the secondary for: only transient
+// upstream failures (connection errors, timeouts, 5xx) are
+func isHedgeable(err error) bool {
+	return errors.Is(err, ErrUnavailable) || errors.Is(err, context.DeadlineExceeded)
+}
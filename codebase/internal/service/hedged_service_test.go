@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// delayedService answers with condition (or err) after delay, unless canceled first
+type delayedService struct {
+	delay     time.Duration
+	condition string
+	err       error
+}
+
+func (s *delayedService) GetWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &WeatherData{Condition: s.condition}, nil
+}
+
+func TestHedgedWeatherService_FastPrimaryWins(t *testing.T) {
+	srv := NewHedged(&delayedService{condition: "primary"}, &delayedService{condition: "secondary"}, 200)
+
+	data, err := srv.GetWeather(context.Background(), 1, 2)
+	if err != nil || data.Condition != "primary" {
+		t.Errorf("Expected primary answer, got %v %v", data, err)
+	}
+}
+
+func TestHedgedWeatherService_SlowPrimaryIsHedged(t *testing.T) {
+	primary := &delayedService{delay: time.Second, condition: "primary"}
+	srv := NewHedged(primary, &delayedService{condition: "secondary"}, 10)
+
+	start := time.Now()
+	data, err := srv.GetWeather(context.Background(), 1, 2)
+	if err != nil || data.Condition != "secondary" {
+		t.Errorf("Expected secondary answer, got %v %v", data, err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected hedged answer well before the slow primary, took %v", elapsed)
+	}
+}
+
+func TestHedgedWeatherService_FailedPrimaryHedgesImmediately(t *testing.T) {
+	down := &ProviderError{Provider: "OpenWeatherMap", Code: 503, Message: "down"}
+	srv := NewHedged(&delayedService{err: down}, &delayedService{condition: "secondary"}, 5000)
+
+	start := time.Now()
+	data, err := srv.GetWeather(context.Background(), 1, 2)
+	if err != nil || data.Condition != "secondary" {
+		t.Errorf("Expected secondary answer, got %v %v", data, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected no wait for the hedging delay, took %v", elapsed)
+	}
+}
+
+func TestHedgedWeatherService_BothFail(t *testing.T) {
+	primaryErr := &ProviderError{Provider: "OpenWeatherMap", Code: 502, Message: "primary down"}
+	srv := NewHedged(&delayedService{err: primaryErr}, &delayedService{err: errors.New("secondary down")}, 10)
+
+	if _, err := srv.GetWeather(context.Background(), 1, 2); !errors.Is(err, primaryErr) {
+		t.Errorf("Expected the primary error, got %v", err)
+	}
+}
+
+func TestHedgedWeatherService_PermanentFailuresAreNotHedged(t *testing.T) {
+	for _, primaryErr := range []error{
+		ErrCircuitOpen,
+		ErrBulkheadFull,
+		ErrUpstreamBudgetExhausted,
+		&ProviderError{Provider: "OpenWeatherMap", Code: 401, Message: "invalid API key"},
+		&ProviderError{Provider: "OpenWeatherMap", Code: 404, Message: "city not found"},
+		&ProviderError{Provider: "OpenWeatherMap", Code: 429, Message: "too many requests"},
+	} {
+		secondary := &countingService{}
+		srv := NewHedged(&delayedService{err: primaryErr}, secondary, 5000)
+
+		if _, err := srv.GetWeather(context.Background(), 1, 2); err != primaryErr {
+			t.Errorf("Expected %v unchanged, got %v", primaryErr, err)
+		}
+		if calls := secondary.calls.Load(); calls != 0 {
+			t.Errorf("Expected no hedge after %v, got %d secondary calls", primaryErr, calls)
+		}
+	}
+}
+
+func TestHedgedWeatherService_OpenBreakerIsNotBypassed(t *testing.T) {
+	down := &ProviderError{Provider: "OpenWeatherMap", Code: 503, Message: "down"}
+	breaker := NewCircuitBreaker(&delayedService{err: down}, 1, 60, slog.Default())
+	breaker.GetWeather(context.Background(), 1, 2) // trips the breaker
+
+	secondary := &countingService{}
+	srv := NewHedged(breaker, secondary, 5000)
+	for range 3 {
+		if _, err := srv.GetWeather(context.Background(), 1, 2); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("Expected ErrCircuitOpen, got %v", err)
+		}
+	}
+	if calls := secondary.calls.Load(); calls != 0 {
+		t.Errorf("Expected the secondary not to be called while the breaker is open, got %d calls", calls)
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/krizvi/weather-app-server/internal/audit"
+	"github.com/krizvi/weather-app-server/internal/metrics"
+	"github.com/krizvi/weather-app-server/internal/tracing"
+	"time"
+)
+
+// UpstreamMetrics are the per-provider call metrics recorded by InstrumentedService
+type UpstreamMetrics struct {
+	calls    *metrics.CounterVec
+	duration *metrics.HistogramVec
+}
+
+// NewUpstreamMetrics registers the upstream call metrics
+func NewUpstreamMetrics(registry *metrics.Registry) *UpstreamMetrics {
+	return &UpstreamMetrics{
+		calls:    registry.NewCounterVec("upstream_requests_total", "Weather provider calls, by provider and result.", "provider", "result"),
+		duration: registry.NewHistogramVec("upstream_request_duration_seconds", "Weather provider call latency, including retries.", metrics.DefaultBuckets, "provider"),
+	}
+}
+
+// InstrumentedService records call counts, results and latencies of a provider
+type InstrumentedService struct {
+	upstream WeatherService
+	provider string
+	metrics  *UpstreamMetrics
+}
+
+// NewInstrumented creates an InstrumentedService recording calls to upstream under the provider label
+func NewInstrumented(upstream WeatherService, provider string, m *UpstreamMetrics) *InstrumentedService {
+	return &InstrumentedService{upstream: upstream, provider: provider, metrics: m}
+}
+
+// GetWeather calls upstream and records the outcome
+func (srv *InstrumentedService) GetWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	ctx, span := tracing.Start(ctx, "provider.GetWeather", tracing.KindInternal)
+	defer span.End()
+
+	audit.CountUpstreamCall(ctx)
+	start := time.Now()
+	data, err := srv.upstream.GetWeather(ctx, lat, lon)
+	srv.metrics.duration.Observe(time.Since(start).Seconds(), srv.provider)
+	result := resultLabel(err)
+	srv.metrics.calls.Inc(srv.provider, result)
+
+	span.SetAttribute("provider", srv.provider)
+	span.SetAttribute("provider.result", result)
+	if err != nil && result != "not_found" {
+		span.SetError(err)
+	}
+	return data, err
+}
+
+// Forecasts returns a ForecastService calling upstream and recording the outcome under the same provider
+// label as GetWeather
+func (srv *InstrumentedService) Forecasts(upstream ForecastService) ForecastService {
+	return forecastFunc(func(ctx context.Context, lat, lon float64) (*Forecast, error) {
+		ctx, span := tracing.Start(ctx, "provider.GetForecast", tracing.KindInternal)
+		defer span.End()
+
+		audit.CountUpstreamCall(ctx)
+		start := time.Now()
+		forecast, err := upstream.GetForecast(ctx, lat, lon)
+		srv.metrics.duration.Observe(time.Since(start).Seconds(), srv.provider)
+		result := resultLabel(err)
+		srv.metrics.calls.Inc(srv.provider, result)
+
+		span.SetAttribute("provider", srv.provider)
+		span.SetAttribute("provider.result", result)
+		if err != nil && result != "not_found" {
+			span.SetError(err)
+		}
+		return forecast, err
+	})
+}
+
+// resultLabel classifies a provider call outcome into a small fixed set of metric label values
+func resultLabel(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrUnauthorized):
+		return "unauthorized"
+	case errors.Is(err, ErrMalformedResponse):
+		return "invalid_response"
+	case errors.Is(err, ErrUnavailable):
+		return "unavailable"
+	}
+	return "error"
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"github.com/krizvi/weather-app-server/internal/metrics"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInstrumentedService_RecordsResults(t *testing.T) {
+	registry := metrics.NewRegistry()
+	upstream := &switchableService{}
+	srv := NewInstrumented(upstream, "primary", NewUpstreamMetrics(registry))
+
+	srv.GetWeather(context.Background(), 1, 2)
+	upstream.err = &ProviderError{Provider: "test", Code: 429}
+	srv.GetWeather(context.Background(), 1, 2)
+	upstream.err = &ProviderError{Provider: "test", Message: "timeout", Err: context.DeadlineExceeded}
+	srv.GetWeather(context.Background(), 1, 2)
+
+	w := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range []string{
+		`upstream_requests_total{provider="primary",result="ok"} 1`,
+		`upstream_requests_total{provider="primary",result="rate_limited"} 1`,
+		`upstream_requests_total{provider="primary",result="timeout"} 1`,
+		`upstream_request_duration_seconds_count{provider="primary"} 3`,
+	} {
+		if !strings.Contains(w.Body.String(), line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, w.Body.String())
+		}
+	}
+}
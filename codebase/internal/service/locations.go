@@ -0,0 +1,85 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Location is a coordinate pair the server cares about ahead of time (e.g. for cache warming)
+type Location struct {
+	Lat float64
+	Lon float64
+}
+
+// ParseLocation parses a "lat,lon" pair and validates its geographical bounds
+func ParseLocation(s string) (Location, error) {
+	latStr, lonStr, ok := strings.Cut(strings.TrimSpace(s), ",")
+	if !ok {
+		return Location{}, fmt.Errorf("invalid location %q: expected lat,lon", s)
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return Location{}, fmt.Errorf("invalid latitude in location %q", s)
+	}
+
+	lon, err := strconv.ParseFloat(strings.TrimSpace(lonStr), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return Location{}, fmt.Errorf("invalid longitude in location %q", s)
+	}
+
+	return Location{Lat: lat, Lon: lon}, nil
+}
+
+// ParseLocationList parses semicolon-separated "lat,lon" pairs, e.g. "40.71,-74.00;51.50,-0.12"
+func ParseLocationList(s string) ([]Location, error) {
+	var locations []Location
+	for _, part := range strings.Split(s, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		location, err := ParseLocation(part)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+	return locations, nil
+}
+
+// ReadLocations reads one "lat,lon" pair per line; blank lines and lines starting with # are ignored
+func ReadLocations(r io.Reader) ([]Location, error) {
+	var locations []Location
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		location, err := ParseLocation(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		locations = append(locations, location)
+	}
+	return locations, scanner.Err()
+}
+
+// LoadLocationsFile reads locations from the file at path
+func LoadLocationsFile(path string) ([]Location, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open locations file: %w", err)
+	}
+	defer file.Close()
+
+	locations, err := ReadLocations(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read locations file %s: %w", path, err)
+	}
+	return locations, nil
+}
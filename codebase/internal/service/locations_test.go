@@ -0,0 +1,38 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadLocations(t *testing.T) {
+	input := `# top cities
+40.7128,-74.0060
+
+51.5074, -0.1278
+`
+	locations, err := ReadLocations(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(locations) != 2 || locations[1].Lat != 51.5074 || locations[1].Lon != -0.1278 {
+		t.Errorf("Unexpected locations: %+v", locations)
+	}
+}
+
+func TestReadLocations_InvalidLine(t *testing.T) {
+	_, err := ReadLocations(strings.NewReader("40.7,-74.0\n95,10\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected error pointing at line 2, got %v", err)
+	}
+}
+
+func TestParseLocationList(t *testing.T) {
+	locations, err := ParseLocationList("40.71,-74.00; 51.50,-0.12;")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(locations) != 2 {
+		t.Errorf("Expected 2 locations, got %d", len(locations))
+	}
+}
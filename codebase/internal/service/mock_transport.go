@@ -0,0 +1,103 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mockConditions are the conditions MockTransport picks from
+var mockConditions = []string{"Clear", "Clouds", "Rain", "Drizzle", "Snow", "Mist"}
+
+// MockTransport answers OpenWeatherMap API requests in process with made-up weather, so the server runs
+// without an API key or network access, e.g. on a laptop
+// The weather only depends on the coordinates and the hour, so repeated lookups agree and cache as usual
+type MockTransport struct {
+	now func() time.Time
+}
+
+// NewMockTransport creates a MockTransport
+func NewMockTransport() *MockTransport {
+	return &MockTransport{now: time.Now}
+}
+
+// RoundTrip answers /weather and /forecast like OpenWeatherMap would, any API key included, and anything
+// else with a 404
+func (t *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	query := req.URL.Query()
+	lat, latErr := strconv.ParseFloat(query.Get("lat"), 64)
+	lon, lonErr := strconv.ParseFloat(query.Get("lon"), 64)
+
+	var body any
+	status := http.StatusOK
+	switch {
+	case !strings.HasSuffix(req.URL.Path, "/weather") && !strings.HasSuffix(req.URL.Path, "/forecast"):
+		status, body = http.StatusNotFound, map[string]string{"cod": "404", "message": "Internal error"}
+	case latErr != nil || lonErr != nil:
+		status, body = http.StatusBadRequest, map[string]string{"cod": "400", "message": "wrong latitude or longitude"}
+	case strings.HasSuffix(req.URL.Path, "/weather"):
+		now := t.now().Truncate(time.Hour)
+		body = map[string]any{
+			"cod":     200,
+			"dt":      now.Unix(),
+			"name":    "Mockville",
+			"sys":     map[string]string{"country": "XX"},
+			"main":    map[string]float64{"temp": mockTemp(lat, now)},
+			"weather": []map[string]string{{"main": mockCondition(lat, lon, now)}},
+		}
+	default:
+		start := t.now().Truncate(3 * time.Hour)
+		list := make([]map[string]any, 0, 40)
+		for i := range 40 {
+			at := start.Add(time.Duration(i) * 3 * time.Hour)
+			list = append(list, map[string]any{
+				"dt":      at.Unix(),
+				"main":    map[string]float64{"temp": mockTemp(lat, at)},
+				"weather": []map[string]string{{"main": mockCondition(lat, lon, at)}},
+			})
+		}
+		body = map[string]any{
+			"cod":     "200",
+			"message": 0,
+			"list":    list,
+			"city":    map[string]any{"name": "Mockville", "country": "XX", "timezone": int(math.Round(lon/15)) * 3600},
+		}
+	}
+
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(encoded)),
+		ContentLength: int64(len(encoded)),
+		Request:       req,
+	}, nil
+}
+
+// mockTemp is a temperature in Kelvin that falls towards the poles and varies over the day
+func mockTemp(lat float64, at time.Time) float64 {
+	return 303.15 - math.Abs(lat)*0.6 + 4*math.Sin(float64(at.UTC().Hour())*math.Pi/12)
+}
+
+// mockCondition picks a condition for the coordinates and hour
+func mockCondition(lat, lon float64, at time.Time) string {
+	h := fnv.New32a()
+	h.Write([]byte(strconv.FormatFloat(lat, 'f', 2, 64) + "," + strconv.FormatFloat(lon, 'f', 2, 64) + "," + strconv.FormatInt(at.Unix()/3600, 10)))
+	return mockConditions[h.Sum32()%uint32(len(mockConditions))]
+}
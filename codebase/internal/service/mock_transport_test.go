@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+)
+
+func TestMockTransport_AnswersLikeOpenWeatherMap(t *testing.T) {
+	srv := New("any-key", "https://api.openweathermap.org/data/2.5", 5, NewMockTransport(), slog.Default())
+
+	first, err := srv.GetWeather(context.Background(), 51.5, -0.12)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	second, _ := srv.GetWeather(context.Background(), 51.5, -0.12)
+	if first.Condition != second.Condition || first.TemperatureCategory != second.TemperatureCategory {
+		t.Errorf("Expected repeated lookups to agree, got %+v and %+v", first, second)
+	}
+
+	forecast, err := srv.GetForecast(context.Background(), 51.5, -0.12)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(forecast.Days) < 5 {
+		t.Errorf("Expected at least 5 forecast days, got %d", len(forecast.Days))
+	}
+
+	if err := srv.Validate(context.Background()); err != nil {
+		t.Errorf("Expected any API key to validate, got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// hotLocationsMaxTracked bounds the tracker so random coordinates can't grow it without limit
+const hotLocationsMaxTracked = 10000
+
+// hotLocationsMinCount drops locations whose decayed count falls below this
+const hotLocationsMinCount = 1
+
+type hotLocation struct {
+	location Location
+	count    float64
+}
+
+// HotLocations is a small frequency tracker of requested locations
+// Counts are halved on every Decay so the ranking follows recent traffic
+type HotLocations struct {
+	mu     sync.Mutex
+	counts map[string]*hotLocation
+}
+
+// NewHotLocations creates an empty HotLocations tracker
+func NewHotLocations() *HotLocations {
+	return &HotLocations{counts: make(map[string]*hotLocation)}
+}
+
+// Record counts one request for the coordinates
+func (hl *HotLocations) Record(lat, lon float64) {
+	key := CacheKey(lat, lon)
+
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+
+	if hot, ok := hl.counts[key]; ok {
+		hot.count++
+		return
+	}
+	if len(hl.counts) >= hotLocationsMaxTracked {
+		return // new locations get a chance once Decay frees up room
+	}
+	hl.counts[key] = &hotLocation{location: Location{Lat: lat, Lon: lon}, count: 1}
+}
+
+// Top returns up to n locations ordered by request count, most requested first
+func (hl *HotLocations) Top(n int) []Location {
+	hl.mu.Lock()
+	ranked := make([]*hotLocation, 0, len(hl.counts))
+	for _, hot := range hl.counts {
+		ranked = append(ranked, &hotLocation{location: hot.location, count: hot.count})
+	}
+	hl.mu.Unlock()
+
+	sort.Slice(ranked, func(i, j int) bool { return ranked[i].count > ranked[j].count })
+	if len(ranked) > n {
+		ranked = ranked[:n]
+	}
+
+	locations := make([]Location, len(ranked))
+	for i, hot := range ranked {
+		locations[i] = hot.location
+	}
+	return locations
+}
+
+// Decay halves all counts and forgets locations that are no longer requested
+func (hl *HotLocations) Decay() {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+
+	for key, hot := range hl.counts {
+		hot.count /= 2
+		if hot.count < hotLocationsMinCount {
+			delete(hl.counts, key)
+		}
+	}
+}
+
+// Prefetcher periodically refreshes the most requested locations so they are always served from cache
+type Prefetcher struct {
+	cached      *CachedWeatherService
+	hot         *HotLocations
+	interval    time.Duration
+	topN        int
+	concurrency int
+	logger      *slog.Logger
+}
+
+// NewPrefetcher creates a Prefetcher and starts tracking requests made through cached
+func NewPrefetcher(cached *CachedWeatherService, intervalSec, topN, concurrency int, logger *slog.Logger) *Prefetcher {
+	hot := NewHotLocations()
+	cached.TrackHotLocations(hot)
+
+	return &Prefetcher{
+		cached:      cached,
+		hot:         hot,
+		interval:    time.Duration(intervalSec) * time.Second,
+		topN:        topN,
+		concurrency: concurrency,
+		logger:      logger,
+	}
+}
+
+// Run refreshes hot locations on every interval until ctx is canceled
+func (p *Prefetcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.prefetch(ctx)
+		}
+	}
+}
+
+// prefetch refreshes the current top locations whose entries would expire before the next run
+func (p *Prefetcher) prefetch(ctx context.Context) {
+	locations := p.hot.Top(p.topN)
+	p.hot.Decay()
+	if len(locations) == 0 {
+		return
+	}
+
+	// With a shared cache one instance per interval does the refreshing for the whole fleet
+	// The lease is left to expire so the next round is up for grabs again
+	if p.cached.coordinator != nil {
+		_, leader, err := p.cached.coordinator.TryLock(ctx, "prefetch-leader", p.interval)
+		if err != nil {
+			p.logger.Warn("prefetch leadership check failed", slog.String("error", err.Error()))
+		} else if !leader {
+			return
+		}
+	}
+
+	// Bound each round by the interval so a slow upstream can't stack up rounds
+	roundCtx, cancel := context.WithTimeout(ctx, p.interval)
+	defer cancel()
+
+	populated := p.cached.populate(roundCtx, locations, p.concurrency, p.interval)
+	p.logger.Debug("prefetched hot locations", slog.Int("locations", len(locations)), slog.Int("refreshed", populated.fetched))
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"github.com/krizvi/weather-app-server/internal/cache"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestHotLocations_TopAndDecay(t *testing.T) {
+	hot := NewHotLocations()
+	for i := 0; i < 5; i++ {
+		hot.Record(40.7, -74.0)
+	}
+	for i := 0; i < 3; i++ {
+		hot.Record(51.5, -0.12)
+	}
+	hot.Record(35.7, 139.7)
+
+	top := hot.Top(2)
+	if len(top) != 2 || top[0].Lat != 40.7 || top[1].Lat != 51.5 {
+		t.Errorf("Unexpected top locations: %+v", top)
+	}
+
+	// A single request decays below the minimum count and is forgotten
+	hot.Decay()
+	if top := hot.Top(10); len(top) != 2 {
+		t.Errorf("Expected 2 locations after decay, got %+v", top)
+	}
+}
+
+func TestPrefetcher_RefreshesExpiringEntries(t *testing.T) {
+	upstream := &countingService{}
+	srv := NewCached(upstream, cache.NewMemory(0), 60, 600, 0, 5, slog.Default())
+	prefetcher := NewPrefetcher(srv, 30, 10, 2, slog.Default())
+
+	now := time.Now()
+	srv.now = func() time.Time { return now }
+
+	srv.GetWeather(context.Background(), 40.7, -74.0)
+
+	// Entry is fresh for another 60s, longer than the interval - nothing to do
+	prefetcher.prefetch(context.Background())
+	if upstream.calls.Load() != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", upstream.calls.Load())
+	}
+
+	// Entry would expire before the next round - refresh it now
+	srv.GetWeather(context.Background(), 40.7, -74.0)
+	now = now.Add(45 * time.Second)
+	prefetcher.prefetch(context.Background())
+	if upstream.calls.Load() != 2 {
+		t.Errorf("Expected 2 upstream calls, got %d", upstream.calls.Load())
+	}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// UpstreamProbe checks that the provider accepts our requests
+// The last result is reused for ttl so frequent health checks don't spend the upstream call budget
+type UpstreamProbe struct {
+	validate func(ctx context.Context) error
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	lastErr   error
+}
+
+// NewUpstreamProbe creates an UpstreamProbe making authenticated validation calls through srv
+func NewUpstreamProbe(srv *OpenWeatherMapService, ttlSec int) *UpstreamProbe {
+	return &UpstreamProbe{
+		validate: srv.Validate,
+		ttl:      time.Duration(ttlSec) * time.Second,
+		now:      time.Now,
+	}
+}
+
+// Check returns the result of the last probe, probing again once it is older than ttl
+// Concurrent callers wait for a single probe instead of each calling upstream
+func (p *UpstreamProbe) Check(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.checkedAt.IsZero() && p.now().Sub(p.checkedAt) < p.ttl {
+		return p.lastErr
+	}
+
+	err := p.validate(ctx)
+	if ctx.Err() != nil {
+		return err // our caller gave up - that says nothing about upstream, so don't remember it
+	}
+	p.checkedAt, p.lastErr = p.now(), err
+	return err
+}
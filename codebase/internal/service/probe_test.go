@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUpstreamProbe_CachesResult(t *testing.T) {
+	calls := 0
+	probeErr := errors.New("invalid API key")
+	probe := &UpstreamProbe{
+		validate: func(ctx context.Context) error {
+			calls++
+			return probeErr
+		},
+		ttl: 30 * time.Second,
+	}
+	now := time.Now()
+	probe.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if err := probe.Check(context.Background()); !errors.Is(err, probeErr) {
+			t.Errorf("Expected the probe error, got %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 upstream call within the TTL, got %d", calls)
+	}
+
+	now = now.Add(31 * time.Second)
+	probeErr = nil
+	if err := probe.Check(context.Background()); err != nil {
+		t.Errorf("Expected a fresh successful probe, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected a second upstream call after the TTL, got %d", calls)
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/krizvi/weather-app-server/internal/coord"
+	"log/slog"
+	"time"
+)
+
+// upstreamCallsCounter is the coordinator counter shared by every instance calling the provider
+const upstreamCallsCounter = "upstream-calls"
+
+// ErrUpstreamBudgetExhausted is returned when the per-minute upstream call budget is used up
+var ErrUpstreamBudgetExhausted = errors.New("upstream call budget exhausted")
+
+// RateLimitedWeatherService caps calls to the wrapped service at a fixed number per minute
+// With a shared coordinator (Redis) the budget applies to the whole fleet rather than each instance
+type RateLimitedWeatherService struct {
+	upstream    WeatherService
+	coordinator coord.Coordinator
+	settings    *RuntimeSettings // calls per minute
+	logger      *slog.Logger
+}
+
+// NewRateLimited creates a new RateLimitedWeatherService allowing callsPerMinute upstream calls
+func NewRateLimited(upstream WeatherService, coordinator coord.Coordinator, callsPerMinute int, logger *slog.Logger) *RateLimitedWeatherService {
+	return &RateLimitedWeatherService{
+		upstream:    upstream,
+		coordinator: coordinator,
+		settings:    NewRuntimeSettings(RuntimeConfig{UpstreamCallsPerMinute: callsPerMinute}),
+		logger:      logger,
+	}
+}
+
+// UseRuntimeSettings makes the budget follow the calls per minute in settings instead of the ones given to
+// NewRateLimited, taking effect within the current minute
+func (srv *RateLimitedWeatherService) UseRuntimeSettings(settings *RuntimeSettings) {
+	srv.settings = settings
+}
+
+// GetWeather calls the wrapped service unless this minute's budget is exhausted
+func (srv *RateLimitedWeatherService) GetWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	if err := srv.spend(ctx); err != nil {
+		return nil, err
+	}
+	return srv.upstream.GetWeather(ctx, lat, lon)
+}
+
+// Forecasts returns a ForecastService calling upstream within the same budget as GetWeather
+func (srv *RateLimitedWeatherService) Forecasts(upstream ForecastService) ForecastService {
+	return forecastFunc(func(ctx context.Context, lat, lon float64) (*Forecast, error) {
+		if err := srv.spend(ctx); err != nil {
+			return nil, err
+		}
+		return upstream.GetForecast(ctx, lat, lon)
+	})
+}
+
+// spend counts a call against this minute's budget, returning ErrUpstreamBudgetExhausted if it is used up
+func (srv *RateLimitedWeatherService) spend(ctx context.Context) error {
+	calls, err := srv.coordinator.Incr(ctx, upstreamCallsCounter, time.Minute)
+	if err != nil {
+		// Fail open - losing the coordinator shouldn't stop us serving weather
+		srv.logger.WarnContext(ctx, "upstream budget check failed", slog.String("error", err.Error()))
+	} else if calls > int64(srv.settings.Load().UpstreamCallsPerMinute) {
+		return ErrUpstreamBudgetExhausted
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/krizvi/weather-app-server/internal/coord"
+	"log/slog"
+	"testing"
+)
+
+func TestRateLimitedWeatherService_Budget(t *testing.T) {
+	upstream := &countingService{}
+	srv := NewRateLimited(upstream, coord.NewLocal(), 2, slog.Default())
+
+	for i := 0; i < 2; i++ {
+		if _, err := srv.GetWeather(context.Background(), 40.7, -74.0); err != nil {
+			t.Fatalf("Expected call %d within budget, got %v", i+1, err)
+		}
+	}
+
+	_, err := srv.GetWeather(context.Background(), 40.7, -74.0)
+	if !errors.Is(err, ErrUpstreamBudgetExhausted) {
+		t.Errorf("Expected ErrUpstreamBudgetExhausted, got %v", err)
+	}
+	if upstream.calls.Load() != 2 {
+		t.Errorf("Expected 2 upstream calls, got %d", upstream.calls.Load())
+	}
+}
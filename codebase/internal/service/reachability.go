@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Reachability wraps the provider client and remembers when the provider last answered
+// Any answer counts, including client errors like 404; only connection failures, timeouts and 5xx don't
+type Reachability struct {
+	upstream     WeatherService
+	lastAnswered atomic.Int64 // unix nanoseconds, 0 if never
+	now          func() time.Time
+}
+
+// NewReachability creates a Reachability tracker in front of upstream
+func NewReachability(upstream WeatherService) *Reachability {
+	return &Reachability{upstream: upstream, now: time.Now}
+}
+
+// GetWeather calls upstream and records whether it answered
+func (r *Reachability) GetWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	data, err := r.upstream.GetWeather(ctx, lat, lon)
+	if !errors.Is(err, ErrUnavailable) {
+		r.lastAnswered.Store(r.now().UnixNano())
+	}
+	return data, err
+}
+
+// Forecasts returns a ForecastService calling upstream and recording whether it answered, like GetWeather
+func (r *Reachability) Forecasts(upstream ForecastService) ForecastService {
+	return forecastFunc(func(ctx context.Context, lat, lon float64) (*Forecast, error) {
+		forecast, err := upstream.GetForecast(ctx, lat, lon)
+		if !errors.Is(err, ErrUnavailable) {
+			r.lastAnswered.Store(r.now().UnixNano())
+		}
+		return forecast, err
+	})
+}
+
+// ReachableWithin reports whether the provider answered within the last d
+func (r *Reachability) ReachableWithin(d time.Duration) bool {
+	last := r.lastAnswered.Load()
+	return last != 0 && r.now().Sub(time.Unix(0, last)) <= d
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReachability(t *testing.T) {
+	upstream := &switchableService{err: &ProviderError{Provider: "test", Message: "connection refused"}}
+	reachability := NewReachability(upstream)
+	now := time.Now()
+	reachability.now = func() time.Time { return now }
+
+	reachability.GetWeather(context.Background(), 1, 2)
+	if reachability.ReachableWithin(time.Minute) {
+		t.Error("Expected a connection failure not to count as reachable")
+	}
+
+	// A provider that answers "not found" is still reachable
+	upstream.err = &ProviderError{Provider: "test", Code: 404, Message: "city not found"}
+	reachability.GetWeather(context.Background(), 1, 2)
+	if !reachability.ReachableWithin(time.Minute) {
+		t.Error("Expected a 404 answer to count as reachable")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if reachability.ReachableWithin(time.Minute) {
+		t.Error("Expected an old answer not to count")
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how OpenWeatherMapService retries transient upstream failures
+// Only connection errors, timeouts and 5xx responses are retried; a zero policy makes one attempt
+type RetryPolicy struct {
+	MaxAttempts int  // Total attempts including the first one
+	BaseDelayMs int  // Delay before the first retry, doubled for every following retry
+	MaxDelayMs  int  // Upper bound for a single delay
+	Jitter      bool // Randomize each delay between zero and its backoff so clients don't retry in lockstep
+}
+
+// delay returns how long to wait before the given retry (1 for the first retry)
+func (p RetryPolicy) delay(retry int) time.Duration {
+	backoff := time.Duration(p.BaseDelayMs) * time.Millisecond
+	maxDelay := time.Duration(p.MaxDelayMs) * time.Millisecond
+	for i := 1; i < retry && (maxDelay <= 0 || backoff < maxDelay); i++ {
+		backoff *= 2
+	}
+	if maxDelay > 0 && backoff > maxDelay {
+		backoff = maxDelay
+	}
+	if p.Jitter && backoff > 0 {
+		backoff = rand.N(backoff + 1)
+	}
+	return backoff
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
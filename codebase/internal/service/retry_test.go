@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testWeatherBody = `{"cod":200,"name":"Testville","weather":[{"main":"Clear"}],"main":{"temp":300}}`
+
+// newFlakyUpstream fails the first failures requests with status, then succeeds
+func newFlakyUpstream(failures int32, status int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(status)
+			io.WriteString(w, "<html>upstream unavailable</html>")
+			return
+		}
+		io.WriteString(w, testWeatherBody)
+	}))
+	return upstream, &calls
+}
+
+// newRetryingService returns a service using policy that records its delays instead of sleeping
+func newRetryingService(baseURL string, policy RetryPolicy, delays *[]time.Duration) *OpenWeatherMapService {
+	srv := New("key", baseURL, 5, nil, slog.Default())
+	srv.UseRetryPolicy(policy)
+	srv.sleep = func(_ context.Context, d time.Duration) error {
+		*delays = append(*delays, d)
+		return nil
+	}
+	return srv
+}
+
+func TestOpenWeatherMapService_RetriesServerErrors(t *testing.T) {
+	upstream, calls := newFlakyUpstream(2, http.StatusBadGateway)
+	defer upstream.Close()
+
+	var delays []time.Duration
+	srv := newRetryingService(upstream.URL, RetryPolicy{MaxAttempts: 3, BaseDelayMs: 100, MaxDelayMs: 150}, &delays)
+
+	data, err := srv.GetWeather(context.Background(), 1, 2)
+	if err != nil {
+		t.Fatalf("Expected success after retries, got %v", err)
+	}
+	if data.City != "Testville" || calls.Load() != 3 {
+		t.Errorf("Expected 3 calls ending in success, got %d calls and %+v", calls.Load(), data)
+	}
+	if len(delays) != 2 || delays[0] != 100*time.Millisecond || delays[1] != 150*time.Millisecond {
+		t.Errorf("Expected delays [100ms 150ms], got %v", delays)
+	}
+}
+
+func TestOpenWeatherMapService_GivesUpAfterMaxAttempts(t *testing.T) {
+	upstream, calls := newFlakyUpstream(10, http.StatusServiceUnavailable)
+	defer upstream.Close()
+
+	var delays []time.Duration
+	srv := newRetryingService(upstream.URL, RetryPolicy{MaxAttempts: 2, BaseDelayMs: 10}, &delays)
+
+	if _, err := srv.GetWeather(context.Background(), 1, 2); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls.Load())
+	}
+}
+
+func TestOpenWeatherMapService_DoesNotRetryClientErrors(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"cod":401,"message":"Invalid API key"}`)
+	}))
+	defer upstream.Close()
+
+	var delays []time.Duration
+	srv := newRetryingService(upstream.URL, RetryPolicy{MaxAttempts: 3, BaseDelayMs: 10}, &delays)
+
+	if _, err := srv.GetWeather(context.Background(), 1, 2); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if len(delays) != 0 {
+		t.Errorf("Expected no retries, got %d", len(delays))
+	}
+}
+
+func TestRetryPolicy_JitterStaysWithinBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelayMs: 100, MaxDelayMs: 1000, Jitter: true}
+	for retry := 1; retry <= 6; retry++ {
+		if d := policy.delay(retry); d < 0 || d > time.Second {
+			t.Errorf("Expected delay within [0, 1s] for retry %d, got %v", retry, d)
+		}
+	}
+}
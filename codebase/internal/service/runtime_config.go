@@ -0,0 +1,64 @@
+package service
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// UpstreamConfig is what an OpenWeatherMapService needs from the runtime configuration for its account
+type UpstreamConfig struct {
+	APIKey  string
+	Timeout time.Duration // per attempt, further bounded by the caller's deadline (0 for none)
+}
+
+// RuntimeConfig holds the settings that change while the server runs, on configuration reloads and API key
+// rotations
+// A published RuntimeConfig is never modified, so a call that loads it once sees one consistent set of
+// settings instead of some from before a reload and some from after
+type RuntimeConfig struct {
+	Primary                 UpstreamConfig
+	Hedge                   UpstreamConfig
+	BreakerFailureThreshold int
+	BreakerOpen             time.Duration
+	UpstreamCallsPerMinute  int
+	ClientTimeout           time.Duration // what a request's lookups get before it fails
+}
+
+// PrimaryUpstream and HedgeUpstream pick the account an OpenWeatherMapService reads from a RuntimeConfig
+func PrimaryUpstream(config *RuntimeConfig) UpstreamConfig { return config.Primary }
+func HedgeUpstream(config *RuntimeConfig) UpstreamConfig   { return config.Hedge }
+
+// RuntimeSettings publishes the current RuntimeConfig to the components sharing it
+type RuntimeSettings struct {
+	current atomic.Pointer[RuntimeConfig]
+}
+
+// NewRuntimeSettings creates RuntimeSettings publishing config
+func NewRuntimeSettings(config RuntimeConfig) *RuntimeSettings {
+	rs := &RuntimeSettings{}
+	rs.Store(config)
+	return rs
+}
+
+// Load returns the current RuntimeConfig, which must not be modified
+func (rs *RuntimeSettings) Load() *RuntimeConfig {
+	return rs.current.Load()
+}
+
+// Store publishes config for calls starting from now on
+func (rs *RuntimeSettings) Store(config RuntimeConfig) {
+	rs.current.Store(&config)
+}
+
+// Update publishes a copy of the current RuntimeConfig changed by change, starting over if another update
+// was published in the meantime
+func (rs *RuntimeSettings) Update(change func(config *RuntimeConfig)) {
+	for {
+		current := rs.current.Load()
+		next := *current
+		change(&next)
+		if rs.current.CompareAndSwap(current, &next) {
+			return
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestRuntimeSettings_CallsKeepTheirSnapshot(t *testing.T) {
+	settings := NewRuntimeSettings(RuntimeConfig{Primary: UpstreamConfig{APIKey: "old"}, Hedge: UpstreamConfig{APIKey: "hedge"}})
+	var mu sync.Mutex
+	var keys []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.URL.Query().Get("appid"))
+		first := len(keys) == 1
+		mu.Unlock()
+		if first {
+			// A reload while the call is retrying
+			settings.Update(func(config *RuntimeConfig) { config.Primary.APIKey = "new" })
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"cod":200,"weather":[{"main":"Clear"}],"main":{"temp":300}}`)
+	}))
+	defer upstream.Close()
+
+	srv := New("unused", upstream.URL, 5, nil, slog.Default())
+	srv.UseRuntimeSettings(settings, PrimaryUpstream)
+	srv.UseRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelayMs: 1})
+	hedge := New("unused", upstream.URL, 5, nil, slog.Default())
+	hedge.UseRuntimeSettings(settings, HedgeUpstream)
+
+	for _, s := range []*OpenWeatherMapService{srv, srv, hedge} {
+		if _, err := s.GetWeather(context.Background(), 1, 2); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if want := []string{"old", "old", "new", "hedge"}; !slices.Equal(keys, want) {
+		t.Errorf("Expected keys %v, got %v", want, keys)
+	}
+}
+
+func TestRuntimeSettings_ConcurrentUpdates(t *testing.T) {
+	settings := NewRuntimeSettings(RuntimeConfig{})
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			settings.Update(func(config *RuntimeConfig) { config.UpstreamCallsPerMinute++ })
+		}()
+	}
+	wg.Wait()
+
+	if calls := settings.Load().UpstreamCallsPerMinute; calls != 50 {
+		t.Errorf("Expected every update to be kept, got %d", calls)
+	}
+}
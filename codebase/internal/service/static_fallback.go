@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// StaticResponse is the placeholder weather served when neither the provider nor the cache has data
+type StaticResponse struct {
+	Condition           string `json:"condition"`
+	TemperatureCategory string `json:"temperature_category"`
+}
+
+// StaticRegion overrides the default placeholder inside a lat/lon bounding box
+type StaticRegion struct {
+	Name     string         `json:"name"`
+	MinLat   float64        `json:"min_lat"`
+	MaxLat   float64        `json:"max_lat"`
+	MinLon   float64        `json:"min_lon"`
+	MaxLon   float64        `json:"max_lon"`
+	Response StaticResponse `json:"response"`
+}
+
+// StaticResponses holds the configured placeholders; the first matching region wins, then Default
+type StaticResponses struct {
+	Default *StaticResponse `json:"default"`
+	Regions []StaticRegion  `json:"regions"`
+}
+
+// LoadStaticResponsesFile reads StaticResponses from the JSON file at path
+func LoadStaticResponsesFile(path string) (*StaticResponses, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read static responses file: %w", err)
+	}
+
+	var responses StaticResponses
+	if err := json.Unmarshal(body, &responses); err != nil {
+		return nil, fmt.Errorf("failed to parse static responses file %s: %w", path, err)
+	}
+	for _, region := range responses.Regions {
+		if region.MinLat > region.MaxLat || region.MinLon > region.MaxLon {
+			return nil, fmt.Errorf("invalid bounds for static response region %q", region.Name)
+		}
+	}
+	return &responses, nil
+}
+
+// lookup returns the placeholder for the coordinates, or nil if none is configured
+func (responses *StaticResponses) lookup(lat, lon float64) *StaticResponse {
+	for i := range responses.Regions {
+		region := &responses.Regions[i]
+		if lat >= region.MinLat && lat <= region.MaxLat && lon >= region.MinLon && lon <= region.MaxLon {
+			return &region.Response
+		}
+	}
+	return responses.Default
+}
+
+// StaticFallbackService serves a configured placeholder, marked Degraded and Static, when upstream
+// (including any cache in front of it) fails, so consumer products always have something to render
+// Unknown locations and requests the client abandoned still fail
+type StaticFallbackService struct {
+	upstream  WeatherService
+	responses *StaticResponses
+	logger    *slog.Logger
+}
+
+// NewStaticFallback creates a StaticFallbackService in front of upstream
+func NewStaticFallback(upstream WeatherService, responses *StaticResponses, logger *slog.Logger) *StaticFallbackService {
+	return &StaticFallbackService{upstream: upstream, responses: responses, logger: logger}
+}
+
+// GetWeather returns upstream's answer, or the placeholder for the coordinates if upstream fails
+func (srv *StaticFallbackService) GetWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	data, err := srv.upstream.GetWeather(ctx, lat, lon)
+	if err == nil || errors.Is(err, ErrNotFound) || errors.Is(err, context.Canceled) {
+		return data, err
+	}
+
+	static := srv.responses.lookup(lat, lon)
+	if static == nil {
+		return nil, err
+	}
+
+	srv.logger.WarnContext(ctx, "serving static degraded response", slog.String("key", CacheKey(lat, lon)), slog.String("error", err.Error()))
+	return &WeatherData{
+		Condition:           static.Condition,
+		TemperatureCategory: static.TemperatureCategory,
+		Degraded:            true,
+		Static:              true,
+	}, nil
+}
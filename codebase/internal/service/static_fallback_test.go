@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testStaticResponses() *StaticResponses {
+	return &StaticResponses{
+		Default: &StaticResponse{Condition: "unavailable", TemperatureCategory: "moderate"},
+		Regions: []StaticRegion{
+			{Name: "arctic", MinLat: 66, MaxLat: 90, MinLon: -180, MaxLon: 180, Response: StaticResponse{Condition: "Snow", TemperatureCategory: "cold"}},
+		},
+	}
+}
+
+func TestStaticFallback_ServesPlaceholderOnFailure(t *testing.T) {
+	srv := NewStaticFallback(&switchableService{err: ErrUnavailable}, testStaticResponses(), slog.Default())
+
+	data, err := srv.GetWeather(context.Background(), 40, -74)
+	if err != nil {
+		t.Fatalf("Expected placeholder, got error %v", err)
+	}
+	if !data.Degraded || !data.Static || data.Condition != "unavailable" {
+		t.Errorf("Expected degraded default placeholder, got %+v", data)
+	}
+
+	data, _ = srv.GetWeather(context.Background(), 70, 20)
+	if data.Condition != "Snow" || data.TemperatureCategory != "cold" {
+		t.Errorf("Expected the arctic placeholder, got %+v", data)
+	}
+}
+
+func TestStaticFallback_PassesThroughSuccessAndClientErrors(t *testing.T) {
+	upstream := &switchableService{}
+	srv := NewStaticFallback(upstream, testStaticResponses(), slog.Default())
+
+	if data, err := srv.GetWeather(context.Background(), 1, 2); err != nil || data.Static {
+		t.Errorf("Expected real data, got %+v %v", data, err)
+	}
+
+	for _, upstreamErr := range []error{ErrNotFound, context.Canceled} {
+		upstream.err = upstreamErr
+		if _, err := srv.GetWeather(context.Background(), 1, 2); !errors.Is(err, upstreamErr) {
+			t.Errorf("Expected %v to pass through, got %v", upstreamErr, err)
+		}
+	}
+}
+
+func TestStaticFallback_NoMatchingPlaceholder(t *testing.T) {
+	responses := testStaticResponses()
+	responses.Default = nil
+	srv := NewStaticFallback(&switchableService{err: ErrUnavailable}, responses, slog.Default())
+
+	if _, err := srv.GetWeather(context.Background(), 40, -74); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected the upstream error outside any region, got %v", err)
+	}
+}
+
+func TestLoadStaticResponsesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "static.json")
+	os.WriteFile(path, []byte(`{"default":{"condition":"unavailable","temperature_category":"moderate"},"regions":[{"name":"bad","min_lat":10,"max_lat":0}]}`), 0o644)
+
+	if _, err := LoadStaticResponsesFile(path); err == nil {
+		t.Error("Expected an error for inverted region bounds")
+	}
+
+	os.WriteFile(path, []byte(`{"default":{"condition":"unavailable","temperature_category":"moderate"}}`), 0o644)
+	responses, err := LoadStaticResponsesFile(path)
+	if err != nil || responses.Default.Condition != "unavailable" {
+		t.Errorf("Expected the default placeholder to load, got %+v %v", responses, err)
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/metrics"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SyntheticProbe periodically fetches a reference location through the same stack users go
+// through, so problems that only show on real lookups (e.g. an expired provider key) are noticed
+// before users report them
+type SyntheticProbe struct {
+	probe    func(ctx context.Context) error
+	interval time.Duration
+	timeout  time.Duration
+	logger   *slog.Logger
+
+	runs     *metrics.CounterVec   // optional probe count by result
+	duration *metrics.HistogramVec // optional probe latency
+
+	mu      sync.Mutex
+	lastErr error
+	lastAt  time.Time
+}
+
+// NewSyntheticProbe creates a SyntheticProbe running probe every intervalSec seconds, each bounded by timeoutSec
+func NewSyntheticProbe(probe func(ctx context.Context) error, intervalSec, timeoutSec int, logger *slog.Logger) *SyntheticProbe {
+	return &SyntheticProbe{
+		probe:    probe,
+		interval: time.Duration(intervalSec) * time.Second,
+		timeout:  time.Duration(timeoutSec) * time.Second,
+		logger:   logger,
+	}
+}
+
+// ServiceProbe looks up loc through srv, bypassing the cache so the provider is really asked
+func ServiceProbe(srv WeatherService, loc Location) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := srv.GetWeather(WithForceRefresh(ctx), loc.Lat, loc.Lon)
+		return err
+	}
+}
+
+// HTTPProbe requests url (e.g. our own /weather endpoint) with client and expects a 200
+// A non-empty bearer token is sent as the Authorization header
+func HTTPProbe(client *http.Client, url, bearer string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("synthetic request answered %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// UseMetrics records probe results and latencies in registry, plus whether the last probe succeeded
+func (sp *SyntheticProbe) UseMetrics(registry *metrics.Registry) {
+	sp.runs = registry.NewCounterVec("synthetic_probes_total", "Synthetic probe runs, by result (ok or error).", "result")
+	sp.duration = registry.NewHistogramVec("synthetic_probe_duration_seconds", "Synthetic probe latency.", metrics.DefaultBuckets)
+	registry.NewGaugeFunc("synthetic_probe_success", "Whether the last synthetic probe succeeded (1) or not (0).", func() float64 {
+		if sp.Check(context.Background()) != nil {
+			return 0
+		}
+		return 1
+	})
+}
+
+// Run probes every interval until ctx is canceled
+// The first probe waits an interval too: startup checks cover the provider at boot, and the
+// server may not be listening yet for probes going through HTTP
+func (sp *SyntheticProbe) Run(ctx context.Context) {
+	ticker := time.NewTicker(sp.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sp.runOnce(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runOnce makes one probe and records its outcome
+func (sp *SyntheticProbe) runOnce(ctx context.Context) {
+	probeCtx, cancel := context.WithTimeout(ctx, sp.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := sp.probe(probeCtx)
+	latency := time.Since(start)
+	if ctx.Err() != nil {
+		return // shutting down - the result says nothing about the stack
+	}
+
+	result := "ok"
+	if err != nil {
+		result = "error"
+		sp.logger.Warn("synthetic probe failed", slog.String("error", err.Error()), slog.Duration("duration", latency))
+	}
+	sp.runs.Inc(result)
+	sp.duration.Observe(latency.Seconds())
+
+	sp.mu.Lock()
+	sp.lastErr, sp.lastAt = err, time.Now()
+	sp.mu.Unlock()
+}
+
+// Check returns the error of the last probe (nil if it succeeded or none has finished yet), for health checks
+// A result older than two intervals counts as a failure, since the probe itself seems stuck
+func (sp *SyntheticProbe) Check(ctx context.Context) error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if !sp.lastAt.IsZero() && time.Since(sp.lastAt) > 2*sp.interval+sp.timeout {
+		return fmt.Errorf("last synthetic probe finished %s ago", time.Since(sp.lastAt).Round(time.Second))
+	}
+	return sp.lastErr
+}
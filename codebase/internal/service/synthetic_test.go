@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/krizvi/weather-app-server/internal/cache"
+	"github.com/krizvi/weather-app-server/internal/metrics"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSyntheticProbe_RecordsResults(t *testing.T) {
+	probeErr := errors.New("invalid API key")
+	probe := NewSyntheticProbe(func(ctx context.Context) error { return probeErr }, 60, 5, slog.Default())
+	registry := metrics.NewRegistry()
+	probe.UseMetrics(registry)
+
+	if err := probe.Check(context.Background()); err != nil {
+		t.Errorf("Expected no failure before the first probe, got %v", err)
+	}
+
+	probe.runOnce(context.Background())
+	if err := probe.Check(context.Background()); !errors.Is(err, probeErr) {
+		t.Errorf("Expected the probe error, got %v", err)
+	}
+
+	probeErr = nil
+	probe.runOnce(context.Background())
+	if err := probe.Check(context.Background()); err != nil {
+		t.Errorf("Expected a successful probe, got %v", err)
+	}
+
+	var body strings.Builder
+	registry.WritePrometheus(&body)
+	for _, line := range []string{
+		`synthetic_probes_total{result="error"} 1`,
+		`synthetic_probes_total{result="ok"} 1`,
+		`synthetic_probe_duration_seconds_count 2`,
+		`synthetic_probe_success 1`,
+	} {
+		if !strings.Contains(body.String(), line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, body.String())
+		}
+	}
+}
+
+func TestServiceProbe_BypassesCache(t *testing.T) {
+	upstream := &countingService{}
+	cached := NewCached(upstream, cache.NewMemory(0), 60, 60, 0, 5, slog.Default())
+	probe := ServiceProbe(cached, Location{Lat: 51.5, Lon: -0.1})
+
+	for i := 0; i < 2; i++ {
+		if err := probe(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if upstream.calls.Load() != 2 {
+		t.Errorf("Expected every probe to reach upstream, got %d calls", upstream.calls.Load())
+	}
+}
+
+func TestHTTPProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer admin" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	if err := HTTPProbe(server.Client(), server.URL+"/weather", "admin")(context.Background()); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if err := HTTPProbe(server.Client(), server.URL+"/weather", "")(context.Background()); err == nil {
+		t.Error("Expected a non-200 answer to fail the probe")
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"crypto/tls"
+	"github.com/krizvi/weather-app-server/internal/metrics"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TransportMetrics are the per-provider connection phase metrics recorded by NewTracedTransport
+type TransportMetrics struct {
+	phases      *metrics.HistogramVec
+	connections *metrics.CounterVec
+}
+
+// NewTransportMetrics registers the upstream connection phase metrics
+func NewTransportMetrics(registry *metrics.Registry) *TransportMetrics {
+	return &TransportMetrics{
+		phases: registry.NewHistogramVec("upstream_http_phase_seconds",
+			"Upstream HTTP request phases by provider: dns, connect, tls, and ttfb (request sent to first response byte).",
+			metrics.DefaultBuckets, "provider", "phase"),
+		connections: registry.NewCounterVec("upstream_http_connections_total", "Connections used for upstream requests, by provider and whether they were reused.", "provider", "reused"),
+	}
+}
+
+// tracedTransport records httptrace timings for every request it sends
+type tracedTransport struct {
+	next     http.RoundTripper
+	provider string
+	metrics  *TransportMetrics
+}
+
+// NewTracedTransport wraps next so DNS, connect, TLS and time-to-first-byte durations are recorded under provider
+// Together with the request latency this tells network slowness apart from slow upstream processing
+// A nil next uses http.DefaultTransport
+func NewTracedTransport(next http.RoundTripper, provider string, m *TransportMetrics) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &tracedTransport{next: next, provider: provider, metrics: m}
+}
+
+func (t *tracedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Dials can outlive the request (and race each other), so the hooks share state under a lock
+	var mu sync.Mutex
+	var dnsStart, connectStart, tlsStart, wroteRequest time.Time
+	observe := func(phase string, start time.Time) {
+		if !start.IsZero() {
+			t.metrics.phases.Observe(time.Since(start).Seconds(), t.provider, phase)
+		}
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			dnsStart = time.Now()
+			mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			mu.Lock()
+			observe("dns", dnsStart)
+			mu.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			if connectStart.IsZero() {
+				connectStart = time.Now()
+			}
+			mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			observe("connect", connectStart)
+			mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			tlsStart = time.Now()
+			mu.Unlock()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			observe("tls", tlsStart)
+			mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.metrics.connections.Inc(t.provider, strconv.FormatBool(info.Reused))
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			mu.Lock()
+			wroteRequest = time.Now()
+			mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			observe("ttfb", wroteRequest)
+			mu.Unlock()
+		},
+	}
+
+	return t.next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"github.com/krizvi/weather-app-server/internal/metrics"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTracedTransport_RecordsPhases(t *testing.T) {
+	upstream := httptest.NewServer(newFakeProvider())
+	defer upstream.Close()
+
+	registry := metrics.NewRegistry()
+	srv := New("key", upstream.URL, 5, NewTracedTransport(NewTransport(TransportConfig{}), "primary", NewTransportMetrics(registry)), slog.Default())
+	for i := 0; i < 2; i++ {
+		if _, err := srv.GetWeather(context.Background(), 1, 2); err != nil {
+			t.Fatalf("Expected success, got %v", err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range []string{
+		`upstream_http_phase_seconds_count{provider="primary",phase="connect"} 1`,
+		`upstream_http_phase_seconds_count{provider="primary",phase="ttfb"} 2`,
+		`upstream_http_connections_total{provider="primary",reused="false"} 1`,
+		`upstream_http_connections_total{provider="primary",reused="true"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, w.Body.String())
+		}
+	}
+}
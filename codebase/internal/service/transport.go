@@ -0,0 +1,152 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/dnscache"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// TransportConfig holds connection pooling settings for the upstream HTTP client
+// Zero values keep the net/http defaults
+type TransportConfig struct {
+	MaxIdleConns           int // Maximum idle connections across all hosts
+	MaxIdleConnsPerHost    int // Maximum idle connections kept per upstream host
+	IdleConnTimeoutSec     int // How long an idle connection stays in the pool
+	TLSHandshakeTimeoutSec int // Maximum time to wait for a TLS handshake
+	DNSCacheTTLSec         int // How long resolved upstream addresses are reused (0 resolves on every dial)
+	// ProxyURL sends upstream requests through a proxy, with credentials as its user info; nil follows
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	ProxyURL *url.URL
+	NoProxy  string // Hosts reached directly despite ProxyURL, in the NO_PROXY format
+	// TLSConfig replaces the client TLS settings, e.g. from NewClientTLSConfig; nil keeps the defaults
+	TLSConfig *tls.Config
+	Logger    *slog.Logger // Logs failed background DNS refreshes
+}
+
+// tlsVersions are the minimum TLS versions NewClientTLSConfig accepts
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewClientTLSConfig creates the client TLS settings for upstream calls, e.g. through a TLS-intercepting
+// gateway or to a private mirror of the API: caFile adds a PEM bundle to the trusted system roots, certFile
+// and keyFile (both or neither) hold a client certificate, and minVersion is the oldest TLS version allowed,
+// "1.0" to "1.3"
+func NewClientTLSConfig(caFile, certFile, keyFile, minVersion string) (*tls.Config, error) {
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS version %q, use 1.0, 1.1, 1.2 or 1.3", minVersion)
+	}
+	cfg := &tls.Config{MinVersion: version}
+
+	if caFile != "" {
+		bundle, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", caFile)
+		}
+		cfg.RootCAs = roots
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// NewTransport creates an upstream transport based on http.DefaultTransport with the given tuning
+// The default of 2 idle connections per host causes constant reconnects to a single upstream under load
+func NewTransport(cfg TransportConfig) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.MaxIdleConns > 0 {
+		transport.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.IdleConnTimeoutSec > 0 {
+		transport.IdleConnTimeout = time.Duration(cfg.IdleConnTimeoutSec) * time.Second
+	}
+	if cfg.TLSHandshakeTimeoutSec > 0 {
+		transport.TLSHandshakeTimeout = time.Duration(cfg.TLSHandshakeTimeoutSec) * time.Second
+	}
+
+	if cfg.TLSConfig != nil {
+		transport.TLSClientConfig = cfg.TLSConfig
+	}
+	if cfg.ProxyURL != nil {
+		transport.Proxy = proxyFunc(cfg.ProxyURL, cfg.NoProxy)
+	}
+
+	if cfg.DNSCacheTTLSec > 0 {
+		// Same dialer settings as http.DefaultTransport, resolving through the cache
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		transport.DialContext = dnscache.New(cfg.DNSCacheTTLSec, cfg.Logger).DialContext(dialer)
+	}
+
+	return transport
+}
+
+// proxyFunc returns a Transport.Proxy sending requests through proxyURL, except to the hosts in noProxy: a
+// comma-separated list of host names (matching their subdomains too), domains starting with a dot, IP
+// addresses, CIDR ranges, optionally with a port, or "*" for all hosts
+func proxyFunc(proxyURL *url.URL, noProxy string) func(*http.Request) (*url.URL, error) {
+	var entries []string
+	for _, entry := range strings.Split(noProxy, ",") {
+		if entry = strings.ToLower(strings.TrimSpace(entry)); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		host, port := strings.ToLower(req.URL.Hostname()), req.URL.Port()
+		for _, entry := range entries {
+			if bypassProxy(entry, host, port) {
+				return nil, nil
+			}
+		}
+		return proxyURL, nil
+	}
+}
+
+// bypassProxy reports whether the NO_PROXY entry matches host and port
+func bypassProxy(entry, host, port string) bool {
+	if entry == "*" {
+		return true
+	}
+	if _, network, err := net.ParseCIDR(entry); err == nil {
+		ip := net.ParseIP(host)
+		return ip != nil && network.Contains(ip)
+	}
+	if entryHost, entryPort, err := net.SplitHostPort(entry); err == nil {
+		if entryPort != port {
+			return false
+		}
+		entry = entryHost
+	}
+	if domain, ok := strings.CutPrefix(entry, "."); ok {
+		return strings.HasSuffix(host, "."+domain)
+	}
+	return host == entry || strings.HasSuffix(host, "."+entry)
+}
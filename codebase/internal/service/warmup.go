@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// validationLat and validationLon are the coordinates used to validate credentials (0,0 always resolves)
+const (
+	validationLat = 0
+	validationLon = 0
+)
+
+// WarmConnections opens up to conns connections to the upstream host in parallel so the first user
+// requests don't pay for TCP and TLS setup; the connections stay in the client's idle pool
+func (srv *OpenWeatherMapService) WarmConnections(ctx context.Context, conns int) error {
+	base, err := url.Parse(srv.baseURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse base URL: %w", err)
+	}
+	root := base.Scheme + "://" + base.Host + "/"
+
+	errs := make(chan error, conns)
+	var wg sync.WaitGroup
+	for i := 0; i < conns; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, root, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			resp, err := srv.httpClient.Do(req)
+			if err != nil {
+				errs <- err
+				return
+			}
+			// Any status will do - we only want the connection; drain so it returns to the pool
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", base.Host, err)
+	}
+	return nil
+}
+
+// Validate makes one authenticated call so a bad API key or base URL is found before serving traffic
+func (srv *OpenWeatherMapService) Validate(ctx context.Context) error {
+	if _, err := srv.GetWeather(ctx, validationLat, validationLon); err != nil {
+		return fmt.Errorf("OpenWeatherMap validation call failed (check OPENWEATHER_API_KEY and OPENWEATHER_BASE_URL): %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOpenWeatherMapService_ValidateRejectsBadKey(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"cod":401,"message":"Invalid API key"}`)
+	}))
+	defer upstream.Close()
+
+	srv := New("bad-key", upstream.URL, 5, nil, slog.Default())
+	err := srv.Validate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "Invalid API key") {
+		t.Errorf("Expected invalid key error, got %v", err)
+	}
+}
+
+func TestOpenWeatherMapService_WarmConnections(t *testing.T) {
+	var heads atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads.Add(1)
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer upstream.Close()
+
+	srv := New("key", upstream.URL+"/data/2.5", 5, nil, slog.Default())
+	if err := srv.WarmConnections(context.Background(), 3); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if heads.Load() != 3 {
+		t.Errorf("Expected 3 warm-up requests, got %d", heads.Load())
+	}
+}
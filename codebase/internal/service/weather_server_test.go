@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"log/slog"
 	"os"
 	"testing"
 )
@@ -14,7 +15,7 @@ func TestOpenWeatherMapService_GetWeather(t *testing.T) {
 		t.Skip("Skipping integration test - no API key")
 	}
 
-	service := New(apiKey, "https://api.openweathermap.org/data/2.5", 10)
+	service := New(apiKey, "https://api.openweathermap.org/data/2.5", 10, nil, slog.Default())
 
 	ctx := context.Background()
 	data, err := service.GetWeather(ctx, 40.7128, -74.0060)
@@ -3,21 +3,36 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // WeatherData represents the weather information we return to clients
+// XML element names follow the JSON field names
 type WeatherData struct {
+	XMLName             xml.Name `json:"-" xml:"weather"`
 	ObservationTime     string
 	Country             string
 	City                string
 	Condition           string
 	TemperatureCategory string
+	Cached              bool      `json:"cached" xml:"cached"`                                         // true when served from the cache rather than a fresh upstream call
+	FetchedAt           time.Time `json:"fetched_at" xml:"fetched_at"`                                 // when the data was fetched from upstream
+	AgeSeconds          int64     `json:"age_seconds" xml:"age_seconds"`                               // seconds since FetchedAt at the time of the response
+	Stale               bool      `json:"stale,omitempty" xml:"stale,omitempty"`                       // set when served from an expired cache entry
+	Degraded            bool      `json:"degraded,omitempty" xml:"degraded,omitempty"`                 // set when upstream failed and last-known-good data is served
+	DataAgeSeconds      int64     `json:"data_age_seconds,omitempty" xml:"data_age_seconds,omitempty"` // age of last-known-good data, only set when Degraded
+	Static              bool      `json:"static,omitempty" xml:"static,omitempty"`                     // set when Degraded data is a configured placeholder rather than real data
+	ETag                string    `json:"-" xml:"-"`                                                   // validator derived from the cached payload, empty if uncached
+	ExpiresAt           time.Time `json:"-" xml:"-"`                                                   // when the cached data stops being fresh, zero if uncached
 }
 
 // OpenWeatherMapResponse represents the response structure from OpenWeatherMap API
@@ -26,7 +41,7 @@ type OpenWeatherMapResponse struct {
 		Main string `json:"main"`
 	} `json:"weather"`
 	Main struct {
-		Temp float64 `json:"temp"`
+		Temp *float64 `json:"temp"` // nil when missing, so it isn't mistaken for 0 Kelvin
 	} `json:"main"`
 	UnixSeconds int64 `json:"dt"` // this is definitely seconds from Epoch (01011970)
 	Location    struct {
@@ -38,8 +53,34 @@ type OpenWeatherMapResponse struct {
 	// Same as the actual HTTP response status but included in JSON for convenience
 	// 200 = success, 429 = rate limited, 401 = bad api key
 	// Reference: https://openweathermap.org/appid
-	HttpCode int    `json:"cod"`
-	Message  string `json:"message,omitempty"` // error details when something goes wrong
+	// Success responses send it as a number, error responses as a string ("404")
+	HttpCode responseCode `json:"cod"`
+	Message  string       `json:"message,omitempty"` // error details when something goes wrong
+}
+
+// unknownValue is reported for fields the provider left out of an otherwise usable response
+const unknownValue = "unknown"
+
+// responseCode is the "cod" field, which OpenWeatherMap sends either as a number or a string
+type responseCode int
+
+func (code *responseCode) UnmarshalJSON(data []byte) error {
+	value, err := strconv.Atoi(strings.Trim(string(data), `"`))
+	if err != nil {
+		return fmt.Errorf("invalid cod %s", data)
+	}
+	*code = responseCode(value)
+	return nil
+}
+
+// upstreamResponse is a decoded OpenWeatherMap response body
+type upstreamResponse interface {
+	// status returns the "cod" and "message" fields, the code being 0 if the body had none
+	status() (responseCode, string)
+}
+
+func (response *OpenWeatherMapResponse) status() (responseCode, string) {
+	return response.HttpCode, response.Message
 }
 
 func (response *OpenWeatherMapResponse) weatherCheckTime() string {
@@ -52,38 +93,150 @@ type WeatherService interface {
 	GetWeather(ctx context.Context, lat, lon float64) (*WeatherData, error)
 }
 
+// openWeatherMapProvider names the provider in ProviderError
+const openWeatherMapProvider = "OpenWeatherMap"
+
 // OpenWeatherMapService implements WeatherService using OpenWeatherMap API
 type OpenWeatherMapService struct {
-	apiKey     string
+	settings   *RuntimeSettings
+	account    func(config *RuntimeConfig) UpstreamConfig // the API key and per-attempt limit in settings
 	baseURL    string
 	httpClient *http.Client
+	margin     time.Duration // reserved from the caller's deadline for work after the call (e.g. encoding the response)
+	retry      RetryPolicy
+	sleep      func(ctx context.Context, d time.Duration) error
+	logger     *slog.Logger
 }
 
 // New creates a new instance of OpenWeatherMapService
-func New(apiKey string, baseURL string, timeoutSec int) *OpenWeatherMapService {
+// timeoutSec bounds each upstream attempt (connection + sending + receiving); attempts never outlive the caller's context
+// A nil transport uses http.DefaultTransport
+func New(apiKey string, baseURL string, timeoutSec int, transport http.RoundTripper, logger *slog.Logger) *OpenWeatherMapService {
 	return &OpenWeatherMapService{
-		apiKey:  apiKey,
-		baseURL: baseURL,
-		httpClient: &http.Client{
-			// Global timeout for the entire HTTP request lifecycle
-			// (connection + sending + receiving + processing)
-			Timeout: time.Duration(timeoutSec) * time.Second,
-		},
+		settings:   NewRuntimeSettings(RuntimeConfig{Primary: UpstreamConfig{APIKey: apiKey, Timeout: time.Duration(timeoutSec) * time.Second}}),
+		account:    PrimaryUpstream,
+		baseURL:    baseURL,
+		httpClient: &http.Client{Transport: transport},
+		sleep:      sleepContext,
+		logger:     logger,
 	}
 }
 
+// UseRuntimeSettings makes calls take the API key and per-attempt limit picked by account from settings,
+// as of the moment they start, instead of the ones given to New
+func (srv *OpenWeatherMapService) UseRuntimeSettings(settings *RuntimeSettings, account func(config *RuntimeConfig) UpstreamConfig) {
+	srv.settings = settings
+	srv.account = account
+}
+
+// UseDeadlineMargin makes upstream attempts give up marginMs before the caller's deadline
+// so there is time left to answer the client instead of the whole request timing out
+func (srv *OpenWeatherMapService) UseDeadlineMargin(marginMs int) {
+	srv.margin = time.Duration(marginMs) * time.Millisecond
+}
+
+// UseRetryPolicy makes GetWeather retry transient upstream failures according to policy
+func (srv *OpenWeatherMapService) UseRetryPolicy(policy RetryPolicy) {
+	srv.retry = policy
+}
+
 // GetWeather fetches weather data for the given coordinates
 func (srv *OpenWeatherMapService) GetWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	var mapResponse OpenWeatherMapResponse
+	if err := srv.call(ctx, "/weather", lat, lon, &mapResponse); err != nil {
+		return nil, err
+	}
+	return mapResponse.toWeatherData()
+}
+
+// call requests the endpoint at path for the given coordinates and decodes the response into out
+func (srv *OpenWeatherMapService) call(ctx context.Context, path string, lat, lon float64, out upstreamResponse) error {
+	// Every attempt uses the key and limit of the moment the call started, even across a reload
+	account := srv.account(srv.settings.Load())
+
 	// Build the API URL with query parameters
-	apiURL, err := srv.buildAPIURL(lat, lon)
+	apiURL, err := srv.buildAPIURL(path, lat, lon, account.APIKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build API URL: %w", err)
+		return fmt.Errorf("failed to build API URL: %w", err)
+	}
+
+	// Retry transient failures so a single upstream hiccup doesn't reach the user
+	for attempt := 1; ; attempt++ {
+		err = srv.fetch(ctx, apiURL, account.Timeout, out)
+		if err == nil || attempt >= srv.retry.MaxAttempts || !errors.Is(err, ErrUnavailable) || !srv.hasBudget(ctx) {
+			break
+		}
+
+		delay := srv.retry.delay(attempt)
+		srv.logger.WarnContext(ctx, "retrying upstream request", slog.Int("attempt", attempt), slog.Duration("delay", delay), slog.String("error", err.Error()))
+		if srv.sleep(ctx, delay) != nil {
+			break
+		}
+	}
+	return err
+}
+
+// toWeatherData converts a successful response into the data we serve
+// The temperature is required; a missing condition is reported as "unknown" rather than failing
+func (response *OpenWeatherMapResponse) toWeatherData() (*WeatherData, error) {
+	if response.Main.Temp == nil {
+		return nil, &ProviderError{Provider: openWeatherMapProvider, Code: int(response.HttpCode), Message: "malformed response: missing main.temp", Err: ErrMalformedResponse}
 	}
 
+	condition := unknownValue
+	if len(response.Weather) > 0 && response.Weather[0].Main != "" {
+		condition = response.Weather[0].Main
+	}
+
+	// Convert temperature from Kelvin to Fahrenheit
+	tempFahrenheit := (*response.Main.Temp-273.15)*9/5 + 32
+
+	return &WeatherData{
+		FetchedAt:           time.Now(),
+		ObservationTime:     response.weatherCheckTime(),
+		Country:             response.Location.Country,
+		City:                response.Name,
+		Condition:           condition,
+		TemperatureCategory: categorizeTemperature(tempFahrenheit),
+	}, nil
+}
+
+// attemptContext bounds one upstream attempt by timeout and the caller's deadline minus the margin
+func (srv *OpenWeatherMapService) attemptContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if callerDeadline, ok := ctx.Deadline(); ok {
+		if reserved := callerDeadline.Add(-srv.margin); deadline.IsZero() || reserved.Before(deadline) {
+			deadline = reserved
+		}
+	}
+	if deadline.IsZero() {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline)
+}
+
+// hasBudget reports whether the caller's deadline leaves room for another attempt
+func (srv *OpenWeatherMapService) hasBudget(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > srv.margin
+}
+
+// fetch makes a single upstream request and decodes the response into out
+// Provider failures are returned as *ProviderError
+func (srv *OpenWeatherMapService) fetch(ctx context.Context, apiURL string, timeout time.Duration, out upstreamResponse) error {
+	ctx, cancel := srv.attemptContext(ctx, timeout)
+	defer cancel()
+
 	// Create HTTP request with context
 	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+		return fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 
 	// Set headers
@@ -93,41 +246,58 @@ func (srv *OpenWeatherMapService) GetWeather(ctx context.Context, lat, lon float
 	// Make the HTTP request
 	resp, err := srv.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make HTTP request: %w", err)
+		// The URL in the error carries the API key, and the error ends up in logs and error reports
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactURL(req.URL)
+		}
+		return &ProviderError{Provider: openWeatherMapProvider, Message: "failed to make HTTP request", Err: err}
 	}
 	defer resp.Body.Close()
 
 	// Read response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return &ProviderError{Provider: openWeatherMapProvider, Message: "failed to read response body", Err: err}
+	}
+
+	// Proxies in front of the API answer 5xx with non-JSON bodies
+	if resp.StatusCode >= 500 {
+		return &ProviderError{Provider: openWeatherMapProvider, Code: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
 	}
 
 	// Parse JSON response
-	var mapResponse OpenWeatherMapResponse
-	if err := json.Unmarshal(body, &mapResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+	if err := json.Unmarshal(body, out); err != nil {
+		return &ProviderError{Provider: openWeatherMapProvider, Code: resp.StatusCode, Message: "malformed response: " + err.Error(), Err: ErrMalformedResponse}
+	}
+	code, message := out.status()
+	if code == 0 {
+		code = responseCode(resp.StatusCode)
 	}
 
 	// Check API status (gets detailed error message)
-	if mapResponse.HttpCode != 200 {
-		return nil, fmt.Errorf("OpenWeatherMap API error (code %d): %s", mapResponse.HttpCode, mapResponse.Message)
+	if code != 200 {
+		providerErr := &ProviderError{Provider: openWeatherMapProvider, Code: int(code), Message: message}
+		if providerErr.Code == http.StatusTooManyRequests {
+			providerErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+		return providerErr
 	}
-	// Convert temperature from Kelvin to Fahrenheit
-	tempFahrenheit := (mapResponse.Main.Temp-273.15)*9/5 + 32
+	return nil
+}
 
-	return &WeatherData{
-		ObservationTime:     mapResponse.weatherCheckTime(),
-		Country:             mapResponse.Location.Country,
-		City:                mapResponse.Name,
-		Condition:           mapResponse.Weather[0].Main,
-		TemperatureCategory: categorizeTemperature(tempFahrenheit),
-	}, nil
+// parseRetryAfter reads a Retry-After header given in seconds (zero if missing or an HTTP date)
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
 }
 
-// buildAPIURL constructs the OpenWeatherMap API URL with the given coordinates
-func (srv *OpenWeatherMapService) buildAPIURL(lat, lon float64) (string, error) {
-	baseURL, err := url.Parse(srv.baseURL + "/weather")
+// buildAPIURL constructs the URL of the OpenWeatherMap endpoint at path with the given coordinates and API key
+func (srv *OpenWeatherMapService) buildAPIURL(path string, lat, lon float64, apiKey string) (string, error) {
+	baseURL, err := url.Parse(srv.baseURL + path)
 	if err != nil {
 		return "", err
 	}
@@ -135,7 +305,7 @@ func (srv *OpenWeatherMapService) buildAPIURL(lat, lon float64) (string, error)
 	params := url.Values{}
 	params.Add("lat", strconv.FormatFloat(lat, 'f', -1, 64))
 	params.Add("lon", strconv.FormatFloat(lon, 'f', -1, 64))
-	params.Add("appid", srv.apiKey)
+	params.Add("appid", apiKey)
 
 	baseURL.RawQuery = params.Encode()
 	return baseURL.String(), nil
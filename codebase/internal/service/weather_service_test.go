@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// getWeatherFrom runs GetWeather against an upstream answering every request with status and body
+func getWeatherFrom(t *testing.T, status int, body string) (*WeatherData, error) {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	defer upstream.Close()
+
+	return New("key", upstream.URL, 5, nil, slog.Default()).GetWeather(context.Background(), 1, 2)
+}
+
+func TestOpenWeatherMapService_PartialData(t *testing.T) {
+	tests := []string{
+		`{"cod":200,"main":{"temp":300}}`,
+		`{"cod":200,"weather":[],"main":{"temp":300}}`,
+		`{"cod":200,"weather":[{}],"main":{"temp":300}}`,
+		`{"weather":null,"main":{"temp":300}}`,
+	}
+
+	for _, body := range tests {
+		data, err := getWeatherFrom(t, http.StatusOK, body)
+		if err != nil {
+			t.Errorf("%s: expected partial data, got %v", body, err)
+			continue
+		}
+		if data.Condition != "unknown" || data.TemperatureCategory != "hot" {
+			t.Errorf("%s: expected unknown/hot, got %s/%s", body, data.Condition, data.TemperatureCategory)
+		}
+	}
+}
+
+func TestOpenWeatherMapService_MalformedResponses(t *testing.T) {
+	tests := []string{
+		``,
+		`not json`,
+		`{"cod":200,"weather":[{"main":"Clear"}]}`,
+		`{"cod":200,"weather":[{"main":"Clear"}],"main":{}}`,
+		`{"cod":200,"weather":{"main":"Clear"},"main":{"temp":300}}`,
+		`{"cod":true,"main":{"temp":300}}`,
+	}
+
+	for _, body := range tests {
+		_, err := getWeatherFrom(t, http.StatusOK, body)
+		if !errors.Is(err, ErrMalformedResponse) {
+			t.Errorf("%q: expected ErrMalformedResponse, got %v", body, err)
+		}
+	}
+}
+
+func TestOpenWeatherMapService_StringErrorCode(t *testing.T) {
+	_, err := getWeatherFrom(t, http.StatusNotFound, `{"cod":"404","message":"city not found"}`)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestOpenWeatherMapService_ReservesDeadlineMargin(t *testing.T) {
+	upstream := httptest.NewServer(newFakeProvider(fakeStep{delay: 5 * time.Second}))
+	defer upstream.Close()
+
+	srv := New("key", upstream.URL, 5, nil, slog.Default())
+	srv.UseRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelayMs: 10})
+	srv.UseDeadlineMargin(200)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := srv.GetWeather(ctx, 1, 2)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("Expected the call to give up before the margin, took %v", elapsed)
+	}
+	if ctx.Err() != nil {
+		t.Error("Expected time left on the request deadline")
+	}
+}
+
+func TestOpenWeatherMapService_TransportErrorsHideAPIKey(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstream.Close() // every call fails to connect
+
+	var logs strings.Builder
+	srv := New("secret-key", upstream.URL, 5, nil, slog.New(slog.NewTextHandler(&logs, nil)))
+	srv.UseRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelayMs: 1})
+
+	_, err := srv.GetWeather(context.Background(), 1, 2)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Expected ErrUnavailable, got %v", err)
+	}
+	if strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("Expected the API key to be redacted from the error, got %q", err.Error())
+	}
+	if !strings.Contains(logs.String(), "retrying upstream request") {
+		t.Fatalf("Expected the retry to be logged, got %q", logs.String())
+	}
+	if strings.Contains(logs.String(), "secret-key") {
+		t.Errorf("Expected the API key to be redacted from the logs, got %q", logs.String())
+	}
+}
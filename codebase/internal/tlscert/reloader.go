@@ -0,0 +1,126 @@
+package tlscert
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader serves a certificate and key pair from disk and picks up renewed files (cert-manager, certbot, ...)
+// without a restart; a pair that fails to load keeps the previous certificate in service
+type Reloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	logger   *slog.Logger
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time // newest modification time of the loaded files
+}
+
+// New loads the pair and creates a Reloader checking the files for changes every checkIntervalSec seconds
+// A non-positive checkIntervalSec falls back to 60 seconds
+func New(certFile, keyFile string, checkIntervalSec int, logger *slog.Logger) (*Reloader, error) {
+	if checkIntervalSec <= 0 {
+		checkIntervalSec = 60
+	}
+	r := &Reloader{certFile: certFile, keyFile: keyFile, interval: time.Duration(checkIntervalSec) * time.Second, logger: logger}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Config returns a server TLS config serving the certificate of r with the ServerConfig policy
+func (r *Reloader) Config() *tls.Config {
+	return ServerConfig(r.GetCertificate)
+}
+
+// ServerConfig returns a server TLS config with certificates from getCertificate and a modern policy: TLS 1.2
+// or later and, for TLS 1.2, only forward-secret AEAD cipher suites (TLS 1.3 suites are not configurable and
+// all qualify)
+func ServerConfig(getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		GetCertificate:   getCertificate,
+	}
+}
+
+// GetCertificate returns the current certificate, for tls.Config.GetCertificate
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload loads the pair if either file changed since the last load and reports whether it did
+func (r *Reloader) Reload() (bool, error) {
+	modTime, err := r.newestModTime()
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	unchanged := r.cert != nil && modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	r.mu.Lock()
+	r.cert, r.modTime = &cert, modTime
+	r.mu.Unlock()
+	return true, nil
+}
+
+// Run checks the files every interval until ctx is canceled
+func (r *Reloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			reloaded, err := r.Reload()
+			if err != nil {
+				// Renewal tools replace the two files one after the other; the next check sees the complete pair
+				r.logger.Warn("keeping the current TLS certificate", slog.String("error", err.Error()))
+			} else if reloaded {
+				r.logger.Info("reloaded TLS certificate", slog.String("cert_file", r.certFile))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Reloader) newestModTime() (time.Time, error) {
+	var newest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to read TLS certificate: %w", err)
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest, nil
+}
@@ -0,0 +1,120 @@
+package tlscert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePair writes a self-signed certificate for commonName and its key, dated modTime
+func writePair(t *testing.T, certFile, keyFile, commonName string, modTime time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	os.Chtimes(certFile, modTime, modTime)
+	os.Chtimes(keyFile, modTime, modTime)
+}
+
+func commonName(t *testing.T, r *Reloader) string {
+	t.Helper()
+	cert, _ := r.GetCertificate(&tls.ClientHelloInfo{})
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Subject.CommonName
+}
+
+func TestReloader_PicksUpRenewedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	start := time.Now().Add(-time.Hour)
+	writePair(t, certFile, keyFile, "first", start)
+
+	r, err := New(certFile, keyFile, 60, slog.Default())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if name := commonName(t, r); name != "first" {
+		t.Errorf("Expected the first certificate, got %q", name)
+	}
+	if reloaded, _ := r.Reload(); reloaded {
+		t.Error("Expected unchanged files not to be reloaded")
+	}
+
+	writePair(t, certFile, keyFile, "second", start.Add(time.Minute))
+	if reloaded, err := r.Reload(); !reloaded || err != nil {
+		t.Fatalf("Expected the renewed pair to be reloaded, got %v (%v)", reloaded, err)
+	}
+	if name := commonName(t, r); name != "second" {
+		t.Errorf("Expected the renewed certificate, got %q", name)
+	}
+}
+
+func TestReloader_KeepsCertificateOnBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writePair(t, certFile, keyFile, "good", time.Now().Add(-time.Hour))
+
+	r, err := New(certFile, keyFile, 60, slog.Default())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	os.WriteFile(keyFile, []byte("half-written"), 0o600)
+	if _, err := r.Reload(); err == nil {
+		t.Error("Expected a broken key to fail the reload")
+	}
+	if name := commonName(t, r); name != "good" {
+		t.Errorf("Expected the previous certificate to stay, got %q", name)
+	}
+}
+
+func TestConfig_ModernPolicy(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writePair(t, certFile, keyFile, "policy", time.Now())
+
+	r, err := New(certFile, keyFile, 60, slog.Default())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	config := r.Config()
+	if config.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Expected TLS 1.2 as the minimum, got %x", config.MinVersion)
+	}
+	for _, id := range config.CipherSuites {
+		for _, insecure := range tls.InsecureCipherSuites() {
+			if id == insecure.ID {
+				t.Errorf("Expected no insecure cipher suite, got %s", insecure.Name)
+			}
+		}
+	}
+}
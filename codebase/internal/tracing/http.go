@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/route"
+	"net/http"
+)
+
+// statusWriter remembers the response status for the server span
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(statusCode int) {
+	if sw.status == 0 {
+		sw.status = statusCode
+	}
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (e.g. to flush)
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// Middleware starts a server span for every request, continuing the caller's trace if it sent a
+// W3C traceparent header, and makes the tracer available to Start further down the call chain
+func Middleware(tracer *Tracer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parent, hasParent := ParseTraceparent(r.Header.Get("traceparent"))
+		if hasParent {
+			parent.TraceState = r.Header.Get("tracestate")
+		}
+		ctx, span := tracer.startSpan(r.Context(), r.Method, KindServer, parent, hasParent)
+		defer span.End()
+
+		sw := &statusWriter{ResponseWriter: w}
+		r = r.WithContext(ctx)
+		next.ServeHTTP(sw, r)
+
+		pattern := route.Pattern(r) // found through route.Track even if a middleware below copied r
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		span.SetName(r.Method + " " + pattern)
+		span.SetAttribute("http.request.method", r.Method)
+		span.SetAttribute("http.route", pattern)
+		span.SetAttribute("url.path", r.URL.Path)
+		span.SetAttribute("http.response.status_code", sw.status)
+		if sw.status >= 500 {
+			span.SetError(fmt.Errorf("HTTP %d", sw.status))
+		}
+	})
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// RoundTripper wraps next with a client span per outgoing request and propagates the trace
+// to the upstream through traceparent/tracestate headers
+// A nil next uses http.DefaultTransport
+func RoundTripper(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		ctx, span := Start(req.Context(), "HTTP "+req.Method, KindClient)
+		if span == nil {
+			return next.RoundTrip(req)
+		}
+		defer span.End()
+
+		// RoundTrippers must not modify the caller's request
+		req = req.Clone(ctx)
+		req.Header.Set("traceparent", span.Context().Traceparent())
+		if span.Context().TraceState != "" {
+			req.Header.Set("tracestate", span.Context().TraceState)
+		}
+		span.SetAttribute("http.request.method", req.Method)
+		span.SetAttribute("server.address", req.URL.Hostname())
+
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			span.SetError(err)
+			return nil, err
+		}
+		span.SetAttribute("http.response.status_code", resp.StatusCode)
+		if resp.StatusCode >= 500 {
+			span.SetError(fmt.Errorf("HTTP %d", resp.StatusCode))
+		}
+		return resp, nil
+	})
+}
@@ -0,0 +1,227 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span kinds as defined by OTLP
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// SpanContext identifies a span across process boundaries (W3C Trace Context)
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Sampled    bool
+	TraceState string // opaque vendor data, passed through unchanged
+}
+
+// ParseTraceparent parses a W3C traceparent header ("00-<trace id>-<span id>-<flags>")
+func ParseTraceparent(header string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil || sc.TraceID == [16]byte{} {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil || sc.SpanID == [8]byte{} {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, true
+}
+
+// Traceparent formats the span context as a W3C traceparent header
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%x-%x-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// Attribute is a span attribute; Value is a string, bool, int, int64 or float64
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Span is one timed operation of a trace
+// Methods on a nil Span do nothing, so code can trace unconditionally
+type Span struct {
+	tracer   *Tracer
+	context  SpanContext
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes []Attribute
+	errMessage string
+	ended      bool
+}
+
+// Context returns the span's context for propagation
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// SetName renames the span, e.g. once the route of a request is known
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+// SetAttribute records a key/value pair on the span
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attributes = append(s.attributes, Attribute{Key: key, Value: value})
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.errMessage = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and hands it to the exporter if it is sampled
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if s.context.Sampled {
+		s.tracer.exporter.enqueue(s)
+	}
+}
+
+// Tracer starts spans and sends sampled ones to its exporter
+type Tracer struct {
+	exporter   *Exporter
+	samplePct  int
+	randomPct  func() int
+	randomRead func(b []byte)
+}
+
+// NewTracer creates a Tracer sampling samplePct percent of new traces
+// Requests that arrive with a traceparent follow the caller's sampling decision instead
+func NewTracer(exporter *Exporter, samplePct int) *Tracer {
+	return &Tracer{
+		exporter:   exporter,
+		samplePct:  samplePct,
+		randomPct:  func() int { return mathrand.IntN(100) },
+		randomRead: func(b []byte) { rand.Read(b) },
+	}
+}
+
+type spanKey struct{}
+type tracerKey struct{}
+
+// WithTracer stores the tracer in ctx so Start can create spans further down the call chain
+func WithTracer(ctx context.Context, tracer *Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, tracer)
+}
+
+// SpanFromContext returns the active span, or nil if ctx isn't traced
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// WithParent returns ctx carrying the tracer and active span of parent, so spans started from it join
+// parent's trace, e.g. for work that outlives the request without sharing its cancellation
+func WithParent(ctx, parent context.Context) context.Context {
+	tracer, _ := parent.Value(tracerKey{}).(*Tracer)
+	if tracer == nil {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, tracerKey{}, tracer)
+	if span := SpanFromContext(parent); span != nil {
+		ctx = context.WithValue(ctx, spanKey{}, span)
+	}
+	return ctx
+}
+
+// TraceID returns the hex ID of the trace ctx belongs to, or "" if it isn't traced or not sampled
+// (an unsampled trace is never exported, so there is nothing to look up)
+func TraceID(ctx context.Context) string {
+	sc := SpanFromContext(ctx).Context()
+	if !sc.Sampled {
+		return ""
+	}
+	return hex.EncodeToString(sc.TraceID[:])
+}
+
+// Start starts a child of the active span in ctx
+// Without a tracer in ctx (tracing disabled) it returns ctx unchanged and a nil span
+func Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	tracer, _ := ctx.Value(tracerKey{}).(*Tracer)
+	if tracer == nil {
+		return ctx, nil
+	}
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return tracer.startSpan(ctx, name, kind, SpanContext{}, false)
+	}
+	return tracer.startSpan(ctx, name, kind, parent.context, true)
+}
+
+// startSpan starts a span with the given parent (a new trace if hasParent is false)
+func (t *Tracer) startSpan(ctx context.Context, name string, kind int, parent SpanContext, hasParent bool) (context.Context, *Span) {
+	span := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+	if hasParent {
+		span.context = parent
+		span.parentID = parent.SpanID
+	} else {
+		t.randomRead(span.context.TraceID[:])
+		span.context.Sampled = t.randomPct() < t.samplePct
+	}
+	t.randomRead(span.context.SpanID[:])
+
+	ctx = context.WithValue(ctx, tracerKey{}, t)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
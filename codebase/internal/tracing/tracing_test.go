@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if !ok || !sc.Sampled {
+		t.Fatalf("Expected a valid sampled traceparent, got %+v %v", sc, ok)
+	}
+	if sc.Traceparent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("Expected the header to round-trip, got %s", sc.Traceparent())
+	}
+
+	for _, header := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+	} {
+		if _, ok := ParseTraceparent(header); ok {
+			t.Errorf("Expected %q to be rejected", header)
+		}
+	}
+}
+
+func TestTracing_PropagatesAndExports(t *testing.T) {
+	var received []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("traceparent"))
+	}))
+	defer upstream.Close()
+
+	var exported otlpRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("Expected export to /v1/traces, got %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&exported)
+	}))
+	defer collector.Close()
+
+	exporter := NewExporter(collector.URL, "weather-test", nil, 60, slog.Default())
+	tracer := NewTracer(exporter, 0) // only continued traces are sampled
+	client := &http.Client{Transport: RoundTripper(nil)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/weather", func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := Start(r.Context(), "cache.GetWeather", KindInternal)
+		defer span.End()
+		req, _ := http.NewRequestWithContext(ctx, "GET", upstream.URL, nil)
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+	})
+	handler := Middleware(tracer, mux)
+
+	req := httptest.NewRequest("GET", "/weather", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(received) != 1 || !strings.HasPrefix(received[0], "00-4bf92f3577b34da6a3ce929d0e0e4736-") || strings.Contains(received[0], "00f067aa0ba902b7") {
+		t.Errorf("Expected the upstream call to carry the trace with a new span ID, got %v", received)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		exporter.Run(ctx)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	if len(exported.ResourceSpans) != 1 {
+		t.Fatalf("Expected one export, got %+v", exported)
+	}
+	spans := exported.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 3 {
+		t.Fatalf("Expected server, internal and client spans, got %d", len(spans))
+	}
+	names := map[string]otlpSpan{}
+	for _, span := range spans {
+		names[span.Name] = span
+		if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("Expected every span in the caller's trace, got %s", span.TraceID)
+		}
+	}
+	if names["GET /weather"].ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected the server span to be a child of the caller's span, got %+v", names["GET /weather"])
+	}
+	if names["HTTP GET"].ParentSpanID != names["cache.GetWeather"].SpanID {
+		t.Error("Expected the client span to be a child of the internal span")
+	}
+}
+
+func TestTracing_Disabled(t *testing.T) {
+	ctx, span := Start(context.Background(), "noop", KindInternal)
+	span.SetAttribute("key", "value")
+	span.End()
+	if span != nil || ctx != context.Background() {
+		t.Error("Expected no span without a tracer in the context")
+	}
+}
@@ -1,14 +1,155 @@
 package utils
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 )
 
+// Values from other sources layered around the environment: overrides (e.g. command-line flags) win
+// over it, fallbacks (e.g. a config file) only apply where it is unset and profileDefaults replace the
+// built-in defaults of the variables they set
+// defaults remembers what each variable read so far falls back to, e.g. for --help, and invalid
+// the variables whose value could not be parsed the last time they were read
+var (
+	sourcesMu sync.RWMutex
+	overrides map[string]string
+	fallbacks map[string]string
+	profile   map[string]string
+	defaults  = make(map[string]string)
+	invalid   = make(map[string]error)
+)
+
+// SetOverrides makes values take precedence over the environment
+func SetOverrides(values map[string]string) {
+	sourcesMu.Lock()
+	overrides = values
+	sourcesMu.Unlock()
+}
+
+// SetFallbacks makes values apply to variables the environment leaves unset
+func SetFallbacks(values map[string]string) {
+	sourcesMu.Lock()
+	fallbacks = values
+	sourcesMu.Unlock()
+}
+
+// SetProfileDefaults makes values the defaults of the variables they set, below every other source
+func SetProfileDefaults(values map[string]string) {
+	sourcesMu.Lock()
+	profile = values
+	sourcesMu.Unlock()
+}
+
+// Default returns the default a variable fell back to the last time it was read
+func Default(envName string) (string, bool) {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	value, ok := defaults[envName]
+	return value, ok
+}
+
+// Variables returns the names of the variables read so far, in name order
+func Variables() []string {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InvalidValues returns a problem for every variable read whose value could not be parsed, in name order,
+// since the getters fall back to the default for those rather than fail
+func InvalidValues() []error {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+
+	names := make([]string, 0, len(invalid))
+	for name := range invalid {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	problems := make([]error, len(names))
+	for i, name := range names {
+		problems[i] = invalid[name]
+	}
+	return problems
+}
+
+// setInvalid records (or, with a nil err, clears) a parse problem of a variable
+func setInvalid(envName string, err error) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	if err == nil {
+		delete(invalid, envName)
+		return
+	}
+	invalid[envName] = err
+}
+
+// lookupEnv returns the value of a variable from the first source that sets it and records defValue as its default
+func lookupEnv(envName string, defValue string) string {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+
+	defaults[envName] = defValue
+	if value, ok := profile[envName]; ok {
+		defaults[envName] = value
+	}
+	if value, ok := overrides[envName]; ok {
+		return value
+	}
+	if value := os.Getenv(envName); value != "" {
+		return value
+	}
+	if value := fallbacks[envName]; value != "" {
+		return value
+	}
+	return profile[envName]
+}
+
+// ReadEnvFile reads NAME=VALUE lines; blank lines and lines starting with # are ignored, and values may be quoted
+func ReadEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if name = strings.TrimSpace(name); !ok || name == "" {
+			return nil, fmt.Errorf("%s line %d: expected NAME=VALUE", path, lineNo)
+		}
+		value = strings.TrimSpace(value)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		}
+		values[name] = value
+	}
+	return values, scanner.Err()
+}
+
 // GetEnvAsStrWithDefault retrieves environment variable as string, returns default value if not found
 func GetEnvAsStrWithDefault(envName string, defValue string) string {
-	envVal := os.Getenv(envName)
+	envVal := lookupEnv(envName, defValue)
 	if envVal == "" {
 		return defValue
 	}
@@ -17,22 +158,60 @@ func GetEnvAsStrWithDefault(envName string, defValue string) string {
 
 // GetEnvAsMustStr retrieves required environment variable as string, returns error if not found
 func GetEnvAsMustStr(envName string, errMsg string) (string, error) {
-	envVal := os.Getenv(envName)
+	envVal := lookupEnv(envName, "")
 	if envVal == "" {
-		return "", fmt.Errorf(errMsg)
+		return "", errors.New(errMsg)
 	}
 	return envVal, nil
 }
 
 // GetEnvAsIntWithDefault retrieves environment variable as integer, returns default value if not found or invalid
+// (invalid values are reported by InvalidValues)
 func GetEnvAsIntWithDefault(envName string, defValue int) int {
-	envVal := os.Getenv(envName)
+	envVal := lookupEnv(envName, strconv.Itoa(defValue))
 	if envVal == "" {
+		setInvalid(envName, nil)
 		return defValue
 	}
 	envValAsInt, err := strconv.Atoi(envVal)
 	if err != nil {
+		setInvalid(envName, fmt.Errorf("%s must be an integer, got %q", envName, envVal))
 		return defValue
 	}
+	setInvalid(envName, nil)
 	return envValAsInt
 }
+
+// GetEnvAsListWithDefault retrieves a comma-separated environment variable as a list of trimmed,
+// non-empty values, returns default value if not found
+func GetEnvAsListWithDefault(envName string, defValue []string) []string {
+	envVal := lookupEnv(envName, strings.Join(defValue, ","))
+	if envVal == "" {
+		return defValue
+	}
+
+	var values []string
+	for _, value := range strings.Split(envVal, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// GetEnvAsBoolWithDefault retrieves environment variable as boolean, returns default value if not found or invalid
+// (invalid values are reported by InvalidValues)
+func GetEnvAsBoolWithDefault(envName string, defValue bool) bool {
+	envVal := lookupEnv(envName, strconv.FormatBool(defValue))
+	if envVal == "" {
+		setInvalid(envName, nil)
+		return defValue
+	}
+	envValAsBool, err := strconv.ParseBool(envVal)
+	if err != nil {
+		setInvalid(envName, fmt.Errorf("%s must be true or false, got %q", envName, envVal))
+		return defValue
+	}
+	setInvalid(envName, nil)
+	return envValAsBool
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestGetEnv_SourcePrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.env")
+	os.WriteFile(path, []byte("# settings\nTEST_PORT=7001\nTEST_LEVEL=\"debug\"\nexport TEST_PROFILE='staging'\n"), 0o600)
+	values, err := ReadEnvFile(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	SetFallbacks(values)
+	defer SetFallbacks(nil)
+	SetOverrides(map[string]string{"TEST_PORT": "7003"})
+	defer SetOverrides(nil)
+	t.Setenv("TEST_PORT", "7002")
+	t.Setenv("TEST_PROFILE", "dev")
+
+	if port := GetEnvAsIntWithDefault("TEST_PORT", 8080); port != 7003 {
+		t.Errorf("Expected the override to win, got %d", port)
+	}
+	if profile := GetEnvAsStrWithDefault("TEST_PROFILE", "production"); profile != "dev" {
+		t.Errorf("Expected the environment to beat the file, got %q", profile)
+	}
+	if level := GetEnvAsStrWithDefault("TEST_LEVEL", "info"); level != "debug" {
+		t.Errorf("Expected the file value, got %q", level)
+	}
+	if format := GetEnvAsStrWithDefault("TEST_FORMAT", "text"); format != "text" {
+		t.Errorf("Expected the default, got %q", format)
+	}
+	if def, _ := Default("TEST_PORT"); def != "8080" {
+		t.Errorf("Expected the default to be recorded, got %q", def)
+	}
+	if names := Variables(); !slices.Contains(names, "TEST_LEVEL") || !slices.IsSorted(names) {
+		t.Errorf("Expected every variable read in name order, got %v", names)
+	}
+}
+
+func TestGetEnv_ProfileDefaults(t *testing.T) {
+	SetProfileDefaults(map[string]string{"TEST_FORMAT": "json", "TEST_LEVEL": "debug"})
+	defer SetProfileDefaults(nil)
+	SetFallbacks(map[string]string{"TEST_LEVEL": "warn"})
+	defer SetFallbacks(nil)
+
+	if format := GetEnvAsStrWithDefault("TEST_FORMAT", "text"); format != "json" {
+		t.Errorf("Expected the profile default, got %q", format)
+	}
+	if def, _ := Default("TEST_FORMAT"); def != "json" {
+		t.Errorf("Expected the profile default to be reported, got %q", def)
+	}
+	if level := GetEnvAsStrWithDefault("TEST_LEVEL", "info"); level != "warn" {
+		t.Errorf("Expected the config file to beat the profile, got %q", level)
+	}
+	t.Setenv("TEST_FORMAT", "text")
+	if format := GetEnvAsStrWithDefault("TEST_FORMAT", "text"); format != "text" {
+		t.Errorf("Expected the environment to beat the profile, got %q", format)
+	}
+}
+
+func TestReadEnvFile_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.env")
+	os.WriteFile(path, []byte("NOT A SETTING\n"), 0o600)
+	if _, err := ReadEnvFile(path); err == nil {
+		t.Error("Expected a line without = to be rejected")
+	}
+}
+
+func TestGetEnv_InvalidValuesAreReported(t *testing.T) {
+	t.Setenv("TEST_TIMEOUT", "ten")
+	t.Setenv("TEST_ENABLED", "yes please")
+
+	if timeout := GetEnvAsIntWithDefault("TEST_TIMEOUT", 10); timeout != 10 {
+		t.Errorf("Expected the default for an invalid value, got %d", timeout)
+	}
+	GetEnvAsBoolWithDefault("TEST_ENABLED", false)
+	if problems := InvalidValues(); len(problems) != 2 {
+		t.Errorf("Expected 2 problems, got %v", problems)
+	}
+
+	t.Setenv("TEST_TIMEOUT", "5")
+	t.Setenv("TEST_ENABLED", "true")
+	GetEnvAsIntWithDefault("TEST_TIMEOUT", 10)
+	GetEnvAsBoolWithDefault("TEST_ENABLED", false)
+	if problems := InvalidValues(); len(problems) != 0 {
+		t.Errorf("Expected fixed values to clear the problems, got %v", problems)
+	}
+}
@@ -0,0 +1,262 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/metrics"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// retryBackoff is the wait before the first retry of a failed delivery; it doubles with every further attempt
+const retryBackoff = 5 * time.Second
+
+// maxRetryBackoff caps the wait between attempts
+const maxRetryBackoff = 5 * time.Minute
+
+// Event is the JSON payload posted to a subscription's URL
+type Event struct {
+	ID             string               `json:"id"` // the same for every attempt, so receivers can drop duplicates
+	Type           string               `json:"type"`
+	SubscriptionID string               `json:"subscription_id"`
+	Lat            float64              `json:"lat"`
+	Lon            float64              `json:"lon"`
+	Previous       State                `json:"previous"`
+	Current        *service.WeatherData `json:"current"`
+	OccurredAt     time.Time            `json:"occurred_at"`
+}
+
+// delivery is an event on its way to a subscriber
+type delivery struct {
+	url      string
+	secret   string
+	eventID  string
+	body     []byte
+	attempts int
+}
+
+// Dispatcher posts events to subscribers from a bounded queue, retrying failed deliveries with exponential
+// backoff so a subscriber that is down for a few minutes doesn't miss them
+type Dispatcher struct {
+	client      *http.Client
+	queue       chan *delivery
+	workers     int
+	maxAttempts int
+	backoff     time.Duration // before the first retry
+	deliveries  *metrics.CounterVec
+	logger      *slog.Logger
+}
+
+// NewDispatcher creates a Dispatcher posting with client from workers goroutines, queueing up to queueSize
+// events and making up to maxAttempts attempts at each
+func NewDispatcher(client *http.Client, queueSize, workers, maxAttempts int, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		client:      client,
+		queue:       make(chan *delivery, queueSize),
+		workers:     workers,
+		maxAttempts: maxAttempts,
+		backoff:     retryBackoff,
+		logger:      logger,
+	}
+}
+
+// UseMetrics records delivery attempts in registry by result: delivered, retried, failed or dropped
+func (d *Dispatcher) UseMetrics(registry *metrics.Registry) {
+	d.deliveries = registry.NewCounterVec("webhook_deliveries_total", "Webhook delivery attempts, by result (delivered, retried, failed or dropped).", "result")
+	registry.NewGaugeFunc("webhook_queue_length", "Webhook deliveries waiting to be sent.", func() float64 {
+		return float64(len(d.queue))
+	})
+}
+
+// Enqueue queues event for delivery to sub, reporting false if the queue is full
+func (d *Dispatcher) Enqueue(sub Subscription, event Event) bool {
+	body, err := json.Marshal(event)
+	if err != nil {
+		d.logger.Error("webhook event encoding failed", slog.String("error", err.Error()))
+		return false
+	}
+	return d.push(&delivery{url: sub.URL, secret: sub.Secret, eventID: event.ID, body: body})
+}
+
+func (d *Dispatcher) push(del *delivery) bool {
+	select {
+	case d.queue <- del:
+		return true
+	default:
+		d.deliveries.Inc("dropped")
+		d.logger.Warn("webhook queue full, dropping event", slog.String("event_id", del.eventID))
+		return false
+	}
+}
+
+// Run delivers queued events until ctx is canceled; events still queued then are dropped
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range d.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case del := <-d.queue:
+					d.deliver(ctx, del)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// deliver makes one attempt and schedules the next one if it failed
+func (d *Dispatcher) deliver(ctx context.Context, del *delivery) {
+	del.attempts++
+	err := d.post(ctx, del)
+	if err == nil {
+		d.deliveries.Inc("delivered")
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	var permanent *permanentError
+	if errors.As(err, &permanent) || del.attempts >= d.maxAttempts {
+		d.deliveries.Inc("failed")
+		d.logger.Warn("webhook delivery failed", slog.String("event_id", del.eventID), slog.String("url", redact(del.url)), slog.Int("attempts", del.attempts), slog.String("error", err.Error()))
+		return
+	}
+	d.deliveries.Inc("retried")
+	backoff := min(d.backoff<<(del.attempts-1), maxRetryBackoff)
+	// Jitter keeps retries to a subscriber that just came back from arriving all at once
+	backoff += rand.N(backoff / 4)
+	time.AfterFunc(backoff, func() { d.push(del) })
+}
+
+// permanentError is a failure retrying won't fix, e.g. the subscriber rejecting the delivery
+type permanentError struct{ error }
+
+// post sends the delivery once, signed with the subscription's secret
+func (d *Dispatcher) post(ctx context.Context, del *delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, del.url, bytes.NewReader(del.body))
+	if err != nil {
+		return &permanentError{err}
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "weather-api-webhooks")
+	req.Header.Set("X-Webhook-ID", del.eventID)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(del.secret, timestamp, del.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("status %d", resp.StatusCode)
+	default:
+		return &permanentError{fmt.Errorf("rejected with status %d", resp.StatusCode)}
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 of timestamp, a dot and body with secret, which subscribers recompute to
+// check a delivery came from us; signing the timestamp lets them reject replays of old deliveries
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewClient returns the HTTP client for deliveries, with a timeout of timeoutSec seconds per attempt
+// Subscribers are picked by API clients, so unless allowPrivate is set it refuses to connect to loopback,
+// private and link-local addresses, which would let them reach internal services through us; it doesn't
+// follow redirects for the same reason
+func NewClient(timeoutSec int, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: time.Duration(timeoutSec) * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+				return fmt.Errorf("webhook address %s is not public", host)
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout:   time.Duration(timeoutSec) * time.Second,
+		Transport: &http.Transport{DialContext: dialer.DialContext, MaxIdleConnsPerHost: 2, IdleConnTimeout: 90 * time.Second},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// ValidateURL checks that raw can be a subscriber URL: absolute http or https, and not an IP address
+// NewClient would refuse unless allowPrivate is set; host names are checked when connecting
+func ValidateURL(raw string, allowPrivate bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if u.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !allowPrivate && !isPublic(ip) {
+		return errors.New("url must not point to a private address")
+	}
+	return nil
+}
+
+// nonPublicNets are the ranges isPublic refuses that net.IP has no method for: carrier-grade NAT, which
+// some clouds use for internal services, and "this network"
+var nonPublicNets = []*net.IPNet{
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+	{IP: net.IPv4(0, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+}
+
+// isPublic reports whether ip is routable on the internet
+func isPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	return !slices.ContainsFunc(nonPublicNets, func(n *net.IPNet) bool { return n.Contains(ip) })
+}
+
+// redact drops the query of a subscriber URL from logs, where tokens tend to be
+func redact(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "invalid"
+	}
+	u.RawQuery = ""
+	return u.Redacted()
+}
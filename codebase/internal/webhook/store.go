@@ -0,0 +1,203 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTooManySubscriptions is returned by Add once the store holds its maximum number of subscriptions
+var ErrTooManySubscriptions = errors.New("too many webhook subscriptions")
+
+// ErrTooManyClientSubscriptions is returned by Add once the client holds its maximum number of subscriptions
+var ErrTooManyClientSubscriptions = errors.New("too many webhook subscriptions from this client")
+
+// State is what a subscription watches at its location
+type State struct {
+	Condition           string `json:"condition"`
+	TemperatureCategory string `json:"temperature_category"`
+}
+
+// Subscription asks for the weather changes at a location to be posted to URL
+// With Conditions or TemperatureCategories set, only changes of those fields to one of the listed values are
+// sent, e.g. TemperatureCategories ["hot"] for the category flipping to hot; without, every change is sent
+type Subscription struct {
+	ID                    string    `json:"id"`
+	URL                   string    `json:"url"`
+	Lat                   float64   `json:"lat"`
+	Lon                   float64   `json:"lon"`
+	Conditions            []string  `json:"conditions,omitempty"`
+	TemperatureCategories []string  `json:"temperature_categories,omitempty"`
+	Secret                string    `json:"secret,omitempty"` // signs the deliveries and authorizes managing the subscription
+	Client                string    `json:"client,omitempty"` // address of the client that subscribed, for its cap
+	CreatedAt             time.Time `json:"created_at"`
+}
+
+// Matches reports whether the change from previous to current is one the subscription asked for
+func (s *Subscription) Matches(previous, current State) bool {
+	if len(s.Conditions) == 0 && len(s.TemperatureCategories) == 0 {
+		return previous != current
+	}
+	return changedTo(previous.Condition, current.Condition, s.Conditions) ||
+		changedTo(previous.TemperatureCategory, current.TemperatureCategory, s.TemperatureCategories)
+}
+
+// changedTo reports whether a field changed from previous to one of values
+func changedTo(previous, current string, values []string) bool {
+	return !strings.EqualFold(previous, current) && slices.ContainsFunc(values, func(value string) bool {
+		return strings.EqualFold(value, current)
+	})
+}
+
+// Store holds the subscriptions, saving them to a file if it has one so they survive restarts
+type Store struct {
+	mu           sync.Mutex
+	path         string // empty to keep the subscriptions in memory only
+	max          int
+	maxPerClient int
+	subs         map[string]Subscription
+}
+
+// NewStore creates a Store for at most max subscriptions, and at most maxPerClient from any one client, loading
+// those saved at path if it exists
+// An empty path keeps the subscriptions in memory only
+func NewStore(path string, max, maxPerClient int) (*Store, error) {
+	s := &Store{path: path, max: max, maxPerClient: maxPerClient, subs: make(map[string]Subscription)}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook subscriptions: %w", err)
+	}
+	var subs []Subscription
+	if err := json.Unmarshal(data, &subs); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook subscriptions: %w", err)
+	}
+	for _, sub := range subs {
+		s.subs[sub.ID] = sub
+	}
+	return s, nil
+}
+
+// Add stores sub under a new ID with a new secret and returns it
+func (s *Store) Add(sub Subscription) (Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.subs) >= s.max {
+		return Subscription{}, ErrTooManySubscriptions
+	}
+	if s.clientSubscriptions(sub.Client) >= s.maxPerClient {
+		return Subscription{}, ErrTooManyClientSubscriptions
+	}
+	sub.ID = randomHex(16)
+	sub.Secret = "whsec_" + randomHex(32)
+	sub.CreatedAt = time.Now().UTC()
+	s.subs[sub.ID] = sub
+	if err := s.save(); err != nil {
+		delete(s.subs, sub.ID)
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+// clientSubscriptions counts the subscriptions of client; s.mu must be held
+func (s *Store) clientSubscriptions(client string) int {
+	n := 0
+	for _, sub := range s.subs {
+		if sub.Client == client {
+			n++
+		}
+	}
+	return n
+}
+
+// Get returns the subscription with id
+func (s *Store) Get(id string) (Subscription, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[id]
+	return sub, ok
+}
+
+// Delete removes the subscription with id, reporting whether there was one
+func (s *Store) Delete(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subs[id]
+	if !ok {
+		return false, nil
+	}
+	delete(s.subs, id)
+	if err := s.save(); err != nil {
+		s.subs[id] = sub
+		return false, err
+	}
+	return true, nil
+}
+
+// All returns every subscription, oldest first
+func (s *Store) All() []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sorted()
+}
+
+func (s *Store) sorted() []Subscription {
+	subs := make([]Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	slices.SortFunc(subs, func(a, b Subscription) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return subs
+}
+
+// save writes the subscriptions to the file, replacing it in one step so a crash can't leave half of it
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(s.sorted())
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook subscriptions: %w", err)
+	}
+
+	// CreateTemp makes the file readable by us only, which matters as it holds the secrets
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to save webhook subscriptions: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save webhook subscriptions: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save webhook subscriptions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to save webhook subscriptions: %w", err)
+	}
+	return nil
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
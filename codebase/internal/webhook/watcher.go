@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/krizvi/weather-app-server/internal/service"
+)
+
+// Watcher looks up the weather at every subscribed location on an interval and queues an event for each
+// subscription whose location changed the way it asked for
+type Watcher struct {
+	store      *Store
+	weather    service.WeatherService
+	dispatcher *Dispatcher
+	interval   time.Duration
+	timeout    time.Duration
+	last       map[string]State // by location, as of the previous check
+	logger     *slog.Logger
+}
+
+// NewWatcher creates a Watcher checking every intervalSec seconds through weather, typically the cached
+// service so subscriptions cost provider calls only as often as the cache refreshes; each lookup gets
+// timeoutSec seconds
+func NewWatcher(store *Store, weather service.WeatherService, dispatcher *Dispatcher, intervalSec, timeoutSec int, logger *slog.Logger) *Watcher {
+	return &Watcher{
+		store:      store,
+		weather:    weather,
+		dispatcher: dispatcher,
+		interval:   time.Duration(intervalSec) * time.Second,
+		timeout:    time.Duration(timeoutSec) * time.Second,
+		last:       make(map[string]State),
+		logger:     logger,
+	}
+}
+
+// Run checks every interval until ctx is canceled
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.check(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check looks every subscribed location up once and queues the events
+// The first lookup of a location only records its state, as there is nothing to compare it to
+func (w *Watcher) check(ctx context.Context) {
+	byLocation := make(map[string][]Subscription)
+	for _, sub := range w.store.All() {
+		key := locationKey(sub.Lat, sub.Lon)
+		byLocation[key] = append(byLocation[key], sub)
+	}
+	for key := range w.last {
+		if byLocation[key] == nil {
+			delete(w.last, key)
+		}
+	}
+
+	for key, subs := range byLocation {
+		if ctx.Err() != nil {
+			return
+		}
+		lookupCtx, cancel := context.WithTimeout(ctx, w.timeout)
+		data, err := w.weather.GetWeather(lookupCtx, subs[0].Lat, subs[0].Lon)
+		cancel()
+		if err != nil {
+			w.logger.Warn("webhook lookup failed", slog.String("location", key), slog.String("error", err.Error()))
+			continue
+		}
+		if data.Static {
+			continue // a placeholder served during an outage, not a change of the weather
+		}
+
+		current := State{Condition: data.Condition, TemperatureCategory: data.TemperatureCategory}
+		previous, seen := w.last[key]
+		w.last[key] = current
+		if !seen || previous == current {
+			continue
+		}
+		for _, sub := range subs {
+			if !sub.Matches(previous, current) {
+				continue
+			}
+			w.dispatcher.Enqueue(sub, Event{
+				ID:             randomHex(16),
+				Type:           "weather.changed",
+				SubscriptionID: sub.ID,
+				Lat:            sub.Lat,
+				Lon:            sub.Lon,
+				Previous:       previous,
+				Current:        data,
+				OccurredAt:     time.Now().UTC(),
+			})
+		}
+	}
+}
+
+// locationKey groups subscriptions to the same location so it is looked up once
+func locationKey(lat, lon float64) string {
+	return fmt.Sprintf("%g,%g", lat, lon)
+}
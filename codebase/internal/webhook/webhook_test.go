@@ -0,0 +1,180 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSubscription_Matches(t *testing.T) {
+	moderate := State{Condition: "Clear", TemperatureCategory: "moderate"}
+	hot := State{Condition: "Clear", TemperatureCategory: "hot"}
+	rainy := State{Condition: "Rain", TemperatureCategory: "moderate"}
+
+	tests := []struct {
+		name     string
+		sub      Subscription
+		previous State
+		current  State
+		want     bool
+	}{
+		{"any change", Subscription{}, moderate, rainy, true},
+		{"no change", Subscription{}, moderate, moderate, false},
+		{"category flips to hot", Subscription{TemperatureCategories: []string{"hot"}}, moderate, hot, true},
+		{"category flips back", Subscription{TemperatureCategories: []string{"hot"}}, hot, moderate, false},
+		{"other field changed", Subscription{TemperatureCategories: []string{"hot"}}, moderate, rainy, false},
+		{"condition ignores case", Subscription{Conditions: []string{"rain"}}, moderate, rainy, true},
+	}
+	for _, tt := range tests {
+		if got := tt.sub.Matches(tt.previous, tt.current); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestStore_PersistsSubscriptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	store, err := NewStore(path, 2, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	sub, err := store.Add(Subscription{URL: "https://example.com/hook", Lat: 40.7, Lon: -74})
+	if err != nil || sub.ID == "" || sub.Secret == "" {
+		t.Fatalf("Expected an ID and a secret, got %+v %v", sub, err)
+	}
+	store.Add(Subscription{URL: "https://example.com/other"})
+	if _, err := store.Add(Subscription{URL: "https://example.com/third"}); !errors.Is(err, ErrTooManySubscriptions) {
+		t.Errorf("Expected ErrTooManySubscriptions, got %v", err)
+	}
+
+	reloaded, err := NewStore(path, 2, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got, ok := reloaded.Get(sub.ID); !ok || got.Secret != sub.Secret {
+		t.Errorf("Expected the subscription to survive a restart, got %+v", got)
+	}
+	if deleted, _ := reloaded.Delete(sub.ID); !deleted {
+		t.Error("Expected the subscription to be deleted")
+	}
+	if len(reloaded.All()) != 1 {
+		t.Errorf("Expected 1 subscription left, got %d", len(reloaded.All()))
+	}
+}
+
+func TestStore_CapsSubscriptionsPerClient(t *testing.T) {
+	store, _ := NewStore("", 10, 2)
+	for i := 0; i < 2; i++ {
+		if _, err := store.Add(Subscription{URL: "https://example.com/hook", Client: "203.0.113.7"}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if _, err := store.Add(Subscription{URL: "https://example.com/hook", Client: "203.0.113.7"}); !errors.Is(err, ErrTooManyClientSubscriptions) {
+		t.Errorf("Expected ErrTooManyClientSubscriptions, got %v", err)
+	}
+	if _, err := store.Add(Subscription{URL: "https://example.com/hook", Client: "198.51.100.1"}); err != nil {
+		t.Errorf("Expected other clients to still subscribe, got %v", err)
+	}
+}
+
+func TestDispatcher_RetriesAndSigns(t *testing.T) {
+	var attempts atomic.Int32
+	received := make(chan *http.Request, 1)
+	var body []byte
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ = io.ReadAll(r.Body)
+		received <- r
+	}))
+	defer subscriber.Close()
+
+	d := NewDispatcher(NewClient(5, true), 10, 1, 3, slog.Default())
+	d.backoff = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	sub := Subscription{ID: "sub", URL: subscriber.URL, Secret: "whsec_test"}
+	d.Enqueue(sub, Event{ID: "evt", Type: "weather.changed", SubscriptionID: "sub", Current: &service.WeatherData{Condition: "Rain"}})
+
+	select {
+	case r := <-received:
+		timestamp := r.Header.Get("X-Webhook-Timestamp")
+		if want := "sha256=" + Sign("whsec_test", timestamp, body); r.Header.Get("X-Webhook-Signature") != want {
+			t.Errorf("Expected signature %s, got %s", want, r.Header.Get("X-Webhook-Signature"))
+		}
+		var event Event
+		if err := json.Unmarshal(body, &event); err != nil || event.ID != "evt" || event.Current.Condition != "Rain" {
+			t.Errorf("Unexpected payload %s", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the delivery to be retried")
+	}
+}
+
+func TestNewClient_RefusesPrivateAddresses(t *testing.T) {
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer subscriber.Close()
+
+	if _, err := NewClient(5, false).Post(subscriber.URL, "application/json", nil); err == nil {
+		t.Error("Expected loopback to be refused")
+	}
+	if err := ValidateURL("http://169.254.169.254/latest", false); err == nil {
+		t.Error("Expected a link-local URL to be rejected")
+	}
+	for _, raw := range []string{"http://100.100.100.200/latest", "http://0.1.2.3/"} {
+		if err := ValidateURL(raw, false); err == nil {
+			t.Errorf("Expected %s to be rejected", raw)
+		}
+	}
+	if err := ValidateURL("ftp://example.com", false); err == nil {
+		t.Error("Expected a non-http URL to be rejected")
+	}
+	if err := ValidateURL("https://example.com/hook", false); err != nil {
+		t.Errorf("Expected a public URL to be accepted, got %v", err)
+	}
+}
+
+// stubWeather answers with the condition it is set to
+type stubWeather struct{ condition atomic.Value }
+
+func (s *stubWeather) GetWeather(ctx context.Context, lat, lon float64) (*service.WeatherData, error) {
+	return &service.WeatherData{Condition: s.condition.Load().(string), TemperatureCategory: "moderate"}, nil
+}
+
+func TestWatcher_QueuesMatchingChanges(t *testing.T) {
+	store, _ := NewStore("", 10, 10)
+	store.Add(Subscription{URL: "https://example.com/rain", Lat: 1, Lon: 2, Conditions: []string{"Rain"}})
+	store.Add(Subscription{URL: "https://example.com/snow", Lat: 1, Lon: 2, Conditions: []string{"Snow"}})
+	weather := &stubWeather{}
+	weather.condition.Store("Clear")
+	d := NewDispatcher(NewClient(5, false), 10, 1, 1, slog.Default())
+	w := NewWatcher(store, weather, d, 60, 5, slog.Default())
+
+	w.check(context.Background())
+	if len(d.queue) != 0 {
+		t.Errorf("Expected the first check to only record the state, got %d events", len(d.queue))
+	}
+	weather.condition.Store("Rain")
+	w.check(context.Background())
+	if len(d.queue) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(d.queue))
+	}
+	var event Event
+	json.Unmarshal((<-d.queue).body, &event)
+	if event.Previous.Condition != "Clear" || event.Current.Condition != "Rain" {
+		t.Errorf("Expected Clear to Rain, got %+v", event)
+	}
+}
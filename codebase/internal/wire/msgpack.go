@@ -0,0 +1,103 @@
+package wire
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// MsgPack builds a MessagePack document value by value
+// Maps and arrays are written as a header with the number of entries followed by the entries
+type MsgPack struct {
+	buf []byte
+}
+
+// Bytes returns the encoded document
+func (m *MsgPack) Bytes() []byte {
+	return m.buf
+}
+
+// Nil adds nil
+func (m *MsgPack) Nil() {
+	m.buf = append(m.buf, 0xc0)
+}
+
+// Bool adds a boolean
+func (m *MsgPack) Bool(value bool) {
+	if value {
+		m.buf = append(m.buf, 0xc3)
+	} else {
+		m.buf = append(m.buf, 0xc2)
+	}
+}
+
+// Int adds an integer in the shortest of the fixint and int64 forms
+func (m *MsgPack) Int(value int64) {
+	switch {
+	case value >= 0 && value <= 0x7f:
+		m.buf = append(m.buf, byte(value))
+	case value < 0 && value >= -32:
+		m.buf = append(m.buf, byte(int8(value)))
+	default:
+		m.buf = append(m.buf, 0xd3)
+		m.buf = binary.BigEndian.AppendUint64(m.buf, uint64(value))
+	}
+}
+
+// Float adds a 64-bit float
+func (m *MsgPack) Float(value float64) {
+	m.buf = append(m.buf, 0xcb)
+	m.buf = binary.BigEndian.AppendUint64(m.buf, math.Float64bits(value))
+}
+
+// String adds a string
+func (m *MsgPack) String(value string) {
+	switch n := len(value); {
+	case n < 32:
+		m.buf = append(m.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		m.buf = append(m.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		m.buf = append(m.buf, 0xda)
+		m.buf = binary.BigEndian.AppendUint16(m.buf, uint16(n))
+	default:
+		m.buf = append(m.buf, 0xdb)
+		m.buf = binary.BigEndian.AppendUint32(m.buf, uint32(n))
+	}
+	m.buf = append(m.buf, value...)
+}
+
+// Timestamp adds a time with the timestamp extension type (-1) in its 96-bit form
+func (m *MsgPack) Timestamp(value time.Time) {
+	m.buf = append(m.buf, 0xc7, 12, 0xff)
+	m.buf = binary.BigEndian.AppendUint32(m.buf, uint32(value.Nanosecond()))
+	m.buf = binary.BigEndian.AppendUint64(m.buf, uint64(value.Unix()))
+}
+
+// Array starts an array of n values
+func (m *MsgPack) Array(n int) {
+	switch {
+	case n < 16:
+		m.buf = append(m.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		m.buf = append(m.buf, 0xdc)
+		m.buf = binary.BigEndian.AppendUint16(m.buf, uint16(n))
+	default:
+		m.buf = append(m.buf, 0xdd)
+		m.buf = binary.BigEndian.AppendUint32(m.buf, uint32(n))
+	}
+}
+
+// Map starts a map of n key/value pairs
+func (m *MsgPack) Map(n int) {
+	switch {
+	case n < 16:
+		m.buf = append(m.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		m.buf = append(m.buf, 0xde)
+		m.buf = binary.BigEndian.AppendUint16(m.buf, uint16(n))
+	default:
+		m.buf = append(m.buf, 0xdf)
+		m.buf = binary.BigEndian.AppendUint32(m.buf, uint32(n))
+	}
+}
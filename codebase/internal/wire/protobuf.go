@@ -0,0 +1,88 @@
+// Package wire encodes responses in binary formats (protobuf and MessagePack) without code generation
+// The message layouts are defined in weather.proto; keep the two in sync
+package wire
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// Proto builds a protobuf message field by field
+// Like proto3, it leaves out fields holding their zero value
+type Proto struct {
+	buf []byte
+}
+
+// Bytes returns the encoded message
+func (p *Proto) Bytes() []byte {
+	return p.buf
+}
+
+func (p *Proto) tag(field int, wireType int) {
+	p.buf = binary.AppendUvarint(p.buf, uint64(field)<<3|uint64(wireType))
+}
+
+// String adds a string field
+func (p *Proto) String(field int, value string) {
+	if value == "" {
+		return
+	}
+	p.tag(field, wireBytes)
+	p.buf = binary.AppendUvarint(p.buf, uint64(len(value)))
+	p.buf = append(p.buf, value...)
+}
+
+// Bool adds a bool field
+func (p *Proto) Bool(field int, value bool) {
+	if !value {
+		return
+	}
+	p.tag(field, wireVarint)
+	p.buf = append(p.buf, 1)
+}
+
+// Int64 adds an int64 (or int32) field
+// Negative values take ten bytes, as in every protobuf implementation
+func (p *Proto) Int64(field int, value int64) {
+	if value == 0 {
+		return
+	}
+	p.tag(field, wireVarint)
+	p.buf = binary.AppendUvarint(p.buf, uint64(value))
+}
+
+// Double adds a double field
+func (p *Proto) Double(field int, value float64) {
+	if value == 0 {
+		return
+	}
+	p.tag(field, wireFixed64)
+	p.buf = binary.LittleEndian.AppendUint64(p.buf, math.Float64bits(value))
+}
+
+// Message adds an embedded message field
+// It is written even if empty, so the receiver sees that it is set
+func (p *Proto) Message(field int, message *Proto) {
+	p.tag(field, wireBytes)
+	p.buf = binary.AppendUvarint(p.buf, uint64(len(message.buf)))
+	p.buf = append(p.buf, message.buf...)
+}
+
+// Timestamp adds a google.protobuf.Timestamp field, left out for the zero time
+func (p *Proto) Timestamp(field int, value time.Time) {
+	if value.IsZero() {
+		return
+	}
+	var timestamp Proto
+	timestamp.Int64(1, value.Unix())
+	timestamp.Int64(2, int64(value.Nanosecond()))
+	p.Message(field, &timestamp)
+}
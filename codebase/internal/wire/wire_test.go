@@ -0,0 +1,82 @@
+package wire
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProto_Fields(t *testing.T) {
+	var message Proto
+	message.String(1, "hi")
+	message.Double(2, 1.5)
+	message.Bool(6, true)
+	message.Int64(8, 150)
+	message.String(3, "") // zero values are left out
+	message.Bool(9, false)
+	message.Int64(11, 0)
+
+	expected := []byte{
+		0x0a, 0x02, 'h', 'i',
+		0x11, 0, 0, 0, 0, 0, 0, 0xf8, 0x3f,
+		0x30, 0x01,
+		0x40, 0x96, 0x01,
+	}
+	if !bytes.Equal(message.Bytes(), expected) {
+		t.Errorf("Expected % x, got % x", expected, message.Bytes())
+	}
+}
+
+func TestProto_EmbeddedMessages(t *testing.T) {
+	var empty, message Proto
+	message.Message(4, &empty)
+	message.Timestamp(7, time.Unix(1, 5))
+	message.Timestamp(8, time.Time{})
+
+	expected := []byte{0x22, 0x00, 0x3a, 0x04, 0x08, 0x01, 0x10, 0x05}
+	if !bytes.Equal(message.Bytes(), expected) {
+		t.Errorf("Expected % x, got % x", expected, message.Bytes())
+	}
+}
+
+func TestMsgPack_Values(t *testing.T) {
+	var doc MsgPack
+	doc.Map(2)
+	doc.String("a")
+	doc.Int(1)
+	doc.String("b")
+	doc.Array(3)
+	doc.Int(-1)
+	doc.Bool(true)
+	doc.Nil()
+
+	expected := []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x93, 0xff, 0xc3, 0xc0}
+	if !bytes.Equal(doc.Bytes(), expected) {
+		t.Errorf("Expected % x, got % x", expected, doc.Bytes())
+	}
+}
+
+func TestMsgPack_WideValues(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		encode   func(*MsgPack)
+		expected []byte
+	}{
+		{"int64", func(m *MsgPack) { m.Int(200) }, []byte{0xd3, 0, 0, 0, 0, 0, 0, 0, 0xc8}},
+		{"negative int64", func(m *MsgPack) { m.Int(-33) }, []byte{0xd3, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xdf}},
+		{"float", func(m *MsgPack) { m.Float(1.5) }, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"str8", func(m *MsgPack) { m.String(strings.Repeat("x", 40)) }, append([]byte{0xd9, 40}, strings.Repeat("x", 40)...)},
+		{"array16", func(m *MsgPack) { m.Array(16) }, []byte{0xdc, 0, 16}},
+		{"map16", func(m *MsgPack) { m.Map(300) }, []byte{0xde, 0x01, 0x2c}},
+		{"timestamp", func(m *MsgPack) { m.Timestamp(time.Unix(1, 5)) }, []byte{0xc7, 12, 0xff, 0, 0, 0, 5, 0, 0, 0, 0, 0, 0, 0, 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var doc MsgPack
+			tc.encode(&doc)
+			if !bytes.Equal(doc.Bytes(), tc.expected) {
+				t.Errorf("Expected % x, got % x", tc.expected, doc.Bytes())
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package workpool
+
+import (
+	"context"
+	"sync"
+)
+
+// Pool caps how many fan-out tasks run at once across every caller sharing it
+// Each fan-out (cache warm-up, prefetch, batch lookups) also has its own per-call limit, but the
+// pool is what keeps several large fan-outs together from exhausting upstream sockets.
+type Pool struct {
+	slots chan struct{} // nil means no global limit
+}
+
+// New creates a Pool running at most size tasks at once; size <= 0 means no global limit
+func New(size int) *Pool {
+	if size <= 0 {
+		return &Pool{}
+	}
+	return &Pool{slots: make(chan struct{}, size)}
+}
+
+// Run calls fn for every index in [0, n), with at most limit calls of this Run in flight and never
+// more than the pool size across all callers; limit <= 0 means only the pool size applies
+// Once ctx is canceled no new calls start and Run returns ctx.Err() after in-flight calls finish
+func (p *Pool) Run(ctx context.Context, n, limit int, fn func(ctx context.Context, i int)) error {
+	var local chan struct{}
+	if limit > 0 {
+		local = make(chan struct{}, limit)
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if err := acquire(ctx, local); err != nil {
+			return err
+		}
+		if err := acquire(ctx, p.slots); err != nil {
+			release(local)
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer release(local)
+			defer release(p.slots)
+			fn(ctx, i)
+		}()
+	}
+	return nil
+}
+
+// acquire takes a slot from sem unless ctx is canceled first; a nil sem never blocks
+func acquire(ctx context.Context, sem chan struct{}) error {
+	if sem == nil {
+		return ctx.Err()
+	}
+	select {
+	case sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release returns a slot taken by acquire
+func release(sem chan struct{}) {
+	if sem != nil {
+		<-sem
+	}
+}
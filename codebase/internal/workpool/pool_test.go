@@ -0,0 +1,85 @@
+package workpool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// trackConcurrency returns a task that records the peak number of concurrent calls
+func trackConcurrency(peak *atomic.Int32) func(context.Context, int) {
+	var running atomic.Int32
+	return func(context.Context, int) {
+		now := running.Add(1)
+		for {
+			old := peak.Load()
+			if now <= old || peak.CompareAndSwap(old, now) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+	}
+}
+
+func TestPool_RunsEveryTask(t *testing.T) {
+	pool := New(4)
+	var calls atomic.Int32
+
+	err := pool.Run(context.Background(), 20, 0, func(context.Context, int) { calls.Add(1) })
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if calls.Load() != 20 {
+		t.Errorf("Expected 20 calls, got %d", calls.Load())
+	}
+}
+
+func TestPool_PerCallLimit(t *testing.T) {
+	pool := New(10)
+	var peak atomic.Int32
+
+	pool.Run(context.Background(), 12, 2, trackConcurrency(&peak))
+	if peak.Load() > 2 {
+		t.Errorf("Expected at most 2 concurrent calls, got %d", peak.Load())
+	}
+}
+
+func TestPool_GlobalLimitAcrossCallers(t *testing.T) {
+	pool := New(3)
+	var peak atomic.Int32
+	task := trackConcurrency(&peak)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.Run(context.Background(), 6, 3, task)
+		}()
+	}
+	wg.Wait()
+
+	if peak.Load() > 3 {
+		t.Errorf("Expected at most 3 concurrent calls across callers, got %d", peak.Load())
+	}
+}
+
+func TestPool_StopsOnCancel(t *testing.T) {
+	pool := New(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+
+	err := pool.Run(ctx, 10, 0, func(context.Context, int) {
+		calls.Add(1)
+		cancel()
+	})
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 call before cancellation, got %d", calls.Load())
+	}
+}
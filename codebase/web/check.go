@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/cache"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"github.com/krizvi/weather-app-server/internal/tlscert"
+	"github.com/krizvi/weather-app-server/internal/webhook"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// checkOnly is set by --check: validate the configuration and exit instead of serving
+var checkOnly bool
+
+// secretFields are the Config fields printed redacted by --check
+var secretFields = []string{"OpenWeatherAPIKey", "HedgeAPIKey", "RedisPassword", "SentryDSN", "AdminToken", "OTLPHeaders", "HeartbeatHeaders", "ClientProxyURL"}
+
+// runCheck reports on the configuration loaded by loadServerConfig (config and loadErr) for --check, for CI and
+// pre-deploy verification: the effective settings with secrets redacted, every problem, and the result of the
+// preflight if it is enabled
+// It returns the exit code: 0 if the configuration is usable, 1 if not
+func runCheck(w io.Writer, config *Config, loadErr error) int {
+	if loadErr != nil {
+		fmt.Fprintln(w, "configuration invalid:")
+		var configErr *ConfigError
+		if errors.As(loadErr, &configErr) {
+			for _, problem := range configErr.Problems {
+				fmt.Fprintf(w, "  - %v\n", problem)
+			}
+		} else {
+			fmt.Fprintf(w, "  - %v\n", loadErr)
+		}
+		return 1
+	}
+
+	fmt.Fprintln(w, "effective configuration:")
+	printConfig(w, config)
+
+	problems := checkFiles(config)
+	if config.Preflight {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.ClientTimeoutSec)*time.Second)
+		defer cancel()
+		// Through the proxy and with the TLS settings of the server, which may be the only way out
+		var proxyURL *url.URL
+		if config.ClientProxyURL != "" {
+			proxyURL, _ = url.Parse(config.ClientProxyURL) // validated when loaded
+		}
+		tlsConfig, _ := service.NewClientTLSConfig(config.ClientCAFile, config.ClientCertFile, config.ClientKeyFile, config.ClientTLSMinVersion) // reported by checkFiles
+		var transport http.RoundTripper = service.NewTransport(service.TransportConfig{ProxyURL: proxyURL, NoProxy: config.ClientNoProxy, TLSConfig: tlsConfig})
+		if config.MockProvider {
+			transport = service.NewMockTransport()
+		}
+		upstream := service.New(config.OpenWeatherAPIKey, config.OpenWeatherBaseURL, config.UpstreamTimeoutSec, transport, slog.Default())
+		var c cache.Cache
+		if config.CacheTTLSec > 0 {
+			var err error
+			if c, err = newCache(config); err != nil {
+				problems = append(problems, fmt.Errorf("cache (%s): %w", config.CacheBackend, err))
+			}
+		}
+		problems = append(problems, preflight(ctx, config, upstream, c)...)
+	}
+
+	if len(problems) > 0 {
+		// Errors may quote a secret, e.g. the API key in a failed request URL
+		var secrets []string
+		for _, secret := range []string{config.OpenWeatherAPIKey, config.HedgeAPIKey, config.RedisPassword, config.AdminToken, config.ClientProxyURL} {
+			if secret != "" {
+				secrets = append(secrets, secret, "REDACTED")
+			}
+		}
+		redactor := strings.NewReplacer(secrets...)
+		fmt.Fprintln(w, "configuration invalid:")
+		for _, problem := range problems {
+			fmt.Fprintf(w, "  - %s\n", redactor.Replace(problem.Error()))
+		}
+		return 1
+	}
+	if config.Preflight {
+		fmt.Fprintln(w, "configuration OK, preflight passed")
+	} else {
+		fmt.Fprintln(w, "configuration OK (enable --preflight to also check the provider and the cache)")
+	}
+	return 0
+}
+
+// checkFiles loads the files the configuration refers to, which loading the configuration doesn't read
+func checkFiles(config *Config) []error {
+	var problems []error
+	if config.TLSCertFile != "" {
+		if _, err := tlscert.New(config.TLSCertFile, config.TLSKeyFile, config.TLSCheckIntervalSec, slog.Default()); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if _, err := service.NewClientTLSConfig(config.ClientCAFile, config.ClientCertFile, config.ClientKeyFile, config.ClientTLSMinVersion); err != nil {
+		problems = append(problems, fmt.Errorf("upstream TLS: %w", err))
+	}
+	if _, err := loadWarmLocations(config); err != nil {
+		problems = append(problems, fmt.Errorf("cache warm-up locations: %w", err))
+	}
+	if config.WebhooksEnabled {
+		if _, err := webhook.NewStore(config.WebhookStorePath, config.WebhookMaxSubscriptions, config.WebhookMaxPerClient); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return problems
+}
+
+// printConfig writes every Config field as "Name value", with secrets redacted
+func printConfig(w io.Writer, config *Config) {
+	v := reflect.ValueOf(*config)
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		value := v.Field(i).Interface()
+		if slices.Contains(secretFields, name) {
+			value = redact(value)
+		}
+		fmt.Fprintf(w, "  %-24s %v\n", name, value)
+	}
+}
+
+// redact hides a secret string, or the values of name=value pairs, keeping whether it is set
+func redact(value any) any {
+	switch value := value.(type) {
+	case string:
+		if value == "" {
+			return ""
+		}
+		return "REDACTED"
+	case []string:
+		redacted := make([]string, len(value))
+		for i, pair := range value {
+			name, _, _ := strings.Cut(pair, "=")
+			redacted[i] = name + "=REDACTED"
+		}
+		return redacted
+	}
+	return value
+}
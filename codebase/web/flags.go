@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/krizvi/weather-app-server/internal/buildinfo"
+	"github.com/krizvi/weather-app-server/internal/utils"
+	"maps"
+	"os"
+)
+
+// configFlags are the settings that can also be given on the command line, with the variable each overrides
+var configFlags = []struct {
+	name   string
+	env    string
+	isBool bool
+	usage  string
+}{
+	{"port", "APP_SERVER_PORT", false, "Port to listen on"},
+	{"bind-host", "APP_SERVER_BIND_HOST", false, "Address to bind the port on, e.g. 127.0.0.1 (all interfaces if empty)"},
+	{"listen", "APP_SERVER_LISTEN", false, "unix:/path to serve on a unix socket instead of the port"},
+	{"base-url", "OPENWEATHER_BASE_URL", false, "OpenWeatherMap API base URL"},
+	{"profile", "APP_SERVER_PROFILE", false, "Deployment profile: development, staging or production change defaults (production if unset, with the built-in defaults); reported by /version"},
+	{"log-level", "APP_SERVER_LOG_LEVEL", false, "Lowest level logged: debug, info, warn or error"},
+	{"log-format", "APP_SERVER_LOG_FORMAT", false, "Log record format: text or json"},
+	{"cache-backend", "APP_SERVER_CACHE_BACKEND", false, "Cache backend: memory, disk or memcached"},
+	{"metrics", "APP_SERVER_METRICS_ENABLED", true, "Serve Prometheus metrics on /metrics"},
+	{"preflight", "APP_SERVER_PREFLIGHT", true, "Check config, cache and provider before accepting traffic"},
+	{"maintenance", "APP_SERVER_MAINTENANCE_MODE", true, "Start in maintenance mode"},
+}
+
+// errVersionShown is returned by parseFlags after printing the version for --version
+var errVersionShown = errors.New("version shown")
+
+// configFile and envFile are the files given with --config and --env-file, re-read by reloadConfigFiles
+var configFile, envFile string
+
+// envFlag holds a flag value as the string its variable would have
+type envFlag struct {
+	value  string
+	isBool bool
+}
+
+func (f *envFlag) String() string     { return f.value }
+func (f *envFlag) Set(s string) error { f.value = s; return nil }
+func (f *envFlag) IsBoolFlag() bool   { return f.isBool }
+
+// parseFlags reads the command line and layers the settings sources, highest precedence first:
+// command-line flags, environment variables, the .env file, the config file (--config) and the built-in defaults
+// It returns flag.ErrHelp after printing the usage for -h/--help
+func parseFlags(args []string) error {
+	fs := flag.NewFlagSet("weather-api-server", flag.ContinueOnError)
+	configPath := fs.String("config", utils.GetEnvAsStrWithDefault("APP_SERVER_CONFIG_FILE", ""), "")
+	envPath := fs.String("env-file", utils.GetEnvAsStrWithDefault("APP_SERVER_ENV_FILE", ""), "")
+	fs.BoolVar(&checkOnly, "check", false, "")
+	showVersion := fs.Bool("version", false, "")
+	envByFlag := make(map[string]string, len(configFlags))
+	for _, cf := range configFlags {
+		fs.Var(&envFlag{isBool: cf.isBool}, cf.name, cf.usage)
+		envByFlag[cf.name] = cf.env
+	}
+	fs.Usage = func() { printUsage(fs) }
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	if *showVersion {
+		build := buildinfo.Get()
+		fmt.Printf("%s %s (commit %s, built %s, %s)\n", fs.Name(), build.Version, build.Commit, build.BuildDate, build.GoVersion)
+		return errVersionShown
+	}
+
+	overrides := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		if env, ok := envByFlag[f.Name]; ok {
+			overrides[env] = f.Value.String()
+		}
+	})
+	utils.SetOverrides(overrides)
+
+	configFile, envFile = *configPath, *envPath
+	return reloadConfigFiles()
+}
+
+// reloadConfigFiles (re-)reads the config file and the .env file, if any, so the next loadServerConfig
+// sees their current content
+// The .env file stands in for exported variables, so it wins over the config file. Unless a path is
+// given it is only looked for (as ./.env) in the development and staging profiles; in production it is likely a leftover
+func reloadConfigFiles() error {
+	values := make(map[string]string)
+	if configFile != "" {
+		fileValues, err := utils.ReadEnvFile(configFile)
+		if err != nil {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+		maps.Copy(values, fileValues)
+	}
+	utils.SetFallbacks(values) // the profile may come from the config file
+
+	path := envFile
+	if path == "" && isDevelopmentProfile(readProfile()) {
+		path = ".env"
+	}
+	if path != "" {
+		envValues, err := utils.ReadEnvFile(path)
+		switch {
+		case err == nil:
+			maps.Copy(values, envValues)
+		case errors.Is(err, os.ErrNotExist) && envFile == "":
+			// No .env file is fine unless one was asked for
+		default:
+			return fmt.Errorf("failed to read .env file: %w", err)
+		}
+	}
+	utils.SetFallbacks(values)
+	return nil
+}
+
+// printUsage lists the flags with the variables they override, then every variable loadServerConfig reads,
+// with its default
+func printUsage(fs *flag.FlagSet) {
+	// Reading the configuration records the default of every variable, including derived ones
+	loadServerConfig()
+
+	out := fs.Output()
+	fmt.Fprintf(out, "Usage: %s [flags]\n\n", fs.Name())
+	fmt.Fprintln(out, "Settings are taken from, highest precedence first: flags, environment variables,")
+	fmt.Fprintln(out, "the .env file, the config file and built-in defaults. Every setting has an")
+	fmt.Fprintln(out, "environment variable (listed below); the flags cover the most common ones.")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "  --config path")
+	fmt.Fprintln(out, "        File of NAME=VALUE lines with settings (APP_SERVER_CONFIG_FILE)")
+	fmt.Fprintln(out, "  --env-file path")
+	fmt.Fprintln(out, "        .env file whose NAME=VALUE lines act as environment variables (APP_SERVER_ENV_FILE,")
+	fmt.Fprintln(out, "        default ./.env in the development and staging profiles)")
+	fmt.Fprintln(out, "  --version")
+	fmt.Fprintln(out, "        Print the version, commit and build date and exit")
+	fmt.Fprintln(out, "  --check")
+	fmt.Fprintln(out, "        Validate the configuration, print it with secrets redacted and exit (non-zero on")
+	fmt.Fprintln(out, "        problems); with --preflight also check the provider and the cache")
+	for _, cf := range configFlags {
+		value := " value"
+		if cf.isBool {
+			value = ""
+		}
+		def, _ := utils.Default(cf.env)
+		fmt.Fprintf(out, "  --%s%s\n        %s (%s, default %q)\n", cf.name, value, cf.usage, cf.env, def)
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Environment variables (OPENWEATHER_API_KEY or APP_SERVER_API_KEY_FILE is required):")
+	for _, name := range utils.Variables() {
+		def, _ := utils.Default(name)
+		fmt.Fprintf(out, "  %s (default %q)\n", name, def)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"cmp"
+	"github.com/krizvi/weather-app-server/internal/service"
+	"strings"
+	"testing"
+)
+
+func TestReloadRuntimeConfig_KeepsRotatedKeys(t *testing.T) {
+	loaded := &Config{OpenWeatherAPIKey: "configured", HedgeAPIKey: "hedge"}
+	settings := service.NewRuntimeSettings(runtimeConfig(loaded))
+	// Rotated through POST /admin/upstream/api-key
+	settings.Update(func(current *service.RuntimeConfig) { current.Primary.APIKey = "rotated" })
+
+	next := &Config{OpenWeatherAPIKey: "configured", HedgeAPIKey: "new-hedge", ClientTimeoutSec: 7}
+	reloaded := reloadRuntimeConfig(*settings.Load(), loaded, next)
+	if reloaded.Primary.APIKey != "rotated" {
+		t.Errorf("Expected the rotated key to survive the reload, got %q", reloaded.Primary.APIKey)
+	}
+	if reloaded.Hedge.APIKey != "new-hedge" {
+		t.Errorf("Expected the changed configured hedge key, got %q", reloaded.Hedge.APIKey)
+	}
+	if reloaded.ClientTimeout.Seconds() != 7 {
+		t.Errorf("Expected the reloaded client timeout, got %v", reloaded.ClientTimeout)
+	}
+
+	changed := &Config{OpenWeatherAPIKey: "reconfigured", HedgeAPIKey: "new-hedge"}
+	if reloaded = reloadRuntimeConfig(reloaded, next, changed); reloaded.Primary.APIKey != "reconfigured" {
+		t.Errorf("Expected a changed configured key to replace the rotated one, got %q", reloaded.Primary.APIKey)
+	}
+}
+
+func TestLoadServerConfig_Profiles(t *testing.T) {
+	t.Setenv("OPENWEATHER_API_KEY", "key")
+	tests := []struct {
+		profile        string
+		wantProfile    string
+		logFormat      string
+		logLevel       string
+		strict         bool
+		pprof          bool
+		mockAllowed    bool
+		wantCacheTTL   int
+		wantCompressed bool
+	}{
+		{"", "production", "text", "info", false, false, false, 300, true},
+		{"development", "development", "text", "debug", false, true, true, 60, false},
+		{"staging", "staging", "json", "info", true, false, false, 300, true},
+		{"production", "production", "json", "info", true, false, false, 300, true},
+	}
+	for _, tt := range tests {
+		t.Run(cmp.Or(tt.profile, "unset"), func(t *testing.T) {
+			t.Setenv("APP_SERVER_PROFILE", tt.profile)
+			config, err := loadServerConfig()
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if config.Profile != tt.wantProfile {
+				t.Errorf("Expected profile %q, got %q", tt.wantProfile, config.Profile)
+			}
+			if config.LogFormat != tt.logFormat || config.LogLevel != tt.logLevel {
+				t.Errorf("Expected %s logs at %s, got %s at %s", tt.logFormat, tt.logLevel, config.LogFormat, config.LogLevel)
+			}
+			if config.StrictValidation != tt.strict {
+				t.Errorf("Expected strict validation %v, got %v", tt.strict, config.StrictValidation)
+			}
+			if config.PprofEnabled != tt.pprof || config.AllowMockProvider != tt.mockAllowed {
+				t.Errorf("Expected pprof %v and the mock provider allowed %v, got %v and %v", tt.pprof, tt.mockAllowed, config.PprofEnabled, config.AllowMockProvider)
+			}
+			if config.CacheTTLSec != tt.wantCacheTTL || config.CompressionEnabled != tt.wantCompressed {
+				t.Errorf("Expected cache TTL %d and compression %v, got %d and %v", tt.wantCacheTTL, tt.wantCompressed, config.CacheTTLSec, config.CompressionEnabled)
+			}
+		})
+	}
+}
+
+func TestLoadServerConfig_ExplicitSettingsBeatProfile(t *testing.T) {
+	t.Setenv("OPENWEATHER_API_KEY", "key")
+	t.Setenv("APP_SERVER_PROFILE", "production")
+	t.Setenv("APP_SERVER_LOG_FORMAT", "text")
+	t.Setenv("APP_SERVER_STRICT_VALIDATION", "false")
+
+	config, err := loadServerConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if config.LogFormat != "text" || config.StrictValidation {
+		t.Errorf("Expected the explicit settings to win, got %s logs and strict validation %v", config.LogFormat, config.StrictValidation)
+	}
+}
+
+func TestLoadServerConfig_RejectsUnknownProfile(t *testing.T) {
+	t.Setenv("OPENWEATHER_API_KEY", "key")
+	for _, profile := range []string{"prod", "dev"} {
+		t.Setenv("APP_SERVER_PROFILE", profile)
+		if _, err := loadServerConfig(); err == nil || !strings.Contains(err.Error(), "APP_SERVER_PROFILE") {
+			t.Errorf("Expected profile %q to be rejected, got %v", profile, err)
+		}
+		if isDevelopmentProfile(profile) {
+			t.Errorf("Expected no .env file to be read in profile %q", profile)
+		}
+	}
+}
+
+func TestLoadServerConfig_MockProvider(t *testing.T) {
+	t.Setenv("APP_SERVER_MOCK_PROVIDER", "true")
+
+	t.Setenv("APP_SERVER_PROFILE", "development")
+	if _, err := loadServerConfig(); err != nil {
+		t.Errorf("Expected the mock provider to need no API key in development, got %v", err)
+	}
+	for _, profile := range []string{"staging", "production"} {
+		t.Setenv("APP_SERVER_PROFILE", profile)
+		if _, err := loadServerConfig(); err == nil || !strings.Contains(err.Error(), "APP_SERVER_MOCK_PROVIDER") {
+			t.Errorf("Expected the mock provider to be refused in %s, got %v", profile, err)
+		}
+	}
+}
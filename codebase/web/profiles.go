@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/krizvi/weather-app-server/internal/utils"
+)
+
+// defaultProfile is the profile of deployments that don't name one. They are held to its rules, e.g. no
+// chaos, but keep the built-in defaults rather than its profileDefaults, so deployments from before
+// profiles keep their behavior
+const defaultProfile = "production"
+
+// profileDefaults are the defaults each deployment profile changes, so environments don't have to repeat long
+// blocks of settings; anything set explicitly still wins
+// Profiles missing from it are configuration errors
+var profileDefaults = map[string]map[string]string{
+	"development": {
+		"APP_SERVER_LOG_LEVEL":           "debug",
+		"APP_SERVER_CACHE_TTL_SEC":       "60",
+		"APP_SERVER_COMPRESSION_ENABLED": "false",
+		"APP_SERVER_PPROF_ENABLED":       "true",
+		"APP_SERVER_ALLOW_MOCK_PROVIDER": "true",
+	},
+	"staging": {
+		"APP_SERVER_LOG_FORMAT":        "json",
+		"APP_SERVER_PREFLIGHT":         "true",
+		"APP_SERVER_STRICT_VALIDATION": "true",
+	},
+	"production": {
+		"APP_SERVER_LOG_FORMAT":        "json",
+		"APP_SERVER_STRICT_VALIDATION": "true",
+	},
+}
+
+// readProfile reads the deployment profile, APP_SERVER_PROFILE or else APP_ENV, and applies its defaults to
+// the settings read after it
+func readProfile() string {
+	profile := utils.GetEnvAsStrWithDefault("APP_SERVER_PROFILE", utils.GetEnvAsStrWithDefault("APP_ENV", ""))
+	utils.SetProfileDefaults(profileDefaults[profile])
+	if profile == "" {
+		return defaultProfile
+	}
+	return profile
+}
+
+// isDevelopmentProfile reports whether profile is a known profile other than production, where leftovers
+// of local development like a .env file are expected
+func isDevelopmentProfile(profile string) bool {
+	_, known := profileDefaults[profile]
+	return known && profile != "production"
+}